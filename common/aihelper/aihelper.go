@@ -1,14 +1,17 @@
 package aihelper
 
 import (
-	"GopherAI/common/rabbitmq"
+	"GopherAI/common/messagestore"
 	"GopherAI/model"
 	"GopherAI/utils"
 	"context"
+	"fmt"
+	"log"
 	"sync"
 	"time"
 
 	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
 )
 
 // AIHelper AI助手结构体，包含消息历史和AI模型
@@ -28,12 +31,8 @@ func NewAIHelper(model_ AIModel, SessionID string, title string, UpdateAt time.T
 	return &AIHelper{
 		model:    model_,
 		messages: make([]*model.Message, 0),
-		//异步推送到消息队列中
-		saveFunc: func(msg *model.Message) (*model.Message, error) {
-			data := rabbitmq.GenerateMessageMQParam(msg.SessionID, msg.Content, msg.UserName, msg.IsUser)
-			err := rabbitmq.RMQMessage.Publish(data)
-			return msg, err
-		},
+		//按配置选择的存储后端保存消息
+		saveFunc:  messagestore.Save,
 		SessionID: SessionID,
 		Title:     title,
 		UpdateAt:  UpdateAt,
@@ -41,17 +40,108 @@ func NewAIHelper(model_ AIModel, SessionID string, title string, UpdateAt time.T
 }
 
 // addMessage 添加消息到内存中并调用自定义存储函数
-func (a *AIHelper) AddMessage(Content string, UserName string, IsUser bool, Save bool) {
-	userMsg := model.Message{
+// 存储失败时消息不会进入内存历史，保证内存与数据库一致
+func (a *AIHelper) AddMessage(Content string, UserName string, IsUser bool, Save bool) error {
+	role := model.MessageRoleAssistant
+	if IsUser {
+		role = model.MessageRoleUser
+	}
+	return a.appendMessage(&model.Message{
 		SessionID: a.SessionID,
 		Content:   Content,
 		UserName:  UserName,
 		IsUser:    IsUser,
+		Role:      role,
+	}, Save)
+}
+
+// addUserMessage 保存用户问题及附带的图片
+func (a *AIHelper) addUserMessage(content string, userName string, images []model.MessageImage) error {
+	return a.appendMessage(&model.Message{
+		SessionID: a.SessionID,
+		Content:   content,
+		UserName:  userName,
+		IsUser:    true,
+		Role:      model.MessageRoleUser,
+		Images:    images,
+	}, true)
+}
+
+// AddSchemaMessage 添加 agent 运行中产生的工具调用、工具结果等消息
+func (a *AIHelper) AddSchemaMessage(msg *schema.Message, UserName string, Save bool) error {
+	return a.appendMessage(utils.ConvertToModelMessage(a.SessionID, UserName, msg), Save)
+}
+
+// addAssistantMessage 保存 AI 回答及其引用的资料
+func (a *AIHelper) addAssistantMessage(content string, userName string, citations []model.MessageCitation) (*model.Message, error) {
+	msg := &model.Message{
+		SessionID: a.SessionID,
+		Content:   content,
+		UserName:  userName,
+		IsUser:    false,
+		Role:      model.MessageRoleAssistant,
+		Citations: citations,
+	}
+	if err := a.appendMessage(msg, true); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// turnCitations 当前轮次（最后一条用户消息之后）检索工具已经编号的资料
+// 从工具确认中恢复时沿用这些编号，保证同一轮的引用编号连续
+func (a *AIHelper) turnCitations() []model.MessageCitation {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	start := 0
+	for i := len(a.messages) - 1; i >= 0; i-- {
+		if a.messages[i].GetRole() == model.MessageRoleUser {
+			start = i + 1
+			break
+		}
 	}
-	a.messages = append(a.messages, &userMsg)
+	var citations []model.MessageCitation
+	for _, msg := range a.messages[start:] {
+		if _, ok := citationTools[msg.ToolName]; ok && msg.GetRole() == model.MessageRoleTool {
+			citations = append(citations, parseCitationToolOutput(msg.Content)...)
+		}
+	}
+	return citations
+}
+
+// RestoreMessage 加载数据库中的历史消息（不开启存储功能）
+func (a *AIHelper) RestoreMessage(msg *model.Message) {
+	restored := *msg
+	restored.SessionID = a.SessionID
+	_ = a.appendMessage(&restored, false)
+}
+
+func (a *AIHelper) appendMessage(msg *model.Message, Save bool) error {
 	if Save {
-		a.saveFunc(&userMsg)
+		// 幂等键和创建时间在入队前确定，重试或重复投递都不会改变消息顺序
+		if msg.MessageKey == "" {
+			msg.MessageKey = uuid.New().String()
+		}
+		if msg.CreatedAt.IsZero() {
+			msg.CreatedAt = time.Now()
+		}
+		if _, err := a.saveFunc(msg); err != nil {
+			return fmt.Errorf("save message failed: %w", err)
+		}
 	}
+	a.mu.Lock()
+	a.messages = append(a.messages, msg)
+	a.mu.Unlock()
+	return nil
+}
+
+// 把 agent 中间过程的消息也写入历史，便于回放完整的调用过程
+func (a *AIHelper) traceOption(userName string) ToolOption {
+	return WithMessageTrace(func(msg *schema.Message) {
+		if err := a.AddSchemaMessage(msg, userName, true); err != nil {
+			log.Printf("session %s save agent trace message failed: %v", a.SessionID, err)
+		}
+	})
 }
 
 // SaveMessage 保存消息到数据库（通过回调函数避免循环依赖）
@@ -60,6 +150,13 @@ func (a *AIHelper) SetSaveFunc(saveFunc func(*model.Message) (*model.Message, er
 	a.saveFunc = saveFunc
 }
 
+// SetMessageStore 为当前会话指定消息存储后端
+func (a *AIHelper) SetMessageStore(store messagestore.MessageStore) {
+	a.saveFunc = func(msg *model.Message) (*model.Message, error) {
+		return msg, store.Save(msg)
+	}
+}
+
 // GetMessages 获取所有消息历史
 func (a *AIHelper) GetMessages() []*model.Message {
 	a.mu.RLock()
@@ -69,41 +166,54 @@ func (a *AIHelper) GetMessages() []*model.Message {
 	return out
 }
 
+// SupportsVision 当前会话的模型是否可以接收图片
+func (a *AIHelper) SupportsVision() bool {
+	vision, ok := a.model.(VisionModel)
+	return ok && vision.SupportsVision()
+}
+
+// schemaMessages 将历史消息转化成 schema.Message，模型不支持图片时图片替换为文字说明
+func (a *AIHelper) schemaMessages() []*schema.Message {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return utils.ConvertToSchemaMessages(a.messages, a.SupportsVision())
+}
+
 // 同步生成
-func (a *AIHelper) GenerateResponse(userName string, ctx context.Context, userQuestion string, usingGoogle bool, usingRAG bool) (*model.Message, error) {
+// opts 决定本次回复可以使用哪些工具，images 为用户问题附带的图片
+func (a *AIHelper) GenerateResponse(userName string, ctx context.Context, userQuestion string, images []model.MessageImage, opts ...ToolOption) (*model.Message, error) {
 
 	//调用存储函数
-	a.AddMessage(userQuestion, userName, true, true)
+	if err := a.addUserMessage(userQuestion, userName, images); err != nil {
+		return nil, err
+	}
 
-	a.mu.RLock()
 	//将model.Message转化成schema.Message
-	messages := utils.ConvertToSchemaMessages(a.messages)
-	a.mu.RUnlock()
+	messages := a.schemaMessages()
 
 	//调用模型生成回复
-	var schemaMsg *schema.Message
-	var err error
-	if usingGoogle {
-		schemaMsg, err = a.model.GenerateResponse(ctx, messages, WithGoogleTool())
-	} else if usingRAG {
-		schemaMsg, err = a.model.GenerateResponse(ctx, messages, WithRAGTool())
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		schemaMsg, err = a.model.GenerateResponse(ctx, messages)
-		if err != nil {
-			return nil, err
-		}
+	citations := NewCitationCollector()
+	opts = append(opts, a.traceOption(userName), WithCitations(citations))
+	schemaMsg, err := a.model.GenerateResponse(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
 	}
 
-	//将schema.Message转化成model.Message
-	modelMsg := utils.ConvertToModelMessage(a.SessionID, userName, schemaMsg)
+	//调用存储函数，引用列表与回答一起保存
+	return a.addAssistantMessage(schemaMsg.Content, userName, citations.Resolve(schemaMsg.Content))
+}
 
-	//调用存储函数
-	a.AddMessage(modelMsg.Content, userName, false, true)
+// ResumeResponse 用户处理完待确认的工具调用后继续生成回复
+func (a *AIHelper) ResumeResponse(userName string, ctx context.Context, checkPointID string, interruptIDs []string, result *ToolApprovalResult, opts ...ToolOption) (*model.Message, error) {
+	citations := NewCitationCollector(a.turnCitations()...)
+	opts = append(opts, a.traceOption(userName), WithCitations(citations))
+	schemaMsg, err := a.model.ResumeResponse(ctx, checkPointID, interruptIDs, result, opts...)
+	if err != nil {
+		return nil, err
+	}
 
-	return modelMsg, nil
+	//调用存储函数，引用列表与回答一起保存
+	return a.addAssistantMessage(schemaMsg.Content, userName, citations.Resolve(schemaMsg.Content))
 }
 
 // 生成旅游规划
@@ -138,32 +248,26 @@ func (a *AIHelper) GenerateTravelPlanResponseWithProgress(ctx context.Context, d
 }
 
 // 流式生成
+// opts 与 GenerateResponse 相同，需要确认的工具调用会返回 *ToolApprovalPendingError
 // FIXME: 目前流式回调函数存在问题，还是都生成了再统一回复，同时到前端渲染也有问题
-func (a *AIHelper) StreamResponse(userName string, ctx context.Context, cb StreamCallback, userQuestion string) (*model.Message, error) {
+func (a *AIHelper) StreamResponse(userName string, ctx context.Context, cb StreamCallback, userQuestion string, images []model.MessageImage, opts ...ToolOption) (*model.Message, error) {
 
 	//调用存储函数
-	a.AddMessage(userQuestion, userName, true, true)
+	if err := a.addUserMessage(userQuestion, userName, images); err != nil {
+		return nil, err
+	}
 
-	a.mu.RLock()
-	messages := utils.ConvertToSchemaMessages(a.messages)
-	a.mu.RUnlock()
+	messages := a.schemaMessages()
 
-	content, err := a.model.StreamResponse(ctx, messages, cb)
+	citations := NewCitationCollector()
+	opts = append(opts, a.traceOption(userName), WithCitations(citations))
+	content, err := a.model.StreamResponse(ctx, messages, cb, opts...)
 	if err != nil {
 		return nil, err
 	}
-	//转化成model.Message
-	modelMsg := &model.Message{
-		SessionID: a.SessionID,
-		UserName:  userName,
-		Content:   content,
-		IsUser:    false,
-	}
-
-	//调用存储函数
-	a.AddMessage(modelMsg.Content, userName, false, true)
 
-	return modelMsg, nil
+	//调用存储函数，引用列表与回答一起保存
+	return a.addAssistantMessage(content, userName, citations.Resolve(content))
 }
 
 // GetModelType 获取模型类型
@@ -0,0 +1,130 @@
+package aihelper
+
+import (
+	myredis "GopherAI/common/redis"
+	"GopherAI/model"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ToolApprovalInfo 工具中断时暴露给调用方的信息
+type ToolApprovalInfo struct {
+	ToolName  string
+	Arguments string
+}
+
+// ToolApprovalResult 用户对工具调用的确认结果，作为恢复数据传给被中断的工具
+type ToolApprovalResult struct {
+	Approved bool
+	Reason   string
+}
+
+// ToolApprovalPendingError Agent 因等待用户确认而暂停
+// CheckPointID 对应保存在 Redis 中的检查点，Tools 为本次交给 Agent 的工具
+type ToolApprovalPendingError struct {
+	CheckPointID string
+	Tools        []string
+	ToolCalls    []model.PendingToolCall
+}
+
+func (e *ToolApprovalPendingError) Error() string {
+	names := make([]string, 0, len(e.ToolCalls))
+	for _, call := range e.ToolCalls {
+		names = append(names, call.ToolName)
+	}
+	return fmt.Sprintf("tool call waiting for approval: %s", strings.Join(names, ","))
+}
+
+func init() {
+	schema.RegisterName[*ToolApprovalInfo]("_gopherai_tool_approval_info")
+}
+
+// approvalTool 需要用户确认的工具包装
+// 首次调用时中断并保存参数，用户确认后才真正执行
+type approvalTool struct {
+	tool.InvokableTool
+}
+
+func (t *approvalTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	info, err := t.Info(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	wasInterrupted, _, storedArgs := compose.GetInterruptState[string](ctx)
+	if !wasInterrupted {
+		return "", compose.StatefulInterrupt(ctx, &ToolApprovalInfo{
+			ToolName:  info.Name,
+			Arguments: argumentsInJSON,
+		}, argumentsInJSON)
+	}
+
+	isResumeTarget, hasData, result := compose.GetResumeContext[*ToolApprovalResult](ctx)
+	if !isResumeTarget {
+		// 本次恢复的不是当前工具，继续保持中断
+		return "", compose.StatefulInterrupt(ctx, &ToolApprovalInfo{
+			ToolName:  info.Name,
+			Arguments: storedArgs,
+		}, storedArgs)
+	}
+	if !hasData || result == nil || !result.Approved {
+		reason := ""
+		if result != nil {
+			reason = result.Reason
+		}
+		log.Printf("tool call %s denied by user, reason: %s", info.Name, reason)
+		return fmt.Sprintf("用户拒绝了工具 %s 的调用，请不要再次调用该工具，直接基于已有信息回答。拒绝原因：%s", info.Name, reason), nil
+	}
+
+	return t.InvokableTool.InvokableRun(ctx, storedArgs, opts...)
+}
+
+// 需要确认的工具必须是可直接调用的工具
+func wrapApprovalTool(t tool.BaseTool) (tool.BaseTool, error) {
+	invokable, ok := t.(tool.InvokableTool)
+	if !ok {
+		return nil, fmt.Errorf("tool requiring approval must be invokable")
+	}
+	return &approvalTool{InvokableTool: invokable}, nil
+}
+
+// redisCheckPointStore 将 Agent 检查点保存在 Redis 中，服务重启后仍可恢复
+type redisCheckPointStore struct{}
+
+func (s *redisCheckPointStore) Get(_ context.Context, checkPointID string) ([]byte, bool, error) {
+	return myredis.GetCheckPoint(checkPointID)
+}
+
+func (s *redisCheckPointStore) Set(_ context.Context, checkPointID string, checkPoint []byte) error {
+	return myredis.SetCheckPoint(checkPointID, checkPoint)
+}
+
+// 从中断事件中取出等待确认的工具调用
+func collectPendingToolCalls(info *adk.InterruptInfo) []model.PendingToolCall {
+	calls := make([]model.PendingToolCall, 0)
+	if info == nil {
+		return calls
+	}
+	for _, ic := range info.InterruptContexts {
+		if ic == nil || !ic.IsRootCause {
+			continue
+		}
+		approvalInfo, ok := ic.Info.(*ToolApprovalInfo)
+		if !ok {
+			continue
+		}
+		calls = append(calls, model.PendingToolCall{
+			InterruptID: ic.ID,
+			ToolName:    approvalInfo.ToolName,
+			Arguments:   approvalInfo.Arguments,
+		})
+	}
+	return calls
+}
@@ -0,0 +1,137 @@
+package aihelper
+
+import (
+	"GopherAI/model"
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// 工具结果中提示模型如何标注引用
+const citationNote = "回答中使用这些资料时，在相应句子末尾用 [index] 标注来源，例如 [1]；不要编造不存在的编号"
+
+// 回答中的引用标记，例如 [1]、[2]
+var citationMarkerRe = regexp.MustCompile(`\[(\d+)\]`)
+
+// 返回结构化引用的工具
+var citationTools = map[string]string{
+	ToolRAGSearch:    model.CitationTypeRAG,
+	ToolGoogleSearch: model.CitationTypeWeb,
+}
+
+// CitationCollector 收集一轮对话中检索到的资料，并按出现顺序统一编号
+// 同一轮中多次调用检索工具时编号连续，保证回答中的 [index] 唯一
+type CitationCollector struct {
+	mu        sync.Mutex
+	citations []model.MessageCitation
+}
+
+// NewCitationCollector 创建收集器，existing 为本轮中断前已经编号的资料
+func NewCitationCollector(existing ...model.MessageCitation) *CitationCollector {
+	return &CitationCollector{citations: append([]model.MessageCitation(nil), existing...)}
+}
+
+func (c *CitationCollector) add(citationType string, items []model.MessageCitation) []model.MessageCitation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]model.MessageCitation, 0, len(items))
+	for _, item := range items {
+		item.Index = len(c.citations) + 1
+		if item.Type == "" {
+			item.Type = citationType
+		}
+		item.Cited = false
+		c.citations = append(c.citations, item)
+		out = append(out, item)
+	}
+	return out
+}
+
+// Resolve 根据回答中的引用标记返回引用列表，被引用的资料 Cited 为 true
+func (c *CitationCollector) Resolve(content string) []model.MessageCitation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.citations) == 0 {
+		return nil
+	}
+	cited := make(map[int]bool)
+	for _, m := range citationMarkerRe.FindAllStringSubmatch(content, -1) {
+		if index, err := strconv.Atoi(m[1]); err == nil {
+			cited[index] = true
+		}
+	}
+	out := make([]model.MessageCitation, len(c.citations))
+	for i, item := range c.citations {
+		item.Cited = cited[item.Index]
+		out[i] = item
+	}
+	return out
+}
+
+// WithCitations 为检索工具的结果编号，并记录到 collector
+func WithCitations(collector *CitationCollector) ToolOption {
+	return func(opts *ToolOptions) {
+		opts.citations = collector
+	}
+}
+
+// citationToolOutput 交给模型的检索结果，同时作为工具消息保存
+type citationToolOutput struct {
+	Note    string                  `json:"note"`
+	Results []model.MessageCitation `json:"results"`
+}
+
+// mcpStructuredResult MCP 工具返回结果中的结构化内容
+type mcpStructuredResult struct {
+	StructuredContent *struct {
+		Results []model.MessageCitation `json:"results"`
+	} `json:"structuredContent"`
+}
+
+// citationTool 把 MCP 检索工具的结构化结果编号后交给模型
+type citationTool struct {
+	tool.InvokableTool
+	citationType string
+	collector    *CitationCollector
+}
+
+func (t *citationTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	output, err := t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+	if err != nil {
+		return output, err
+	}
+	var result mcpStructuredResult
+	// 没有结构化内容时原样返回，不产生引用
+	if json.Unmarshal([]byte(output), &result) != nil || result.StructuredContent == nil || len(result.StructuredContent.Results) == 0 {
+		return output, nil
+	}
+	data, err := json.Marshal(citationToolOutput{
+		Note:    citationNote,
+		Results: t.collector.add(t.citationType, result.StructuredContent.Results),
+	})
+	if err != nil {
+		return output, nil
+	}
+	return string(data), nil
+}
+
+func wrapCitationTool(t tool.BaseTool, citationType string, collector *CitationCollector) tool.BaseTool {
+	invokable, ok := t.(tool.InvokableTool)
+	if !ok {
+		return t
+	}
+	return &citationTool{InvokableTool: invokable, citationType: citationType, collector: collector}
+}
+
+// parseCitationToolOutput 从保存的工具消息中还原已编号的资料
+func parseCitationToolOutput(content string) []model.MessageCitation {
+	var output citationToolOutput
+	if err := json.Unmarshal([]byte(content), &output); err != nil {
+		return nil
+	}
+	return output.Results
+}
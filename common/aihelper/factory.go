@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 	"time"
 )
@@ -48,7 +49,16 @@ func (f *AIModelFactory) registerCreators() {
 		if baseURL == "" || modelName == "" {
 			return nil, fmt.Errorf("Ollama model requires baseURL and modelName in config")
 		}
-		return NewOllamaModel(ctx, baseURL, modelName)
+		return NewOllamaModel(ctx, baseURL, modelName, myconfig.GetConfig().OllamaConfig.Vision)
+	}
+
+	//视觉模型，使用 imageAIConfig 中 OpenAI 兼容的模型（如 qwen3-vl-plus），可以在聊天中发送图片
+	f.creators["3"] = func(ctx context.Context, config map[string]interface{}) (AIModel, error) {
+		imageConf := myconfig.GetConfig().ImageAIConfig
+		if imageConf.BaseURL == "" || imageConf.ModelName == "" {
+			return nil, fmt.Errorf("vision model requires baseurl and modelname in imageAIConfig")
+		}
+		return NewOpenAICompatibleModel(ctx, imageConf.BaseURL, imageConf.ModelName, imageConf.Key, true)
 	}
 }
 
@@ -71,6 +81,16 @@ func (f *AIModelFactory) CreateAIHelper(ctx context.Context, modelType string, S
 	return NewAIHelper(model, SessionID, title, UpdateAt), nil
 }
 
+// ModelTypes 已注册的模型类型，按类型排序
+func (f *AIModelFactory) ModelTypes() []string {
+	types := make([]string, 0, len(f.creators))
+	for modelType := range f.creators {
+		types = append(types, modelType)
+	}
+	sort.Strings(types)
+	return types
+}
+
 // RegisterModel 可扩展注册
 func (f *AIModelFactory) RegisterModel(modelType string, creator ModelCreator) {
 	f.creators[modelType] = creator
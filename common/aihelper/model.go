@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/cloudwego/eino-ext/components/model/ollama"
@@ -13,11 +14,16 @@ import (
 	"github.com/cloudwego/eino-ext/components/tool/mcp"
 	"github.com/cloudwego/eino/adk"
 	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
 )
 
 type StreamCallback func(msg string)
+
+// MessageTraceCallback 接收 agent 运行过程中产生的工具调用与工具结果消息
+type MessageTraceCallback func(msg *schema.Message)
 type TravelPlanningProgressCallback func(progress TravelPlanningProgress)
 
 type TravelPlanningProgress struct {
@@ -33,28 +39,60 @@ const myChatBoxMcpURL = "http://localhost:8083/sse"
 // AIModel 定义AI模型接口
 type AIModel interface {
 	GenerateResponse(ctx context.Context, messages []*schema.Message, opts ...ToolOption) (*schema.Message, error)
-	StreamResponse(ctx context.Context, messages []*schema.Message, cb StreamCallback) (string, error)
+	ResumeResponse(ctx context.Context, checkPointID string, interruptIDs []string, result *ToolApprovalResult, opts ...ToolOption) (*schema.Message, error)
+	StreamResponse(ctx context.Context, messages []*schema.Message, cb StreamCallback, opts ...ToolOption) (string, error)
 	GenerateTravelPlanResponse(ctx context.Context, messages string) (*schema.Message, error)
 	GenerateTravelPlanResponseWithProgress(ctx context.Context, messages string, cb TravelPlanningProgressCallback) (*schema.Message, error)
 	GetModelType() string
 }
 
+// VisionModel 可选实现，SupportsVision 为 true 的模型可以接收用户消息中的图片
+type VisionModel interface {
+	SupportsVision() bool
+}
+
+// ToolSource 提供交给聊天 agent 的全部工具，再由 ToolOption 过滤
+type ToolSource func(ctx context.Context) ([]tool.BaseTool, error)
+
 // =================== OpenAI 实现 ===================
 type OpenAIModel struct {
-	llm model.ToolCallingChatModel
+	llm         model.ToolCallingChatModel
+	vision      bool                    // 模型是否支持图片输入
+	tools       ToolSource              // 为 nil 时使用 chatbox MCP 服务提供的工具
+	checkPoints compose.CheckPointStore // 为 nil 时检查点保存在 Redis 中
 }
 
-// TODO: 增加一个 MCP 的实现
+// chatbox MCP 服务提供的工具名称
+const (
+	ToolCurrentTime  = "current time"
+	ToolGoogleSearch = "google_search"
+	ToolRAGSearch    = "rag_search"
+)
+
+// ChatBoxTools 聊天助手可选的全部工具
+var ChatBoxTools = []string{ToolCurrentTime, ToolGoogleSearch, ToolRAGSearch}
+
 // 工具选择
+// enabledTools 为本次请求希望启用的工具，allowedTools 为角色允许使用的工具，
+// 两者都为 nil 时表示不做限制；最终交给 Agent 的工具是两者的交集
+// confirmTools 中的工具在调用前会暂停 Agent，等待用户确认
+// traceCallback 用于接收中间的工具调用与工具结果消息
+// knowledgeBases 为 rag_search 允许检索的知识库，由服务端强制写入工具参数
+// citations 不为 nil 时为检索工具的结果编号，用于生成回答的引用列表
 type ToolOptions struct {
-	usingGoogle bool
-	usingRAG    bool
+	enabledTools   map[string]bool
+	allowedTools   map[string]bool
+	confirmTools   map[string]bool
+	traceCallback  MessageTraceCallback
+	knowledgeBases []string
+	citations      *CitationCollector
 }
 
 func defaultToolOptions() *ToolOptions {
 	out := &ToolOptions{
-		usingGoogle: false,
-		usingRAG:    false,
+		enabledTools: nil,
+		allowedTools: nil,
+		confirmTools: map[string]bool{},
 	}
 	return out
 }
@@ -62,15 +100,101 @@ func defaultToolOptions() *ToolOptions {
 type ToolOption func(opts *ToolOptions)
 
 func WithGoogleTool() ToolOption {
+	return WithTools(ToolGoogleSearch)
+}
+
+func WithRAGTool() ToolOption {
+	return WithTools(ToolRAGSearch)
+}
+
+// WithTools 指定本次请求启用的工具，可多次调用累加
+func WithTools(names ...string) ToolOption {
 	return func(opts *ToolOptions) {
-		opts.usingGoogle = true
+		if opts.enabledTools == nil {
+			opts.enabledTools = make(map[string]bool)
+		}
+		for _, name := range names {
+			opts.enabledTools[name] = true
+		}
 	}
 }
 
-func WithRAGTool() ToolOption {
+// WithAllowedTools 指定角色允许使用的工具白名单
+func WithAllowedTools(names ...string) ToolOption {
 	return func(opts *ToolOptions) {
-		opts.usingRAG = true
+		opts.allowedTools = make(map[string]bool, len(names))
+		for _, name := range names {
+			opts.allowedTools[name] = true
+		}
+	}
+}
+
+// WithConfirmTools 指定调用前需要用户确认的工具
+func WithConfirmTools(names ...string) ToolOption {
+	return func(opts *ToolOptions) {
+		for _, name := range names {
+			opts.confirmTools[name] = true
+		}
+	}
+}
+
+// WithMessageTrace 接收 agent 运行中产生的工具调用与工具结果消息
+func WithMessageTrace(cb MessageTraceCallback) ToolOption {
+	return func(opts *ToolOptions) {
+		opts.traceCallback = cb
+	}
+}
+
+// WithKnowledgeBases 指定 rag_search 检索的知识库，为空时只检索默认知识库
+func WithKnowledgeBases(ids ...string) ToolOption {
+	return func(opts *ToolOptions) {
+		opts.knowledgeBases = append(opts.knowledgeBases, ids...)
+	}
+}
+
+// 判断工具是否可以交给 Agent 使用
+func (opts *ToolOptions) isToolAllowed(name string) bool {
+	if opts.enabledTools != nil && !opts.enabledTools[name] {
+		return false
+	}
+	if opts.allowedTools != nil && !opts.allowedTools[name] {
+		return false
+	}
+	return true
+}
+
+// filterTools 按工具选择过滤 MCP 返回的工具列表，并包装需要确认的工具
+// 同时返回过滤后的工具名称
+func (opts *ToolOptions) filterTools(ctx context.Context, tools []tool.BaseTool) ([]tool.BaseTool, []string, error) {
+	out := make([]tool.BaseTool, 0, len(tools))
+	names := make([]string, 0, len(tools))
+	for _, t := range tools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("get tool info failed: %v", err)
+		}
+		if !opts.isToolAllowed(info.Name) {
+			continue
+		}
+		if info.Name == ToolRAGSearch {
+			t, err = wrapScopedRAGTool(t, opts.knowledgeBases)
+			if err != nil {
+				return nil, nil, fmt.Errorf("wrap tool %s failed: %v", info.Name, err)
+			}
+		}
+		if citationType, ok := citationTools[info.Name]; ok && opts.citations != nil {
+			t = wrapCitationTool(t, citationType, opts.citations)
+		}
+		if opts.confirmTools[info.Name] {
+			t, err = wrapApprovalTool(t)
+			if err != nil {
+				return nil, nil, fmt.Errorf("wrap tool %s failed: %v", info.Name, err)
+			}
+		}
+		out = append(out, t)
+		names = append(names, info.Name)
 	}
+	return out, names, nil
 }
 
 func AddTodoFunc(_ context.Context, params string) (string, error) {
@@ -78,11 +202,17 @@ func AddTodoFunc(_ context.Context, params string) (string, error) {
 	return `{"msg": "add todo success"}`, nil
 }
 
+// OPENAI_VISION=true 表示 OPENAI_MODEL_NAME 是支持图片输入的模型
 func NewOpenAIModel(ctx context.Context) (*OpenAIModel, error) {
 	key := os.Getenv("OPENAI_API_KEY")
 	modelName := os.Getenv("OPENAI_MODEL_NAME")
 	baseURL := os.Getenv("OPENAI_BASE_URL_ALIYUN")
+	vision, _ := strconv.ParseBool(os.Getenv("OPENAI_VISION"))
+	return NewOpenAICompatibleModel(ctx, baseURL, modelName, key, vision)
+}
 
+// NewOpenAICompatibleModel 使用指定地址与模型创建 OpenAI 兼容的聊天模型
+func NewOpenAICompatibleModel(ctx context.Context, baseURL, modelName, key string, vision bool) (*OpenAIModel, error) {
 	llm, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
 		BaseURL: baseURL,
 		Model:   modelName,
@@ -91,64 +221,116 @@ func NewOpenAIModel(ctx context.Context) (*OpenAIModel, error) {
 	if err != nil {
 		return nil, fmt.Errorf("create openai model failed: %v", err)
 	}
-	return &OpenAIModel{llm: llm}, nil
+	return &OpenAIModel{llm: llm, vision: vision}, nil
 }
 
-// 去除了 google 和 rag 的参数，使用 agent 直接调用 mcp，这里输入参数需要调整
-func (o *OpenAIModel) GenerateResponse(ctx context.Context, messages []*schema.Message, opts ...ToolOption) (*schema.Message, error) {
-	// // 处理可选参数
-	// var options *ToolOptions
-	// options = defaultToolOptions()
-	// for _, opt := range opts {
-	// 	opt(options)
-	// }
-
-	// // 使用 Google 能力进行回复
-	// if options.usingGoogle {
-	// 	return o.GenerateResponseWithGoogle(ctx, messages)
-	// }
-
-	// if options.usingRAG {
-	// 	return o.GenerateResponseWithRAG(ctx, messages)
-	// }
-
-	// // 如果不需要工具，直接使用 chat 生成回复
-	// resp, err := o.llm.Generate(ctx, messages)
-	// if err != nil {
-	// 	return nil, fmt.Errorf("openai generate failed: %v", err)
-	// }
+// NewOpenAIModelWithChatModel 使用已创建的聊天模型，tools 与 checkPoints 为 nil 时使用 chatbox MCP 服务与 Redis
+func NewOpenAIModelWithChatModel(llm model.ToolCallingChatModel, vision bool, tools ToolSource, checkPoints compose.CheckPointStore) *OpenAIModel {
+	return &OpenAIModel{llm: llm, vision: vision, tools: tools, checkPoints: checkPoints}
+}
 
-	cli, err := initMCPClient(ctx, myChatBoxMcpURL)
+// 使用 agent 直接调用 mcp，交给 agent 的工具由 ToolOption 决定
+// 如果 agent 调用了需要确认的工具，会返回 *ToolApprovalPendingError
+func (o *OpenAIModel) GenerateResponse(ctx context.Context, messages []*schema.Message, opts ...ToolOption) (*schema.Message, error) {
+	runner, options, toolNames, err := o.newChatBoxRunner(ctx, false, opts...)
 	if err != nil {
-		log.Printf("ERROR initializing MCP client: %v\n", err)
 		return nil, err
 	}
-	tools, err := mcp.GetTools(ctx, &mcp.Config{Cli: cli})
+
+	// 生成回复，检查点ID 即后续的审批ID
+	checkPointID := uuid.New().String()
+	iter := runner.Run(ctx, messages, adk.WithCheckPointID(checkPointID))
+	return collectChatBoxResponse(iter, checkPointID, toolNames, options.traceCallback, nil)
+}
+
+// ResumeResponse 用户确认工具调用后，从检查点恢复 agent 运行
+// opts 需要与中断前保持一致，保证重建出相同的 agent
+func (o *OpenAIModel) ResumeResponse(ctx context.Context, checkPointID string, interruptIDs []string, result *ToolApprovalResult, opts ...ToolOption) (*schema.Message, error) {
+	runner, options, toolNames, err := o.newChatBoxRunner(ctx, false, opts...)
 	if err != nil {
-		log.Printf("ERROR getting MCP tools: %v\n", err)
 		return nil, err
 	}
 
+	targets := make(map[string]any, len(interruptIDs))
+	for _, id := range interruptIDs {
+		targets[id] = result
+	}
+	iter, err := runner.ResumeWithParams(ctx, checkPointID, &adk.ResumeParams{Targets: targets})
+	if err != nil {
+		return nil, fmt.Errorf("resume agent failed: %v", err)
+	}
+	return collectChatBoxResponse(iter, checkPointID, toolNames, options.traceCallback, nil)
+}
+
+// 构建带工具的聊天 agent，检查点保存在 Redis 中
+// streaming 为 true 时模型回复以流的形式输出
+func (o *OpenAIModel) newChatBoxRunner(ctx context.Context, streaming bool, opts ...ToolOption) (*adk.Runner, *ToolOptions, []string, error) {
+	// 处理可选参数
+	options := defaultToolOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	source := o.tools
+	if source == nil {
+		source = chatBoxMCPTools
+	}
+	tools, err := source(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// 只把允许使用的工具交给 Agent
+	tools, toolNames, err := options.filterTools(ctx, tools)
+	if err != nil {
+		log.Printf("ERROR filtering MCP tools: %v\n", err)
+		return nil, nil, nil, err
+	}
+
 	agent, err := adk.NewChatModelAgent(ctx, &adk.ChatModelAgentConfig{
 		Name:        "ChatBoxMCPAgent",
 		Description: "你是一个聪明的聊天助手，可以使用多个工具来帮助用户回答问题。",
-		Instruction: `你是一个聪明的聊天助手，可以使用相关工具。`,
-		Model:       o.llm,
+		Instruction: `你是一个聪明的聊天助手，可以使用相关工具。
+使用 rag_search 或 google_search 的结果回答时，在引用资料的句子末尾用 [index] 标注来源，index 为工具结果中的编号。`,
+		Model: o.llm,
 		ToolsConfig: adk.ToolsConfig{
 			ToolsNodeConfig: compose.ToolsNodeConfig{Tools: tools},
 		},
 	})
-
 	if err != nil {
-		log.Printf("ERROR creating red flag agent: %v\n", err)
-		return nil, err
+		log.Printf("ERROR creating chat box agent: %v\n", err)
+		return nil, nil, nil, err
 	}
 
-	// 生成回复
+	var checkPoints compose.CheckPointStore = &redisCheckPointStore{}
+	if o.checkPoints != nil {
+		checkPoints = o.checkPoints
+	}
 	runner := adk.NewRunner(ctx, adk.RunnerConfig{
-		Agent: agent,
+		Agent:           agent,
+		EnableStreaming: streaming,
+		CheckPointStore: checkPoints,
 	})
-	iter := runner.Run(ctx, messages)
+	return runner, options, toolNames, nil
+}
+
+// chatBoxMCPTools 从 chatbox MCP 服务获取工具
+func chatBoxMCPTools(ctx context.Context) ([]tool.BaseTool, error) {
+	cli, err := initMCPClient(ctx, myChatBoxMcpURL)
+	if err != nil {
+		log.Printf("ERROR initializing MCP client: %v\n", err)
+		return nil, err
+	}
+	tools, err := mcp.GetTools(ctx, &mcp.Config{Cli: cli})
+	if err != nil {
+		log.Printf("ERROR getting MCP tools: %v\n", err)
+		return nil, err
+	}
+	return tools, nil
+}
+
+// 读取 agent 事件，返回最终回复；遇到工具确认中断时返回 *ToolApprovalPendingError
+// 中间的工具调用与工具结果消息交给 traceCb，streamCb 不为 nil 时模型输出的内容会实时交给 streamCb
+func collectChatBoxResponse(iter *adk.AsyncIterator[*adk.AgentEvent], checkPointID string, toolNames []string, traceCb MessageTraceCallback, streamCb StreamCallback) (*schema.Message, error) {
 	var resMsg string
 	for {
 		event, ok := iter.Next()
@@ -156,21 +338,69 @@ func (o *OpenAIModel) GenerateResponse(ctx context.Context, messages []*schema.M
 			break
 		}
 		if event.Err != nil {
-			log.Fatal(event.Err)
+			log.Printf("ERROR running chat box agent: %v\n", event.Err)
+			return nil, event.Err
+		}
+		if event.Action != nil && event.Action.Interrupted != nil {
+			calls := collectPendingToolCalls(event.Action.Interrupted)
+			if len(calls) == 0 {
+				return nil, fmt.Errorf("agent interrupted without pending tool calls")
+			}
+			return nil, &ToolApprovalPendingError{
+				CheckPointID: checkPointID,
+				Tools:        toolNames,
+				ToolCalls:    calls,
+			}
 		}
-		msg, err := event.Output.MessageOutput.GetMessage()
+		if event.Output == nil || event.Output.MessageOutput == nil {
+			continue
+		}
+		msg, err := readMessageOutput(event.Output.MessageOutput, streamCb)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		resMsg = msg.Content
 		log.Printf("\nmessage:\n%+v\n======", msg)
+		if msg.Role == schema.Tool || len(msg.ToolCalls) > 0 {
+			if traceCb != nil {
+				traceCb(msg)
+			}
+			continue
+		}
+		resMsg = msg.Content
 	}
 
 	return &schema.Message{
 		Role:    schema.Assistant,
 		Content: resMsg,
 	}, nil
+}
 
+// 读取 agent 输出的消息，流式输出的模型回复逐块交给 cb，返回拼接后的完整消息
+func readMessageOutput(output *adk.MessageVariant, cb StreamCallback) (*schema.Message, error) {
+	if !output.IsStreaming || cb == nil || output.Role != schema.Assistant {
+		return output.GetMessage()
+	}
+	stream := output.MessageStream
+	defer stream.Close()
+
+	var chunks []*schema.Message
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+		if len(chunk.Content) > 0 {
+			cb(chunk.Content) // 实时调用cb函数，方便主动发送给前端
+		}
+	}
+	if len(chunks) == 0 {
+		return &schema.Message{Role: schema.Assistant}, nil
+	}
+	return schema.ConcatMessages(chunks)
 }
 
 // NOTE: 去除了 google 和 RAG 的单独实现，直接在 GenerateResponse 里使用 MCP 处理，需要代码 revieww
@@ -318,43 +548,36 @@ func (o *OpenAIModel) GenerateTravelPlanResponseWithProgress(ctx context.Context
 	return o.TravelAgentResp(ctx, messages, cb)
 }
 
-func (o *OpenAIModel) StreamResponse(ctx context.Context, messages []*schema.Message, cb StreamCallback) (string, error) {
-	stream, err := o.llm.Stream(ctx, messages)
+// 流式生成与 GenerateResponse 使用同一个 agent，模型回复逐块交给 cb
+// 如果 agent 调用了需要确认的工具，会返回 *ToolApprovalPendingError，之后通过 ResumeResponse 继续
+func (o *OpenAIModel) StreamResponse(ctx context.Context, messages []*schema.Message, cb StreamCallback, opts ...ToolOption) (string, error) {
+	runner, options, toolNames, err := o.newChatBoxRunner(ctx, true, opts...)
 	if err != nil {
-		return "", fmt.Errorf("openai stream failed: %v", err)
+		return "", err
 	}
-	defer stream.Close()
-
-	var fullResp strings.Builder
 
-	for {
-		msg, err := stream.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return "", fmt.Errorf("openai stream recv failed: %v", err)
-		}
-		if len(msg.Content) > 0 {
-			fullResp.WriteString(msg.Content) // 聚合
-
-			cb(msg.Content) // 实时调用cb函数，方便主动发送给前端
-		}
+	checkPointID := uuid.New().String()
+	iter := runner.Run(ctx, messages, adk.WithCheckPointID(checkPointID))
+	msg, err := collectChatBoxResponse(iter, checkPointID, toolNames, options.traceCallback, cb)
+	if err != nil {
+		return "", err
 	}
-
-	return fullResp.String(), nil //返回完整内容，方便后续存储
+	return msg.Content, nil //返回完整内容，方便后续存储
 }
 
 func (o *OpenAIModel) GetModelType() string { return "openai" }
 
+func (o *OpenAIModel) SupportsVision() bool { return o.vision }
+
 // =================== Ollama 实现 ===================
 
 // OllamaModel Ollama模型实现
 type OllamaModel struct {
-	llm model.ToolCallingChatModel
+	llm    model.ToolCallingChatModel
+	vision bool // 模型是否支持图片输入，如 gemma3、llava
 }
 
-func NewOllamaModel(ctx context.Context, baseURL, modelName string) (*OllamaModel, error) {
+func NewOllamaModel(ctx context.Context, baseURL, modelName string, vision bool) (*OllamaModel, error) {
 	llm, err := ollama.NewChatModel(ctx, &ollama.ChatModelConfig{
 		BaseURL: baseURL,
 		Model:   modelName,
@@ -362,7 +585,7 @@ func NewOllamaModel(ctx context.Context, baseURL, modelName string) (*OllamaMode
 	if err != nil {
 		return nil, fmt.Errorf("create ollama model failed: %v", err)
 	}
-	return &OllamaModel{llm: llm}, nil
+	return &OllamaModel{llm: llm, vision: vision}, nil
 }
 
 func (o *OllamaModel) GenerateResponse(ctx context.Context, messages []*schema.Message, opts ...ToolOption) (*schema.Message, error) {
@@ -373,8 +596,14 @@ func (o *OllamaModel) GenerateResponse(ctx context.Context, messages []*schema.M
 	return resp, nil
 }
 
+// Ollama 模型不使用工具，不会产生需要确认的工具调用
+func (o *OllamaModel) ResumeResponse(ctx context.Context, checkPointID string, interruptIDs []string, result *ToolApprovalResult, opts ...ToolOption) (*schema.Message, error) {
+	return nil, fmt.Errorf("ollama model does not support tool approval")
+}
+
 // TODO: 流式响应回调函数存在问题，还是都生成了再统一回复
-func (o *OllamaModel) StreamResponse(ctx context.Context, messages []*schema.Message, cb StreamCallback) (string, error) {
+// Ollama 模型不使用工具，opts 会被忽略
+func (o *OllamaModel) StreamResponse(ctx context.Context, messages []*schema.Message, cb StreamCallback, opts ...ToolOption) (string, error) {
 	stream, err := o.llm.Stream(ctx, messages)
 	if err != nil {
 		return "", fmt.Errorf("ollama stream failed: %v", err)
@@ -399,6 +628,8 @@ func (o *OllamaModel) StreamResponse(ctx context.Context, messages []*schema.Mes
 
 func (o *OllamaModel) GetModelType() string { return "ollama" }
 
+func (o *OllamaModel) SupportsVision() bool { return o.vision }
+
 func (o *OllamaModel) GenerateTravelPlanResponse(ctx context.Context, messages string) (*schema.Message, error) {
 	return o.GenerateTravelPlanResponseWithProgress(ctx, messages, nil)
 }
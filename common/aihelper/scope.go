@@ -0,0 +1,47 @@
+package aihelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// rag_search 中指定知识库范围的参数名，与 chatbox MCP 服务保持一致
+const ragKnowledgeBasesArg = "knowledge_bases"
+
+// scopedRAGTool 调用 rag_search 前用会话允许的知识库覆盖模型给出的范围，
+// 模型无法通过构造参数检索未授权的知识库
+type scopedRAGTool struct {
+	tool.InvokableTool
+	knowledgeBases string
+}
+
+func (t *scopedRAGTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	args := make(map[string]any)
+	if strings.TrimSpace(argumentsInJSON) != "" {
+		if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+			return "", fmt.Errorf("invalid rag_search arguments: %v", err)
+		}
+	}
+	args[ragKnowledgeBasesArg] = t.knowledgeBases
+
+	scoped, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	return t.InvokableTool.InvokableRun(ctx, string(scoped), opts...)
+}
+
+func wrapScopedRAGTool(t tool.BaseTool, knowledgeBases []string) (tool.BaseTool, error) {
+	invokable, ok := t.(tool.InvokableTool)
+	if !ok {
+		return nil, fmt.Errorf("rag tool must be invokable")
+	}
+	return &scopedRAGTool{
+		InvokableTool:  invokable,
+		knowledgeBases: strings.Join(knowledgeBases, ","),
+	}, nil
+}
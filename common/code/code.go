@@ -17,14 +17,24 @@ const (
 	CodeInvalidCaptcha   Code = 2008
 	CodeRecordNotFound   Code = 2009
 	CodeIllegalPassword  Code = 2010
+	CodeInvalidImage     Code = 2011
+	CodeImageTooLarge    Code = 2012
+	CodeUnsupportedImage Code = 2013
+	CodeWeakPassword     Code = 2014
+	CodeTooManyRequests  Code = 2015
+	CodeAccountLocked    Code = 2016
+	CodeUserDisabled     Code = 2017
 
 	CodeForbidden Code = 3001
 
 	CodeServerBusy Code = 4001
 
-	AIModelNotFind    Code = 5001
-	AIModelCannotOpen Code = 5002
-	AIModelFail       Code = 5003
+	AIModelNotFind     Code = 5001
+	AIModelCannotOpen  Code = 5002
+	AIModelFail        Code = 5003
+	AIModelNoVision    Code = 5004
+	AIModelBadOutput   Code = 5005
+	AIModelUnsupported Code = 5006
 )
 
 var msg = map[Code]string{
@@ -40,14 +50,24 @@ var msg = map[Code]string{
 	CodeInvalidCaptcha:   "验证码错误",
 	CodeRecordNotFound:   "记录不存在",
 	CodeIllegalPassword:  "密码不合法",
+	CodeInvalidImage:     "图片无法识别",
+	CodeImageTooLarge:    "图片过大",
+	CodeUnsupportedImage: "不支持的图片格式",
+	CodeWeakPassword:     "密码强度不足，需要满足最短长度并同时包含字母和数字",
+	CodeTooManyRequests:  "请求过于频繁，请稍后再试",
+	CodeAccountLocked:    "登录失败次数过多，账号已临时锁定，请稍后再试",
+	CodeUserDisabled:     "账号已被禁用",
 
 	CodeForbidden: "权限不足",
 
 	CodeServerBusy: "服务繁忙",
 
-	AIModelNotFind:    "模型不存在",
-	AIModelCannotOpen: "无法打开模型",
-	AIModelFail:       "模型运行失败",
+	AIModelNotFind:     "模型不存在",
+	AIModelCannotOpen:  "无法打开模型",
+	AIModelFail:        "模型运行失败",
+	AIModelNoVision:    "当前模型不支持图片输入",
+	AIModelBadOutput:   "模型输出格式错误",
+	AIModelUnsupported: "当前模型不支持该功能",
 }
 
 func (code Code) Code() int64 {
@@ -0,0 +1,174 @@
+package image
+
+import (
+	"GopherAI/model"
+	"GopherAI/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+var (
+	// ErrInvalidImage 图片无法解码
+	ErrInvalidImage = errors.New("invalid image")
+	// ErrInvalidSchema 调用方提供的输出 schema 不是合法的 JSON Schema 对象
+	ErrInvalidSchema = errors.New("invalid output schema")
+	// ErrInvalidOutput 模型没有返回可解析或符合 schema 的 JSON
+	ErrInvalidOutput = errors.New("invalid model output")
+	// ErrNotSupported 当前识别后端不支持该选项，例如本地分类模型无法按 schema 输出
+	ErrNotSupported = errors.New("not supported by image recognizer backend")
+)
+
+// DetectedObject 图片中识别出的物体
+type DetectedObject struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"` // 0~1
+}
+
+// LandmarkGuess 推测的地标或景点，用于旅行规划
+type LandmarkGuess struct {
+	Name       string  `json:"name"`
+	City       string  `json:"city,omitempty"`
+	Country    string  `json:"country,omitempty"`
+	Confidence float64 `json:"confidence"` // 0~1
+}
+
+// ImageAnalysis 图片分析结果
+type ImageAnalysis struct {
+	Caption  string           `json:"caption"`
+	Objects  []DetectedObject `json:"objects"`
+	Text     string           `json:"text,omitempty"`     // 图片中的文字（OCR）
+	Landmark *LandmarkGuess   `json:"landmark,omitempty"` // 无法判断时为空
+	// 调用方提供输出 schema 时，按 schema 生成的结果
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// AnalyzeOptions 分析参数
+type AnalyzeOptions struct {
+	Prompt string          // 用户的问题或关注点，可为空
+	Schema json.RawMessage // result 字段的 JSON Schema，可为空
+}
+
+const analyzeInstruction = `你是一个图片分析助手，只输出一个 JSON 对象，不要输出其他内容。字段如下：
+- caption：一句话描述图片内容
+- objects：图片中的主要物体，数组，每项为 {"name": 名称, "confidence": 0 到 1 的置信度}，按置信度从高到低
+- text：图片中能辨认出的文字，没有则为空字符串
+- landmark：如果能判断出具体的地标、景点或城市，给出 {"name": 名称, "city": 城市, "country": 国家, "confidence": 0 到 1 的置信度}，否则为 null`
+
+// Analyze 对图片进行结构化分析，返回描述、物体、文字与地标推测
+func (r *LLMRecognizer) Analyze(ctx context.Context, buf []byte, opts AnalyzeOptions) (*ImageAnalysis, error) {
+	var schemaDoc map[string]any
+	if len(opts.Schema) > 0 {
+		if err := json.Unmarshal(opts.Schema, &schemaDoc); err != nil || schemaDoc == nil {
+			return nil, ErrInvalidSchema
+		}
+	}
+	img, err := r.prepare(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	instruction := analyzeInstruction
+	if schemaDoc != nil {
+		instruction += "\n- result：按照下面的 JSON Schema 回答用户的问题\n" + string(opts.Schema)
+	}
+	prompt := strings.TrimSpace(opts.Prompt)
+	if prompt == "" {
+		prompt = "请分析这张图片"
+	}
+	messages := []*schema.Message{
+		schema.SystemMessage(instruction),
+		{
+			Role:                  schema.User,
+			UserInputMultiContent: utils.ConvertToSchemaImageParts(prompt, []model.MessageImage{img}),
+		},
+	}
+	resp, err := r.model.GenerateImageDescription(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	return parseAnalysis(resp.Content, schemaDoc)
+}
+
+// parseAnalysis 解析模型输出，容忍 markdown 代码块包裹
+func parseAnalysis(content string, schemaDoc map[string]any) (*ImageAnalysis, error) {
+	cleaned := strings.TrimSpace(content)
+	start := strings.Index(cleaned, "{")
+	end := strings.LastIndex(cleaned, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("%w: no json object in response", ErrInvalidOutput)
+	}
+	var analysis ImageAnalysis
+	if err := json.Unmarshal([]byte(cleaned[start:end+1]), &analysis); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOutput, err)
+	}
+	if analysis.Caption == "" {
+		return nil, fmt.Errorf("%w: caption is empty", ErrInvalidOutput)
+	}
+	if analysis.Objects == nil {
+		analysis.Objects = []DetectedObject{}
+	}
+	for i := range analysis.Objects {
+		analysis.Objects[i].Confidence = clampConfidence(analysis.Objects[i].Confidence)
+	}
+	if analysis.Landmark != nil {
+		if analysis.Landmark.Name == "" {
+			analysis.Landmark = nil
+		} else {
+			analysis.Landmark.Confidence = clampConfidence(analysis.Landmark.Confidence)
+		}
+	}
+
+	if schemaDoc == nil {
+		analysis.Result = nil
+		return &analysis, nil
+	}
+	if err := checkSchema(analysis.Result, schemaDoc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOutput, err)
+	}
+	return &analysis, nil
+}
+
+// checkSchema 检查结果的类型与必填字段，只覆盖顶层，不是完整的 JSON Schema 校验
+func checkSchema(result json.RawMessage, schemaDoc map[string]any) error {
+	if len(result) == 0 || string(result) == "null" {
+		return errors.New("result is missing")
+	}
+	var value any
+	if err := json.Unmarshal(result, &value); err != nil {
+		return err
+	}
+	switch schemaDoc["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return errors.New("result is not an object")
+		}
+		required, _ := schemaDoc["required"].([]any)
+		for _, field := range required {
+			name, _ := field.(string)
+			if _, ok := obj[name]; name != "" && !ok {
+				return fmt.Errorf("result is missing required field %s", name)
+			}
+		}
+	case "array":
+		if _, ok := value.([]any); !ok {
+			return errors.New("result is not an array")
+		}
+	}
+	return nil
+}
+
+func clampConfidence(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,179 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	defaultInputSize = 224
+	defaultTopK      = 5
+)
+
+// ErrLocalUnavailable 当前构建不包含本地分类模型（需要 onnx 构建标签）
+var ErrLocalUnavailable = errors.New("local image classifier is not available in this build, rebuild with -tags onnx")
+
+// ClassifierConfig 本地分类模型配置
+type ClassifierConfig struct {
+	ModelPath   string    // 模型文件
+	LabelPath   string    // 标签文件，每行一个，顺序与模型输出一致
+	LibraryPath string    // onnxruntime 动态库路径，为空时使用系统默认位置
+	InputSize   int       // 输入的边长，默认 224
+	Mean        []float32 // RGB 三个通道的均值，为空时只缩放到 0~1
+	Std         []float32 // RGB 三个通道的标准差
+	TopK        int       // Analyze 返回的物体数，默认 5
+}
+
+// Classifier 本地图片分类器，模型推理由 run 完成
+type Classifier struct {
+	name   string
+	conf   ClassifierConfig
+	opts   PrepareOptions
+	labels []string
+
+	mu    sync.Mutex // 推理使用同一组输入输出张量，需要串行
+	run   func(input []float32) ([]float32, error)
+	close func()
+}
+
+func (c *Classifier) Name() string {
+	return c.name
+}
+
+func (c *Classifier) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.close != nil {
+		c.close()
+		c.close = nil
+	}
+}
+
+// PredictFromBuffer 返回置信度最高的标签
+func (c *Classifier) PredictFromBuffer(ctx context.Context, buf []byte) (string, error) {
+	objects, err := c.classify(ctx, buf, 1)
+	if err != nil {
+		return "", err
+	}
+	return objects[0].Name, nil
+}
+
+// Analyze 以分类结果作为物体列表，不支持按 schema 输出
+func (c *Classifier) Analyze(ctx context.Context, buf []byte, opts AnalyzeOptions) (*ImageAnalysis, error) {
+	if len(opts.Schema) > 0 {
+		return nil, fmt.Errorf("%w: %s backend cannot follow an output schema", ErrNotSupported, c.name)
+	}
+	objects, err := c.classify(ctx, buf, c.conf.TopK)
+	if err != nil {
+		return nil, err
+	}
+	return &ImageAnalysis{Caption: objects[0].Name, Objects: objects}, nil
+}
+
+func (c *Classifier) classify(ctx context.Context, buf []byte, k int) ([]DetectedObject, error) {
+	img, err := decodeImage(buf, c.opts)
+	if err != nil {
+		return nil, err
+	}
+	input := imageToTensor(img, c.conf.InputSize, c.conf.Mean, c.conf.Std)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.run == nil {
+		c.mu.Unlock()
+		return nil, errors.New("classifier is closed")
+	}
+	scores, err := c.run(input)
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	objects := topLabels(scores, c.labels, k)
+	if len(objects) == 0 {
+		return nil, errors.New("empty output from model")
+	}
+	return objects, nil
+}
+
+func (conf ClassifierConfig) withDefaults() ClassifierConfig {
+	if conf.InputSize <= 0 {
+		conf.InputSize = defaultInputSize
+	}
+	if conf.TopK <= 0 {
+		conf.TopK = defaultTopK
+	}
+	return conf
+}
+
+// imageToTensor 缩放到 size×size 并按 NCHW 排列 RGB
+func imageToTensor(img image.Image, size int, mean, std []float32) []float32 {
+	resized := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	plane := size * size
+	data := make([]float32, 3*plane)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			p := resized.PixOffset(x, y)
+			for ch := 0; ch < 3; ch++ {
+				v := float32(resized.Pix[p+ch]) / 255
+				if len(mean) == 3 && len(std) == 3 && std[ch] != 0 {
+					v = (v - mean[ch]) / std[ch]
+				}
+				data[ch*plane+y*size+x] = v
+			}
+		}
+	}
+	return data
+}
+
+// topLabels 取得分最高的 k 个标签，输出不是概率分布时先做 softmax
+func topLabels(scores []float32, labels []string, k int) []DetectedObject {
+	n := min(len(scores), len(labels))
+	if n == 0 || k <= 0 {
+		return nil
+	}
+	probs := make([]float64, n)
+	sum, isDist := 0.0, true
+	for i := 0; i < n; i++ {
+		probs[i] = float64(scores[i])
+		sum += probs[i]
+		if probs[i] < 0 || probs[i] > 1 {
+			isDist = false
+		}
+	}
+	if !isDist || math.Abs(sum-1) > 1e-3 {
+		maxScore := probs[0]
+		for _, v := range probs {
+			maxScore = max(maxScore, v)
+		}
+		sum = 0
+		for i, v := range probs {
+			probs[i] = math.Exp(v - maxScore)
+			sum += probs[i]
+		}
+		for i := range probs {
+			probs[i] /= sum
+		}
+	}
+
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return probs[idx[a]] > probs[idx[b]] })
+	objects := make([]DetectedObject, 0, min(k, n))
+	for _, i := range idx[:min(k, n)] {
+		objects = append(objects, DetectedObject{Name: labels[i], Confidence: probs[i]})
+	}
+	return objects
+}
@@ -0,0 +1,207 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	exifHeader   = []byte("Exif\x00\x00")
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// 去除元数据时丢弃的 JPEG 段：APP1（EXIF、XMP，GPS 信息在 EXIF 中）、APP13（IPTC）、COM
+var jpegMetadataMarkers = map[byte]bool{
+	0xE1: true,
+	0xED: true,
+	0xFE: true,
+}
+
+// 去除元数据时丢弃的 PNG 块
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// jpegSegments 遍历 JPEG 在图像数据（SOS）之前的段，fn 返回 false 时停止
+// 返回 SOS 段的起始位置，结构异常时返回 -1
+func jpegSegments(buf []byte, fn func(marker byte, segment []byte) bool) int {
+	if len(buf) < 4 || buf[0] != 0xFF || buf[1] != 0xD8 {
+		return -1
+	}
+	pos := 2
+	for pos+4 <= len(buf) {
+		if buf[pos] != 0xFF {
+			return -1
+		}
+		marker := buf[pos+1]
+		switch {
+		case marker == 0xFF: // 填充字节
+			pos++
+			continue
+		case marker == 0xDA:
+			return pos
+		case marker == 0xD9:
+			return -1
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7): // 没有长度字段的标记
+			pos += 2
+			continue
+		}
+		size := int(binary.BigEndian.Uint16(buf[pos+2:]))
+		if size < 2 || pos+2+size > len(buf) {
+			return -1
+		}
+		if !fn(marker, buf[pos:pos+2+size]) {
+			return pos
+		}
+		pos += 2 + size
+	}
+	return -1
+}
+
+// jpegOrientation 读取 EXIF 中的方向（tag 0x0112），没有或无法解析时返回 1
+func jpegOrientation(buf []byte) int {
+	orientation := 1
+	jpegSegments(buf, func(marker byte, segment []byte) bool {
+		payload := segment[4:]
+		if marker != 0xE1 || !bytes.HasPrefix(payload, exifHeader) {
+			return true
+		}
+		orientation = tiffOrientation(payload[len(exifHeader):])
+		return false
+	})
+	return orientation
+}
+
+// tiffOrientation 在 EXIF 的 IFD0 中查找方向
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < n; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != 0x0112 {
+			continue
+		}
+		// SHORT 类型，值直接存放在 value 字段的前两个字节
+		if order.Uint16(tiff[entry+2:]) != 3 {
+			return 1
+		}
+		if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+			return v
+		}
+		return 1
+	}
+	return 1
+}
+
+// stripJPEGMetadata 去掉 EXIF/XMP/IPTC/注释段，不重新编码图像数据
+func stripJPEGMetadata(buf []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(buf))
+	out = append(out, 0xFF, 0xD8)
+	sos := jpegSegments(buf, func(marker byte, segment []byte) bool {
+		if !jpegMetadataMarkers[marker] {
+			out = append(out, segment...)
+		}
+		return true
+	})
+	if sos < 0 {
+		return nil, false
+	}
+	return append(out, buf[sos:]...), true
+}
+
+// stripPNGMetadata 去掉 PNG 中的 EXIF 与文本块
+func stripPNGMetadata(buf []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(buf, pngSignature) {
+		return nil, false
+	}
+	out := make([]byte, 0, len(buf))
+	out = append(out, pngSignature...)
+	pos := len(pngSignature)
+	for pos+12 <= len(buf) {
+		end := pos + 12 + int(binary.BigEndian.Uint32(buf[pos:]))
+		if end > len(buf) || end < pos {
+			return nil, false
+		}
+		typ := string(buf[pos+4 : pos+8])
+		if !pngMetadataChunks[typ] {
+			out = append(out, buf[pos:end]...)
+		}
+		pos = end
+		if typ == "IEND" {
+			return out, true
+		}
+	}
+	return nil, false
+}
+
+// applyOrientation 按 EXIF 方向旋转或翻转，返回方向为 1 的图片
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	src, ok := img.(*image.RGBA)
+	if !ok || src.Bounds().Min != (image.Point{}) {
+		b := img.Bounds()
+		src = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := x, y
+			switch orientation {
+			case 2: // 水平翻转
+				sx = w - 1 - x
+			case 3: // 旋转 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				sy = h - 1 - y
+			case 5: // 沿主对角线翻转
+				sx, sy = y, x
+			case 6: // 顺时针旋转 90°
+				sx, sy = y, h-1-x
+			case 7: // 沿副对角线翻转
+				sx, sy = w-1-y, h-1-x
+			case 8: // 逆时针旋转 90°
+				sx, sy = w-1-y, x
+			}
+			d := dst.PixOffset(x, y)
+			s := src.PixOffset(sx, sy)
+			copy(dst.Pix[d:d+4], src.Pix[s:s+4])
+		}
+	}
+	return dst
+}
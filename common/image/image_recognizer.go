@@ -1,6 +1,7 @@
 package image
 
 import (
+	"GopherAI/model"
 	"GopherAI/utils"
 	"bufio"
 	"bytes"
@@ -17,63 +18,105 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 与 utils.ConvertToSchemaImageRequests 保持一致的描述指令
+const describePrompt = "帮我对照片进行简单描述"
+
+// 识别后端，在 [imageAIConfig] backend 中选择
+const (
+	BackendLLM  = "llm"  // 远程视觉大模型（默认）
+	BackendONNX = "onnx" // 本地 ONNX 分类模型，需要 onnx 构建标签
+	BackendStub = "stub" // 确定性的假识别器，用于测试与离线开发
 )
 
-// TODO: 换成一个基于 qwen 的能力，因为 onnx 在 Mac 上这个库跑不了
+// ImageRecognizer 图片识别器，实现需要并发安全，服务中只创建一个实例
+type ImageRecognizer interface {
+	// PredictFromBuffer 返回一句话的描述（大模型）或分类标签（本地模型）
+	PredictFromBuffer(ctx context.Context, buf []byte) (string, error)
+	// Analyze 结构化分析，后端不支持的选项返回 ErrNotSupported
+	Analyze(ctx context.Context, buf []byte, opts AnalyzeOptions) (*ImageAnalysis, error)
+	// Name 后端名称
+	Name() string
+	Close()
+}
 
-type ImageRecognizer struct {
-	model *OpenAIImageModel
+// LLMRecognizer 调用远程视觉大模型识别图片
+type LLMRecognizer struct {
+	model AIImageModel
+	opts  PrepareOptions // 发送给模型前的预处理参数
 }
 
-// NewImageRecognizer 创建识别器（自动使用默认 input/output 名称）
-func NewImageRecognizer(ctx context.Context) (*ImageRecognizer, error) {
+// NewLLMRecognizer 使用 [imageAIConfig] 中的视觉模型创建识别器
+func NewLLMRecognizer(ctx context.Context, opts PrepareOptions) (*LLMRecognizer, error) {
 	ir_llm, err := NewOpenAIImageModel(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &ImageRecognizer{model: ir_llm}, nil
+	return &LLMRecognizer{model: ir_llm, opts: opts}, nil
+}
+
+// NewLLMRecognizerWithModel 使用指定的图像模型创建识别器
+func NewLLMRecognizerWithModel(m AIImageModel, opts PrepareOptions) *LLMRecognizer {
+	return &LLMRecognizer{model: m, opts: opts}
+}
+
+func (r *LLMRecognizer) Name() string {
+	return BackendLLM
 }
 
-func (r *ImageRecognizer) Close() {
+func (r *LLMRecognizer) Close() {
 	// 目前没有需要关闭的资源
 }
 
-func (r *ImageRecognizer) PredictFromFile(ctx context.Context, img image.Image) (string, error) {
+func (r *LLMRecognizer) PredictFromFile(ctx context.Context, img image.Image) (string, error) {
 	return r.PredictFromImage(ctx, img)
 }
 
-func (r *ImageRecognizer) PredictFromBuffer(ctx context.Context, buf []byte) (string, error) {
-	img, _, err := image.Decode(bytes.NewReader(buf))
+func (r *LLMRecognizer) PredictFromBuffer(ctx context.Context, buf []byte) (string, error) {
+	img, err := r.prepare(buf)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode image from buffer: %w", err)
+		return "", err
 	}
-	return r.PredictFromImage(ctx, img)
+	msg := []*schema.Message{{
+		Role:                  schema.User,
+		UserInputMultiContent: utils.ConvertToSchemaImageParts(describePrompt, []model.MessageImage{img}),
+	}}
+	return r.describe(ctx, msg)
 }
 
-func (r *ImageRecognizer) PredictFromImage(ctx context.Context, img image.Image) (string, error) {
-	// image.Image -> JPEG
-	var buf bytes.Buffer
-	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+func (r *LLMRecognizer) PredictFromImage(ctx context.Context, img image.Image) (string, error) {
+	// image.Image -> JPEG -> Base64
+	b64, err := imageToJPEGBase64(img)
+	if err != nil {
 		return "", err
 	}
+	return r.describe(ctx, utils.ConvertToSchemaImageRequests(b64))
+}
 
-	// Base64 + data URL
-	b64 := base64.StdEncoding.EncodeToString(buf.Bytes())
-
-	msg := utils.ConvertToSchemaImageRequests(b64)
-	om, err := NewOpenAIImageModel(ctx)
+// prepare 预处理图片并转为消息中的图片
+func (r *LLMRecognizer) prepare(buf []byte) (model.MessageImage, error) {
+	prepared, err := Prepare(buf, r.opts)
 	if err != nil {
-		return "", err
+		return model.MessageImage{}, err
 	}
+	return model.MessageImage{
+		MIMEType: prepared.MIMEType,
+		Data:     base64.StdEncoding.EncodeToString(prepared.Data),
+	}, nil
+}
 
-	// 4. 调用 OpenAI
-	resp, err := om.GenerateImageDescription(ctx, msg)
+func (r *LLMRecognizer) describe(ctx context.Context, msg []*schema.Message) (string, error) {
+	// 调用识别器持有的模型
+	resp, err := r.model.GenerateImageDescription(ctx, msg)
 	if err != nil {
 		log.Printf("GenerateImageDescription error: %v", err)
 		return "", err
 	}
 
-	// 5. 取模型输出文本
+	// 取模型输出文本
 	result := strings.TrimSpace(resp.Content)
 	if result == "" {
 		return "", errors.New("empty response from model")
@@ -82,6 +125,14 @@ func (r *ImageRecognizer) PredictFromImage(ctx context.Context, img image.Image)
 	return result, nil
 }
 
+func imageToJPEGBase64(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
 func loadLabels(path string) ([]string, error) {
 	f, err := os.Open(filepath.Clean(path))
 	if err != nil {
@@ -0,0 +1,8 @@
+//go:build !onnx
+
+package image
+
+// NewONNXClassifier onnxruntime 依赖 cgo 和动态库，默认构建不包含，使用 -tags onnx 构建
+func NewONNXClassifier(conf ClassifierConfig, opts PrepareOptions) (*Classifier, error) {
+	return nil, ErrLocalUnavailable
+}
@@ -0,0 +1,202 @@
+package image
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// DefaultMaxPixels 解码前检查的像素上限，防止解压炸弹
+	DefaultMaxPixels = 40_000_000
+	// DefaultMaxEdge 发送给模型前长边缩放到的目标像素
+	DefaultMaxEdge = 1568
+	// DefaultJPEGQuality 重新编码时的 JPEG 质量
+	DefaultJPEGQuality = 85
+)
+
+var (
+	// ErrUnsupportedFormat 图片格式不支持，例如 HEIC（没有纯 Go 的解码器）
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	// ErrTooManyPixels 图片像素数超过上限
+	ErrTooManyPixels = errors.New("image has too many pixels")
+)
+
+// 可以直接发送给模型的格式，WebP 会转为 JPEG
+var preparedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// 能识别但无法解码的格式，提示用户转换后再上传
+var undecodableTypes = map[string]bool{
+	"image/heic":          true,
+	"image/heic-sequence": true,
+	"image/heif":          true,
+	"image/heif-sequence": true,
+	"image/avif":          true,
+}
+
+// PrepareOptions 图片预处理参数，零值使用默认值
+type PrepareOptions struct {
+	MaxPixels     int64 // 宽 × 高 的上限
+	MaxEdge       int   // 长边超过时等比缩小
+	JPEGQuality   int
+	StripMetadata bool // 去除 EXIF（含 GPS）、XMP 等元数据
+}
+
+// PreparedImage 预处理后的图片
+type PreparedImage struct {
+	Data     []byte
+	MIMEType string
+	Width    int
+	Height   int
+}
+
+func (o PrepareOptions) withDefaults() PrepareOptions {
+	if o.MaxPixels <= 0 {
+		o.MaxPixels = DefaultMaxPixels
+	}
+	if o.MaxEdge <= 0 {
+		o.MaxEdge = DefaultMaxEdge
+	}
+	if o.JPEGQuality <= 0 || o.JPEGQuality > 100 {
+		o.JPEGQuality = DefaultJPEGQuality
+	}
+	return o
+}
+
+// Prepare 按内容识别格式并检查像素数，再按 EXIF 方向摆正、缩小到目标尺寸
+// 不需要处理的图片原样返回（按需去除元数据）；重新编码的图片不保留任何元数据
+func Prepare(buf []byte, opts PrepareOptions) (*PreparedImage, error) {
+	opts = opts.withDefaults()
+	mimeType, cfg, err := inspect(buf, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := 1
+	if mimeType == "image/jpeg" {
+		orientation = jpegOrientation(buf)
+	}
+	if orientation == 1 && max(cfg.Width, cfg.Height) <= opts.MaxEdge && mimeType != "image/webp" {
+		prepared := &PreparedImage{Data: buf, MIMEType: mimeType, Width: cfg.Width, Height: cfg.Height}
+		if !opts.StripMetadata {
+			return prepared, nil
+		}
+		stripped, ok := buf, true
+		switch mimeType {
+		case "image/jpeg":
+			stripped, ok = stripJPEGMetadata(buf)
+		case "image/png":
+			stripped, ok = stripPNGMetadata(buf)
+		}
+		if ok {
+			prepared.Data = stripped
+			return prepared, nil
+		}
+		// 结构异常时重新编码，保证元数据被去除
+	}
+	return reencode(buf, mimeType, orientation, opts)
+}
+
+// decodeImage 与 Prepare 做相同的检查，返回摆正、缩小后的图片，不重新编码
+func decodeImage(buf []byte, opts PrepareOptions) (image.Image, error) {
+	opts = opts.withDefaults()
+	mimeType, _, err := inspect(buf, opts)
+	if err != nil {
+		return nil, err
+	}
+	orientation := 1
+	if mimeType == "image/jpeg" {
+		orientation = jpegOrientation(buf)
+	}
+	img, _, err := image.Decode(bytes.NewReader(buf))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	return applyOrientation(fitLongEdge(img, opts.MaxEdge), orientation), nil
+}
+
+// inspect 按内容识别格式，并在完整解码前检查尺寸
+func inspect(buf []byte, opts PrepareOptions) (string, image.Config, error) {
+	mimeType := mimetype.Detect(buf).String()
+	if undecodableTypes[mimeType] {
+		return "", image.Config{}, fmt.Errorf("%w: %s, convert it to jpeg first", ErrUnsupportedFormat, mimeType)
+	}
+	if !preparedTypes[mimeType] {
+		// 不是图片的内容视为无法识别，BMP、TIFF 等其他图片格式视为不支持
+		if !strings.HasPrefix(mimeType, "image/") {
+			return "", image.Config{}, fmt.Errorf("%w: %s", ErrInvalidImage, mimeType)
+		}
+		return "", image.Config{}, fmt.Errorf("%w: %s", ErrUnsupportedFormat, mimeType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(buf))
+	if err != nil {
+		return "", image.Config{}, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return "", image.Config{}, fmt.Errorf("%w: empty image", ErrInvalidImage)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > opts.MaxPixels {
+		return "", image.Config{}, fmt.Errorf("%w: %dx%d", ErrTooManyPixels, cfg.Width, cfg.Height)
+	}
+	return mimeType, cfg, nil
+}
+
+// reencode 解码后缩放、摆正，PNG/GIF 与带透明通道的图片输出 PNG，其余输出 JPEG
+func reencode(buf []byte, mimeType string, orientation int, opts PrepareOptions) (*PreparedImage, error) {
+	img, _, err := image.Decode(bytes.NewReader(buf))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	img = applyOrientation(fitLongEdge(img, opts.MaxEdge), orientation)
+
+	var out bytes.Buffer
+	outType := "image/jpeg"
+	if mimeType == "image/png" || mimeType == "image/gif" || !isOpaque(img) {
+		outType = "image/png"
+		err = png.Encode(&out, img)
+	} else {
+		err = jpeg.Encode(&out, img, &jpeg.Options{Quality: opts.JPEGQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	return &PreparedImage{Data: out.Bytes(), MIMEType: outType, Width: b.Dx(), Height: b.Dy()}, nil
+}
+
+// fitLongEdge 长边超过 maxEdge 时等比缩小
+func fitLongEdge(img image.Image, maxEdge int) image.Image {
+	b := img.Bounds()
+	long := max(b.Dx(), b.Dy())
+	if long <= maxEdge {
+		return img
+	}
+	scale := float64(maxEdge) / float64(long)
+	w := max(1, int(math.Round(float64(b.Dx())*scale)))
+	h := max(1, int(math.Round(float64(b.Dy())*scale)))
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package image
+
+import (
+	"context"
+	"hash/fnv"
+)
+
+// 未指定标签时 StubRecognizer 使用的标签
+var defaultStubLabels = []string{"风景", "建筑", "美食", "人物", "动物"}
+
+// StubRecognizer 确定性的假识别器：同样的图片总是得到同样的标签，不访问网络和模型
+// 图片仍经过与其他后端相同的预处理校验，便于测试错误处理
+type StubRecognizer struct {
+	labels []string
+	opts   PrepareOptions
+}
+
+func NewStubRecognizer(labels []string, opts PrepareOptions) *StubRecognizer {
+	if len(labels) == 0 {
+		labels = defaultStubLabels
+	}
+	return &StubRecognizer{labels: labels, opts: opts}
+}
+
+func (r *StubRecognizer) Name() string {
+	return BackendStub
+}
+
+func (r *StubRecognizer) Close() {}
+
+func (r *StubRecognizer) PredictFromBuffer(_ context.Context, buf []byte) (string, error) {
+	return r.label(buf)
+}
+
+func (r *StubRecognizer) Analyze(_ context.Context, buf []byte, opts AnalyzeOptions) (*ImageAnalysis, error) {
+	if len(opts.Schema) > 0 {
+		return nil, ErrNotSupported
+	}
+	label, err := r.label(buf)
+	if err != nil {
+		return nil, err
+	}
+	return &ImageAnalysis{
+		Caption: label,
+		Objects: []DetectedObject{{Name: label, Confidence: 1}},
+	}, nil
+}
+
+// label 按图片内容的哈希选择标签
+func (r *StubRecognizer) label(buf []byte) (string, error) {
+	if _, err := Prepare(buf, r.opts); err != nil {
+		return "", err
+	}
+	h := fnv.New32a()
+	h.Write(buf)
+	return r.labels[h.Sum32()%uint32(len(r.labels))], nil
+}
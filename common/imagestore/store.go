@@ -0,0 +1,163 @@
+package imagestore
+
+import (
+	"GopherAI/config"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 未配置 [imageAIConfig] storageDir 时的保存目录
+const defaultDir = "data/images"
+
+var (
+	// ErrNotFound 图片不存在
+	ErrNotFound = errors.New("image not found")
+	// ErrInvalidKey 图片引用格式不正确
+	ErrInvalidKey = errors.New("invalid image key")
+)
+
+// key 的格式为 <owner>/<sha256>.<ext>，owner 为上传用户的账号
+var keyPattern = regexp.MustCompile(`^[0-9A-Za-z_-]{1,64}/[0-9a-f]{64}\.(jpg|png|gif|webp)$`)
+
+var extensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// Store 聊天图片存储，消息中只保存 Put 返回的 key
+type Store interface {
+	Put(owner string, data []byte, mimeType string) (string, error)
+	Get(key string) ([]byte, error)
+}
+
+// FileStore 把图片保存在本地目录，相同用户的相同图片只保存一份
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) *FileStore {
+	if strings.TrimSpace(dir) == "" {
+		dir = defaultDir
+	}
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) Put(owner string, data []byte, mimeType string) (string, error) {
+	ext, ok := extensions[mimeType]
+	if !ok {
+		return "", fmt.Errorf("unsupported image type: %s", mimeType)
+	}
+	sum := sha256.Sum256(data)
+	key := owner + "/" + hex.EncodeToString(sum[:]) + "." + ext
+	if !ValidKey(key) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidKey, key)
+	}
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if _, err := os.Stat(path); err == nil {
+		return key, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	// 先写临时文件再重命名，读取方不会看到写了一半的图片
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (s *FileStore) Get(key string) ([]byte, error) {
+	if !ValidKey(key) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, key)
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return data, err
+}
+
+// ValidKey key 是否为 Put 生成的格式，同时保证不会访问存储目录之外的文件
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// Owner 返回 key 所属的用户账号
+func Owner(key string) string {
+	owner, _, _ := strings.Cut(key, "/")
+	return owner
+}
+
+// MIMEType 按 key 的扩展名返回图片格式
+func MIMEType(key string) string {
+	ext := strings.TrimPrefix(filepath.Ext(key), ".")
+	for mimeType, e := range extensions {
+		if e == ext {
+			return mimeType
+		}
+	}
+	return "application/octet-stream"
+}
+
+var (
+	defaultStore Store
+	storeMu      sync.RWMutex
+)
+
+// Init 按配置创建全局图片存储
+func Init() {
+	SetDefault(NewFileStore(config.GetConfig().ImageAIConfig.StorageDir))
+}
+
+// SetDefault 替换全局图片存储
+func SetDefault(store Store) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	defaultStore = store
+}
+
+// Default 获取全局图片存储
+func Default() Store {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return defaultStore
+}
+
+// Put 通过全局图片存储保存图片
+func Put(owner string, data []byte, mimeType string) (string, error) {
+	store := Default()
+	if store == nil {
+		return "", errors.New("image store is not initialized")
+	}
+	return store.Put(owner, data, mimeType)
+}
+
+// Get 通过全局图片存储读取图片
+func Get(key string) ([]byte, error) {
+	store := Default()
+	if store == nil {
+		return nil, errors.New("image store is not initialized")
+	}
+	return store.Get(key)
+}
@@ -0,0 +1,243 @@
+package messagestore
+
+import (
+	"GopherAI/dao/message"
+	"GopherAI/model"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQueueSize     = 1024
+	defaultBatchSize     = 50
+	defaultFlushInterval = 200 * time.Millisecond
+	defaultMaxRetry      = 3
+
+	// 未配置 deadLetterFile 时的死信文件
+	defaultDeadLetterFile = "data/messages.deadletter.jsonl"
+)
+
+// BatchWriter 批量写入函数，默认写入 MySQL
+type BatchWriter func(msgs []*model.Message) error
+
+// BatchOptions 批量写入配置
+type BatchOptions struct {
+	QueueSize      int           // 内存队列容量
+	BatchSize      int           // 每批最多写入的消息数
+	FlushInterval  time.Duration // 未攒满一批时的最长等待时间
+	EnqueueTimeout time.Duration // 队列满时 Save 的最长等待时间，0 表示立即返回 ErrQueueFull
+	MaxRetry       int           // 批量写入失败的重试次数
+	DeadLetterFile string        // 逐条写入仍失败的消息追加到该文件（JSON Lines），为空时只记录日志
+}
+
+// 写入死信文件的记录，MessageKey 不参与 JSON 序列化，这里单独保存以便重放时保持幂等
+type deadLetter struct {
+	MessageKey string         `json:"messageKey"`
+	Message    *model.Message `json:"message"`
+	Error      string         `json:"error"`
+	FailedAt   time.Time      `json:"failedAt"`
+}
+
+// BatchStore 进程内有界队列 + 后台批量写入
+// 消息按入队顺序写入；整批写入失败时改为逐条写入，仍失败的消息写入死信文件
+// 进程崩溃时队列中尚未写入的消息会丢失
+type BatchStore struct {
+	write   BatchWriter
+	opts    BatchOptions
+	queue   chan *model.Message
+	metrics *Metrics
+
+	closeOnce sync.Once
+	mu        sync.RWMutex
+	closed    bool
+	done      chan struct{}
+}
+
+// NewBatchStore 创建批量写入存储，write 为空时写入 MySQL
+func NewBatchStore(write BatchWriter, opts BatchOptions) *BatchStore {
+	if write == nil {
+		write = message.CreateMessagesIdempotent
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.MaxRetry < 0 {
+		opts.MaxRetry = 0
+	} else if opts.MaxRetry == 0 {
+		opts.MaxRetry = defaultMaxRetry
+	}
+	s := &BatchStore{
+		write:   write,
+		opts:    opts,
+		queue:   make(chan *model.Message, opts.QueueSize),
+		metrics: &Metrics{},
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *BatchStore) reportMetrics() *Metrics {
+	return s.metrics
+}
+
+func (s *BatchStore) Save(msg *model.Message) error {
+	// 读锁保证 Close 关闭队列时没有正在进行的入队
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrClosed
+	}
+
+	// 入队前先计数，否则后台可能在计数前就写完并扣减，pending 短暂为负
+	s.metrics.pending.Add(1)
+	select {
+	case s.queue <- msg:
+		return nil
+	default:
+	}
+	if s.opts.EnqueueTimeout <= 0 {
+		s.metrics.pending.Add(-1)
+		return ErrQueueFull
+	}
+
+	timer := time.NewTimer(s.opts.EnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case s.queue <- msg:
+		return nil
+	case <-timer.C:
+		s.metrics.pending.Add(-1)
+		return ErrQueueFull
+	}
+}
+
+// Close 停止接受新消息，等待队列中的消息全部写完
+func (s *BatchStore) Close() error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		close(s.queue)
+		s.mu.Unlock()
+	})
+	<-s.done
+	return nil
+}
+
+func (s *BatchStore) Name() string {
+	return BackendBatch
+}
+
+func (s *BatchStore) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*model.Message, 0, s.opts.BatchSize)
+	for {
+		select {
+		case msg, ok := <-s.queue:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+			batch = append(batch, msg)
+			if len(batch) >= s.opts.BatchSize {
+				s.flush(batch)
+				batch = make([]*model.Message, 0, s.opts.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				s.flush(batch)
+				batch = make([]*model.Message, 0, s.opts.BatchSize)
+			}
+		}
+	}
+}
+
+func (s *BatchStore) flush(batch []*model.Message) {
+	if len(batch) == 0 {
+		return
+	}
+	defer s.metrics.pending.Add(-int64(len(batch)))
+
+	var err error
+	for attempt := 0; attempt <= s.opts.MaxRetry; attempt++ {
+		if attempt > 0 {
+			time.Sleep(s.opts.FlushInterval * time.Duration(attempt))
+		}
+		if err = s.write(batch); err == nil {
+			s.metrics.persisted.Add(int64(len(batch)))
+			return
+		}
+		log.Printf("batch message store write %d messages failed (attempt %d/%d): %v", len(batch), attempt+1, s.opts.MaxRetry+1, err)
+	}
+
+	// 整批失败时逐条写入，只有本身写不进去的消息才算失败
+	failed := make([]*model.Message, 0)
+	for _, msg := range batch {
+		if err := s.write([]*model.Message{msg}); err != nil {
+			log.Printf("batch message store write message %s of session %s failed: %v", msg.MessageKey, msg.SessionID, err)
+			failed = append(failed, msg)
+			continue
+		}
+		s.metrics.persisted.Add(1)
+	}
+	if len(failed) == 0 {
+		return
+	}
+
+	s.metrics.writeFailed.Add(int64(len(failed)))
+	s.metrics.recordError(err)
+	if s.opts.DeadLetterFile == "" {
+		for _, msg := range failed {
+			log.Printf("batch message store dropped message %s of session %s: %v", msg.MessageKey, msg.SessionID, err)
+		}
+		return
+	}
+	if spillErr := s.spill(failed, err); spillErr != nil {
+		log.Printf("batch message store write dead letter file %s failed: %v", s.opts.DeadLetterFile, spillErr)
+		for _, msg := range failed {
+			log.Printf("batch message store dropped message %s of session %s: %v", msg.MessageKey, msg.SessionID, err)
+		}
+		return
+	}
+	log.Printf("batch message store spilled %d messages to %s", len(failed), s.opts.DeadLetterFile)
+}
+
+// spill 把写入失败的消息追加到死信文件，之后可以按 messageKey 幂等地重新导入
+func (s *BatchStore) spill(msgs []*model.Message, cause error) error {
+	if err := os.MkdirAll(filepath.Dir(s.opts.DeadLetterFile), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.opts.DeadLetterFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	now := time.Now()
+	for _, msg := range msgs {
+		if err := enc.Encode(deadLetter{
+			MessageKey: msg.MessageKey,
+			Message:    msg,
+			Error:      cause.Error(),
+			FailedAt:   now,
+		}); err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
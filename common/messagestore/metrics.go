@@ -0,0 +1,108 @@
+package messagestore
+
+import (
+	"GopherAI/model"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics 所有后端共用的一组指标
+type Metrics struct {
+	accepted     atomic.Int64 // Save 成功的消息数
+	rejected     atomic.Int64 // Save 失败的消息数
+	persisted    atomic.Int64 // 确认写入数据库的消息数（rabbitmq 后端由消费端写入，这里不统计）
+	writeFailed  atomic.Int64 // 已接受但最终写入失败的消息数
+	pending      atomic.Int64 // 已接受、尚未写入的消息数
+	saveNanos    atomic.Int64 // Save 累计耗时
+	lastErrorMsg atomic.Value
+}
+
+// MetricsSnapshot 指标快照
+type MetricsSnapshot struct {
+	Backend       string  `json:"backend"`
+	Accepted      int64   `json:"accepted"`
+	Rejected      int64   `json:"rejected"`
+	Persisted     int64   `json:"persisted"`
+	WriteFailed   int64   `json:"writeFailed"`
+	Pending       int64   `json:"pending"`
+	AvgSaveMillis float64 `json:"avgSaveMillis"`
+	LastError     string  `json:"lastError,omitempty"`
+}
+
+func (m *Metrics) recordError(err error) {
+	m.lastErrorMsg.Store(err.Error())
+}
+
+func (m *Metrics) snapshot(backend string) MetricsSnapshot {
+	s := MetricsSnapshot{
+		Backend:     backend,
+		Accepted:    m.accepted.Load(),
+		Rejected:    m.rejected.Load(),
+		Persisted:   m.persisted.Load(),
+		WriteFailed: m.writeFailed.Load(),
+		Pending:     m.pending.Load(),
+	}
+	if total := s.Accepted + s.Rejected; total > 0 {
+		s.AvgSaveMillis = float64(m.saveNanos.Load()) / float64(total) / float64(time.Millisecond)
+	}
+	if v, ok := m.lastErrorMsg.Load().(string); ok {
+		s.LastError = v
+	}
+	return s
+}
+
+// metricsReporter 由需要上报写入结果的后端实现，包装时沿用后端自己的指标
+type metricsReporter interface {
+	reportMetrics() *Metrics
+}
+
+// InstrumentedStore 为任意后端统一统计指标并包装错误
+type InstrumentedStore struct {
+	inner   MessageStore
+	metrics *Metrics
+}
+
+// Instrument 包装后端，统一指标与错误格式
+func Instrument(store MessageStore) *InstrumentedStore {
+	s := &InstrumentedStore{inner: store, metrics: &Metrics{}}
+	if r, ok := store.(metricsReporter); ok {
+		s.metrics = r.reportMetrics()
+	}
+	return s
+}
+
+func (s *InstrumentedStore) Save(msg *model.Message) error {
+	start := time.Now()
+	err := s.inner.Save(msg)
+	s.metrics.saveNanos.Add(int64(time.Since(start)))
+	if err != nil {
+		s.metrics.rejected.Add(1)
+		err = fmt.Errorf("%s message store: %w", s.inner.Name(), err)
+		s.metrics.recordError(err)
+		return err
+	}
+	s.metrics.accepted.Add(1)
+	return nil
+}
+
+func (s *InstrumentedStore) Close() error {
+	return s.inner.Close()
+}
+
+func (s *InstrumentedStore) Name() string {
+	return s.inner.Name()
+}
+
+// Stats 获取指标快照
+func (s *InstrumentedStore) Stats() MetricsSnapshot {
+	return s.metrics.snapshot(s.inner.Name())
+}
+
+// Stats 获取全局消息存储的指标
+func Stats() MetricsSnapshot {
+	if s, ok := Default().(*InstrumentedStore); ok {
+		return s.Stats()
+	}
+	return MetricsSnapshot{}
+}
@@ -0,0 +1,37 @@
+package messagestore
+
+import (
+	"GopherAI/common/rabbitmq"
+	"GopherAI/model"
+	"errors"
+	"sync/atomic"
+)
+
+// RabbitMQStore 发布到 RabbitMQ，由消费者异步写入 MySQL
+type RabbitMQStore struct {
+	closed atomic.Bool
+}
+
+func NewRabbitMQStore() *RabbitMQStore {
+	return &RabbitMQStore{}
+}
+
+func (s *RabbitMQStore) Save(msg *model.Message) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	if rabbitmq.RMQMessage == nil {
+		return errors.New("rabbitmq is not initialized")
+	}
+	data := rabbitmq.GenerateMessageMQParam(msg)
+	return rabbitmq.RMQMessage.PublishWithID(msg.MessageKey, data)
+}
+
+func (s *RabbitMQStore) Close() error {
+	s.closed.Store(true)
+	return nil
+}
+
+func (s *RabbitMQStore) Name() string {
+	return BackendRabbitMQ
+}
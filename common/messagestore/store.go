@@ -0,0 +1,106 @@
+package messagestore
+
+import (
+	"GopherAI/config"
+	"GopherAI/model"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 支持的持久化后端
+const (
+	BackendSync     = "sync"
+	BackendRabbitMQ = "rabbitmq"
+	BackendBatch    = "batch"
+)
+
+var (
+	// ErrQueueFull 批量写入的内存队列已满，消息未被接受
+	ErrQueueFull = errors.New("message store queue is full")
+	// ErrClosed 存储已关闭，不再接受新消息
+	ErrClosed = errors.New("message store is closed")
+)
+
+// MessageStore 消息持久化后端
+// Save 返回 nil 表示消息已被后端接受：同步后端已写入数据库，异步后端已进入队列并由后端保证最终写入
+// Save 返回错误表示消息未被接受，调用方不应把消息视为已保存
+type MessageStore interface {
+	Save(msg *model.Message) error
+	// Close 停止接受新消息，并尽量把已接受的消息写完
+	Close() error
+	Name() string
+}
+
+var (
+	defaultStore MessageStore
+	storeMu      sync.RWMutex
+)
+
+// Init 按配置创建全局消息存储
+func Init() error {
+	store, err := New(config.GetConfig().MessageStoreConfig)
+	if err != nil {
+		return err
+	}
+	SetDefault(store)
+	return nil
+}
+
+// New 按配置创建消息存储，所有后端都带统一的指标统计
+func New(conf config.MessageStoreConfig) (MessageStore, error) {
+	switch conf.Backend {
+	case BackendSync:
+		return Instrument(NewSyncStore()), nil
+	case "", BackendRabbitMQ:
+		return Instrument(NewRabbitMQStore()), nil
+	case BackendBatch:
+		deadLetterFile := conf.DeadLetterFile
+		if deadLetterFile == "" {
+			deadLetterFile = defaultDeadLetterFile
+		}
+		return Instrument(NewBatchStore(nil, BatchOptions{
+			QueueSize:      conf.QueueSize,
+			BatchSize:      conf.BatchSize,
+			FlushInterval:  time.Duration(conf.FlushInterval) * time.Millisecond,
+			EnqueueTimeout: time.Duration(conf.EnqueueTimeout) * time.Millisecond,
+			MaxRetry:       conf.MaxRetry,
+			DeadLetterFile: deadLetterFile,
+		})), nil
+	default:
+		return nil, fmt.Errorf("unknown message store backend: %s", conf.Backend)
+	}
+}
+
+// SetDefault 替换全局消息存储
+func SetDefault(store MessageStore) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	defaultStore = store
+}
+
+// Default 获取全局消息存储
+func Default() MessageStore {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return defaultStore
+}
+
+// Save 通过全局消息存储保存消息
+func Save(msg *model.Message) (*model.Message, error) {
+	store := Default()
+	if store == nil {
+		return msg, errors.New("message store is not initialized")
+	}
+	return msg, store.Save(msg)
+}
+
+// Close 关闭全局消息存储
+func Close() error {
+	store := Default()
+	if store == nil {
+		return nil
+	}
+	return store.Close()
+}
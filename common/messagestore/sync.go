@@ -0,0 +1,41 @@
+package messagestore
+
+import (
+	"GopherAI/dao/message"
+	"GopherAI/model"
+	"sync/atomic"
+)
+
+// SyncStore 直接同步写入 MySQL，适合本地开发与测试
+type SyncStore struct {
+	closed  atomic.Bool
+	metrics *Metrics
+}
+
+func NewSyncStore() *SyncStore {
+	return &SyncStore{metrics: &Metrics{}}
+}
+
+func (s *SyncStore) reportMetrics() *Metrics {
+	return s.metrics
+}
+
+func (s *SyncStore) Save(msg *model.Message) error {
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	if _, err := message.CreateMessageIdempotent(msg); err != nil {
+		return err
+	}
+	s.metrics.persisted.Add(1)
+	return nil
+}
+
+func (s *SyncStore) Close() error {
+	s.closed.Store(true)
+	return nil
+}
+
+func (s *SyncStore) Name() string {
+	return BackendSync
+}
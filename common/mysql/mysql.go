@@ -65,6 +65,11 @@ func migration() error {
 		new(model.User),
 		new(model.Session),
 		new(model.Message),
+		new(model.ToolPreference),
+		new(model.KnowledgeBase),
+		new(model.KnowledgeBaseShare),
+		new(model.AuditLog),
+		new(model.ModelProfile),
 	)
 }
 
@@ -78,3 +83,65 @@ func GetUserByUsername(username string) (*model.User, error) {
 	err := DB.Where("username = ?", username).First(user).Error
 	return user, err
 }
+
+// GetUsersByEmail 旧版本注册时没有检查邮箱是否重复，同一邮箱可能对应多个账号
+func GetUsersByEmail(email string) ([]*model.User, error) {
+	var users []*model.User
+	err := DB.Where("email = ?", email).Order("id").Find(&users).Error
+	return users, err
+}
+
+// UsernameExists 包括已软删除的用户，与唯一索引保持一致
+func UsernameExists(username string) (bool, error) {
+	var count int64
+	err := DB.Unscoped().Model(new(model.User)).Where("username = ?", username).Count(&count).Error
+	return count > 0, err
+}
+
+func UpdateUserPassword(id int64, password string) error {
+	return DB.Model(new(model.User)).Where("id = ?", id).Update("password", password).Error
+}
+
+func GetUserByID(id int64) (*model.User, error) {
+	user := new(model.User)
+	err := DB.Where("id = ?", id).First(user).Error
+	return user, err
+}
+
+// ListUsers 按账号或邮箱模糊搜索，keyword 为空时返回全部
+func ListUsers(keyword string, offset, limit int) ([]*model.User, int64, error) {
+	var users []*model.User
+	var total int64
+	query := DB.Model(new(model.User))
+	if keyword != "" {
+		like := "%" + keyword + "%"
+		query = query.Where("username LIKE ? OR email LIKE ?", like, like)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id").Offset(offset).Limit(limit).Find(&users).Error
+	return users, total, err
+}
+
+func UpdateUserRole(id int64, role string) error {
+	return DB.Model(new(model.User)).Where("id = ?", id).Update("role", role).Error
+}
+
+func UpdateUserDisabled(id int64, disabled bool) error {
+	return DB.Model(new(model.User)).Where("id = ?", id).Update("disabled", disabled).Error
+}
+
+// DeleteUser 软删除，账号不会被重新分配
+func DeleteUser(id int64) error {
+	return DB.Where("id = ?", id).Delete(new(model.User)).Error
+}
+
+// UpdateRoleByUsernames 把这些账号设置为指定角色，返回实际修改的数量
+func UpdateRoleByUsernames(usernames []string, role string) (int64, error) {
+	if len(usernames) == 0 {
+		return 0, nil
+	}
+	res := DB.Model(new(model.User)).Where("username IN ? AND role <> ?", usernames, role).Update("role", role)
+	return res.RowsAffected, res.Error
+}
@@ -1,5 +1,9 @@
 package rabbitmq
 
+// MessageQueue 消息落库队列
+// 旧版本声明的 Message 队列是非持久化的，参数不同无法重新声明，因此改用新的队列名
+const MessageQueue = "Message.durable"
+
 var (
 
 	RMQMessage *RabbitMQ
@@ -10,7 +14,7 @@ func InitRabbitMQ() {
 	// 无论调用多少次 NewWorkRabbitMQ，只会创建一次连接
 	// 不同队列共用一个连接，可以保持不同队列消费消息的顺序
 
-	RMQMessage = NewWorkRabbitMQ("Message")
+	RMQMessage = NewWorkRabbitMQ(MessageQueue)
 	go RMQMessage.Consume(MQMessage)
 
 }
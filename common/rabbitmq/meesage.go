@@ -4,23 +4,40 @@ import (
 	"GopherAI/dao/message"
 	"GopherAI/model"
 	"encoding/json"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
 type MessageMQParam struct {
-	SessionID string `json:"session_id"`
-	Content   string `json:"content"`
-	UserName  string `json:"user_name"`
-	IsUser    bool   `json:"is_user"`
+	MessageKey string                  `json:"message_key"`
+	SessionID  string                  `json:"session_id"`
+	Content    string                  `json:"content"`
+	UserName   string                  `json:"user_name"`
+	IsUser     bool                    `json:"is_user"`
+	Role       string                  `json:"role"`
+	ToolCalls  []model.MessageToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string                  `json:"tool_call_id,omitempty"`
+	ToolName   string                  `json:"tool_name,omitempty"`
+	Citations  []model.MessageCitation `json:"citations,omitempty"`
+	Images     []model.MessageImage    `json:"images,omitempty"`
+	CreatedAt  time.Time               `json:"created_at"`
 }
 
-func GenerateMessageMQParam(sessionID string, content string, userName string, IsUser bool) []byte {
+func GenerateMessageMQParam(msg *model.Message) []byte {
 	param := MessageMQParam{
-		SessionID: sessionID,
-		Content:   content,
-		UserName:  userName,
-		IsUser:    IsUser,
+		MessageKey: msg.MessageKey,
+		SessionID:  msg.SessionID,
+		Content:    msg.Content,
+		UserName:   msg.UserName,
+		IsUser:     msg.IsUser,
+		Role:       msg.Role,
+		ToolCalls:  msg.ToolCalls,
+		ToolCallID: msg.ToolCallID,
+		ToolName:   msg.ToolName,
+		Citations:  msg.Citations,
+		Images:     msg.Images,
+		CreatedAt:  msg.CreatedAt,
 	}
 	data, _ := json.Marshal(param)
 	return data
@@ -33,12 +50,23 @@ func MQMessage(msg *amqp.Delivery) error {
 		return err
 	}
 	newMsg := &model.Message{
-		SessionID: param.SessionID,
-		Content:   param.Content,
-		UserName:  param.UserName,
-		IsUser:    param.IsUser,
+		MessageKey: param.MessageKey,
+		SessionID:  param.SessionID,
+		Content:    param.Content,
+		UserName:   param.UserName,
+		IsUser:     param.IsUser,
+		Role:       param.Role,
+		ToolCalls:  param.ToolCalls,
+		ToolCallID: param.ToolCallID,
+		ToolName:   param.ToolName,
+		Citations:  param.Citations,
+		Images:     param.Images,
+		CreatedAt:  param.CreatedAt,
 	}
-	//消费者异步插入到数据库中
-	message.CreateMessage(newMsg)
-	return nil
+	if newMsg.MessageKey == "" {
+		newMsg.MessageKey = msg.MessageId
+	}
+	//消费者异步插入到数据库中，按 MessageKey 去重，重复投递不会产生重复记录
+	_, err = message.CreateMessageIdempotent(newMsg)
+	return err
 }
@@ -2,12 +2,22 @@ package rabbitmq
 
 import (
 	"GopherAI/config"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
+const (
+	defaultMaxRetry       = 3
+	defaultRetryInterval  = 500 * time.Millisecond
+	defaultConfirmTimeout = 5 * time.Second
+	confirmBuffer         = 64
+)
+
 // 全局connection对象
 // 所有RabbitMQ都会复用该对象
 var conn *amqp.Connection
@@ -19,7 +29,7 @@ func initConn() {
 		"amqp://%s:%s@%s:%d/%s",
 		c.RabbitmqUsername, c.RabbitmqPassword, c.RabbitmqHost, c.RabbitmqPort, c.RabbitmqVhost,
 	)
-	log.Println("mqUrl is  " + mqUrl)
+	log.Printf("connecting to RabbitMQ at %s:%d%s", c.RabbitmqHost, c.RabbitmqPort, c.RabbitmqVhost)
 	var err error
 	conn, err = amqp.Dial(mqUrl)
 	if err != nil {
@@ -33,15 +43,47 @@ type RabbitMQ struct {
 	channel  *amqp.Channel
 	Exchange string
 	Key      string
+
+	// 发布使用 confirm 模式，同一 channel 上的发布需要串行，才能与确认一一对应
+	publishMu sync.Mutex
+	confirms  chan amqp.Confirmation
+	// 已发布的消息数，即最后一条消息的 DeliveryTag，confirm 模式下从 1 开始逐条递增
+	published uint64
+	// 消费单独使用一个 channel，避免与发布确认互相影响
+	consumeChannel *amqp.Channel
+
+	maxRetry       int
+	retryInterval  time.Duration
+	confirmTimeout time.Duration
 }
 
 // NewRabbitMQ 创建RabbitMQ对象
 func NewRabbitMQ(exchange string, key string) *RabbitMQ {
-	return &RabbitMQ{Exchange: exchange, Key: key}
+	c := config.GetConfig()
+	r := &RabbitMQ{
+		Exchange:       exchange,
+		Key:            key,
+		maxRetry:       c.RabbitmqMaxRetry,
+		retryInterval:  time.Duration(c.RabbitmqRetryInterval) * time.Millisecond,
+		confirmTimeout: time.Duration(c.RabbitmqConfirmTimeout) * time.Millisecond,
+	}
+	if r.maxRetry <= 0 {
+		r.maxRetry = defaultMaxRetry
+	}
+	if r.retryInterval <= 0 {
+		r.retryInterval = defaultRetryInterval
+	}
+	if r.confirmTimeout <= 0 {
+		r.confirmTimeout = defaultConfirmTimeout
+	}
+	return r
 }
 
 // Destroy 断开 channel 和 connection
 func (r *RabbitMQ) Destroy() {
+	if r.consumeChannel != nil {
+		_ = r.consumeChannel.Close()
+	}
 	_ = r.channel.Close()
 	_ = r.conn.Close()
 }
@@ -64,46 +106,142 @@ func NewWorkRabbitMQ(queue string) *RabbitMQ {
 		panic(err.Error())
 	}
 
+	// 队列只在创建时声明一次
+	if err := rabbitmq.declareQueue(rabbitmq.channel); err != nil {
+		panic(err.Error())
+	}
+
+	// 开启发布确认，消息落到 broker 后才算发送成功
+	if err := rabbitmq.channel.Confirm(false); err != nil {
+		panic(err.Error())
+	}
+	// 超时后晚到的确认会留在缓冲中，缓冲写满会阻塞整个连接的读取，因此留出余量
+	rabbitmq.confirms = rabbitmq.channel.NotifyPublish(make(chan amqp.Confirmation, confirmBuffer))
+
 	return rabbitmq
 }
 
-// Publish 发送消息
-func (r *RabbitMQ) Publish(message []byte) error {
-	// 创建队列（不存在时）
-	// 使用默认交换机的情况下，queue即为key
-	_, err := r.channel.QueueDeclare(r.Key, false, false, false, false, nil)
-	if err != nil {
+// DeadLetterQueue 死信队列名称
+func (r *RabbitMQ) DeadLetterQueue() string {
+	return r.Key + ".dlq"
+}
+
+// 声明持久化队列及其死信队列
+// 使用默认交换机的情况下，queue即为key
+func (r *RabbitMQ) declareQueue(ch *amqp.Channel) error {
+	if _, err := ch.QueueDeclare(r.DeadLetterQueue(), true, false, false, false, nil); err != nil {
 		return err
 	}
+	_, err := ch.QueueDeclare(r.Key, true, false, false, false, amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": r.DeadLetterQueue(),
+	})
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp.PreconditionFailed {
+		return fmt.Errorf("queue %s already exists with different arguments, delete it and restart: %w", r.Key, err)
+	}
+	return err
+}
+
+// Publish 发送消息，等待 broker 确认后返回
+func (r *RabbitMQ) Publish(message []byte) error {
+	return r.PublishWithID("", message)
+}
+
+// PublishWithID 发送带消息ID的持久化消息，消息ID用于消费端幂等
+func (r *RabbitMQ) PublishWithID(messageID string, message []byte) error {
+	r.publishMu.Lock()
+	defer r.publishMu.Unlock()
 
 	// 调用 channel 发送消息到队列
-	return r.channel.Publish(r.Exchange, r.Key, false, false,
+	err := r.channel.Publish(r.Exchange, r.Key, false, false,
 		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        message,
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    messageID,
+			Timestamp:    time.Now(),
+			Body:         message,
 		},
 	)
+	if err != nil {
+		return err
+	}
+	r.published++
+	tag := r.published
+
+	// 之前超时的消息的确认可能晚到，按 DeliveryTag 丢弃不属于本条消息的确认
+	timeout := time.NewTimer(r.confirmTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case confirm, ok := <-r.confirms:
+			if !ok {
+				return errors.New("rabbitmq channel closed before publish was confirmed")
+			}
+			if confirm.DeliveryTag < tag {
+				log.Printf("rabbitmq discard late confirmation of message %d (ack=%v)", confirm.DeliveryTag, confirm.Ack)
+				continue
+			}
+			if confirm.DeliveryTag != tag {
+				return fmt.Errorf("rabbitmq unexpected confirmation %d, want %d", confirm.DeliveryTag, tag)
+			}
+			if !confirm.Ack {
+				return fmt.Errorf("rabbitmq nacked message %d", confirm.DeliveryTag)
+			}
+			return nil
+		case <-timeout.C:
+			return fmt.Errorf("rabbitmq publish confirm timeout after %s", r.confirmTimeout)
+		}
+	}
 }
 
 // Consume 消费者
 // handle: 消息的消费业务函数，用于消费消息
+// 消息处理成功后才 ack；失败时按配置重试，重试耗尽后投递到死信队列
 func (r *RabbitMQ) Consume(handle func(msg *amqp.Delivery) error) {
-	// 创建队列
-	q, err := r.channel.QueueDeclare(r.Key, false, false, false, false, nil)
+	var err error
+	r.consumeChannel, err = r.conn.Channel()
 	if err != nil {
 		panic(err)
 	}
+	if err := r.declareQueue(r.consumeChannel); err != nil {
+		panic(err)
+	}
+	// 每次只取一条，保证同一队列中的消息按顺序处理
+	if err := r.consumeChannel.Qos(1, 0, false); err != nil {
+		panic(err)
+	}
 
 	// 接收消息
-	msgs, err := r.channel.Consume(q.Name, "", true, false, false, false, nil)
+	msgs, err := r.consumeChannel.Consume(r.Key, "", false, false, false, false, nil)
 	if err != nil {
 		panic(err)
 	}
 
 	// 处理消息
 	for msg := range msgs {
-		if err := handle(&msg); err != nil {
-			fmt.Println(err.Error())
+		r.handleDelivery(&msg, handle)
+	}
+}
+
+func (r *RabbitMQ) handleDelivery(msg *amqp.Delivery, handle func(msg *amqp.Delivery) error) {
+	var err error
+	for attempt := 0; attempt <= r.maxRetry; attempt++ {
+		if attempt > 0 {
+			time.Sleep(r.retryInterval * time.Duration(attempt))
+		}
+		if err = handle(msg); err == nil {
+			if ackErr := msg.Ack(false); ackErr != nil {
+				log.Printf("rabbitmq ack message %s failed: %v", msg.MessageId, ackErr)
+			}
+			return
 		}
+		log.Printf("rabbitmq handle message %s failed (attempt %d/%d): %v", msg.MessageId, attempt+1, r.maxRetry+1, err)
+	}
+
+	// 重试耗尽，拒绝且不重新入队，由 broker 转入死信队列
+	log.Printf("rabbitmq message %s moved to %s: %v", msg.MessageId, r.DeadLetterQueue(), err)
+	if nackErr := msg.Nack(false, false); nackErr != nil {
+		log.Printf("rabbitmq nack message %s failed: %v", msg.MessageId, nackErr)
 	}
 }
@@ -0,0 +1,507 @@
+package rag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 文档在 Redis 中的组织方式，默认知识库的 base 为 rag:，其他知识库为 rag:kb:<id>:
+//
+//	<base>doc:<docID>       hash，文档信息
+//	<base>docs              zset，按更新时间排序的文档ID，用于分页
+//	<base>source:<source>   set，同一来源下的文档ID
+//	<keyPrefix><docID>:<n>  hash，文档的第 n 个分块，由向量索引收录
+const (
+	defaultDocumentBase       = "rag:"
+	knowledgeBaseDocumentBase = "rag:kb:%s:"
+	maxDocumentIDLength       = 64
+	reembedBatchSize          = 100
+)
+
+var (
+	// ErrDocumentNotFound 知识库中没有该文档
+	ErrDocumentNotFound = errors.New("document not found")
+	// ErrInvalidDocumentID 文档ID只能包含字母、数字、下划线与中划线
+	ErrInvalidDocumentID = errors.New("invalid document id")
+)
+
+// DocumentInfo 文档信息，一个文档对应多个分块
+type DocumentInfo struct {
+	ID            string    `json:"id"`
+	KnowledgeBase string    `json:"knowledgeBase,omitempty"`
+	Source        string    `json:"source"`
+	Title         string    `json:"title,omitempty"`
+	Tags          []string  `json:"tags,omitempty"`
+	Chunks        int       `json:"chunks"`
+	ContentHash   string    `json:"contentHash,omitempty"` // 全部分块内容的 sha256
+	Embedder      string    `json:"embedder,omitempty"`    // 写入时使用的向量模型
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// DocumentID 由来源生成稳定的文档ID，同一知识库中重复导入同一来源会替换原文档
+func DocumentID(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ValidDocumentID 文档ID会出现在 key 中，只允许字母、数字、下划线与中划线
+func ValidDocumentID(id string) bool {
+	if id == "" || len(id) > maxDocumentIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// ContentHash 按顺序计算全部分块内容的 sha256
+func ContentHash(contents []string) string {
+	h := sha256.New()
+	for _, content := range contents {
+		h.Write([]byte(content))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (redisRag *RedisRAG) documentBase(kb string) string {
+	if isDefaultKnowledgeBase(kb) {
+		return defaultDocumentBase
+	}
+	return fmt.Sprintf(knowledgeBaseDocumentBase, kb)
+}
+
+func (redisRag *RedisRAG) documentKey(kb string, docID string) string {
+	return redisRag.documentBase(kb) + "doc:" + docID
+}
+
+func (redisRag *RedisRAG) documentListKey(kb string) string {
+	return redisRag.documentBase(kb) + "docs"
+}
+
+func (redisRag *RedisRAG) sourceKey(kb string, source string) string {
+	return redisRag.documentBase(kb) + "source:" + source
+}
+
+func (redisRag *RedisRAG) chunkKey(kb string, docID string, n int) string {
+	return fmt.Sprintf("%s%s:%d", redisRag.keyPrefix(kb), docID, n)
+}
+
+// UpsertDocument 写入文档，文档已存在时替换其全部分块
+// 先完成全部向量化再在一个事务中写入，失败时原文档保持不变
+func (redisRag *RedisRAG) UpsertDocument(ctx context.Context, doc DocumentInfo, records []Record) (*DocumentInfo, error) {
+	if redisRag.RedisClient == nil {
+		return nil, fmt.Errorf("redis client is nil")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if doc.ID == "" {
+		if strings.TrimSpace(doc.Source) == "" {
+			return nil, fmt.Errorf("%w: id and source are both empty", ErrInvalidDocumentID)
+		}
+		doc.ID = DocumentID(doc.Source)
+	}
+	if !ValidDocumentID(doc.ID) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDocumentID, doc.ID)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("document %s has no content", doc.ID)
+	}
+
+	contents := make([]string, len(records))
+	for i, record := range records {
+		contents[i] = record.Content
+	}
+	embeddings, err := redisRag.GetEmbeddings(ctx, contents)
+	if err != nil {
+		return nil, err
+	}
+	if err := redisRag.checkDimension(embeddings); err != nil {
+		return nil, err
+	}
+
+	kb := doc.KnowledgeBase
+	if isDefaultKnowledgeBase(kb) {
+		doc.KnowledgeBase = ""
+	}
+	old, err := redisRag.GetDocument(ctx, kb, doc.ID)
+	if err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		return nil, err
+	}
+	vf, err := redisRag.writeFields(ctx, kb)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	doc.Chunks = len(records)
+	doc.ContentHash = ContentHash(contents)
+	doc.Embedder = redisRag.EmbedderName()
+	doc.CreatedAt = now
+	doc.UpdatedAt = now
+	if old != nil {
+		doc.CreatedAt = old.CreatedAt
+	}
+
+	_, err = redisRag.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, record := range records {
+			metadata := record.Metadata
+			pipe.HDel(ctx, redisRag.chunkKey(kb, doc.ID, i), vf.stale)
+			pipe.HSet(ctx, redisRag.chunkKey(kb, doc.ID, i), map[string]interface{}{
+				"content":     record.Content,
+				vf.live:       float32SliceToBytes(embeddings[i]),
+				"doc_id":      doc.ID,
+				"source":      metadata.Source,
+				"title":       metadata.Title,
+				"heading":     metadata.Heading,
+				"chunk_index": metadata.ChunkIndex,
+				"tags":        strings.Join(metadata.Tags, tagSeparator),
+			})
+		}
+		if old != nil {
+			// 新版本分块更少时删除多出的旧分块
+			for i := len(records); i < old.Chunks; i++ {
+				pipe.Del(ctx, redisRag.chunkKey(kb, doc.ID, i))
+			}
+			if old.Source != doc.Source {
+				pipe.SRem(ctx, redisRag.sourceKey(kb, old.Source), doc.ID)
+			}
+		}
+		pipe.HSet(ctx, redisRag.documentKey(kb, doc.ID), map[string]interface{}{
+			"id":           doc.ID,
+			"source":       doc.Source,
+			"title":        doc.Title,
+			"tags":         strings.Join(doc.Tags, tagSeparator),
+			"chunks":       doc.Chunks,
+			"content_hash": doc.ContentHash,
+			"embedder":     doc.Embedder,
+			"created_at":   doc.CreatedAt.UnixMilli(),
+			"updated_at":   doc.UpdatedAt.UnixMilli(),
+		})
+		pipe.ZAdd(ctx, redisRag.documentListKey(kb), &redis.Z{Score: float64(doc.UpdatedAt.UnixMilli()), Member: doc.ID})
+		pipe.SAdd(ctx, redisRag.sourceKey(kb, doc.Source), doc.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("store rag document failed: %w", err)
+	}
+	return &doc, nil
+}
+
+// GetDocument 读取文档信息，不存在时返回 ErrDocumentNotFound
+func (redisRag *RedisRAG) GetDocument(ctx context.Context, kb string, docID string) (*DocumentInfo, error) {
+	if !ValidDocumentID(docID) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDocumentID, docID)
+	}
+	fields, err := redisRag.RedisClient.HGetAll(ctx, redisRag.documentKey(kb, docID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("read rag document failed: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, ErrDocumentNotFound
+	}
+	return parseDocumentInfo(kb, fields), nil
+}
+
+// ListDocuments 按更新时间从新到旧分页列出文档，返回当前页与文档总数
+func (redisRag *RedisRAG) ListDocuments(ctx context.Context, kb string, offset int, limit int) ([]*DocumentInfo, int64, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	total, err := redisRag.RedisClient.ZCard(ctx, redisRag.documentListKey(kb)).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("count rag documents failed: %w", err)
+	}
+	docs := make([]*DocumentInfo, 0)
+	if limit <= 0 || int64(offset) >= total {
+		return docs, total, nil
+	}
+	ids, err := redisRag.RedisClient.ZRevRange(ctx, redisRag.documentListKey(kb), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("list rag documents failed: %w", err)
+	}
+
+	pipe := redisRag.RedisClient.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HGetAll(ctx, redisRag.documentKey(kb, id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, 0, fmt.Errorf("read rag documents failed: %w", err)
+	}
+	for _, cmd := range cmds {
+		if fields := cmd.Val(); len(fields) > 0 {
+			docs = append(docs, parseDocumentInfo(kb, fields))
+		}
+	}
+	return docs, total, nil
+}
+
+// DeleteDocument 删除文档及其全部分块
+func (redisRag *RedisRAG) DeleteDocument(ctx context.Context, kb string, docID string) error {
+	doc, err := redisRag.GetDocument(ctx, kb, docID)
+	if err != nil {
+		return err
+	}
+	_, err = redisRag.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		keys := make([]string, 0, doc.Chunks+1)
+		for i := 0; i < doc.Chunks; i++ {
+			keys = append(keys, redisRag.chunkKey(kb, docID, i))
+		}
+		keys = append(keys, redisRag.documentKey(kb, docID))
+		pipe.Del(ctx, keys...)
+		pipe.ZRem(ctx, redisRag.documentListKey(kb), docID)
+		pipe.SRem(ctx, redisRag.sourceKey(kb, doc.Source), docID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete rag document failed: %w", err)
+	}
+	return nil
+}
+
+// DeleteDocumentsBySource 删除同一来源下的全部文档，返回删除的文档数
+func (redisRag *RedisRAG) DeleteDocumentsBySource(ctx context.Context, kb string, source string) (int, error) {
+	ids, err := redisRag.RedisClient.SMembers(ctx, redisRag.sourceKey(kb, source)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("read rag source failed: %w", err)
+	}
+	deleted := 0
+	for _, id := range ids {
+		if err := redisRag.DeleteDocument(ctx, kb, id); err != nil {
+			if errors.Is(err, ErrDocumentNotFound) {
+				continue
+			}
+			return deleted, err
+		}
+		deleted++
+	}
+	// 清理已经失效的来源记录
+	if err := redisRag.RedisClient.Del(ctx, redisRag.sourceKey(kb, source)).Err(); err != nil {
+		return deleted, fmt.Errorf("delete rag source failed: %w", err)
+	}
+	return deleted, nil
+}
+
+// deleteDocumentRegistry 删除知识库的文档信息，不影响分块
+func (redisRag *RedisRAG) deleteDocumentRegistry(ctx context.Context, kb string) error {
+	base := redisRag.documentBase(kb)
+	if err := redisRag.deleteByPattern(ctx, base+"doc:*"); err != nil {
+		return err
+	}
+	if err := redisRag.deleteByPattern(ctx, base+"source:*"); err != nil {
+		return err
+	}
+	return redisRag.RedisClient.Del(ctx, redisRag.documentListKey(kb)).Err()
+}
+
+func (redisRag *RedisRAG) deleteByPattern(ctx context.Context, pattern string) error {
+	var cursor uint64
+	for {
+		keys, next, err := redisRag.RedisClient.Scan(ctx, cursor, pattern, 500).Result()
+		if err != nil {
+			return fmt.Errorf("scan rag data failed: %w", err)
+		}
+		if len(keys) > 0 {
+			if err := redisRag.RedisClient.Del(ctx, keys...).Err(); err != nil {
+				return fmt.Errorf("delete rag data failed: %w", err)
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// vectorFields 写入分块时使用的向量字段
+// 重新向量化期间新写入的分块会删除新版本字段中的旧向量，切换后由补齐步骤重新向量化
+type vectorFields struct {
+	live  string
+	stale string
+}
+
+func (redisRag *RedisRAG) writeFields(ctx context.Context, kb string) (vectorFields, error) {
+	gen, err := redisRag.generation(ctx, kb)
+	if err != nil {
+		return vectorFields{}, err
+	}
+	return vectorFields{live: vectorFieldAt(gen), stale: vectorFieldAt(gen + 1)}, nil
+}
+
+// ReembedAll 用当前向量模型重新向量化知识库中的全部分块（包括没有文档ID的旧数据），用于更换向量模型
+// 新向量写入另一个字段并建立新版本的索引，全部完成后再切换，切换前旧索引一直可以检索；
+// 切换后补齐期间新写入的分块，再删除旧索引与旧向量。中断后重新执行即可，未切换时旧索引不受影响
+func (redisRag *RedisRAG) ReembedAll(ctx context.Context, kb string, conf IndexConfig) (int, error) {
+	conf, err := conf.normalize()
+	if err != nil {
+		return 0, err
+	}
+	dim, err := redisRag.EmbeddingDimension(ctx)
+	if err != nil {
+		return 0, err
+	}
+	gen, err := redisRag.generation(ctx, kb)
+	if err != nil {
+		return 0, err
+	}
+	oldIndex, oldField := redisRag.indexNameAt(kb, gen), vectorFieldAt(gen)
+	nextIndex, nextField := redisRag.indexNameAt(kb, gen+1), vectorFieldAt(gen+1)
+
+	// 上次中断时可能留下了新版本的索引
+	if err := redisRag.dropIndexDefinition(ctx, nextIndex); err != nil {
+		return 0, err
+	}
+	if err := redisRag.createIndex(ctx, kb, nextIndex, nextField, conf, dim); err != nil {
+		return 0, err
+	}
+	reembedded, err := redisRag.reembedScan(ctx, kb, nextField, false, "")
+	if err != nil {
+		return reembedded, err
+	}
+	if err := redisRag.waitIndexed(ctx, nextIndex); err != nil {
+		return reembedded, err
+	}
+
+	// 切换后检索与写入都使用新版本的索引和字段
+	if err := redisRag.RedisClient.Set(ctx, redisRag.generationKey(kb), gen+1, 0).Err(); err != nil {
+		return reembedded, fmt.Errorf("switch rag index generation failed: %w", err)
+	}
+	n, err := redisRag.reembedScan(ctx, kb, nextField, true, oldField)
+	reembedded += n
+	if err != nil {
+		return reembedded, err
+	}
+	if err := redisRag.dropIndexDefinition(ctx, oldIndex); err != nil {
+		return reembedded, err
+	}
+
+	// 文档信息中记录新的向量模型
+	ids, err := redisRag.RedisClient.ZRange(ctx, redisRag.documentListKey(kb), 0, -1).Result()
+	if err != nil {
+		return reembedded, fmt.Errorf("list rag documents failed: %w", err)
+	}
+	if len(ids) > 0 {
+		pipe := redisRag.RedisClient.Pipeline()
+		for _, id := range ids {
+			pipe.HSet(ctx, redisRag.documentKey(kb, id), "embedder", redisRag.EmbedderName())
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return reembedded, fmt.Errorf("update rag documents failed: %w", err)
+		}
+	}
+
+	log.Printf("rag knowledge base %s re-embedded with %s into %s: %d chunks", redisRag.indexName(kb), redisRag.EmbedderName(), nextIndex, reembedded)
+	return reembedded, nil
+}
+
+// reembedScan 遍历知识库的全部分块，把向量写入 field
+// onlyMissing 为 true 时只处理 field 为空的分块；staleField 不为空时同时删除该字段
+func (redisRag *RedisRAG) reembedScan(ctx context.Context, kb string, field string, onlyMissing bool, staleField string) (int, error) {
+	reembedded := 0
+	var cursor uint64
+	for {
+		keys, next, err := redisRag.RedisClient.Scan(ctx, cursor, redisRag.keyPrefix(kb)+"*", reembedBatchSize).Result()
+		if err != nil {
+			return reembedded, fmt.Errorf("scan rag data failed: %w", err)
+		}
+		n, err := redisRag.reembedKeys(ctx, keys, field, onlyMissing, staleField)
+		reembedded += n
+		if err != nil {
+			return reembedded, err
+		}
+		if next == 0 {
+			return reembedded, nil
+		}
+		cursor = next
+	}
+}
+
+func (redisRag *RedisRAG) reembedKeys(ctx context.Context, keys []string, field string, onlyMissing bool, staleField string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	pipe := redisRag.RedisClient.Pipeline()
+	contentCmds := make([]*redis.StringCmd, len(keys))
+	existsCmds := make([]*redis.BoolCmd, len(keys))
+	for i, key := range keys {
+		contentCmds[i] = pipe.HGet(ctx, key, "content")
+		existsCmds[i] = pipe.HExists(ctx, key, field)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, fmt.Errorf("read rag data failed: %w", err)
+	}
+
+	targets := make([]string, 0, len(keys))
+	contents := make([]string, 0, len(keys))
+	for i, cmd := range contentCmds {
+		if onlyMissing && existsCmds[i].Val() {
+			continue
+		}
+		if content := cmd.Val(); strings.TrimSpace(content) != "" {
+			targets = append(targets, keys[i])
+			contents = append(contents, content)
+		}
+	}
+	var embeddings [][]float32
+	if len(contents) > 0 {
+		var err error
+		if embeddings, err = redisRag.GetEmbeddings(ctx, contents); err != nil {
+			return 0, err
+		}
+	}
+
+	pipe = redisRag.RedisClient.Pipeline()
+	for i, key := range targets {
+		pipe.HSet(ctx, key, field, float32SliceToBytes(embeddings[i]))
+	}
+	if staleField != "" {
+		for _, key := range keys {
+			pipe.HDel(ctx, key, staleField)
+		}
+	}
+	if pipe.Len() == 0 {
+		return 0, nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("store rag data failed: %w", err)
+	}
+	return len(targets), nil
+}
+
+func parseDocumentInfo(kb string, fields map[string]string) *DocumentInfo {
+	doc := &DocumentInfo{
+		ID:          fields["id"],
+		Source:      fields["source"],
+		Title:       fields["title"],
+		ContentHash: fields["content_hash"],
+		Embedder:    fields["embedder"],
+	}
+	if !isDefaultKnowledgeBase(kb) {
+		doc.KnowledgeBase = kb
+	}
+	if tags := fields["tags"]; tags != "" {
+		doc.Tags = strings.Split(tags, tagSeparator)
+	}
+	doc.Chunks, _ = strconv.Atoi(fields["chunks"])
+	if ms, err := strconv.ParseInt(fields["created_at"], 10, 64); err == nil {
+		doc.CreatedAt = time.UnixMilli(ms)
+	}
+	if ms, err := strconv.ParseInt(fields["updated_at"], 10, 64); err == nil {
+		doc.UpdatedAt = time.UnixMilli(ms)
+	}
+	return doc
+}
@@ -0,0 +1,419 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// 向量模型提供方
+const (
+	EmbeddingProviderOllama = "ollama" // Ollama /api/embed
+	EmbeddingProviderOpenAI = "openai" // 兼容 OpenAI 的 /v1/embeddings
+	EmbeddingProviderFake   = "fake"   // 本地哈希向量，仅用于测试与离线开发
+)
+
+const (
+	DefaultOllamaEmbeddingModel = "nomic-embed-text"
+	DefaultOpenAIEmbeddingModel = "text-embedding-3-small"
+	DefaultFakeDimension        = 256
+	DefaultEmbeddingBatchSize   = 32
+	DefaultEmbeddingConcurrency = 4
+)
+
+// 所有向量模型共用的 HTTP 客户端，复用连接
+var defaultEmbeddingClient = &http.Client{Timeout: 60 * time.Second}
+
+// ErrUnsupportedEmbeddingProvider 向量模型提供方不是 ollama / openai / fake
+var ErrUnsupportedEmbeddingProvider = errors.New("unsupported embedding provider")
+
+// Embedder 把文本转换为向量，返回与 texts 一一对应的结果
+type Embedder interface {
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+	// Name 提供方与模型名，例如 ollama:nomic-embed-text，用于缓存 key 与错误信息
+	Name() string
+}
+
+// EmbeddingConfig 创建 Embedder 的参数，零值字段使用默认值
+type EmbeddingConfig struct {
+	Provider    string // ollama / openai / fake，默认 ollama
+	BaseURL     string // ollama 为服务地址，openai 为包含 /v1 的接口前缀
+	Model       string
+	APIKey      string
+	Dimensions  int           // openai 的 dimensions 参数，fake 的向量维度
+	BatchSize   int           // 每次请求最多包含的文本数
+	Concurrency int           // 同时进行的请求数
+	Timeout     time.Duration // 单次请求超时，0 使用共享客户端的 60 秒
+	Cache       EmbeddingCache
+}
+
+// NewEmbedder 按配置创建 Embedder：先查缓存，未命中的文本按 BatchSize 分批、并发请求模型
+func NewEmbedder(conf EmbeddingConfig) (Embedder, error) {
+	client := defaultEmbeddingClient
+	if conf.Timeout > 0 {
+		client = &http.Client{Timeout: conf.Timeout}
+	}
+
+	var embedder Embedder
+	switch strings.ToLower(strings.TrimSpace(conf.Provider)) {
+	case "", EmbeddingProviderOllama:
+		if strings.TrimSpace(conf.BaseURL) == "" {
+			return nil, fmt.Errorf("ollama baseURL is empty")
+		}
+		embedder = &OllamaEmbedder{BaseURL: conf.BaseURL, Model: conf.Model, Client: client}
+	case EmbeddingProviderOpenAI:
+		if strings.TrimSpace(conf.BaseURL) == "" {
+			return nil, fmt.Errorf("openai embedding baseURL is empty")
+		}
+		embedder = &OpenAIEmbedder{BaseURL: conf.BaseURL, Model: conf.Model, APIKey: conf.APIKey, Dimensions: conf.Dimensions, Client: client}
+	case EmbeddingProviderFake:
+		embedder = &FakeEmbedder{Dimension: conf.Dimensions}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedEmbeddingProvider, conf.Provider)
+	}
+
+	embedder = &BatchEmbedder{Embedder: embedder, BatchSize: conf.BatchSize, Concurrency: conf.Concurrency}
+	if conf.Cache != nil {
+		embedder = &CachedEmbedder{Embedder: embedder, Cache: conf.Cache}
+	}
+	return embedder, nil
+}
+
+// BatchEmbedder 把大批文本切分为多个请求，并限制同时进行的请求数，结果保持原顺序
+type BatchEmbedder struct {
+	Embedder
+	BatchSize   int
+	Concurrency int
+}
+
+func (b *BatchEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	batchSize := b.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultEmbeddingBatchSize
+	}
+	if len(texts) <= batchSize {
+		return b.Embedder.EmbedBatch(ctx, texts)
+	}
+	concurrency := b.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultEmbeddingConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	vectors := make([][]float32, len(texts))
+	sem := make(chan struct{}, concurrency)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for start := 0; start < len(texts); start += batchSize {
+		end := min(start+batchSize, len(texts))
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			batch, err := b.Embedder.EmbedBatch(ctx, texts[start:end])
+			if err == nil && len(batch) != end-start {
+				err = fmt.Errorf("embedding response has %d vectors for %d texts", len(batch), end-start)
+			}
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			copy(vectors[start:end], batch)
+		}(start, end)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// OllamaEmbedder 调用 Ollama 的 /api/embed 批量接口，旧版本不支持时逐条调用 /api/embeddings
+type OllamaEmbedder struct {
+	BaseURL string
+	Model   string // 默认 nomic-embed-text
+	Client  *http.Client
+}
+
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+type ollamaLegacyRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+type ollamaLegacyResponse struct {
+	Embedding []float32 `json:"embedding"`
+}
+
+func (o *OllamaEmbedder) model() string {
+	if model := strings.TrimSpace(o.Model); model != "" {
+		return model
+	}
+	return DefaultOllamaEmbeddingModel
+}
+
+func (o *OllamaEmbedder) Name() string {
+	return EmbeddingProviderOllama + ":" + o.model()
+}
+
+func (o *OllamaEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	baseURL := strings.TrimRight(o.BaseURL, "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("ollama baseURL is empty")
+	}
+	if len(texts) == 0 {
+		return [][]float32{}, nil
+	}
+
+	var resp ollamaEmbedResponse
+	status, err := postEmbeddingJSON(ctx, o.Client, baseURL+"/api/embed", "", ollamaEmbedRequest{Model: o.model(), Input: texts}, &resp)
+	if status == http.StatusNotFound {
+		return o.embedLegacy(ctx, baseURL, texts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d texts", len(resp.Embeddings), len(texts))
+	}
+	for i, vector := range resp.Embeddings {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("embedding response is empty for text %d", i)
+		}
+	}
+	return resp.Embeddings, nil
+}
+
+// embedLegacy Ollama 0.3 之前的版本只有单条的 /api/embeddings
+func (o *OllamaEmbedder) embedLegacy(ctx context.Context, baseURL string, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		var resp ollamaLegacyResponse
+		if _, err := postEmbeddingJSON(ctx, o.Client, baseURL+"/api/embeddings", "", ollamaLegacyRequest{Model: o.model(), Prompt: text}, &resp); err != nil {
+			return nil, err
+		}
+		if len(resp.Embedding) == 0 {
+			return nil, fmt.Errorf("embedding response is empty")
+		}
+		vectors[i] = resp.Embedding
+	}
+	return vectors, nil
+}
+
+// OpenAIEmbedder 调用兼容 OpenAI 的 /v1/embeddings 接口，例如 OpenAI、DashScope、vLLM、TEI
+type OpenAIEmbedder struct {
+	BaseURL    string // 包含 /v1 的接口前缀，例如 https://api.openai.com/v1
+	Model      string // 默认 text-embedding-3-small
+	APIKey     string
+	Dimensions int // 大于 0 时请求指定维度，需要模型支持
+	Client     *http.Client
+}
+
+type openAIEmbeddingRequest struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	Dimensions     int      `json:"dimensions,omitempty"`
+	EncodingFormat string   `json:"encoding_format"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (o *OpenAIEmbedder) model() string {
+	if model := strings.TrimSpace(o.Model); model != "" {
+		return model
+	}
+	return DefaultOpenAIEmbeddingModel
+}
+
+func (o *OpenAIEmbedder) Name() string {
+	name := EmbeddingProviderOpenAI + ":" + o.model()
+	if o.Dimensions > 0 {
+		name = fmt.Sprintf("%s:%d", name, o.Dimensions)
+	}
+	return name
+}
+
+func (o *OpenAIEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	baseURL := strings.TrimRight(o.BaseURL, "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("openai embedding baseURL is empty")
+	}
+	if len(texts) == 0 {
+		return [][]float32{}, nil
+	}
+
+	var resp openAIEmbeddingResponse
+	if _, err := postEmbeddingJSON(ctx, o.Client, baseURL+"/embeddings", o.APIKey, openAIEmbeddingRequest{
+		Model:          o.model(),
+		Input:          texts,
+		Dimensions:     o.Dimensions,
+		EncodingFormat: "float",
+	}, &resp); err != nil {
+		return nil, err
+	}
+
+	// 按 index 还原顺序，不依赖返回顺序
+	vectors := make([][]float32, len(texts))
+	for _, item := range resp.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding response index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, fmt.Errorf("embedding response is empty for text %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// postEmbeddingJSON 发送 JSON 请求并解析响应，返回 HTTP 状态码便于调用方降级
+func postEmbeddingJSON(ctx context.Context, client *http.Client, url string, apiKey string, body interface{}, out interface{}) (int, error) {
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("marshal embedding request failed: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return 0, fmt.Errorf("create embedding request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	if client == nil {
+		client = defaultEmbeddingClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return resp.StatusCode, fmt.Errorf("embedding request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("decode embedding response failed: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// FakeEmbedder 把词哈希到固定维度后归一化，相同文本得到相同向量，共享词越多越相似
+// 不请求任何服务，用于测试与没有向量模型时的本地开发
+type FakeEmbedder struct {
+	Dimension int // 默认 256
+}
+
+func (f *FakeEmbedder) dimension() int {
+	if f.Dimension > 0 {
+		return f.Dimension
+	}
+	return DefaultFakeDimension
+}
+
+func (f *FakeEmbedder) Name() string {
+	return fmt.Sprintf("%s:%d", EmbeddingProviderFake, f.dimension())
+}
+
+func (f *FakeEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vectors[i] = f.embed(text)
+	}
+	return vectors, nil
+}
+
+func (f *FakeEmbedder) embed(text string) []float32 {
+	dim := f.dimension()
+	vector := make([]float32, dim)
+	// 英文按词、中文按字切分
+	var word strings.Builder
+	addToken := func(token string) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(token))
+		vector[h.Sum32()%uint32(dim)]++
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			if word.Len() > 0 {
+				addToken(word.String())
+				word.Reset()
+			}
+			addToken(string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			if word.Len() > 0 {
+				addToken(word.String())
+				word.Reset()
+			}
+		}
+	}
+	if word.Len() > 0 {
+		addToken(word.String())
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		// 没有可用词时给一个固定方向，避免零向量在余弦距离下无意义
+		vector[0] = 1
+		return vector
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+	return vector
+}
@@ -0,0 +1,197 @@
+package rag
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	DefaultEmbeddingCachePrefix = "rag:embedding:"
+	DefaultEmbeddingCacheSize   = 10000
+)
+
+// EmbeddingCache 按内容哈希缓存向量
+type EmbeddingCache interface {
+	// GetMulti 返回与 keys 一一对应的向量，未命中的位置为 nil
+	GetMulti(ctx context.Context, keys []string) ([][]float32, error)
+	SetMulti(ctx context.Context, keys []string, vectors [][]float32) error
+}
+
+// EmbeddingCacheKey 缓存 key 为模型名与内容的 sha256，换模型后不会命中旧向量
+func EmbeddingCacheKey(model string, content string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + content))
+	return hex.EncodeToString(sum[:])
+}
+
+// CachedEmbedder 先查缓存，只对未命中的文本请求模型，同一批中重复的文本只请求一次
+// 缓存读写失败只记录日志，不影响向量化
+type CachedEmbedder struct {
+	Embedder
+	Cache EmbeddingCache
+}
+
+func (c *CachedEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return [][]float32{}, nil
+	}
+	name := c.Embedder.Name()
+	keys := make([]string, len(texts))
+	for i, text := range texts {
+		keys[i] = EmbeddingCacheKey(name, text)
+	}
+
+	vectors, err := c.Cache.GetMulti(ctx, keys)
+	if err != nil || len(vectors) != len(keys) {
+		if err != nil {
+			log.Printf("embedding cache get failed: %v", err)
+		}
+		vectors = make([][]float32, len(keys))
+	}
+
+	// 收集未命中的文本，按 key 去重
+	missIndex := make(map[string][]int)
+	missKeys := make([]string, 0)
+	missTexts := make([]string, 0)
+	for i, vector := range vectors {
+		if vector != nil {
+			continue
+		}
+		if _, ok := missIndex[keys[i]]; !ok {
+			missKeys = append(missKeys, keys[i])
+			missTexts = append(missTexts, texts[i])
+		}
+		missIndex[keys[i]] = append(missIndex[keys[i]], i)
+	}
+	if len(missTexts) == 0 {
+		return vectors, nil
+	}
+
+	embedded, err := c.Embedder.EmbedBatch(ctx, missTexts)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range missKeys {
+		for _, idx := range missIndex[key] {
+			vectors[idx] = embedded[i]
+		}
+	}
+	if err := c.Cache.SetMulti(ctx, missKeys, embedded); err != nil {
+		log.Printf("embedding cache set failed: %v", err)
+	}
+	return vectors, nil
+}
+
+// MemoryEmbeddingCache 进程内 LRU 缓存
+type MemoryEmbeddingCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key    string
+	vector []float32
+}
+
+// NewMemoryEmbeddingCache 创建最多保存 size 条向量的缓存，size <= 0 时使用默认值
+func NewMemoryEmbeddingCache(size int) *MemoryEmbeddingCache {
+	if size <= 0 {
+		size = DefaultEmbeddingCacheSize
+	}
+	return &MemoryEmbeddingCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (m *MemoryEmbeddingCache) GetMulti(_ context.Context, keys []string) ([][]float32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	vectors := make([][]float32, len(keys))
+	for i, key := range keys {
+		if elem, ok := m.entries[key]; ok {
+			m.order.MoveToFront(elem)
+			vectors[i] = elem.Value.(*memoryCacheEntry).vector
+		}
+	}
+	return vectors, nil
+}
+
+func (m *MemoryEmbeddingCache) SetMulti(_ context.Context, keys []string, vectors [][]float32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, key := range keys {
+		if elem, ok := m.entries[key]; ok {
+			elem.Value.(*memoryCacheEntry).vector = vectors[i]
+			m.order.MoveToFront(elem)
+			continue
+		}
+		m.entries[key] = m.order.PushFront(&memoryCacheEntry{key: key, vector: vectors[i]})
+		for m.order.Len() > m.size {
+			oldest := m.order.Back()
+			m.order.Remove(oldest)
+			delete(m.entries, oldest.Value.(*memoryCacheEntry).key)
+		}
+	}
+	return nil
+}
+
+// RedisEmbeddingCache 把向量以 FLOAT32 字节保存在 Redis 中，多个进程与 CLI 共用
+type RedisEmbeddingCache struct {
+	Client *redis.Client
+	Prefix string        // 默认 rag:embedding:
+	TTL    time.Duration // 0 表示不过期
+}
+
+func (r *RedisEmbeddingCache) key(key string) string {
+	if r.Prefix != "" {
+		return r.Prefix + key
+	}
+	return DefaultEmbeddingCachePrefix + key
+}
+
+func (r *RedisEmbeddingCache) GetMulti(ctx context.Context, keys []string) ([][]float32, error) {
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = r.key(key)
+	}
+	values, err := r.Client.MGet(ctx, fullKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(keys))
+	for i, value := range values {
+		if s, ok := value.(string); ok && len(s) > 0 && len(s)%4 == 0 {
+			vectors[i] = bytesToFloat32Slice([]byte(s))
+		}
+	}
+	return vectors, nil
+}
+
+func (r *RedisEmbeddingCache) SetMulti(ctx context.Context, keys []string, vectors [][]float32) error {
+	pipe := r.Client.Pipeline()
+	for i, key := range keys {
+		pipe.Set(ctx, r.key(key), float32SliceToBytes(vectors[i]), r.TTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func bytesToFloat32Slice(data []byte) []float32 {
+	values := make([]float32, len(data)/4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4 : (i+1)*4]))
+	}
+	return values
+}
@@ -0,0 +1,210 @@
+package eval
+
+import (
+	"GopherAI/common/rag"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+const (
+	DefaultK = 5
+	// 同一文档的多个分块只计一次，按 K 的倍数多取一些分块再去重
+	fetchFactor = 3
+)
+
+// Query 一条标注好的查询，Expected 为应当被检索到的文档ID，也可以写来源（没有文档ID的旧数据）
+type Query struct {
+	ID             string   `json:"id,omitempty"`
+	Query          string   `json:"query"`
+	Expected       []string `json:"expected"`
+	KnowledgeBases []string `json:"knowledgeBases,omitempty"` // 为空时使用 Config 中的范围
+}
+
+// Config 一组检索配置，对比时每组配置对同一批查询各跑一次
+type Config struct {
+	Name    string
+	DB      rag.RAGDatabase
+	Scope   rag.Scope
+	Options rag.SearchOptions // K 会被 Run 的 k 覆盖
+}
+
+// QueryResult 单条查询的评估结果，Rank 从 1 开始，0 表示前 K 个文档中没有相关文档
+type QueryResult struct {
+	ID        string   `json:"id,omitempty"`
+	Query     string   `json:"query"`
+	Expected  []string `json:"expected"`
+	Retrieved []string `json:"retrieved"` // 去重后的前 K 个文档
+	Recall    float64  `json:"recall"`
+	RR        float64  `json:"rr"` // 第一个相关文档排名的倒数
+	NDCG      float64  `json:"ndcg"`
+	Rank      int      `json:"rank"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// Report 一组配置在整个查询集上的平均指标
+type Report struct {
+	Name    string         `json:"name"`
+	K       int            `json:"k"`
+	Queries int            `json:"queries"`
+	Failed  int            `json:"failed"` // 检索出错的查询数，按 0 分计入平均值
+	Recall  float64        `json:"recall"`
+	MRR     float64        `json:"mrr"`
+	NDCG    float64        `json:"ndcg"`
+	Results []*QueryResult `json:"results"`
+}
+
+// LoadQueries 读取查询集，支持 JSON 数组或每行一个 JSON 对象
+func LoadQueries(path string) ([]Query, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseQueries(data)
+}
+
+// ParseQueries 解析查询集，支持 JSON 数组或 JSONL
+func ParseQueries(data []byte) ([]Query, error) {
+	var queries []Query
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &queries); err != nil {
+			return nil, fmt.Errorf("decode query set failed: %w", err)
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			var q Query
+			if err := json.Unmarshal([]byte(text), &q); err != nil {
+				return nil, fmt.Errorf("decode query set line %d failed: %w", line, err)
+			}
+			queries = append(queries, q)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	for i, q := range queries {
+		if strings.TrimSpace(q.Query) == "" || len(q.Expected) == 0 {
+			return nil, fmt.Errorf("query %d has no query text or expected documents", i+1)
+		}
+	}
+	return queries, nil
+}
+
+// Run 用一组配置检索全部查询并计算 recall@k、MRR 与 nDCG@k
+// 单条查询出错时记录在结果中并按 0 分计算，不中断整个评估
+func Run(ctx context.Context, conf Config, queries []Query, k int) (*Report, error) {
+	if conf.DB == nil {
+		return nil, fmt.Errorf("config %s has no database", conf.Name)
+	}
+	if k <= 0 {
+		k = DefaultK
+	}
+	report := &Report{Name: conf.Name, K: k, Queries: len(queries), Results: make([]*QueryResult, 0, len(queries))}
+	opts := conf.Options
+	opts.K = k * fetchFactor
+
+	for _, q := range queries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		scope := conf.Scope
+		if len(q.KnowledgeBases) > 0 {
+			scope = rag.Scope{KnowledgeBases: q.KnowledgeBases}
+		}
+		result := &QueryResult{ID: q.ID, Query: q.Query, Expected: q.Expected}
+		results, err := conf.DB.Search(ctx, q.Query, scope, opts)
+		if err != nil {
+			result.Error = err.Error()
+			report.Failed++
+		} else {
+			result.Retrieved = documentRanking(results, k)
+			result.Recall, result.RR, result.NDCG, result.Rank = Score(result.Retrieved, q.Expected, k)
+		}
+		report.Recall += result.Recall
+		report.MRR += result.RR
+		report.NDCG += result.NDCG
+		report.Results = append(report.Results, result)
+	}
+	if n := float64(len(queries)); n > 0 {
+		report.Recall /= n
+		report.MRR /= n
+		report.NDCG /= n
+	}
+	return report, nil
+}
+
+// documentRanking 把分块结果按文档去重，返回前 k 个文档的ID，没有文档ID的旧数据使用来源
+func documentRanking(results []*rag.RagReturnedData, k int) []string {
+	seen := make(map[string]bool)
+	docs := make([]string, 0, k)
+	for _, r := range results {
+		id := r.Metadata.DocID
+		if id == "" {
+			id = r.Metadata.Source
+		}
+		if id == "" {
+			id = r.ID
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		docs = append(docs, id)
+		if len(docs) == k {
+			break
+		}
+	}
+	return docs
+}
+
+// Score 计算单条查询的 recall@k、倒数排名与 nDCG@k（二元相关性），rank 为第一个相关文档的排名
+func Score(retrieved []string, expected []string, k int) (recall float64, rr float64, ndcg float64, rank int) {
+	relevant := make(map[string]bool, len(expected))
+	for _, id := range expected {
+		relevant[id] = true
+	}
+	if len(relevant) == 0 {
+		return 0, 0, 0, 0
+	}
+	if k > 0 && len(retrieved) > k {
+		retrieved = retrieved[:k]
+	}
+
+	hits := 0
+	var dcg float64
+	for i, id := range retrieved {
+		if !relevant[id] {
+			continue
+		}
+		hits++
+		dcg += 1 / math.Log2(float64(i+2))
+		if rank == 0 {
+			rank = i + 1
+		}
+	}
+	var idcg float64
+	for i := 0; i < min(len(relevant), k); i++ {
+		idcg += 1 / math.Log2(float64(i+2))
+	}
+
+	recall = float64(hits) / float64(len(relevant))
+	if rank > 0 {
+		rr = 1 / float64(rank)
+	}
+	if idcg > 0 {
+		ndcg = dcg / idcg
+	}
+	return recall, rr, ndcg, rank
+}
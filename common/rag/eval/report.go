@@ -0,0 +1,157 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 输出格式
+const (
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+)
+
+// Delta 相对基线（第一组配置）的指标变化
+type Delta struct {
+	Name   string  `json:"name"`
+	Recall float64 `json:"recall"`
+	MRR    float64 `json:"mrr"`
+	NDCG   float64 `json:"ndcg"`
+	// 单条查询的 nDCG 变好、变差的数量
+	Improved  int `json:"improved"`
+	Regressed int `json:"regressed"`
+}
+
+// Comparison 多组配置的评估结果，第一组为基线
+type Comparison struct {
+	Reports []*Report `json:"reports"`
+	Deltas  []Delta   `json:"deltas,omitempty"`
+}
+
+// Compare 以第一组为基线计算其余各组的变化，各组需使用同一个查询集
+func Compare(reports ...*Report) *Comparison {
+	comparison := &Comparison{Reports: reports}
+	if len(reports) < 2 {
+		return comparison
+	}
+	base := reports[0]
+	for _, report := range reports[1:] {
+		delta := Delta{
+			Name:   report.Name,
+			Recall: report.Recall - base.Recall,
+			MRR:    report.MRR - base.MRR,
+			NDCG:   report.NDCG - base.NDCG,
+		}
+		for i, result := range report.Results {
+			if i >= len(base.Results) {
+				break
+			}
+			switch diff := result.NDCG - base.Results[i].NDCG; {
+			case diff > 1e-9:
+				delta.Improved++
+			case diff < -1e-9:
+				delta.Regressed++
+			}
+		}
+		comparison.Deltas = append(comparison.Deltas, delta)
+	}
+	return comparison
+}
+
+// Write 按格式输出对比结果
+func (c *Comparison) Write(w io.Writer, format string) error {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", FormatMarkdown, "md":
+		return c.WriteMarkdown(w)
+	case FormatJSON:
+		return c.WriteJSON(w)
+	default:
+		return fmt.Errorf("unsupported report format: %s", format)
+	}
+}
+
+func (c *Comparison) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
+
+// WriteMarkdown 输出汇总表与逐条查询的对比表，多组配置时附带相对基线的变化
+func (c *Comparison) WriteMarkdown(w io.Writer) error {
+	if len(c.Reports) == 0 {
+		return nil
+	}
+	var sb strings.Builder
+	k := c.Reports[0].K
+
+	sb.WriteString("## RAG 检索评估\n\n")
+	fmt.Fprintf(&sb, "查询数：%d，K = %d\n\n", c.Reports[0].Queries, k)
+	sb.WriteString("| 配置 | recall@" + fmt.Sprint(k) + " | MRR | nDCG@" + fmt.Sprint(k) + " | 失败 |\n")
+	sb.WriteString("| --- | --- | --- | --- | --- |\n")
+	for i, report := range c.Reports {
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s | %d |\n", escapeCell(report.Name),
+			metricCell(report.Recall, c.delta(i, func(d Delta) float64 { return d.Recall })),
+			metricCell(report.MRR, c.delta(i, func(d Delta) float64 { return d.MRR })),
+			metricCell(report.NDCG, c.delta(i, func(d Delta) float64 { return d.NDCG })),
+			report.Failed)
+	}
+	for _, delta := range c.Deltas {
+		fmt.Fprintf(&sb, "\n%s 相对 %s：%d 条查询变好，%d 条变差\n", escapeCell(delta.Name), escapeCell(c.Reports[0].Name), delta.Improved, delta.Regressed)
+	}
+
+	sb.WriteString("\n### 逐条查询\n\n| 查询 | 期望文档 |")
+	for _, report := range c.Reports {
+		fmt.Fprintf(&sb, " %s 排名 | %s nDCG |", escapeCell(report.Name), escapeCell(report.Name))
+	}
+	sb.WriteString("\n| --- | --- |")
+	for range c.Reports {
+		sb.WriteString(" --- | --- |")
+	}
+	sb.WriteString("\n")
+	for i, base := range c.Reports[0].Results {
+		fmt.Fprintf(&sb, "| %s | %s |", escapeCell(base.Query), escapeCell(strings.Join(base.Expected, ", ")))
+		for _, report := range c.Reports {
+			if i >= len(report.Results) {
+				sb.WriteString(" | |")
+				continue
+			}
+			result := report.Results[i]
+			if result.Error != "" {
+				sb.WriteString(" 出错 | - |")
+				continue
+			}
+			rank := "-"
+			if result.Rank > 0 {
+				rank = fmt.Sprint(result.Rank)
+			}
+			fmt.Fprintf(&sb, " %s | %.3f |", rank, result.NDCG)
+		}
+		sb.WriteString("\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// delta 第 i 组相对基线的变化，基线本身返回 nil
+func (c *Comparison) delta(i int, pick func(Delta) float64) *float64 {
+	if i == 0 || i-1 >= len(c.Deltas) {
+		return nil
+	}
+	v := pick(c.Deltas[i-1])
+	return &v
+}
+
+func metricCell(value float64, delta *float64) string {
+	if delta == nil {
+		return fmt.Sprintf("%.4f", value)
+	}
+	return fmt.Sprintf("%.4f (%+.4f)", value, *delta)
+}
+
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
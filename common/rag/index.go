@@ -0,0 +1,498 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	DefaultIndexName = "idx:rag_data"
+	DefaultKeyPrefix = "rag:data:"
+
+	IndexAlgorithmHNSW = "HNSW"
+	IndexAlgorithmFLAT = "FLAT"
+
+	// 用于探测向量维度的文本
+	dimensionProbeText = "dimension probe"
+
+	// 相邻版本的索引交替使用这两个字段保存向量，索引中的字段名都是 embedding
+	vectorField    = "embedding"
+	vectorFieldAlt = "embedding_alt"
+	// 等待新索引建立完成时的轮询间隔
+	indexPollInterval = 500 * time.Millisecond
+	// 文本字段的分词语言，chinese 会对中文做分词，英文等按空格切分的文字不受影响
+	indexLanguage = "chinese"
+)
+
+// ErrDimensionMismatch 索引或已存储向量的维度与当前向量模型不一致
+var ErrDimensionMismatch = errors.New("embedding dimension mismatch")
+
+var indexVersionSuffix = regexp.MustCompile(`:v\d+$`)
+
+// IndexConfig 向量索引配置，零值字段使用默认值
+type IndexConfig struct {
+	Algorithm      string // HNSW / FLAT，默认 HNSW
+	DistanceMetric string // COSINE / L2 / IP，默认 COSINE
+	// HNSW 参数
+	M              int
+	EFConstruction int
+	EFRuntime      int
+	// FLAT 参数
+	BlockSize int
+}
+
+func (c IndexConfig) normalize() (IndexConfig, error) {
+	c.Algorithm = strings.ToUpper(strings.TrimSpace(c.Algorithm))
+	if c.Algorithm == "" {
+		c.Algorithm = IndexAlgorithmHNSW
+	}
+	if c.Algorithm != IndexAlgorithmHNSW && c.Algorithm != IndexAlgorithmFLAT {
+		return c, fmt.Errorf("unsupported index algorithm: %s", c.Algorithm)
+	}
+	c.DistanceMetric = strings.ToUpper(strings.TrimSpace(c.DistanceMetric))
+	if c.DistanceMetric == "" {
+		c.DistanceMetric = "COSINE"
+	}
+	switch c.DistanceMetric {
+	case "COSINE", "L2", "IP":
+	default:
+		return c, fmt.Errorf("unsupported distance metric: %s", c.DistanceMetric)
+	}
+	return c, nil
+}
+
+// IndexInfo 当前索引中向量字段的定义
+type IndexInfo struct {
+	Exists         bool
+	Dimension      int
+	Algorithm      string
+	DistanceMetric string
+	Language       string // 文本字段的默认分词语言
+	Params         string // 创建索引时记录的参数，旧版本创建的索引为空
+	NumDocs        int64
+}
+
+// vectorParams FT.CREATE 中向量字段的参数
+func (c IndexConfig) vectorParams(dim int) []interface{} {
+	params := []interface{}{"TYPE", "FLOAT32", "DIM", dim, "DISTANCE_METRIC", c.DistanceMetric}
+	if c.Algorithm == IndexAlgorithmHNSW {
+		if c.M > 0 {
+			params = append(params, "M", c.M)
+		}
+		if c.EFConstruction > 0 {
+			params = append(params, "EF_CONSTRUCTION", c.EFConstruction)
+		}
+		if c.EFRuntime > 0 {
+			params = append(params, "EF_RUNTIME", c.EFRuntime)
+		}
+	} else if c.BlockSize > 0 {
+		params = append(params, "BLOCK_SIZE", c.BlockSize)
+	}
+	return params
+}
+
+// params 索引定义中影响检索的全部参数，FT.INFO 不一定返回 M、EF_RUNTIME、BLOCK_SIZE 等，创建索引时记录下来用于比较
+func (c IndexConfig) params(dim int) string {
+	parts := []string{"LANGUAGE", indexLanguage, c.Algorithm}
+	for _, p := range c.vectorParams(dim) {
+		parts = append(parts, fmt.Sprint(p))
+	}
+	return strings.Join(parts, " ")
+}
+
+// hasTuningParams 是否设置了算法参数，未设置时使用 RediSearch 的默认值
+func (c IndexConfig) hasTuningParams() bool {
+	if c.Algorithm == IndexAlgorithmHNSW {
+		return c.M > 0 || c.EFConstruction > 0 || c.EFRuntime > 0
+	}
+	return c.BlockSize > 0
+}
+
+// matches 已有索引是否与配置一致
+// 旧版本创建的索引没有记录参数，只能比较 FT.INFO 返回的算法、距离度量与分词语言，且配置中不能有算法参数
+func (info *IndexInfo) matches(conf IndexConfig, dim int) bool {
+	if info.Params != "" {
+		return info.Params == conf.params(dim)
+	}
+	return strings.EqualFold(info.Algorithm, conf.Algorithm) && strings.EqualFold(info.DistanceMetric, conf.DistanceMetric) &&
+		strings.EqualFold(info.Language, indexLanguage) && !conf.hasTuningParams()
+}
+
+// 索引参数记录在 <索引名>:params 中，与索引定义一起创建和删除
+func indexParamsKey(index string) string {
+	return index + ":params"
+}
+
+// EmbeddingDimension 通过一次探测请求获取当前向量模型的维度
+// 向量模型在进程运行期间不会变化，探测结果一直有效；并发探测时结果相同，重复写入没有影响
+func (redisRag *RedisRAG) EmbeddingDimension(ctx context.Context) (int, error) {
+	if dim := redisRag.dimension.Load(); dim > 0 {
+		return int(dim), nil
+	}
+	embedding, err := redisRag.GetEmbedding(ctx, dimensionProbeText)
+	if err != nil {
+		return 0, fmt.Errorf("probe embedding dimension failed: %w", err)
+	}
+	redisRag.dimension.Store(int64(len(embedding)))
+	return len(embedding), nil
+}
+
+// checkDimension 维度已经探测过时，拒绝写入维度不一致的向量
+func (redisRag *RedisRAG) checkDimension(embeddings [][]float32) error {
+	dim := int(redisRag.dimension.Load())
+	if dim <= 0 {
+		return nil
+	}
+	for _, embedding := range embeddings {
+		if len(embedding) != dim {
+			return fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, dim, len(embedding))
+		}
+	}
+	return nil
+}
+
+// 重新向量化时在新版本的索引中构建，完成后再切换，当前版本记录在 <索引名>:generation 中
+// 0 版本使用 indexName 与 embedding 字段，与之前创建的索引兼容
+func (redisRag *RedisRAG) generationKey(kb string) string {
+	return redisRag.indexName(kb) + ":generation"
+}
+
+func (redisRag *RedisRAG) generation(ctx context.Context, kb string) (int64, error) {
+	gen, err := redisRag.RedisClient.Get(ctx, redisRag.generationKey(kb)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read rag index generation failed: %w", err)
+	}
+	return gen, nil
+}
+
+func (redisRag *RedisRAG) indexNameAt(kb string, gen int64) string {
+	if gen == 0 {
+		return redisRag.indexName(kb)
+	}
+	return fmt.Sprintf("%s:v%d", redisRag.indexName(kb), gen)
+}
+
+func vectorFieldAt(gen int64) string {
+	if gen%2 == 0 {
+		return vectorField
+	}
+	return vectorFieldAlt
+}
+
+// liveIndex 当前用于检索与写入的索引名和向量字段
+func (redisRag *RedisRAG) liveIndex(ctx context.Context, kb string) (string, string, error) {
+	gen, err := redisRag.generation(ctx, kb)
+	if err != nil {
+		return "", "", err
+	}
+	return redisRag.indexNameAt(kb, gen), vectorFieldAt(gen), nil
+}
+
+// EnsureIndex 创建或迁移知识库的向量索引，kb 为空表示默认知识库
+// 索引不存在时按当前模型维度创建；算法、距离度量、算法参数或分词语言与配置不同时删除索引定义（保留数据）并重建；
+// 索引或已存储向量的维度与当前模型不一致时返回 ErrDimensionMismatch，需要重新向量化全部数据
+func (redisRag *RedisRAG) EnsureIndex(ctx context.Context, kb string, conf IndexConfig) error {
+	conf, err := conf.normalize()
+	if err != nil {
+		return err
+	}
+	dim, err := redisRag.EmbeddingDimension(ctx)
+	if err != nil {
+		return err
+	}
+	index, field, err := redisRag.liveIndex(ctx, kb)
+	if err != nil {
+		return err
+	}
+
+	info, err := redisRag.getIndexInfo(ctx, index)
+	if err != nil {
+		return err
+	}
+	if info.Exists && info.Dimension != 0 && info.Dimension != dim {
+		return fmt.Errorf("%w: index %s has dim %d, model %s produces %d",
+			ErrDimensionMismatch, index, info.Dimension, redisRag.EmbedderName(), dim)
+	}
+
+	storedDim, err := redisRag.sampleStoredDimension(ctx, kb, field)
+	if err != nil {
+		return err
+	}
+	if storedDim != 0 && storedDim != dim {
+		return fmt.Errorf("%w: stored vectors under %s have dim %d, model %s produces %d",
+			ErrDimensionMismatch, redisRag.keyPrefix(kb), storedDim, redisRag.EmbedderName(), dim)
+	}
+
+	if info.Exists {
+		if info.matches(conf, dim) {
+			if info.Params == "" {
+				// 旧索引与配置一致，补记参数，之后按完整参数比较
+				if err := redisRag.RedisClient.Set(ctx, indexParamsKey(index), conf.params(dim), 0).Err(); err != nil {
+					return fmt.Errorf("save rag index params failed: %w", err)
+				}
+			}
+			return nil
+		}
+		oldParams := info.Params
+		if oldParams == "" {
+			oldParams = fmt.Sprintf("LANGUAGE %s %s DISTANCE_METRIC %s", info.Language, info.Algorithm, info.DistanceMetric)
+		}
+		log.Printf("rag index %s migrating from [%s] to [%s]", index, oldParams, conf.params(dim))
+		// 不带 DD 参数，只删除索引定义，数据会在重建后重新被索引
+		if err := redisRag.RedisClient.Do(ctx, "FT.DROPINDEX", index).Err(); err != nil {
+			return fmt.Errorf("drop rag index failed: %w", err)
+		}
+	}
+
+	return redisRag.createIndex(ctx, kb, index, field, conf, dim)
+}
+
+// createIndex 在知识库的 key 前缀上创建索引，field 为保存向量的 hash 字段
+func (redisRag *RedisRAG) createIndex(ctx context.Context, kb string, index string, field string, conf IndexConfig, dim int) error {
+	vectorParams := conf.vectorParams(dim)
+	args := []interface{}{
+		"FT.CREATE", index, "ON", "HASH", "PREFIX", 1, redisRag.keyPrefix(kb),
+		"LANGUAGE", indexLanguage,
+		"SCHEMA",
+		"content", "TEXT",
+		"source", "TAG",
+		"title", "TEXT",
+		"heading", "TEXT",
+		"chunk_index", "NUMERIC",
+		"tags", "TAG", "SEPARATOR", tagSeparator,
+		field,
+	}
+	if field != vectorField {
+		args = append(args, "AS", vectorField)
+	}
+	args = append(args, "VECTOR", conf.Algorithm, len(vectorParams))
+	args = append(args, vectorParams...)
+	if err := redisRag.RedisClient.Do(ctx, args...).Err(); err != nil {
+		return fmt.Errorf("create rag index failed: %w", err)
+	}
+	if err := redisRag.RedisClient.Set(ctx, indexParamsKey(index), conf.params(dim), 0).Err(); err != nil {
+		return fmt.Errorf("save rag index params failed: %w", err)
+	}
+	log.Printf("rag index %s created: %s %s dim=%d", index, conf.Algorithm, conf.DistanceMetric, dim)
+	return nil
+}
+
+// dropIndexDefinition 只删除索引定义，数据保留，索引不存在时忽略
+func (redisRag *RedisRAG) dropIndexDefinition(ctx context.Context, index string) error {
+	if err := redisRag.RedisClient.Do(ctx, "FT.DROPINDEX", index).Err(); err != nil && !isUnknownIndexError(err) {
+		return fmt.Errorf("drop rag index failed: %w", err)
+	}
+	if err := redisRag.RedisClient.Del(ctx, indexParamsKey(index)).Err(); err != nil {
+		return fmt.Errorf("delete rag index params failed: %w", err)
+	}
+	return nil
+}
+
+// waitIndexed 等待索引完成对已有数据的扫描
+func (redisRag *RedisRAG) waitIndexed(ctx context.Context, index string) error {
+	ticker := time.NewTicker(indexPollInterval)
+	defer ticker.Stop()
+	for {
+		res, err := redisRag.RedisClient.Do(ctx, "FT.INFO", index).Result()
+		if err != nil {
+			return fmt.Errorf("read rag index info failed: %w", err)
+		}
+		if toInt64(toPairs(res)["indexing"]) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetIndexInfo 读取知识库当前索引中向量字段的定义，索引不存在时 Exists 为 false
+func (redisRag *RedisRAG) GetIndexInfo(ctx context.Context, kb string) (*IndexInfo, error) {
+	index, _, err := redisRag.liveIndex(ctx, kb)
+	if err != nil {
+		return nil, err
+	}
+	return redisRag.getIndexInfo(ctx, index)
+}
+
+func (redisRag *RedisRAG) getIndexInfo(ctx context.Context, index string) (*IndexInfo, error) {
+	res, err := redisRag.RedisClient.Do(ctx, "FT.INFO", index).Result()
+	if err != nil {
+		if isUnknownIndexError(err) {
+			return &IndexInfo{}, nil
+		}
+		return nil, fmt.Errorf("read rag index info failed: %w", err)
+	}
+
+	info := &IndexInfo{Exists: true}
+	fields := toPairs(res)
+	if v, ok := fields["num_docs"]; ok {
+		info.NumDocs = toInt64(v)
+	}
+	info.Language = strings.ToLower(toString(toPairs(fields["index_definition"])["default_language"]))
+	params, err := redisRag.RedisClient.Get(ctx, indexParamsKey(index)).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("read rag index params failed: %w", err)
+	}
+	info.Params = params
+	attributes, _ := fields["attributes"].([]interface{})
+	for _, attr := range attributes {
+		attrFields := toPairs(attr)
+		if !strings.EqualFold(toString(attrFields["type"]), "VECTOR") {
+			continue
+		}
+		info.Algorithm = strings.ToUpper(toString(attrFields["algorithm"]))
+		info.DistanceMetric = strings.ToUpper(toString(attrFields["distance_metric"]))
+		info.Dimension = int(toInt64(attrFields["dim"]))
+		break
+	}
+	return info, nil
+}
+
+// sampleStoredDimension 抽取一条已存储的向量，返回其维度；没有数据时返回 0
+func (redisRag *RedisRAG) sampleStoredDimension(ctx context.Context, kb string, field string) (int, error) {
+	var cursor uint64
+	for {
+		keys, next, err := redisRag.RedisClient.Scan(ctx, cursor, redisRag.keyPrefix(kb)+"*", 100).Result()
+		if err != nil {
+			return 0, fmt.Errorf("scan rag data failed: %w", err)
+		}
+		for _, key := range keys {
+			vector, err := redisRag.RedisClient.HGet(ctx, key, field).Bytes()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return 0, fmt.Errorf("read rag vector failed: %w", err)
+			}
+			return len(vector) / 4, nil
+		}
+		if next == 0 {
+			return 0, nil
+		}
+		cursor = next
+	}
+}
+
+// DropIndex 删除知识库的索引，deleteData 为 true 时同时删除知识库中的全部数据以及正在重新向量化的新版本索引
+func (redisRag *RedisRAG) DropIndex(ctx context.Context, kb string, deleteData bool) error {
+	gen, err := redisRag.generation(ctx, kb)
+	if err != nil {
+		return err
+	}
+	indexes := []string{redisRag.indexNameAt(kb, gen)}
+	if deleteData {
+		indexes = append(indexes, redisRag.indexNameAt(kb, gen+1))
+	}
+	for _, index := range indexes {
+		args := []interface{}{"FT.DROPINDEX", index}
+		if deleteData {
+			args = append(args, "DD")
+		}
+		if err := redisRag.RedisClient.Do(ctx, args...).Err(); err != nil && !isUnknownIndexError(err) {
+			return fmt.Errorf("drop rag index failed: %w", err)
+		}
+		if err := redisRag.RedisClient.Del(ctx, indexParamsKey(index)).Err(); err != nil {
+			return fmt.Errorf("delete rag index params failed: %w", err)
+		}
+	}
+	if !deleteData {
+		return nil
+	}
+	// 没有索引时 DD 不会生效，按前缀兜底清理
+	if err := redisRag.deleteByPattern(ctx, redisRag.keyPrefix(kb)+"*"); err != nil {
+		return err
+	}
+	if err := redisRag.RedisClient.Del(ctx, redisRag.generationKey(kb)).Err(); err != nil {
+		return fmt.Errorf("delete rag index generation failed: %w", err)
+	}
+	return redisRag.deleteDocumentRegistry(ctx, kb)
+}
+
+// ListIndexedKnowledgeBases 列出已建立索引的知识库，默认知识库为 DefaultKnowledgeBase
+func (redisRag *RedisRAG) ListIndexedKnowledgeBases(ctx context.Context) ([]string, error) {
+	res, err := redisRag.RedisClient.Do(ctx, "FT._LIST").Result()
+	if err != nil {
+		return nil, fmt.Errorf("list rag indexes failed: %w", err)
+	}
+	names, _ := res.([]interface{})
+	kbPrefix := strings.TrimSuffix(knowledgeBaseIndexFormat, "%s")
+	kbs := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		// 重新向量化后索引名带有 :v<版本> 后缀
+		index := indexVersionSuffix.ReplaceAllString(toString(name), "")
+		var kb string
+		switch {
+		case index == redisRag.indexName(DefaultKnowledgeBase):
+			kb = DefaultKnowledgeBase
+		case strings.HasPrefix(index, kbPrefix):
+			kb = strings.TrimPrefix(index, kbPrefix)
+		default:
+			continue
+		}
+		if !seen[kb] {
+			seen[kb] = true
+			kbs = append(kbs, kb)
+		}
+	}
+	return kbs, nil
+}
+
+func isUnknownIndexError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "unknown index") || strings.Contains(msg, "no such index") || strings.Contains(msg, "not found")
+}
+
+// toPairs 把 RediSearch 返回的 [k1, v1, k2, v2, ...] 转为 map，键统一小写
+func toPairs(v interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	list, ok := v.([]interface{})
+	if !ok {
+		return out
+	}
+	for i := 0; i+1 < len(list); i += 2 {
+		out[strings.ToLower(toString(list[i]))] = list[i+1]
+	}
+	return out
+}
+
+func toString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func toInt64(v interface{}) int64 {
+	switch val := v.(type) {
+	case int64:
+		return val
+	case string:
+		n, _ := strconv.ParseFloat(val, 64)
+		return int64(n)
+	default:
+		return 0
+	}
+}
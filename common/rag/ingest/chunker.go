@@ -0,0 +1,407 @@
+package ingest
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Strategy 分块策略
+type Strategy string
+
+const (
+	// StrategySentence 按句子切分，长度按字符计算
+	StrategySentence Strategy = "sentence"
+	// StrategyHeading 先按 Markdown 标题切成章节，再在章节内按句子切分，分块带上章节标题
+	StrategyHeading Strategy = "heading"
+	// StrategyCJK 按句子切分，长度按估算 token 计算（每个汉字算一个，连续的字母数字算一个），
+	// 超长句子优先在中文逗号、顿号处断开
+	StrategyCJK Strategy = "cjk"
+)
+
+const (
+	DefaultChunkSize    = 500
+	DefaultChunkOverlap = 50
+)
+
+// DefaultOverlapFor 未指定重叠长度时的默认值：分块大小的 10%，不超过 DefaultChunkOverlap
+func DefaultOverlapFor(size int) int {
+	return min(DefaultChunkOverlap, size/10)
+}
+
+// ChunkOptions 分块配置，Size 与 Overlap 的单位由策略决定
+type ChunkOptions struct {
+	Strategy Strategy
+	Size     int
+	Overlap  int
+}
+
+// Chunk 文档分块
+type Chunk struct {
+	Index   int
+	Heading string
+	Content string
+}
+
+// ParseStrategy 解析策略名称，空字符串使用 sentence
+func ParseStrategy(name string) (Strategy, error) {
+	switch Strategy(strings.ToLower(strings.TrimSpace(name))) {
+	case "", StrategySentence:
+		return StrategySentence, nil
+	case StrategyHeading:
+		return StrategyHeading, nil
+	case StrategyCJK:
+		return StrategyCJK, nil
+	default:
+		return "", fmt.Errorf("unsupported chunk strategy: %s", name)
+	}
+}
+
+// Validate 检查补全默认值后的分块配置，重叠长度必须小于分块大小
+func (o ChunkOptions) Validate() error {
+	_, err := o.normalize()
+	return err
+}
+
+func (o ChunkOptions) normalize() (ChunkOptions, error) {
+	strategy, err := ParseStrategy(string(o.Strategy))
+	if err != nil {
+		return o, err
+	}
+	o.Strategy = strategy
+	if o.Size <= 0 {
+		o.Size = DefaultChunkSize
+	}
+	if o.Overlap < 0 {
+		return o, fmt.Errorf("chunk overlap must not be negative")
+	}
+	if o.Overlap >= o.Size {
+		return o, fmt.Errorf("chunk overlap %d must be smaller than chunk size %d", o.Overlap, o.Size)
+	}
+	return o, nil
+}
+
+// Split 按配置把文档切分成若干分块
+func Split(doc *Document, opts ChunkOptions) ([]Chunk, error) {
+	opts, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []section
+	if opts.Strategy == StrategyHeading {
+		sections = splitSections(doc.Content)
+	} else {
+		sections = []section{{text: doc.Content}}
+	}
+
+	measure := runeLen
+	if opts.Strategy == StrategyCJK {
+		measure = tokenLen
+	}
+
+	chunks := make([]Chunk, 0)
+	for _, sec := range sections {
+		for _, text := range packSentences(splitSentences(sec.text), opts, measure) {
+			content := text
+			if sec.heading != "" {
+				// 章节标题拼在分块前面，检索时能带上上下文
+				content = sec.heading + "\n" + text
+			}
+			chunks = append(chunks, Chunk{
+				Index:   len(chunks),
+				Heading: sec.heading,
+				Content: content,
+			})
+		}
+	}
+	return chunks, nil
+}
+
+type section struct {
+	heading string
+	text    string
+}
+
+// splitSections 按 Markdown 标题切分章节，heading 为 "一级 > 二级" 形式的标题路径
+func splitSections(content string) []section {
+	type heading struct {
+		level int
+		text  string
+	}
+	var (
+		sections []section
+		stack    []heading
+		body     []string
+	)
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(body, "\n"))
+		if text != "" {
+			path := make([]string, 0, len(stack))
+			for _, h := range stack {
+				path = append(path, h.text)
+			}
+			sections = append(sections, section{heading: strings.Join(path, " > "), text: text})
+		}
+		body = body[:0]
+	}
+
+	inFence := false
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		level, text := 0, ""
+		if !inFence {
+			level, text = parseHeading(line)
+		}
+		if level == 0 {
+			body = append(body, line)
+			continue
+		}
+		flush()
+		for len(stack) > 0 && stack[len(stack)-1].level >= level {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, heading{level: level, text: text})
+	}
+	flush()
+	return sections
+}
+
+// splitSentences 按中英文句末标点与空行切分句子
+func splitSentences(text string) []string {
+	var (
+		sentences []string
+		current   strings.Builder
+	)
+	flush := func() {
+		s := strings.TrimSpace(current.String())
+		if s != "" {
+			sentences = append(sentences, s)
+		}
+		current.Reset()
+	}
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\n' {
+			// 空行视为段落结束
+			if i+1 < len(runes) && runes[i+1] == '\n' {
+				flush()
+				continue
+			}
+			current.WriteRune(' ')
+			continue
+		}
+		current.WriteRune(r)
+		switch r {
+		case '。', '！', '？', '；', '…':
+			// 吸收紧跟的右引号、右括号
+			for i+1 < len(runes) && isClosingPunct(runes[i+1]) {
+				i++
+				current.WriteRune(runes[i])
+			}
+			flush()
+		case '.', '!', '?', ';':
+			if i+1 == len(runes) || unicode.IsSpace(runes[i+1]) {
+				flush()
+			}
+		}
+	}
+	flush()
+	return sentences
+}
+
+func isClosingPunct(r rune) bool {
+	switch r {
+	case '”', '’', '」', '』', '）', ')', '"', '\'':
+		return true
+	}
+	return false
+}
+
+// packSentences 把句子组合成不超过 Size 的分块，相邻分块之间保留不超过 Overlap 的句子重叠
+func packSentences(sentences []string, opts ChunkOptions, measure func(string) int) []string {
+	units := make([]string, 0, len(sentences))
+	for _, s := range sentences {
+		if measure(s) <= opts.Size {
+			units = append(units, s)
+			continue
+		}
+		units = append(units, splitLongSentence(s, opts, measure)...)
+	}
+
+	var (
+		chunks  []string
+		current []string
+		size    int
+	)
+	for _, unit := range units {
+		n := measure(unit)
+		if len(current) > 0 && size+n > opts.Size {
+			chunks = append(chunks, joinSentences(current))
+			current, size = overlapTail(current, opts.Overlap, measure)
+			// 重叠加上新句子仍然超长时放弃重叠
+			if size+n > opts.Size {
+				current, size = nil, 0
+			}
+		}
+		current = append(current, unit)
+		size += n
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, joinSentences(current))
+	}
+	return chunks
+}
+
+// overlapTail 取上一个分块末尾总长度不超过 overlap 的若干句子
+func overlapTail(sentences []string, overlap int, measure func(string) int) ([]string, int) {
+	if overlap <= 0 {
+		return nil, 0
+	}
+	size := 0
+	start := len(sentences)
+	for start > 0 {
+		n := measure(sentences[start-1])
+		if size+n > overlap {
+			break
+		}
+		size += n
+		start--
+	}
+	return append([]string(nil), sentences[start:]...), size
+}
+
+// splitLongSentence 拆分超过 Size 的句子
+func splitLongSentence(s string, opts ChunkOptions, measure func(string) int) []string {
+	var parts []string
+	if opts.Strategy == StrategyCJK {
+		parts = splitKeep(s, "，、,：:")
+	} else {
+		parts = strings.Fields(s)
+	}
+
+	var (
+		out     []string
+		current []string
+		size    int
+	)
+	for _, part := range parts {
+		n := measure(part)
+		if n > opts.Size {
+			if len(current) > 0 {
+				out = append(out, joinSentences(current))
+				current, size = nil, 0
+			}
+			out = append(out, hardSplit(part, opts.Size, measure)...)
+			continue
+		}
+		if len(current) > 0 && size+n > opts.Size {
+			out = append(out, joinSentences(current))
+			current, size = nil, 0
+		}
+		current = append(current, part)
+		size += n
+	}
+	if len(current) > 0 {
+		out = append(out, joinSentences(current))
+	}
+	return out
+}
+
+// splitKeep 在分隔符之后断开，分隔符保留在前一段
+func splitKeep(s string, seps string) []string {
+	var (
+		out     []string
+		current strings.Builder
+	)
+	for _, r := range s {
+		current.WriteRune(r)
+		if strings.ContainsRune(seps, r) {
+			out = append(out, current.String())
+			current.Reset()
+		}
+	}
+	if current.Len() > 0 {
+		out = append(out, current.String())
+	}
+	return out
+}
+
+// hardSplit 在字符边界上强制切分
+func hardSplit(s string, size int, measure func(string) int) []string {
+	var (
+		out   []string
+		start int
+	)
+	for i := range s {
+		if i > start && measure(s[start:i]) >= size {
+			out = append(out, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		out = append(out, s[start:])
+	}
+	return out
+}
+
+// joinSentences 中文之间直接拼接，其余用空格分隔
+func joinSentences(parts []string) string {
+	var sb strings.Builder
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if i > 0 && sb.Len() > 0 {
+			last, _ := utf8.DecodeLastRuneInString(sb.String())
+			first, _ := utf8.DecodeRuneInString(p)
+			if !isCJK(last) || !isCJK(first) {
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString(p)
+	}
+	return sb.String()
+}
+
+func runeLen(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// tokenLen 估算 token 数：每个中日韩文字算一个，连续的字母数字（包括全角字母数字）算一个，标点和空白不计
+func tokenLen(s string) int {
+	n := 0
+	inWord := false
+	for _, r := range s {
+		switch {
+		case isCJKLetter(r):
+			n++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				n++
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+	}
+	return n
+}
+
+// isCJK 拼接句子时判断是否需要空格，包括中日韩标点和全角字符
+func isCJK(r rune) bool {
+	return isCJKLetter(r) || (r >= 0x3000 && r <= 0x303F) || (r >= 0xFF00 && r <= 0xFFEF)
+}
+
+// isCJKLetter 中日韩文字本身，不包括标点与全角字母数字
+func isCJKLetter(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
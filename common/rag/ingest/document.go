@@ -0,0 +1,154 @@
+package ingest
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format 支持导入的文档格式
+type Format string
+
+const (
+	FormatMarkdown Format = "md"
+	FormatText     Format = "txt"
+	FormatHTML     Format = "html"
+	FormatPDF      Format = "pdf"
+)
+
+// Document 解析后的文档，Content 为纯文本（Markdown 保留标题行，HTML 标题会转换为 Markdown 标题）
+type Document struct {
+	ID            string // 文档ID，为空时由 Source 生成，同一知识库中相同ID的文档会被替换
+	KnowledgeBase string // 写入的知识库，为空表示默认知识库
+	Source        string
+	Title         string
+	Format        Format
+	Content       string
+	Tags          []string
+}
+
+// ParseFormat 解析格式名称，支持常见别名
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), ".")) {
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	case "txt", "text":
+		return FormatText, nil
+	case "html", "htm":
+		return FormatHTML, nil
+	case "pdf":
+		return FormatPDF, nil
+	default:
+		return "", fmt.Errorf("unsupported document format: %s", name)
+	}
+}
+
+// DetectFormat 根据文件扩展名判断格式，扩展名无法识别时根据内容嗅探
+func DetectFormat(fileName string, data []byte) (Format, error) {
+	if ext := filepath.Ext(fileName); ext != "" {
+		if format, err := ParseFormat(ext); err == nil {
+			return format, nil
+		}
+	}
+
+	contentType := http.DetectContentType(data)
+	switch {
+	case strings.HasPrefix(contentType, "application/pdf"):
+		return FormatPDF, nil
+	case strings.HasPrefix(contentType, "text/html"):
+		return FormatHTML, nil
+	case strings.HasPrefix(contentType, "text/plain") && utf8.Valid(data):
+		return FormatText, nil
+	default:
+		return "", fmt.Errorf("cannot detect format of %s (%s)", fileName, contentType)
+	}
+}
+
+// Parse 把原始文件内容解析为 Document，format 为空时自动识别
+func Parse(source string, data []byte, format Format) (*Document, error) {
+	if format == "" {
+		var err error
+		format, err = DetectFormat(source, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	doc := &Document{Source: source, Format: format}
+	var err error
+	switch format {
+	case FormatMarkdown:
+		doc.Content, doc.Title = parseMarkdown(data)
+	case FormatText:
+		doc.Content = normalizeText(string(data))
+	case FormatHTML:
+		doc.Content, doc.Title, err = parseHTML(data)
+	case FormatPDF:
+		doc.Content, doc.Title, err = parsePDF(data)
+	default:
+		err = fmt.Errorf("unsupported document format: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s failed: %w", source, err)
+	}
+	if strings.TrimSpace(doc.Content) == "" {
+		return nil, fmt.Errorf("parse %s failed: document has no text content", source)
+	}
+	if doc.Title == "" {
+		doc.Title = strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	}
+	return doc, nil
+}
+
+// LoadFile 读取并解析本地文件
+func LoadFile(path string, format Format) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(filepath.Base(path), data, format)
+}
+
+// 统一换行符并去掉 BOM
+func normalizeText(text string) string {
+	text = strings.TrimPrefix(text, "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.TrimSpace(text)
+}
+
+// Markdown 保留原文，取第一个一级标题作为文档标题
+func parseMarkdown(data []byte) (string, string) {
+	content := normalizeText(string(data))
+	title := ""
+	for _, line := range strings.Split(content, "\n") {
+		if level, text := parseHeading(line); level == 1 {
+			title = text
+			break
+		}
+	}
+	return content, title
+}
+
+// parseHeading 解析 Markdown ATX 标题，非标题行返回 level 0
+func parseHeading(line string) (int, string) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, ""
+	}
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, ""
+	}
+	rest := trimmed[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(strings.TrimRight(strings.TrimSpace(rest), "#"))
+}
@@ -0,0 +1,151 @@
+package ingest
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 解析 HTML 为文本，h1-h6 转换为 Markdown 标题，便于按标题切分
+func parseHTML(data []byte) (string, string, error) {
+	root, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", "", err
+	}
+
+	var (
+		sb       strings.Builder
+		title    string
+		firstH1  string
+		walkNode func(n *html.Node)
+	)
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+	}
+
+	walkNode = func(n *html.Node) {
+		switch n.Type {
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Svg, atom.Head:
+				if n.DataAtom == atom.Head {
+					if t := findTitle(n); t != "" {
+						title = t
+					}
+				}
+				return
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				text := strings.Join(strings.Fields(textOf(n)), " ")
+				if text == "" {
+					return
+				}
+				if n.DataAtom == atom.H1 && firstH1 == "" {
+					firstH1 = text
+				}
+				level := int(n.Data[1] - '0')
+				newline()
+				sb.WriteString("\n" + strings.Repeat("#", level) + " " + text + "\n")
+				return
+			case atom.Br:
+				sb.WriteString("\n")
+				return
+			}
+			block := isBlock(n.DataAtom)
+			if block {
+				newline()
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				walkNode(c)
+			}
+			if block {
+				newline()
+			}
+			return
+		case html.TextNode:
+			// 保留文本节点首尾是否有空白，行内元素之间按原文决定是否加空格
+			text := strings.Join(strings.Fields(n.Data), " ")
+			if text == "" {
+				if n.Data != "" && sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") && !strings.HasSuffix(sb.String(), " ") {
+					sb.WriteString(" ")
+				}
+				return
+			}
+			if unicode.IsSpace(rune(n.Data[0])) && sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") && !strings.HasSuffix(sb.String(), " ") {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(text)
+			if unicode.IsSpace(rune(n.Data[len(n.Data)-1])) {
+				sb.WriteString(" ")
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walkNode(c)
+		}
+	}
+	walkNode(root)
+
+	if title == "" {
+		title = firstH1
+	}
+	return normalizeText(collapseBlankLines(sb.String())), title, nil
+}
+
+func findTitle(n *html.Node) string {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Title {
+		return strings.Join(strings.Fields(textOf(n)), " ")
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if t := findTitle(c); t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+func textOf(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textOf(c))
+		sb.WriteString(" ")
+	}
+	return sb.String()
+}
+
+func isBlock(a atom.Atom) bool {
+	switch a {
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer, atom.Aside, atom.Nav,
+		atom.Ul, atom.Ol, atom.Li, atom.Table, atom.Tr, atom.Blockquote, atom.Pre, atom.Dl, atom.Dt, atom.Dd,
+		atom.Figure, atom.Figcaption, atom.Hr, atom.Body:
+		return true
+	}
+	return false
+}
+
+// 连续空行合并为一个空行
+func collapseBlankLines(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
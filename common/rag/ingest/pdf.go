@@ -0,0 +1,331 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// PDF 只做文本层提取：支持未压缩或 FlateDecode 压缩的内容流，以及 Tj/TJ/'/" 文本操作符
+// 扫描件或使用 CID 字体且没有 ToUnicode 映射的 PDF 无法提取，会返回错误
+var (
+	pdfStreamRe = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	pdfTitleRe  = regexp.MustCompile(`/Title\s*\(((?:\\.|[^\\)])*)\)`)
+)
+
+const maxPDFStreamSize = 32 << 20
+
+func parsePDF(data []byte) (string, string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF")) {
+		return "", "", errors.New("not a pdf file")
+	}
+
+	var sb strings.Builder
+	for _, loc := range pdfStreamRe.FindAllSubmatchIndex(data, -1) {
+		dict := string(data[loc[2]:loc[3]])
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+		raw := data[start : start+end]
+
+		// 字体、图片等非内容流直接跳过
+		if strings.Contains(dict, "/Subtype") || strings.Contains(dict, "/Type /XObject") || strings.Contains(dict, "/Length1") {
+			continue
+		}
+		stream, ok := decodePDFStream(dict, raw)
+		if !ok {
+			continue
+		}
+		if text := extractPDFText(stream); strings.TrimSpace(text) != "" {
+			sb.WriteString(text)
+			sb.WriteString("\n\n")
+		}
+	}
+
+	content := normalizeText(collapseBlankLines(sb.String()))
+	if content == "" {
+		return "", "", errors.New("no extractable text in pdf (scanned or unsupported encoding)")
+	}
+
+	title := ""
+	if m := pdfTitleRe.FindSubmatch(data); m != nil {
+		title = strings.TrimSpace(decodePDFString(unescapePDFLiteral(m[1])))
+	}
+	return content, title, nil
+}
+
+func decodePDFStream(dict string, raw []byte) ([]byte, bool) {
+	if !strings.Contains(dict, "/Filter") {
+		return raw, true
+	}
+	// 只支持单一 FlateDecode
+	if !strings.Contains(dict, "/FlateDecode") || strings.Contains(dict, "/DCTDecode") || strings.Contains(dict, "/LZWDecode") ||
+		strings.Contains(dict, "/ASCII85Decode") || strings.Contains(dict, "/ASCIIHexDecode") {
+		return nil, false
+	}
+	r, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, false
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxPDFStreamSize))
+	if err != nil && len(out) == 0 {
+		return nil, false
+	}
+	return out, true
+}
+
+// extractPDFText 解析内容流中的文本操作符
+func extractPDFText(stream []byte) string {
+	var (
+		sb       strings.Builder
+		operands []pdfOperand
+		inText   bool
+	)
+	emit := func(s string) {
+		sb.WriteString(s)
+	}
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+	}
+
+	for i := 0; i < len(stream); {
+		c := stream[i]
+		switch {
+		case c == '(':
+			s, next := readPDFLiteral(stream, i)
+			operands = append(operands, pdfOperand{str: s, isStr: true})
+			i = next
+		case c == '<' && i+1 < len(stream) && stream[i+1] != '<':
+			s, next := readPDFHex(stream, i)
+			operands = append(operands, pdfOperand{str: s, isStr: true})
+			i = next
+		case c == '[':
+			operands = append(operands, pdfOperand{arrayStart: true})
+			i++
+		case c == ']':
+			// 把数组内容折叠成一个字符串操作数，负数较大的间距视为空格
+			j := len(operands) - 1
+			for j >= 0 && !operands[j].arrayStart {
+				j--
+			}
+			var part strings.Builder
+			if j >= 0 {
+				for _, op := range operands[j+1:] {
+					if op.isStr {
+						part.WriteString(decodePDFString(op.str))
+					} else if op.num < -200 {
+						part.WriteString(" ")
+					}
+				}
+				operands = operands[:j]
+			}
+			operands = append(operands, pdfOperand{str: []byte(part.String()), isStr: true, decoded: true})
+			i++
+		case c == '%':
+			for i < len(stream) && stream[i] != '\n' && stream[i] != '\r' {
+				i++
+			}
+		case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(stream) && (stream[j] == '.' || (stream[j] >= '0' && stream[j] <= '9')) {
+				j++
+			}
+			num, _ := strconv.ParseFloat(string(stream[i:j]), 64)
+			operands = append(operands, pdfOperand{num: num})
+			i = j
+		case c == '/':
+			j := i + 1
+			for j < len(stream) && !isPDFDelimiter(stream[j]) {
+				j++
+			}
+			operands = append(operands, pdfOperand{})
+			i = j
+		case isPDFDelimiter(c):
+			i++
+		default:
+			j := i
+			for j < len(stream) && !isPDFDelimiter(stream[j]) {
+				j++
+			}
+			if j == i {
+				j++
+			}
+			op := string(stream[i:j])
+			i = j
+
+			switch op {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				newline()
+			case "Tj", "TJ":
+				if inText && len(operands) > 0 {
+					emit(operands[len(operands)-1].text())
+				}
+			case "'", "\"":
+				if inText && len(operands) > 0 {
+					newline()
+					emit(operands[len(operands)-1].text())
+				}
+			case "T*", "TD":
+				newline()
+			case "Td":
+				if len(operands) >= 2 && operands[len(operands)-1].num != 0 {
+					newline()
+				} else if sb.Len() > 0 && !strings.HasSuffix(sb.String(), " ") && !strings.HasSuffix(sb.String(), "\n") {
+					emit(" ")
+				}
+			}
+			operands = operands[:0]
+		}
+	}
+	return sb.String()
+}
+
+type pdfOperand struct {
+	str        []byte
+	num        float64
+	isStr      bool
+	decoded    bool
+	arrayStart bool
+}
+
+func (o pdfOperand) text() string {
+	if !o.isStr {
+		return ""
+	}
+	if o.decoded {
+		return string(o.str)
+	}
+	return decodePDFString(o.str)
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// 读取字面量字符串 (...)，处理括号嵌套与转义
+func readPDFLiteral(data []byte, start int) ([]byte, int) {
+	depth := 0
+	i := start
+	var raw []byte
+	for ; i < len(data); i++ {
+		c := data[i]
+		if c == '\\' && i+1 < len(data) {
+			raw = append(raw, c, data[i+1])
+			i++
+			continue
+		}
+		if c == '(' {
+			depth++
+			if depth == 1 {
+				continue
+			}
+		} else if c == ')' {
+			depth--
+			if depth == 0 {
+				i++
+				break
+			}
+		}
+		raw = append(raw, c)
+	}
+	return unescapePDFLiteral(raw), i
+}
+
+func unescapePDFLiteral(raw []byte) []byte {
+	out := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != '\\' || i+1 >= len(raw) {
+			out = append(out, c)
+			continue
+		}
+		i++
+		switch raw[i] {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case '\r', '\n':
+			// 行尾续行
+		default:
+			if raw[i] >= '0' && raw[i] <= '7' {
+				j := i
+				for j < len(raw) && j < i+3 && raw[j] >= '0' && raw[j] <= '7' {
+					j++
+				}
+				v, _ := strconv.ParseUint(string(raw[i:j]), 8, 8)
+				out = append(out, byte(v))
+				i = j - 1
+			} else {
+				out = append(out, raw[i])
+			}
+		}
+	}
+	return out
+}
+
+// 读取十六进制字符串 <...>
+func readPDFHex(data []byte, start int) ([]byte, int) {
+	end := bytes.IndexByte(data[start:], '>')
+	if end < 0 {
+		return nil, len(data)
+	}
+	hex := make([]byte, 0, end)
+	for _, c := range data[start+1 : start+end] {
+		if unicode.Is(unicode.ASCII_Hex_Digit, rune(c)) {
+			hex = append(hex, c)
+		}
+	}
+	if len(hex)%2 == 1 {
+		hex = append(hex, '0')
+	}
+	out := make([]byte, 0, len(hex)/2)
+	for i := 0; i < len(hex); i += 2 {
+		v, _ := strconv.ParseUint(string(hex[i:i+2]), 16, 8)
+		out = append(out, byte(v))
+	}
+	return out, start + end + 1
+}
+
+// decodePDFString UTF-16BE（带 BOM）按 Unicode 解码，其余按 Latin-1 处理；不可打印内容丢弃
+func decodePDFString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		u := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	var sb strings.Builder
+	for _, c := range b {
+		r := rune(c)
+		if unicode.IsPrint(r) || r == '\n' || r == '\t' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,103 @@
+package ingest
+
+import (
+	"GopherAI/common/rag"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Pipeline 文档导入流程：解析 -> 分块 -> 向量化并连同元数据写入 RAG 数据库
+type Pipeline struct {
+	db   rag.RAGDatabase
+	opts ChunkOptions
+}
+
+// Result 单个文档的导入结果
+type Result struct {
+	DocumentID string `json:"documentId,omitempty"`
+	Source     string `json:"source"`
+	Title      string `json:"title"`
+	Format     Format `json:"format"`
+	Chunks     int    `json:"chunks"`
+	Stored     int    `json:"stored"`
+}
+
+func NewPipeline(db rag.RAGDatabase, opts ChunkOptions) *Pipeline {
+	return &Pipeline{db: db, opts: opts}
+}
+
+// Ingest 导入一个已解析的文档，整篇文档一起向量化后写入，已存在的同ID文档会被替换
+// 写入失败时原文档保持不变
+func (p *Pipeline) Ingest(ctx context.Context, doc *Document) (*Result, error) {
+	chunks, err := Split(doc, p.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Source: doc.Source,
+		Title:  doc.Title,
+		Format: doc.Format,
+		Chunks: len(chunks),
+	}
+	records := make([]rag.Record, len(chunks))
+	for i, chunk := range chunks {
+		records[i] = rag.Record{
+			Content: chunk.Content,
+			Metadata: rag.Metadata{
+				KnowledgeBase: doc.KnowledgeBase,
+				Source:        doc.Source,
+				Title:         doc.Title,
+				Heading:       chunk.Heading,
+				ChunkIndex:    chunk.Index,
+				Tags:          doc.Tags,
+			},
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	info, err := p.db.UpsertDocument(ctx, rag.DocumentInfo{
+		ID:            doc.ID,
+		KnowledgeBase: doc.KnowledgeBase,
+		Source:        doc.Source,
+		Title:         doc.Title,
+		Tags:          doc.Tags,
+	}, records)
+	if err != nil {
+		return result, fmt.Errorf("store chunks of %s failed: %w", doc.Source, err)
+	}
+	result.DocumentID = info.ID
+	result.Stored = info.Chunks
+	return result, nil
+}
+
+// IngestBytes 解析原始文件内容并导入
+func (p *Pipeline) IngestBytes(ctx context.Context, source string, data []byte, format Format, title string, tags []string) (*Result, error) {
+	doc, err := Parse(source, data, format)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(title) != "" {
+		doc.Title = strings.TrimSpace(title)
+	}
+	doc.Tags = tags
+	return p.Ingest(ctx, doc)
+}
+
+// ParseTags 解析逗号分隔的标签，去掉空白与重复项
+func ParseTags(raw string) []string {
+	seen := make(map[string]bool)
+	tags := make([]string, 0)
+	for _, tag := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == '，' }) {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
@@ -0,0 +1,702 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BM25 参数，与 RediSearch 默认值一致
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// 持久化文件格式版本
+	memoryFileVersion = 1
+)
+
+// MemoryRAG 纯 Go 实现的 RAGDatabase，不依赖 Redis Stack
+// 向量检索对全部分块暴力计算余弦距离，关键词检索在进程内计算 BM25，适合本地开发、测试与 CI 中的小规模数据
+// 设置 Path 时每次写入后把全部数据保存到文件，读取前发现文件被其他进程更新会重新加载；同一文件同时只应有一个进程写入
+type MemoryRAG struct {
+	Embedder Embedder
+	Path     string // 持久化文件，为空时数据只保存在内存中
+
+	mu       sync.Mutex
+	kbs      map[string]*memoryKnowledgeBase
+	loadedAt time.Time // 最近一次读取或写入文件时文件的修改时间
+}
+
+type memoryKnowledgeBase struct {
+	Chunks    map[string]*memoryChunk  `json:"chunks"`
+	Documents map[string]*DocumentInfo `json:"documents"`
+}
+
+type memoryChunk struct {
+	ID        string   `json:"id"`
+	Content   string   `json:"content"`
+	Embedding []byte   `json:"embedding"` // 与 Redis 中相同的 float32 小端字节
+	Metadata  Metadata `json:"metadata"`
+
+	vector []float32
+	terms  map[string]int // 关键词词频
+	length int
+}
+
+// memoryFile 持久化文件的内容
+type memoryFile struct {
+	Version        int                             `json:"version"`
+	KnowledgeBases map[string]*memoryKnowledgeBase `json:"knowledgeBases"`
+}
+
+// NewMemoryRAG 创建只保存在内存中的 RAG 数据库
+func NewMemoryRAG(embedder Embedder) *MemoryRAG {
+	return &MemoryRAG{Embedder: embedder, kbs: make(map[string]*memoryKnowledgeBase)}
+}
+
+// OpenFileRAG 创建保存到文件的 RAG 数据库，文件已存在时加载其中的数据
+func OpenFileRAG(path string, embedder Embedder) (*MemoryRAG, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("rag file path is empty")
+	}
+	memoryRag := NewMemoryRAG(embedder)
+	memoryRag.Path = path
+	if err := memoryRag.load(); err != nil {
+		return nil, err
+	}
+	return memoryRag, nil
+}
+
+// EmbedderName 当前向量模型的名称
+func (memoryRag *MemoryRAG) EmbedderName() string {
+	if memoryRag.Embedder == nil {
+		return ""
+	}
+	return memoryRag.Embedder.Name()
+}
+
+func (memoryRag *MemoryRAG) GetEmbedding(ctx context.Context, content string) ([]float32, error) {
+	vectors, err := memoryRag.GetEmbeddings(ctx, []string{content})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// GetEmbeddings 批量获取向量，返回与 contents 一一对应的结果
+func (memoryRag *MemoryRAG) GetEmbeddings(ctx context.Context, contents []string) ([][]float32, error) {
+	for _, content := range contents {
+		if strings.TrimSpace(content) == "" {
+			return nil, fmt.Errorf("content is empty")
+		}
+	}
+	if memoryRag.Embedder == nil {
+		return nil, fmt.Errorf("embedder is nil")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	vectors, err := memoryRag.Embedder.EmbedBatch(ctx, contents)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(contents) {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d texts", len(vectors), len(contents))
+	}
+	// 同一批向量的维度必须一致
+	for _, vector := range vectors[1:] {
+		if len(vector) != len(vectors[0]) {
+			return nil, fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, len(vectors[0]), len(vector))
+		}
+	}
+	return vectors, nil
+}
+
+func (memoryRag *MemoryRAG) AddOneData(ctx context.Context, content string) error {
+	return memoryRag.AddData(ctx, content, Metadata{})
+}
+
+func (memoryRag *MemoryRAG) AddData(ctx context.Context, content string, metadata Metadata) error {
+	return memoryRag.AddDataBatch(ctx, []Record{{Content: content, Metadata: metadata}})
+}
+
+// AddDataBatch 批量写入不属于任何文档的数据，任意一条失败时都不写入
+func (memoryRag *MemoryRAG) AddDataBatch(ctx context.Context, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	contents := make([]string, len(records))
+	for i, record := range records {
+		contents[i] = record.Content
+	}
+	embeddings, err := memoryRag.GetEmbeddings(ctx, contents)
+	if err != nil {
+		return err
+	}
+
+	memoryRag.mu.Lock()
+	defer memoryRag.mu.Unlock()
+	if err := memoryRag.refreshLocked(); err != nil {
+		return err
+	}
+	now := time.Now().UnixNano()
+	for i, record := range records {
+		if err := memoryRag.knowledgeBase(record.Metadata.KnowledgeBase, false).checkDimension(len(embeddings[i]), nil); err != nil {
+			return err
+		}
+	}
+	for i, record := range records {
+		metadata := record.Metadata
+		kb := memoryRag.knowledgeBase(metadata.KnowledgeBase, true)
+		metadata.KnowledgeBase = knowledgeBaseLabel(metadata.KnowledgeBase)
+		kb.put(newMemoryChunk(fmt.Sprintf("%s%d", dataKeyPrefix(metadata.KnowledgeBase), now+int64(i)), record.Content, embeddings[i], metadata))
+	}
+	return memoryRag.saveLocked()
+}
+
+func (memoryRag *MemoryRAG) GetKNN(ctx context.Context, content string, k int, scope Scope) ([]*RagReturnedData, error) {
+	embedding, err := memoryRag.GetEmbedding(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	memoryRag.mu.Lock()
+	defer memoryRag.mu.Unlock()
+	if err := memoryRag.refreshLocked(); err != nil {
+		return nil, err
+	}
+	results := make([]*RagReturnedData, 0)
+	for _, kb := range scope.knowledgeBases() {
+		results = append(results, memoryRag.knowledgeBase(kb, false).vectorSearch(embedding, k, Filter{})...)
+	}
+	return mergeByScore(results, k), nil
+}
+
+// Search 与 RedisRAG.Search 的语义一致，vector 模式只支持余弦距离
+func (memoryRag *MemoryRAG) Search(ctx context.Context, query string, scope Scope, opts SearchOptions) ([]*RagReturnedData, error) {
+	filter := opts.Filter
+	return search(ctx, query, scope, opts, searchFuncs{
+		metric: "COSINE",
+		embed:  memoryRag.GetEmbedding,
+		vector: func(_ context.Context, kb string, embedding []float32, k int) ([]*RagReturnedData, error) {
+			memoryRag.mu.Lock()
+			defer memoryRag.mu.Unlock()
+			if err := memoryRag.refreshLocked(); err != nil {
+				return nil, err
+			}
+			return memoryRag.knowledgeBase(kb, false).vectorSearch(embedding, k, filter), nil
+		},
+		keyword: func(_ context.Context, kb string, query string, k int) ([]*RagReturnedData, error) {
+			memoryRag.mu.Lock()
+			defer memoryRag.mu.Unlock()
+			if err := memoryRag.refreshLocked(); err != nil {
+				return nil, err
+			}
+			return memoryRag.knowledgeBase(kb, false).keywordSearch(keywordTerms(query), k, filter), nil
+		},
+	})
+}
+
+// UpsertDocument 写入文档，文档已存在时替换其全部分块
+func (memoryRag *MemoryRAG) UpsertDocument(ctx context.Context, doc DocumentInfo, records []Record) (*DocumentInfo, error) {
+	if doc.ID == "" {
+		if strings.TrimSpace(doc.Source) == "" {
+			return nil, fmt.Errorf("%w: id and source are both empty", ErrInvalidDocumentID)
+		}
+		doc.ID = DocumentID(doc.Source)
+	}
+	if !ValidDocumentID(doc.ID) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDocumentID, doc.ID)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("document %s has no content", doc.ID)
+	}
+	contents := make([]string, len(records))
+	for i, record := range records {
+		contents[i] = record.Content
+	}
+	embeddings, err := memoryRag.GetEmbeddings(ctx, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	memoryRag.mu.Lock()
+	defer memoryRag.mu.Unlock()
+	if err := memoryRag.refreshLocked(); err != nil {
+		return nil, err
+	}
+	kbID := doc.KnowledgeBase
+	doc.KnowledgeBase = knowledgeBaseLabel(kbID)
+	kb := memoryRag.knowledgeBase(kbID, true)
+	old := kb.Documents[doc.ID]
+	// 被替换的旧分块不参与维度检查
+	replaced := make(map[string]bool)
+	if old != nil {
+		for i := 0; i < old.Chunks; i++ {
+			replaced[dataChunkKey(kbID, doc.ID, i)] = true
+		}
+	}
+	if err := kb.checkDimension(len(embeddings[0]), replaced); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	doc.Chunks = len(records)
+	doc.ContentHash = ContentHash(contents)
+	doc.Embedder = memoryRag.EmbedderName()
+	doc.CreatedAt = now
+	doc.UpdatedAt = now
+	if old != nil {
+		doc.CreatedAt = old.CreatedAt
+		for i := len(records); i < old.Chunks; i++ {
+			delete(kb.Chunks, dataChunkKey(kbID, doc.ID, i))
+		}
+	}
+	for i, record := range records {
+		metadata := record.Metadata
+		metadata.DocID = doc.ID
+		metadata.KnowledgeBase = doc.KnowledgeBase
+		kb.put(newMemoryChunk(dataChunkKey(kbID, doc.ID, i), record.Content, embeddings[i], metadata))
+	}
+	stored := doc
+	kb.Documents[doc.ID] = &stored
+	if err := memoryRag.saveLocked(); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// GetDocument 读取文档信息，不存在时返回 ErrDocumentNotFound
+func (memoryRag *MemoryRAG) GetDocument(ctx context.Context, kb string, docID string) (*DocumentInfo, error) {
+	if !ValidDocumentID(docID) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDocumentID, docID)
+	}
+	memoryRag.mu.Lock()
+	defer memoryRag.mu.Unlock()
+	if err := memoryRag.refreshLocked(); err != nil {
+		return nil, err
+	}
+	doc, ok := memoryRag.knowledgeBase(kb, false).Documents[docID]
+	if !ok {
+		return nil, ErrDocumentNotFound
+	}
+	copied := *doc
+	return &copied, nil
+}
+
+// ListDocuments 按更新时间从新到旧分页列出文档，返回当前页与文档总数
+func (memoryRag *MemoryRAG) ListDocuments(ctx context.Context, kb string, offset int, limit int) ([]*DocumentInfo, int64, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	memoryRag.mu.Lock()
+	defer memoryRag.mu.Unlock()
+	if err := memoryRag.refreshLocked(); err != nil {
+		return nil, 0, err
+	}
+	all := make([]*DocumentInfo, 0)
+	for _, doc := range memoryRag.knowledgeBase(kb, false).Documents {
+		copied := *doc
+		all = append(all, &copied)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].UpdatedAt.Equal(all[j].UpdatedAt) {
+			return all[i].UpdatedAt.After(all[j].UpdatedAt)
+		}
+		return all[i].ID > all[j].ID
+	})
+	total := int64(len(all))
+	if limit <= 0 || offset >= len(all) {
+		return []*DocumentInfo{}, total, nil
+	}
+	return all[offset:min(offset+limit, len(all))], total, nil
+}
+
+// DeleteDocument 删除文档及其全部分块
+func (memoryRag *MemoryRAG) DeleteDocument(ctx context.Context, kb string, docID string) error {
+	if !ValidDocumentID(docID) {
+		return fmt.Errorf("%w: %s", ErrInvalidDocumentID, docID)
+	}
+	memoryRag.mu.Lock()
+	defer memoryRag.mu.Unlock()
+	if err := memoryRag.refreshLocked(); err != nil {
+		return err
+	}
+	if !memoryRag.knowledgeBase(kb, false).deleteDocument(kb, docID) {
+		return ErrDocumentNotFound
+	}
+	return memoryRag.saveLocked()
+}
+
+// DeleteDocumentsBySource 删除同一来源下的全部文档，返回删除的文档数
+func (memoryRag *MemoryRAG) DeleteDocumentsBySource(ctx context.Context, kb string, source string) (int, error) {
+	memoryRag.mu.Lock()
+	defer memoryRag.mu.Unlock()
+	if err := memoryRag.refreshLocked(); err != nil {
+		return 0, err
+	}
+	store := memoryRag.knowledgeBase(kb, false)
+	deleted := 0
+	for id, doc := range store.Documents {
+		if doc.Source == source && store.deleteDocument(kb, id) {
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return 0, nil
+	}
+	return deleted, memoryRag.saveLocked()
+}
+
+// DropIndex 内存实现没有索引，deleteData 为 true 时删除知识库的全部数据
+func (memoryRag *MemoryRAG) DropIndex(ctx context.Context, kb string, deleteData bool) error {
+	if !deleteData {
+		return nil
+	}
+	memoryRag.mu.Lock()
+	defer memoryRag.mu.Unlock()
+	if err := memoryRag.refreshLocked(); err != nil {
+		return err
+	}
+	delete(memoryRag.kbs, normalizeKnowledgeBase(kb))
+	return memoryRag.saveLocked()
+}
+
+// ReembedAll 用当前向量模型重新向量化知识库中的全部分块，conf 仅为与 RedisRAG 保持一致，不会被使用
+func (memoryRag *MemoryRAG) ReembedAll(ctx context.Context, kb string, conf IndexConfig) (int, error) {
+	memoryRag.mu.Lock()
+	if err := memoryRag.refreshLocked(); err != nil {
+		memoryRag.mu.Unlock()
+		return 0, err
+	}
+	store := memoryRag.knowledgeBase(kb, false)
+	keys := make([]string, 0, len(store.Chunks))
+	contents := make([]string, 0, len(store.Chunks))
+	for key, chunk := range store.Chunks {
+		keys = append(keys, key)
+		contents = append(contents, chunk.Content)
+	}
+	memoryRag.mu.Unlock()
+	if len(contents) == 0 {
+		return 0, nil
+	}
+
+	embeddings, err := memoryRag.GetEmbeddings(ctx, contents)
+	if err != nil {
+		return 0, err
+	}
+
+	memoryRag.mu.Lock()
+	defer memoryRag.mu.Unlock()
+	store = memoryRag.knowledgeBase(kb, false)
+	reembedded := 0
+	for i, key := range keys {
+		// 向量化期间被替换或删除的分块保持不变
+		chunk, ok := store.Chunks[key]
+		if !ok || chunk.Content != contents[i] {
+			continue
+		}
+		store.put(newMemoryChunk(key, chunk.Content, embeddings[i], chunk.Metadata))
+		reembedded++
+	}
+	for _, doc := range store.Documents {
+		doc.Embedder = memoryRag.EmbedderName()
+	}
+	if err := memoryRag.saveLocked(); err != nil {
+		return reembedded, err
+	}
+	log.Printf("rag knowledge base %s re-embedded with %s: %d chunks", normalizeKnowledgeBase(kb), memoryRag.EmbedderName(), reembedded)
+	return reembedded, nil
+}
+
+// KnowledgeBases 有数据的知识库ID，默认知识库为 DefaultKnowledgeBase
+func (memoryRag *MemoryRAG) KnowledgeBases() []string {
+	memoryRag.mu.Lock()
+	defer memoryRag.mu.Unlock()
+	if err := memoryRag.refreshLocked(); err != nil {
+		log.Println("rag file refresh error:", err)
+	}
+	kbs := make([]string, 0, len(memoryRag.kbs))
+	for kb, store := range memoryRag.kbs {
+		if len(store.Chunks) > 0 {
+			kbs = append(kbs, kb)
+		}
+	}
+	sort.Strings(kbs)
+	return kbs
+}
+
+// knowledgeBase create 为 false 时不存在的知识库返回空的临时对象，不会被保存
+func (memoryRag *MemoryRAG) knowledgeBase(kb string, create bool) *memoryKnowledgeBase {
+	kb = normalizeKnowledgeBase(kb)
+	if memoryRag.kbs == nil {
+		memoryRag.kbs = make(map[string]*memoryKnowledgeBase)
+	}
+	store, ok := memoryRag.kbs[kb]
+	if !ok {
+		store = &memoryKnowledgeBase{Chunks: make(map[string]*memoryChunk), Documents: make(map[string]*DocumentInfo)}
+		if create {
+			memoryRag.kbs[kb] = store
+		}
+	}
+	return store
+}
+
+// load 读取持久化文件，文件不存在时视为空数据库
+func (memoryRag *MemoryRAG) load() error {
+	memoryRag.mu.Lock()
+	defer memoryRag.mu.Unlock()
+	return memoryRag.loadLocked()
+}
+
+func (memoryRag *MemoryRAG) loadLocked() error {
+	info, err := os.Stat(memoryRag.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		memoryRag.kbs = make(map[string]*memoryKnowledgeBase)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read rag file failed: %w", err)
+	}
+	data, err := os.ReadFile(memoryRag.Path)
+	if err != nil {
+		return fmt.Errorf("read rag file failed: %w", err)
+	}
+	var file memoryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("decode rag file %s failed: %w", memoryRag.Path, err)
+	}
+	if file.Version != memoryFileVersion {
+		return fmt.Errorf("unsupported rag file version %d", file.Version)
+	}
+	kbs := make(map[string]*memoryKnowledgeBase, len(file.KnowledgeBases))
+	for id, kb := range file.KnowledgeBases {
+		store := &memoryKnowledgeBase{Chunks: make(map[string]*memoryChunk, len(kb.Chunks)), Documents: kb.Documents}
+		if store.Documents == nil {
+			store.Documents = make(map[string]*DocumentInfo)
+		}
+		for _, chunk := range kb.Chunks {
+			store.put(newMemoryChunk(chunk.ID, chunk.Content, bytesToFloat32Slice(chunk.Embedding), chunk.Metadata))
+		}
+		kbs[id] = store
+	}
+	memoryRag.kbs = kbs
+	memoryRag.loadedAt = info.ModTime()
+	return nil
+}
+
+// refreshLocked 文件被其他进程（例如导入脚本）更新后重新加载
+func (memoryRag *MemoryRAG) refreshLocked() error {
+	if memoryRag.Path == "" {
+		return nil
+	}
+	info, err := os.Stat(memoryRag.Path)
+	if err != nil || !info.ModTime().After(memoryRag.loadedAt) {
+		return nil
+	}
+	return memoryRag.loadLocked()
+}
+
+// saveLocked 先写临时文件再重命名，避免写入中途失败损坏原文件
+func (memoryRag *MemoryRAG) saveLocked() error {
+	if memoryRag.Path == "" {
+		return nil
+	}
+	data, err := json.Marshal(memoryFile{Version: memoryFileVersion, KnowledgeBases: memoryRag.kbs})
+	if err != nil {
+		return fmt.Errorf("encode rag file failed: %w", err)
+	}
+	if dir := filepath.Dir(memoryRag.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("create rag file dir failed: %w", err)
+		}
+	}
+	tmp := memoryRag.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write rag file failed: %w", err)
+	}
+	if err := os.Rename(tmp, memoryRag.Path); err != nil {
+		return fmt.Errorf("write rag file failed: %w", err)
+	}
+	if info, err := os.Stat(memoryRag.Path); err == nil {
+		memoryRag.loadedAt = info.ModTime()
+	}
+	return nil
+}
+
+func (kb *memoryKnowledgeBase) put(chunk *memoryChunk) {
+	kb.Chunks[chunk.ID] = chunk
+}
+
+// checkDimension 同一知识库的向量维度必须一致，skip 中的分块不参与检查
+func (kb *memoryKnowledgeBase) checkDimension(dim int, skip map[string]bool) error {
+	for key, chunk := range kb.Chunks {
+		if skip[key] {
+			continue
+		}
+		if len(chunk.vector) != dim {
+			return fmt.Errorf("%w: expected %d, got %d", ErrDimensionMismatch, len(chunk.vector), dim)
+		}
+		return nil
+	}
+	return nil
+}
+
+func (kb *memoryKnowledgeBase) deleteDocument(kbID string, docID string) bool {
+	doc, ok := kb.Documents[docID]
+	if !ok {
+		return false
+	}
+	for i := 0; i < doc.Chunks; i++ {
+		delete(kb.Chunks, dataChunkKey(kbID, docID, i))
+	}
+	delete(kb.Documents, docID)
+	return true
+}
+
+// vectorSearch 暴力计算余弦距离，返回距离最小的 k 条，Score 为距离
+func (kb *memoryKnowledgeBase) vectorSearch(embedding []float32, k int, filter Filter) []*RagReturnedData {
+	results := make([]*RagReturnedData, 0)
+	for _, chunk := range kb.Chunks {
+		if len(chunk.vector) != len(embedding) || !filter.match(chunk.Metadata) {
+			continue
+		}
+		result := chunk.result()
+		result.Score = cosineDistance(embedding, chunk.vector)
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score < results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// keywordSearch 任意一个词命中即可召回，按 BM25 分数从高到低返回前 k 条
+func (kb *memoryKnowledgeBase) keywordSearch(terms []string, k int, filter Filter) []*RagReturnedData {
+	results := make([]*RagReturnedData, 0)
+	if len(terms) == 0 || len(kb.Chunks) == 0 {
+		return results
+	}
+	total := 0
+	docFreq := make(map[string]int, len(terms))
+	for _, chunk := range kb.Chunks {
+		total += chunk.length
+		for _, term := range terms {
+			if chunk.terms[term] > 0 {
+				docFreq[term]++
+			}
+		}
+	}
+	n := float64(len(kb.Chunks))
+	avgLength := float64(total) / n
+	for _, chunk := range kb.Chunks {
+		if !filter.match(chunk.Metadata) {
+			continue
+		}
+		var score float64
+		for _, term := range terms {
+			tf := float64(chunk.terms[term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(docFreq[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - bm25B + bm25B*float64(chunk.length)/avgLength
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+		if score <= 0 {
+			continue
+		}
+		result := chunk.result()
+		result.Score = score
+		result.KeywordScore = score
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func newMemoryChunk(id string, content string, vector []float32, metadata Metadata) *memoryChunk {
+	chunk := &memoryChunk{
+		ID:        id,
+		Content:   content,
+		Embedding: float32SliceToBytes(vector),
+		Metadata:  metadata,
+		vector:    vector,
+		terms:     make(map[string]int),
+	}
+	// 与 keywordTerms 使用相同的切分方式
+	for _, w := range splitWords(content) {
+		chunk.terms[w]++
+		chunk.length++
+	}
+	return chunk
+}
+
+func (chunk *memoryChunk) result() *RagReturnedData {
+	metadata := chunk.Metadata
+	metadata.Tags = append([]string(nil), chunk.Metadata.Tags...)
+	return &RagReturnedData{ID: chunk.ID, Content: chunk.Content, Metadata: metadata}
+}
+
+// cosineDistance 与 RediSearch 的 COSINE 距离一致：1 - 余弦相似度
+func cosineDistance(a []float32, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB))
+}
+
+func normalizeKnowledgeBase(kb string) string {
+	if isDefaultKnowledgeBase(kb) {
+		return DefaultKnowledgeBase
+	}
+	return kb
+}
+
+// knowledgeBaseLabel 写入元数据的知识库ID，默认知识库为空，与 RedisRAG 一致
+func knowledgeBaseLabel(kb string) string {
+	if isDefaultKnowledgeBase(kb) {
+		return ""
+	}
+	return kb
+}
+
+// 分块ID与 RedisRAG 中的 key 保持一致，引用中的ID在各实现之间通用
+func dataKeyPrefix(kb string) string {
+	if isDefaultKnowledgeBase(kb) {
+		return DefaultKeyPrefix
+	}
+	return fmt.Sprintf(knowledgeBaseKeyPrefixFormat, kb)
+}
+
+func dataChunkKey(kb string, docID string, n int) string {
+	return fmt.Sprintf("%s%s:%d", dataKeyPrefix(kb), docID, n)
+}
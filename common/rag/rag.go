@@ -1,17 +1,14 @@
 package rag
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
-	"encoding/json"
 	"fmt"
-	"io"
-	"log"
 	"math"
-	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -29,28 +26,76 @@ type OllamaConfig struct {
 }
 
 type RAGDatabase interface {
-	AddOneData(ctx context.Context, content string) error                          // 向数据库添加一条消息
-	GetEmbedding(ctx context.Context, content string) ([]float32, error)           // 获取内容的向量表示
-	GetKNN(ctx context.Context, content string, k int) ([]*RagReturnedData, error) // 基于向量检索K条相似内容
+	AddOneData(ctx context.Context, content string) error                                       // 向数据库添加一条消息
+	AddData(ctx context.Context, content string, metadata Metadata) error                       // 添加一条带元数据的内容
+	GetEmbedding(ctx context.Context, content string) ([]float32, error)                        // 获取内容的向量表示
+	GetKNN(ctx context.Context, content string, k int, scope Scope) ([]*RagReturnedData, error) // 在指定知识库范围内基于向量检索K条相似内容
+	// 按 SearchOptions 进行向量、关键词或混合检索，支持元数据过滤、最低分数与重排
+	Search(ctx context.Context, query string, scope Scope, opts SearchOptions) ([]*RagReturnedData, error)
+	// 文档管理，kb 为空表示默认知识库
+	UpsertDocument(ctx context.Context, doc DocumentInfo, records []Record) (*DocumentInfo, error) // 按文档ID写入并替换原有分块，ID 为空时由来源生成
+	GetDocument(ctx context.Context, kb string, docID string) (*DocumentInfo, error)
+	ListDocuments(ctx context.Context, kb string, offset int, limit int) ([]*DocumentInfo, int64, error)
+	DeleteDocument(ctx context.Context, kb string, docID string) error
+	DeleteDocumentsBySource(ctx context.Context, kb string, source string) (int, error)
+}
+
+// RAGDatabase 的存储后端
+const (
+	BackendRedis    = "redis"    // RedisRAG，需要 Redis Stack
+	BackendMemory   = "memory"   // MemoryRAG，数据只保存在进程内存中
+	BackendFile     = "file"     // MemoryRAG，数据保存到本地文件
+	BackendVikingDB = "vikingdb" // VikingDBRAG，火山引擎向量数据库
+)
+
+// Record 一条待写入的内容及其元数据
+type Record struct {
+	Content  string
+	Metadata Metadata
 }
 
 type RedisRAG struct {
 	RedisClient  *redis.Client
-	OllamaConfig OllamaConfig
+	OllamaConfig OllamaConfig // 未设置 Embedder 时使用 Ollama
+	Embedder     Embedder
+	IndexName    string       // 向量索引名称，默认 idx:rag_data
+	KeyPrefix    string       // 数据 key 前缀，默认 rag:data:
+	dimension    atomic.Int64 // 当前向量模型的维度，探测后缓存，上传、检索等请求会并发读写
 }
 
-type OllamaResponseBody struct {
-	Embedding []float32 `json:"embedding"`
+// Metadata 与向量一同保存的元数据
+type Metadata struct {
+	DocID         string   `json:"docId,omitempty"`         // 所属文档，AddData 写入的数据没有文档ID
+	KnowledgeBase string   `json:"knowledgeBase,omitempty"` // 所属知识库，为空表示默认知识库
+	Source        string   `json:"source,omitempty"`        // 来源，例如文件名或 URL
+	Title         string   `json:"title,omitempty"`         // 文档标题
+	Heading       string   `json:"heading,omitempty"`       // 所在章节标题
+	ChunkIndex    int      `json:"chunkIndex"`              // 在文档中的分块序号
+	Tags          []string `json:"tags,omitempty"`          // 标签
 }
 
-type OllamaRequestBody struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
+type RagReturnedData struct {
+	ID       string   `json:"id"` // 数据在 Redis 中的 key
+	Content  string   `json:"content"`
+	Score    float64  `json:"score"` // GetKNN 为向量距离（越小越相似），Search 为最终分数（越大越相关）
+	Metadata Metadata `json:"metadata"`
+	// Search 中各路召回的原始分数，未被该路召回时为 0
+	VectorDistance float64 `json:"vectorDistance,omitempty"`
+	KeywordScore   float64 `json:"keywordScore,omitempty"`
 }
 
-type RagReturnedData struct {
-	Content string
-	Score   float64
+// tag 字段在 Redis 中以逗号分隔保存
+const tagSeparator = ","
+
+// mergeByScore 按距离从小到大合并多个知识库的结果，保留前 k 条
+func mergeByScore(results []*RagReturnedData, k int) []*RagReturnedData {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score < results[j].Score
+	})
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results
 }
 
 func InitRedisRAG(redisConfig RedisConfig, ollamaConfig OllamaConfig) *RedisRAG {
@@ -66,9 +111,45 @@ func InitRedisRAG(redisConfig RedisConfig, ollamaConfig OllamaConfig) *RedisRAG
 	}
 }
 
+// NewRedisRAG 使用指定的 Embedder 创建 RedisRAG
+func NewRedisRAG(redisConfig RedisConfig, embedder Embedder) *RedisRAG {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     redisConfig.Addr,
+		Password: redisConfig.Password,
+		DB:       redisConfig.DB,
+	})
+
+	return &RedisRAG{
+		RedisClient: rdb,
+		Embedder:    embedder,
+	}
+}
+
+func (redisRag *RedisRAG) embedder() Embedder {
+	if redisRag.Embedder != nil {
+		return redisRag.Embedder
+	}
+	return &OllamaEmbedder{BaseURL: redisRag.OllamaConfig.BaseURL, Model: redisRag.OllamaConfig.ModelName}
+}
+
+// EmbedderName 当前向量模型的名称
+func (redisRag *RedisRAG) EmbedderName() string {
+	return redisRag.embedder().Name()
+}
+
 func (redisRag *RedisRAG) AddOneData(ctx context.Context, content string) error {
-	if strings.TrimSpace(content) == "" {
-		return fmt.Errorf("content is empty")
+	return redisRag.AddData(ctx, content, Metadata{})
+}
+
+func (redisRag *RedisRAG) AddData(ctx context.Context, content string, metadata Metadata) error {
+	return redisRag.AddDataBatch(ctx, []Record{{Content: content, Metadata: metadata}})
+}
+
+// AddDataBatch 批量向量化后用 pipeline 一次写入，任意一条失败时都不写入
+// 写入的数据不属于任何文档，需要按文档更新或删除时使用 UpsertDocument
+func (redisRag *RedisRAG) AddDataBatch(ctx context.Context, records []Record) error {
+	if len(records) == 0 {
+		return nil
 	}
 	if redisRag.RedisClient == nil {
 		return fmt.Errorf("redis client is nil")
@@ -77,25 +158,75 @@ func (redisRag *RedisRAG) AddOneData(ctx context.Context, content string) error
 		ctx = context.Background()
 	}
 
+	contents := make([]string, len(records))
+	for i, record := range records {
+		if strings.TrimSpace(record.Content) == "" {
+			return fmt.Errorf("content is empty")
+		}
+		contents[i] = record.Content
+	}
 	// 获取内容的向量表示
-	embedding, err := redisRag.GetEmbedding(ctx, content)
+	embeddings, err := redisRag.GetEmbeddings(ctx, contents)
 	if err != nil {
 		return err
 	}
-	// 将 float32 切片转换为字节切片
-	vectorBytes := float32SliceToBytes(embedding)
-	key := fmt.Sprintf("rag:data:%d", time.Now().UnixNano())
+	// 维度已知时拒绝写入不一致的向量，避免索引静默跳过
+	if err := redisRag.checkDimension(embeddings); err != nil {
+		return err
+	}
 
-	if err := redisRag.RedisClient.HSet(ctx, key, map[string]interface{}{
-		"content":   content,
-		"embedding": vectorBytes,
-	}).Err(); err != nil {
+	fields := make(map[string]vectorFields)
+	now := time.Now().UnixNano()
+	pipe := redisRag.RedisClient.Pipeline()
+	for i, record := range records {
+		metadata := record.Metadata
+		vf, ok := fields[metadata.KnowledgeBase]
+		if !ok {
+			if vf, err = redisRag.writeFields(ctx, metadata.KnowledgeBase); err != nil {
+				return err
+			}
+			fields[metadata.KnowledgeBase] = vf
+		}
+		key := fmt.Sprintf("%s%d", redisRag.keyPrefix(metadata.KnowledgeBase), now+int64(i))
+		pipe.HDel(ctx, key, vf.stale)
+		pipe.HSet(ctx, key, map[string]interface{}{
+			"content":     record.Content,
+			vf.live:       float32SliceToBytes(embeddings[i]),
+			"source":      metadata.Source,
+			"title":       metadata.Title,
+			"heading":     metadata.Heading,
+			"chunk_index": metadata.ChunkIndex,
+			"tags":        strings.Join(metadata.Tags, tagSeparator),
+		})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("store rag data failed: %w", err)
 	}
 
 	return nil
 }
 
+// 每个知识库使用独立的索引与 key 前缀，默认知识库沿用 IndexName / KeyPrefix
+func (redisRag *RedisRAG) indexName(kb string) string {
+	if !isDefaultKnowledgeBase(kb) {
+		return fmt.Sprintf(knowledgeBaseIndexFormat, kb)
+	}
+	if redisRag.IndexName != "" {
+		return redisRag.IndexName
+	}
+	return DefaultIndexName
+}
+
+func (redisRag *RedisRAG) keyPrefix(kb string) string {
+	if !isDefaultKnowledgeBase(kb) {
+		return fmt.Sprintf(knowledgeBaseKeyPrefixFormat, kb)
+	}
+	if redisRag.KeyPrefix != "" {
+		return redisRag.KeyPrefix
+	}
+	return DefaultKeyPrefix
+}
+
 func float32SliceToBytes(values []float32) []byte {
 	buf := make([]byte, 4*len(values))
 	for i, v := range values {
@@ -105,71 +236,34 @@ func float32SliceToBytes(values []float32) []byte {
 }
 
 func (redisRag *RedisRAG) GetEmbedding(ctx context.Context, content string) ([]float32, error) {
-	if strings.TrimSpace(content) == "" {
-		return nil, fmt.Errorf("content is empty")
-	}
-	if ctx == nil {
-		ctx = context.Background()
-	}
-
-	// 获取 ollama 配置
-	baseURL := strings.TrimRight(redisRag.OllamaConfig.BaseURL, "/")
-	if baseURL == "" {
-		return nil, fmt.Errorf("ollama baseURL is empty")
-	}
-	modelName := strings.TrimSpace(redisRag.OllamaConfig.ModelName)
-	if modelName == "" {
-		modelName = "nomic-embed-text"
-	}
-
-	// 构造请求体
-	reqBody := OllamaRequestBody{
-		Model:  modelName,
-		Prompt: content,
-	}
-
-	bodyBytes, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("marshal embedding request failed: %w", err)
-	}
-
-	// 发送请求
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/api/embeddings", bytes.NewReader(bodyBytes))
+	vectors, err := redisRag.GetEmbeddings(ctx, []string{content})
 	if err != nil {
-		return nil, fmt.Errorf("create embedding request failed: %w", err)
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return vectors[0], nil
+}
 
-	// 超时设置为30秒
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("embedding request failed: %w", err)
+// GetEmbeddings 批量获取向量，返回与 contents 一一对应的结果
+func (redisRag *RedisRAG) GetEmbeddings(ctx context.Context, contents []string) ([][]float32, error) {
+	for _, content := range contents {
+		if strings.TrimSpace(content) == "" {
+			return nil, fmt.Errorf("content is empty")
+		}
 	}
-	defer resp.Body.Close()
-
-	// 检查响应状态码
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return nil, fmt.Errorf("embedding request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	if ctx == nil {
+		ctx = context.Background()
 	}
-
-	// 解析响应体
-	var respBody OllamaResponseBody
-
-	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
-		return nil, fmt.Errorf("decode embedding response failed: %w", err)
+	vectors, err := redisRag.embedder().EmbedBatch(ctx, contents)
+	if err != nil {
+		return nil, err
 	}
-	if len(respBody.Embedding) == 0 {
-		return nil, fmt.Errorf("embedding response is empty")
+	if len(vectors) != len(contents) {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d texts", len(vectors), len(contents))
 	}
-
-	log.Println("Origin Word: ", content, " Received embedding:", respBody.Embedding)
-
-	return respBody.Embedding, nil
+	return vectors, nil
 }
 
-func (redisRag *RedisRAG) GetKNN(ctx context.Context, content string, k int) ([]*RagReturnedData, error) {
+func (redisRag *RedisRAG) GetKNN(ctx context.Context, content string, k int, scope Scope) ([]*RagReturnedData, error) {
 	if strings.TrimSpace(content) == "" {
 		return nil, fmt.Errorf("content is empty")
 	}
@@ -184,59 +278,131 @@ func (redisRag *RedisRAG) GetKNN(ctx context.Context, content string, k int) ([]
 	}
 	vectorBytes := float32SliceToBytes(embedding)
 
-	// 构造 KNN 查询命令
-	query := fmt.Sprintf("*=>[KNN %d @embedding $vec AS vector_score]", k)
+	// 分别检索每个知识库，再按距离合并
+	ragResults := make([]*RagReturnedData, 0)
+	for _, kb := range scope.knowledgeBases() {
+		results, err := redisRag.knnSearch(ctx, kb, vectorBytes, k, "")
+		if err != nil {
+			return nil, err
+		}
+		ragResults = append(ragResults, results...)
+	}
+	return mergeByScore(ragResults, k), nil
+}
+
+// filter 为 RediSearch 过滤条件，为空时检索全部数据
+func (redisRag *RedisRAG) knnSearch(ctx context.Context, kb string, vectorBytes []byte, k int, filter string) ([]*RagReturnedData, error) {
+	// 构造 KNN 查询命令，过滤条件作为预过滤
+	if filter == "" {
+		filter = "*"
+	} else {
+		filter = "(" + filter + ")"
+	}
+	query := fmt.Sprintf("%s=>[KNN %d @embedding $vec AS vector_score]", filter, k)
+	index, _, err := redisRag.liveIndex(ctx, kb)
+	if err != nil {
+		return nil, err
+	}
 
 	// 执行查询
-	res, err := redisRag.RedisClient.Do(ctx, "FT.SEARCH", "idx:rag_data", query,
+	res, err := redisRag.RedisClient.Do(ctx, "FT.SEARCH", index, query,
 		"PARAMS", 2, "vec", vectorBytes,
 		"SORTBY", "vector_score", "ASC",
-		"RETURN", "1", "content",
+		"RETURN", "8", "content", "doc_id", "source", "title", "heading", "chunk_index", "tags", "vector_score",
 		"DIALECT", "2",
 	).Result()
 	if err != nil {
+		// 知识库还没有写入过数据时没有索引，视为没有结果
+		if isUnknownIndexError(err) {
+			return []*RagReturnedData{}, nil
+		}
 		return nil, fmt.Errorf("execute KNN search failed: %w", err)
 	}
 
-	// 解析结果
+	ragResults := parseSearchResults(res, false)
+	for _, r := range ragResults {
+		if !isDefaultKnowledgeBase(kb) {
+			r.Metadata.KnowledgeBase = kb
+		}
+	}
+	return ragResults, nil
+}
+
+// 解析 FT.SEARCH 返回的结果，withScores 为 true 时每条结果的 ID 后跟着查询分数
+func parseSearchResults(res interface{}, withScores bool) []*RagReturnedData {
 	results, ok := res.([]interface{})
 	if !ok || len(results) < 1 {
-		return []*RagReturnedData{}, nil
+		return []*RagReturnedData{}
 	}
 	numResults, ok := results[0].(int64)
 	if !ok || numResults == 0 {
-		return []*RagReturnedData{}, nil
+		return []*RagReturnedData{}
 	}
 
-	var ragResults []*RagReturnedData
-	for i := 1; i < len(results); i += 2 {
-		// 每条结果包含 ID 和字段列表
-		fields, ok := results[i+1].([]interface{})
+	step := 2
+	if withScores {
+		step = 3
+	}
+	ragResults := make([]*RagReturnedData, 0, numResults)
+	for i := 1; i+step-1 < len(results); i += step {
+		// 每条结果包含 ID、可选的分数和字段列表
+		fields, ok := results[i+step-1].([]interface{})
 		if !ok || len(fields) < 2 {
 			continue
 		}
+		id, _ := results[i].(string)
 		var content string
 		var score float64
+		if withScores {
+			score = toFloat64(results[i+1])
+		}
+		var metadata Metadata
 		// 解析字段列表
-		for j := 0; j < len(fields); j += 2 {
+		for j := 0; j+1 < len(fields); j += 2 {
 			fieldName, _ := fields[j].(string)
 			fieldValue := fields[j+1]
-			if fieldName == "content" {
+			switch fieldName {
+			case "content":
 				content, _ = fieldValue.(string)
-			} else if fieldName == "vector_score" {
-				switch v := fieldValue.(type) {
-				case string:
-					score, _ = strconv.ParseFloat(v, 64)
-				case float64:
-					score = v
+			case "vector_score":
+				score = toFloat64(fieldValue)
+			case "doc_id":
+				metadata.DocID, _ = fieldValue.(string)
+			case "source":
+				metadata.Source, _ = fieldValue.(string)
+			case "title":
+				metadata.Title, _ = fieldValue.(string)
+			case "heading":
+				metadata.Heading, _ = fieldValue.(string)
+			case "chunk_index":
+				if v, ok := fieldValue.(string); ok {
+					metadata.ChunkIndex, _ = strconv.Atoi(v)
+				}
+			case "tags":
+				if v, ok := fieldValue.(string); ok && v != "" {
+					metadata.Tags = strings.Split(v, tagSeparator)
 				}
 			}
 		}
 		ragResults = append(ragResults, &RagReturnedData{
-			Content: content,
-			Score:   score,
+			ID:       id,
+			Content:  content,
+			Score:    score,
+			Metadata: metadata,
 		})
 	}
+	return ragResults
+}
 
-	return ragResults, nil
+func toFloat64(v interface{}) float64 {
+	switch val := v.(type) {
+	case string:
+		f, _ := strconv.ParseFloat(val, 64)
+		return f
+	case float64:
+		return val
+	case int64:
+		return float64(val)
+	}
+	return 0
 }
@@ -6,4 +6,4 @@ docker run -d \
   redis/redis-stack:latest
 
 # 创建向量索引
-FT.CREATE idx:rag_data ON HASH PREFIX 1 rag:data: SCHEMA content TEXT embedding VECTOR HNSW 6 TYPE FLOAT32 DIM 768 DISTANCE_METRIC COSINE
\ No newline at end of file
+FT.CREATE idx:rag_data ON HASH PREFIX 1 rag:data: LANGUAGE chinese SCHEMA content TEXT source TAG title TEXT heading TEXT chunk_index NUMERIC tags TAG SEPARATOR , embedding VECTOR HNSW 6 TYPE FLOAT32 DIM 768 DISTANCE_METRIC COSINE
\ No newline at end of file
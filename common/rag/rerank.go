@@ -0,0 +1,195 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Reranker 对候选内容重新打分，返回与 docs 一一对应的分数，分数越高越相关
+type Reranker interface {
+	Rerank(ctx context.Context, query string, docs []string) ([]float64, error)
+}
+
+// rerank 对前 topN 条结果重排，其余结果丢弃
+func rerank(ctx context.Context, reranker Reranker, query string, results []*RagReturnedData, topN int) ([]*RagReturnedData, error) {
+	if topN > 0 && len(results) > topN {
+		results = results[:topN]
+	}
+	docs := make([]string, len(results))
+	for i, r := range results {
+		docs[i] = r.Content
+	}
+	scores, err := reranker.Rerank(ctx, query, docs)
+	if err != nil {
+		return nil, fmt.Errorf("rerank failed: %w", err)
+	}
+	if len(scores) != len(results) {
+		return nil, fmt.Errorf("rerank failed: expected %d scores, got %d", len(results), len(scores))
+	}
+	for i, r := range results {
+		r.Score = scores[i]
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results, nil
+}
+
+// CrossEncoderReranker 调用兼容 Jina / Cohere / Xinference 的 rerank 接口
+// 也兼容 text-embeddings-inference 的 /rerank（请求字段 texts，返回数组）
+type CrossEncoderReranker struct {
+	URL    string // 完整的接口地址，例如 http://localhost:8080/v1/rerank
+	Model  string
+	APIKey string
+	Client *http.Client
+}
+
+type crossEncoderRequest struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	Texts     []string `json:"texts"`
+	TopN      int      `json:"top_n"`
+}
+
+type crossEncoderResult struct {
+	Index          int      `json:"index"`
+	RelevanceScore *float64 `json:"relevance_score"`
+	Score          *float64 `json:"score"`
+}
+
+func (r *CrossEncoderReranker) Rerank(ctx context.Context, query string, docs []string) ([]float64, error) {
+	if strings.TrimSpace(r.URL) == "" {
+		return nil, fmt.Errorf("rerank url is empty")
+	}
+	body, err := json.Marshal(crossEncoderRequest{
+		Model:     r.Model,
+		Query:     query,
+		Documents: docs,
+		Texts:     docs,
+		TopN:      len(docs),
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.APIKey)
+	}
+
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("rerank request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var results []crossEncoderResult
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &results)
+	} else {
+		var wrapped struct {
+			Results []crossEncoderResult `json:"results"`
+		}
+		err = json.Unmarshal(trimmed, &wrapped)
+		results = wrapped.Results
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode rerank response failed: %w", err)
+	}
+
+	scores := make([]float64, len(docs))
+	for _, item := range results {
+		if item.Index < 0 || item.Index >= len(docs) {
+			return nil, fmt.Errorf("rerank response index %d out of range", item.Index)
+		}
+		switch {
+		case item.RelevanceScore != nil:
+			scores[item.Index] = *item.RelevanceScore
+		case item.Score != nil:
+			scores[item.Index] = *item.Score
+		}
+	}
+	// 部分服务返回未经 sigmoid 的 logits，统一换算到 [0, 1]，与 MinScore 的取值范围一致
+	for _, score := range scores {
+		if score < 0 || score > 1 {
+			for i := range scores {
+				scores[i] = 1 / (1 + math.Exp(-scores[i]))
+			}
+			break
+		}
+	}
+	return scores, nil
+}
+
+// LLMReranker 让大模型为每条候选内容打 0-10 分，再归一化到 0-1
+type LLMReranker struct {
+	Model model.BaseChatModel
+}
+
+const llmRerankPrompt = `你是检索结果的相关性评估器。请判断每条候选内容对回答问题的帮助程度，按 0-10 打分，10 表示完全相关。
+只输出一个 JSON 数字数组，长度与候选数量一致，顺序与候选编号一致，不要输出其他内容。
+
+问题：%s
+
+候选：
+%s`
+
+func (r *LLMReranker) Rerank(ctx context.Context, query string, docs []string) ([]float64, error) {
+	if r.Model == nil {
+		return nil, fmt.Errorf("rerank model is nil")
+	}
+	var sb strings.Builder
+	for i, doc := range docs {
+		sb.WriteString(fmt.Sprintf("[%d] %s\n", i, doc))
+	}
+	msg, err := r.Model.Generate(ctx, []*schema.Message{
+		schema.UserMessage(fmt.Sprintf(llmRerankPrompt, query, sb.String())),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	content := msg.Content
+	start := strings.Index(content, "[")
+	end := strings.LastIndex(content, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("rerank model returned no score array: %s", content)
+	}
+	var scores []float64
+	if err := json.Unmarshal([]byte(content[start:end+1]), &scores); err != nil {
+		return nil, fmt.Errorf("decode rerank scores failed: %w", err)
+	}
+	if len(scores) != len(docs) {
+		return nil, fmt.Errorf("rerank model returned %d scores for %d docs", len(scores), len(docs))
+	}
+	for i := range scores {
+		scores[i] /= 10
+	}
+	return scores, nil
+}
@@ -0,0 +1,57 @@
+package rag
+
+import "strings"
+
+// DefaultKnowledgeBase 默认知识库，对应未划分知识库前的 rag:data: 数据
+const DefaultKnowledgeBase = "default"
+
+const (
+	knowledgeBaseIndexFormat     = "idx:rag_kb:%s"
+	knowledgeBaseKeyPrefixFormat = "rag:kb:%s:data:"
+)
+
+// Scope 检索范围
+type Scope struct {
+	// 参与检索的知识库ID，为空时只检索默认知识库
+	KnowledgeBases []string
+}
+
+// ParseScope 解析逗号分隔的知识库ID
+func ParseScope(raw string) Scope {
+	var scope Scope
+	for _, id := range strings.Split(raw, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			scope.KnowledgeBases = append(scope.KnowledgeBases, id)
+		}
+	}
+	return scope
+}
+
+// String 以逗号分隔的形式输出，与 ParseScope 对应
+func (s Scope) String() string {
+	return strings.Join(s.knowledgeBases(), ",")
+}
+
+// knowledgeBases 去重后的知识库列表，空范围返回默认知识库
+func (s Scope) knowledgeBases() []string {
+	seen := make(map[string]bool, len(s.KnowledgeBases))
+	out := make([]string, 0, len(s.KnowledgeBases))
+	for _, kb := range s.KnowledgeBases {
+		if isDefaultKnowledgeBase(kb) {
+			kb = DefaultKnowledgeBase
+		}
+		if seen[kb] {
+			continue
+		}
+		seen[kb] = true
+		out = append(out, kb)
+	}
+	if len(out) == 0 {
+		out = append(out, DefaultKnowledgeBase)
+	}
+	return out
+}
+
+func isDefaultKnowledgeBase(kb string) bool {
+	return kb == "" || kb == DefaultKnowledgeBase
+}
@@ -0,0 +1,435 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 检索模式
+const (
+	SearchModeVector  = "vector"  // 只做向量 KNN
+	SearchModeKeyword = "keyword" // 只做 content 字段的 BM25 全文检索
+	SearchModeHybrid  = "hybrid"  // 两路召回后用 RRF 融合
+)
+
+const (
+	DefaultSearchK = 3
+	// RRF 常数，取论文中的经验值
+	DefaultRRFK = 60
+	// 每一路召回的候选数下限
+	minCandidateK = 20
+	// BM25 分数等于该值时归一化分数为 0.5
+	keywordScoreMidpoint = 1.0
+)
+
+// ErrUnsupportedSearchMode 检索模式不是 vector / keyword / hybrid
+var ErrUnsupportedSearchMode = errors.New("unsupported search mode")
+
+// Filter 元数据过滤条件，同一字段的多个值之间为“或”，不同字段之间为“与”
+type Filter struct {
+	Sources []string // 来源，精确匹配
+	Tags    []string // 标签，命中任意一个即可
+}
+
+// SearchOptions 检索参数，零值字段使用默认值
+//
+// 各模式的最终分数都归一化到 [0, 1]，越高越相关，MinScore 在所有模式下含义一致：
+//   - vector：由向量距离换算的相似度，COSINE / IP 为 1 - 距离/2，L2 为 1 / (1 + 距离)
+//   - keyword：BM25 分数 s 换算为 s / (s + 1)
+//   - hybrid：RRF 融合分数除以各路都排第一时的最大值
+//   - 使用重排时为重排分数，CrossEncoderReranker 与 LLMReranker 均返回 [0, 1] 的分数
+//
+// 原始的向量距离与 BM25 分数保留在 VectorDistance 与 KeywordScore 中
+type SearchOptions struct {
+	Mode       string  // vector / keyword / hybrid，默认 hybrid
+	K          int     // 返回条数
+	CandidateK int     // 每一路召回的候选数，默认 max(4K, 20)
+	RRFK       int     // RRF 常数
+	MinScore   float64 // 归一化后的分数低于该值的结果会被丢弃，取值 [0, 1]，0 表示不过滤
+	// 向量索引的距离度量 COSINE / L2 / IP，用于把距离换算为相似度，默认 COSINE
+	// 只对 RedisRAG 生效，MemoryRAG 与 VikingDBRAG 的向量分数固定按余弦距离换算
+	DistanceMetric string
+	Filter         Filter
+	// 不为 nil 时对融合后的前 RerankTopN 条结果重排，最终分数为重排分数
+	Reranker   Reranker
+	RerankTopN int // 默认等于 CandidateK
+}
+
+func (o SearchOptions) normalize() (SearchOptions, error) {
+	o.Mode = strings.ToLower(strings.TrimSpace(o.Mode))
+	if o.Mode == "" {
+		o.Mode = SearchModeHybrid
+	}
+	switch o.Mode {
+	case SearchModeVector, SearchModeKeyword, SearchModeHybrid:
+	default:
+		return o, fmt.Errorf("%w: %s", ErrUnsupportedSearchMode, o.Mode)
+	}
+	if o.K <= 0 {
+		o.K = DefaultSearchK
+	}
+	if o.CandidateK < o.K {
+		o.CandidateK = max(o.K*4, minCandidateK)
+	}
+	if o.RRFK <= 0 {
+		o.RRFK = DefaultRRFK
+	}
+	if o.RerankTopN <= 0 {
+		o.RerankTopN = o.CandidateK
+	}
+	o.DistanceMetric = strings.ToUpper(strings.TrimSpace(o.DistanceMetric))
+	if o.DistanceMetric == "" {
+		o.DistanceMetric = "COSINE"
+	}
+	switch o.DistanceMetric {
+	case "COSINE", "L2", "IP":
+	default:
+		return o, fmt.Errorf("unsupported distance metric: %s", o.DistanceMetric)
+	}
+	if o.MinScore < 0 || o.MinScore > 1 {
+		return o, fmt.Errorf("min score must be between 0 and 1: %v", o.MinScore)
+	}
+	return o, nil
+}
+
+// expression 转为 RediSearch 查询中的过滤条件，没有条件时返回空字符串
+func (f Filter) expression() string {
+	var parts []string
+	if values := tagValues(f.Sources); values != "" {
+		parts = append(parts, "@source:{"+values+"}")
+	}
+	if values := tagValues(f.Tags); values != "" {
+		parts = append(parts, "@tags:{"+values+"}")
+	}
+	return strings.Join(parts, " ")
+}
+
+// match 进程内检索使用的过滤，与 expression 的语义一致
+func (f Filter) match(metadata Metadata) bool {
+	if sources := trimmedValues(f.Sources); len(sources) > 0 && !containsAny(sources, []string{metadata.Source}) {
+		return false
+	}
+	if tags := trimmedValues(f.Tags); len(tags) > 0 && !containsAny(tags, metadata.Tags) {
+		return false
+	}
+	return true
+}
+
+func trimmedValues(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func containsAny(values []string, targets []string) bool {
+	for _, v := range values {
+		for _, target := range targets {
+			if v == target {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func tagValues(values []string) string {
+	escaped := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			escaped = append(escaped, escapeTagValue(v))
+		}
+	}
+	return strings.Join(escaped, "|")
+}
+
+// escapeTagValue tag 查询中除字母、数字、下划线外的字符都需要转义
+func escapeTagValue(v string) string {
+	var sb strings.Builder
+	for _, r := range v {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// keywordTerms 把查询拆成只包含字母和数字的词，拆分后的词不需要再转义
+func keywordTerms(query string) []string {
+	words := splitWords(query)
+	seen := make(map[string]bool, len(words))
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, w)
+	}
+	return terms
+}
+
+// splitWords 按非字母数字切分并转为小写；中日文没有空格分隔，连续的汉字、假名再切成相邻两字的词
+// 例如 "大熊猫基地" 切成 "大熊 熊猫 猫基 基地"，其中能与 RediSearch 中文分词结果对应的词会命中
+func splitWords(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	words := make([]string, 0, len(fields))
+	for _, f := range fields {
+		words = appendSegments(words, []rune(strings.ToLower(f)))
+	}
+	return words
+}
+
+func appendSegments(words []string, runes []rune) []string {
+	start := 0
+	for start < len(runes) {
+		end := start + 1
+		cjk := isIdeographic(runes[start])
+		for end < len(runes) && isIdeographic(runes[end]) == cjk {
+			end++
+		}
+		switch {
+		case !cjk:
+			words = append(words, string(runes[start:end]))
+		case end-start == 1:
+			words = append(words, string(runes[start]))
+		default:
+			for i := start; i+1 < end; i++ {
+				words = append(words, string(runes[i:i+2]))
+			}
+		}
+		start = end
+	}
+	return words
+}
+
+func isIdeographic(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r)
+}
+
+// Search 在指定知识库范围内检索，分数的含义见 SearchOptions
+func (redisRag *RedisRAG) Search(ctx context.Context, query string, scope Scope, opts SearchOptions) ([]*RagReturnedData, error) {
+	filter := opts.Filter.expression()
+	return search(ctx, query, scope, opts, searchFuncs{
+		embed: redisRag.GetEmbedding,
+		vector: func(ctx context.Context, kb string, embedding []float32, k int) ([]*RagReturnedData, error) {
+			return redisRag.knnSearch(ctx, kb, float32SliceToBytes(embedding), k, filter)
+		},
+		keyword: func(ctx context.Context, kb string, query string, k int) ([]*RagReturnedData, error) {
+			return redisRag.keywordSearch(ctx, kb, query, k, filter)
+		},
+	})
+}
+
+// searchFuncs 各存储在单个知识库内的召回实现，vector 返回的 Score 为向量距离，keyword 为 BM25 分数
+// metric 为向量距离的度量，为空时使用 SearchOptions.DistanceMetric
+type searchFuncs struct {
+	metric  string
+	embed   func(ctx context.Context, content string) ([]float32, error)
+	vector  func(ctx context.Context, kb string, embedding []float32, k int) ([]*RagReturnedData, error)
+	keyword func(ctx context.Context, kb string, query string, k int) ([]*RagReturnedData, error)
+}
+
+// search 按知识库分别召回后合并，再按模式融合、重排与过滤，过滤条件由 funcs 自行处理
+func search(ctx context.Context, query string, scope Scope, opts SearchOptions, funcs searchFuncs) ([]*RagReturnedData, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("content is empty")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	opts, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	var vectorResults, keywordResults []*RagReturnedData
+	if opts.Mode != SearchModeKeyword {
+		embedding, err := funcs.embed(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, kb := range scope.knowledgeBases() {
+			results, err := funcs.vector(ctx, kb, embedding, opts.CandidateK)
+			if err != nil {
+				return nil, err
+			}
+			vectorResults = append(vectorResults, results...)
+		}
+		vectorResults = mergeByScore(vectorResults, opts.CandidateK)
+		metric := opts.DistanceMetric
+		if funcs.metric != "" {
+			metric = funcs.metric
+		}
+		for _, r := range vectorResults {
+			r.VectorDistance = r.Score
+			r.Score = vectorSimilarity(metric, r.Score)
+		}
+	}
+	if opts.Mode != SearchModeVector {
+		for _, kb := range scope.knowledgeBases() {
+			results, err := funcs.keyword(ctx, kb, query, opts.CandidateK)
+			if err != nil {
+				return nil, err
+			}
+			keywordResults = append(keywordResults, results...)
+		}
+		sort.SliceStable(keywordResults, func(i, j int) bool {
+			return keywordResults[i].KeywordScore > keywordResults[j].KeywordScore
+		})
+		if len(keywordResults) > opts.CandidateK {
+			keywordResults = keywordResults[:opts.CandidateK]
+		}
+		for _, r := range keywordResults {
+			r.Score = r.KeywordScore / (r.KeywordScore + keywordScoreMidpoint)
+		}
+	}
+
+	var results []*RagReturnedData
+	switch opts.Mode {
+	case SearchModeVector:
+		results = vectorResults
+	case SearchModeKeyword:
+		results = keywordResults
+	default:
+		results = FuseRRF(opts.RRFK, vectorResults, keywordResults)
+		// 只有一路有结果时以一路的最大值归一化，避免分数被压到 0.5 以下
+		lists := 0
+		for _, list := range [][]*RagReturnedData{vectorResults, keywordResults} {
+			if len(list) > 0 {
+				lists++
+			}
+		}
+		if lists > 0 {
+			maxScore := float64(lists) / float64(opts.RRFK+1)
+			for _, r := range results {
+				r.Score /= maxScore
+			}
+		}
+	}
+
+	if opts.Reranker != nil && len(results) > 0 {
+		results, err = rerank(ctx, opts.Reranker, query, results, opts.RerankTopN)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return FilterByScore(results, opts.MinScore, opts.K), nil
+}
+
+// vectorSimilarity 把向量距离换算为 [0, 1] 的相似度
+// COSINE 距离为 1 - 余弦相似度，IP 距离为 1 - 内积，归一化向量的取值都在 [0, 2]；L2 为欧氏距离的平方
+func vectorSimilarity(metric string, distance float64) float64 {
+	var score float64
+	switch metric {
+	case "L2":
+		score = 1 / (1 + math.Max(distance, 0))
+	default:
+		score = 1 - distance/2
+	}
+	return math.Min(math.Max(score, 0), 1)
+}
+
+// FuseRRF 用 reciprocal rank fusion 融合多路已排序的结果：score = Σ 1 / (k + rank)
+// 同一条数据按 ID 合并，保留各路的原始分数
+func FuseRRF(k int, lists ...[]*RagReturnedData) []*RagReturnedData {
+	if k <= 0 {
+		k = DefaultRRFK
+	}
+	fused := make(map[string]*RagReturnedData)
+	order := make([]*RagReturnedData, 0)
+	for _, list := range lists {
+		for rank, r := range list {
+			id := r.ID
+			if id == "" {
+				id = r.Content
+			}
+			item, ok := fused[id]
+			if !ok {
+				copied := *r
+				copied.Score = 0
+				item = &copied
+				fused[id] = item
+				order = append(order, item)
+			} else {
+				if r.VectorDistance != 0 {
+					item.VectorDistance = r.VectorDistance
+				}
+				if r.KeywordScore != 0 {
+					item.KeywordScore = r.KeywordScore
+				}
+			}
+			item.Score += 1 / float64(k+rank+1)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].Score > order[j].Score
+	})
+	return order
+}
+
+// FilterByScore 丢弃分数低于 minScore 的结果并保留前 k 条，results 需已按分数从高到低排序
+func FilterByScore(results []*RagReturnedData, minScore float64, k int) []*RagReturnedData {
+	out := make([]*RagReturnedData, 0, len(results))
+	for _, r := range results {
+		if minScore != 0 && r.Score < minScore {
+			continue
+		}
+		out = append(out, r)
+		if k > 0 && len(out) == k {
+			break
+		}
+	}
+	return out
+}
+
+func (redisRag *RedisRAG) keywordSearch(ctx context.Context, kb string, query string, k int, filter string) ([]*RagReturnedData, error) {
+	terms := keywordTerms(query)
+	if len(terms) == 0 {
+		return []*RagReturnedData{}, nil
+	}
+	// 任意一个词命中即可召回，由 BM25 决定排序
+	q := fmt.Sprintf("@content:(%s)", strings.Join(terms, "|"))
+	if filter != "" {
+		q = q + " " + filter
+	}
+
+	index, _, err := redisRag.liveIndex(ctx, kb)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := redisRag.RedisClient.Do(ctx, "FT.SEARCH", index, q,
+		"LANGUAGE", indexLanguage,
+		"SCORER", "BM25", "WITHSCORES",
+		"RETURN", "7", "content", "doc_id", "source", "title", "heading", "chunk_index", "tags",
+		"LIMIT", 0, k,
+		"DIALECT", "2",
+	).Result()
+	if err != nil {
+		if isUnknownIndexError(err) {
+			return []*RagReturnedData{}, nil
+		}
+		return nil, fmt.Errorf("execute keyword search failed: %w", err)
+	}
+
+	ragResults := parseSearchResults(res, true)
+	for _, r := range ragResults {
+		r.KeywordScore = r.Score
+		if !isDefaultKnowledgeBase(kb) {
+			r.Metadata.KnowledgeBase = kb
+		}
+	}
+	return ragResults, nil
+}
@@ -0,0 +1,568 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/volcengine/volc-sdk-golang/service/vikingdb"
+)
+
+const (
+	DefaultVikingDBHost   = "api-vikingdb.volces.com"
+	DefaultVikingDBRegion = "cn-beijing"
+	// 标量检索单次返回的上限，列出文档与删除知识库时按该数量分批
+	vikingDBMaxLimit = 5000
+	// 分块记录的类型，文档的第一个分块同时保存文档信息
+	vikingDBKindChunk = "chunk"
+	vikingDBKindDoc   = "doc"
+)
+
+// VikingDB 的向量化方式
+const (
+	VikingDBEmbeddingPlatform = "platform" // 集合配置 vectorize，由平台向量化 content
+	VikingDBEmbeddingEmbedder = "embedder" // 使用本地配置的 Embedder 写入 embedding 字段
+)
+
+// VikingDBConfig 火山引擎 VikingDB 连接参数
+type VikingDBConfig struct {
+	Host       string // 默认 api-vikingdb.volces.com
+	Region     string // 默认 cn-beijing
+	Scheme     string // 默认 https
+	AK         string
+	SK         string
+	Collection string
+	Index      string
+}
+
+// VikingDBRAG 以 VikingDB 为存储的 RAGDatabase
+// 集合需要包含以下字段：id（string 主键）、content（text）、kind、kb、doc_id、source、title、heading、doc_info（string）、
+// chunk_index、updated_at（int64）、tags（list<string>），索引需对 kind、kb、source、tags、updated_at 建立标量索引
+// Embedder 为 nil 时由集合配置的 vectorize 在平台侧向量化 content；否则写入 embedding（float_vector）字段
+// VikingDB 不支持 BM25，keyword 模式返回 ErrUnsupportedSearchMode，hybrid 模式只使用向量召回
+type VikingDBRAG struct {
+	Config   VikingDBConfig
+	Embedder Embedder
+
+	mu         sync.Mutex
+	collection *vikingdb.Collection
+	index      *vikingdb.Index
+}
+
+// NewVikingDBRAG 创建 VikingDBRAG，首次使用时才连接
+func NewVikingDBRAG(conf VikingDBConfig, embedder Embedder) *VikingDBRAG {
+	return &VikingDBRAG{Config: conf, Embedder: embedder}
+}
+
+// connect 获取集合与索引，失败时下次调用重试
+func (vikingRag *VikingDBRAG) connect() (*vikingdb.Collection, *vikingdb.Index, error) {
+	vikingRag.mu.Lock()
+	defer vikingRag.mu.Unlock()
+	if vikingRag.collection != nil && vikingRag.index != nil {
+		return vikingRag.collection, vikingRag.index, nil
+	}
+	conf := vikingRag.Config
+	if conf.AK == "" || conf.SK == "" || conf.Collection == "" || conf.Index == "" {
+		return nil, nil, fmt.Errorf("vikingdb config is incomplete")
+	}
+	if conf.Host == "" {
+		conf.Host = DefaultVikingDBHost
+	}
+	if conf.Region == "" {
+		conf.Region = DefaultVikingDBRegion
+	}
+	if conf.Scheme == "" {
+		conf.Scheme = "https"
+	}
+	service := vikingdb.NewVikingDBService(conf.Host, conf.Region, conf.AK, conf.SK, conf.Scheme)
+	collection, err := service.GetCollection(conf.Collection)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get vikingdb collection failed: %w", err)
+	}
+	index, err := service.GetIndex(conf.Collection, conf.Index)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get vikingdb index failed: %w", err)
+	}
+	vikingRag.collection, vikingRag.index = collection, index
+	return collection, index, nil
+}
+
+// EmbedderName 当前向量模型的名称，平台侧向量化时为 vikingdb
+func (vikingRag *VikingDBRAG) EmbedderName() string {
+	if vikingRag.Embedder == nil {
+		return "vikingdb"
+	}
+	return vikingRag.Embedder.Name()
+}
+
+func (vikingRag *VikingDBRAG) GetEmbedding(ctx context.Context, content string) ([]float32, error) {
+	vectors, err := vikingRag.getEmbeddings(ctx, []string{content})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+func (vikingRag *VikingDBRAG) getEmbeddings(ctx context.Context, contents []string) ([][]float32, error) {
+	for _, content := range contents {
+		if strings.TrimSpace(content) == "" {
+			return nil, fmt.Errorf("content is empty")
+		}
+	}
+	if vikingRag.Embedder == nil {
+		return nil, fmt.Errorf("vikingdb uses platform vectorization, no embedder configured")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	vectors, err := vikingRag.Embedder.EmbedBatch(ctx, contents)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(contents) {
+		return nil, fmt.Errorf("embedding response has %d vectors for %d texts", len(vectors), len(contents))
+	}
+	return vectors, nil
+}
+
+func (vikingRag *VikingDBRAG) AddOneData(ctx context.Context, content string) error {
+	return vikingRag.AddData(ctx, content, Metadata{})
+}
+
+// AddData 写入不属于任何文档的数据
+func (vikingRag *VikingDBRAG) AddData(ctx context.Context, content string, metadata Metadata) error {
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("content is empty")
+	}
+	kb := metadata.KnowledgeBase
+	metadata.KnowledgeBase = knowledgeBaseLabel(kb)
+	id := fmt.Sprintf("%s%d", dataKeyPrefix(kb), time.Now().UnixNano())
+	data, err := vikingRag.chunkData(ctx, []string{id}, []Record{{Content: content, Metadata: metadata}}, kb, nil)
+	if err != nil {
+		return err
+	}
+	return vikingRag.upsert(data)
+}
+
+func (vikingRag *VikingDBRAG) GetKNN(ctx context.Context, content string, k int, scope Scope) ([]*RagReturnedData, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, fmt.Errorf("content is empty")
+	}
+	results := make([]*RagReturnedData, 0)
+	for _, kb := range scope.knowledgeBases() {
+		found, err := vikingRag.vectorSearch(ctx, kb, content, nil, k, Filter{})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, found...)
+	}
+	return mergeByScore(results, k), nil
+}
+
+// Search 支持 vector 与 hybrid 模式，hybrid 只有向量一路召回
+func (vikingRag *VikingDBRAG) Search(ctx context.Context, query string, scope Scope, opts SearchOptions) ([]*RagReturnedData, error) {
+	normalized, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+	if normalized.Mode == SearchModeKeyword {
+		return nil, fmt.Errorf("%w: vikingdb does not support keyword search", ErrUnsupportedSearchMode)
+	}
+	filter := opts.Filter
+	return search(ctx, query, scope, opts, searchFuncs{
+		metric: "COSINE",
+		// 平台侧向量化时把查询原文交给 VikingDB，不在本地向量化
+		embed: func(ctx context.Context, content string) ([]float32, error) {
+			if vikingRag.Embedder == nil {
+				return nil, nil
+			}
+			return vikingRag.GetEmbedding(ctx, content)
+		},
+		vector: func(ctx context.Context, kb string, embedding []float32, k int) ([]*RagReturnedData, error) {
+			return vikingRag.vectorSearch(ctx, kb, query, embedding, k, filter)
+		},
+		keyword: func(context.Context, string, string, int) ([]*RagReturnedData, error) {
+			return []*RagReturnedData{}, nil
+		},
+	})
+}
+
+// vectorSearch 返回的 Score 为 1 - 相似度，与其他实现的向量距离保持一致
+func (vikingRag *VikingDBRAG) vectorSearch(ctx context.Context, kb string, query string, embedding []float32, k int, filter Filter) ([]*RagReturnedData, error) {
+	_, index, err := vikingRag.connect()
+	if err != nil {
+		return nil, err
+	}
+	opts := vikingdb.NewSearchOptions().SetLimit(int64(k)).SetFilter(vikingDBFilter(kb, filter))
+	var datas []*vikingdb.Data
+	if vikingRag.Embedder == nil {
+		datas, err = index.SearchWithMultiModal(opts.SetText(query))
+	} else {
+		if embedding == nil {
+			if embedding, err = vikingRag.GetEmbedding(ctx, query); err != nil {
+				return nil, err
+			}
+		}
+		datas, err = index.SearchByVector(float64Slice(embedding), opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("execute vikingdb search failed: %w", err)
+	}
+	results := make([]*RagReturnedData, 0, len(datas))
+	for _, data := range datas {
+		result := vikingDBResult(data.Fields)
+		if result.ID == "" {
+			result.ID = fmt.Sprint(data.Id)
+		}
+		result.Score = 1 - data.Score
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// UpsertDocument 写入文档，文档已存在时替换其全部分块
+// 先写入新分块再删除多出的旧分块，中途失败时不会丢失原有数据
+func (vikingRag *VikingDBRAG) UpsertDocument(ctx context.Context, doc DocumentInfo, records []Record) (*DocumentInfo, error) {
+	if doc.ID == "" {
+		if strings.TrimSpace(doc.Source) == "" {
+			return nil, fmt.Errorf("%w: id and source are both empty", ErrInvalidDocumentID)
+		}
+		doc.ID = DocumentID(doc.Source)
+	}
+	if !ValidDocumentID(doc.ID) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDocumentID, doc.ID)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("document %s has no content", doc.ID)
+	}
+	kb := doc.KnowledgeBase
+	doc.KnowledgeBase = knowledgeBaseLabel(kb)
+	old, err := vikingRag.GetDocument(ctx, kb, doc.ID)
+	if err != nil && !errors.Is(err, ErrDocumentNotFound) {
+		return nil, err
+	}
+
+	contents := make([]string, len(records))
+	ids := make([]string, len(records))
+	for i, record := range records {
+		contents[i] = record.Content
+		ids[i] = dataChunkKey(kb, doc.ID, i)
+		records[i].Metadata.DocID = doc.ID
+		records[i].Metadata.KnowledgeBase = doc.KnowledgeBase
+	}
+	now := time.Now()
+	doc.Chunks = len(records)
+	doc.ContentHash = ContentHash(contents)
+	doc.Embedder = vikingRag.EmbedderName()
+	doc.CreatedAt = now
+	doc.UpdatedAt = now
+	if old != nil {
+		doc.CreatedAt = old.CreatedAt
+	}
+
+	data, err := vikingRag.chunkData(ctx, ids, records, kb, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := vikingRag.upsert(data); err != nil {
+		return nil, err
+	}
+	if old != nil && old.Chunks > len(records) {
+		surplus := make([]string, 0, old.Chunks-len(records))
+		for i := len(records); i < old.Chunks; i++ {
+			surplus = append(surplus, dataChunkKey(kb, doc.ID, i))
+		}
+		if err := vikingRag.delete(surplus); err != nil {
+			return nil, err
+		}
+	}
+	return &doc, nil
+}
+
+// GetDocument 读取文档第一个分块中保存的文档信息
+func (vikingRag *VikingDBRAG) GetDocument(ctx context.Context, kb string, docID string) (*DocumentInfo, error) {
+	if !ValidDocumentID(docID) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDocumentID, docID)
+	}
+	collection, _, err := vikingRag.connect()
+	if err != nil {
+		return nil, err
+	}
+	datas, err := collection.FetchData(dataChunkKey(kb, docID, 0))
+	if err != nil {
+		return nil, fmt.Errorf("read vikingdb document failed: %w", err)
+	}
+	for _, data := range datas {
+		if doc := parseVikingDBDocument(data.Fields); doc != nil {
+			return doc, nil
+		}
+	}
+	return nil, ErrDocumentNotFound
+}
+
+// ListDocuments 按更新时间从新到旧分页列出文档，单个知识库最多列出 5000 个文档
+func (vikingRag *VikingDBRAG) ListDocuments(ctx context.Context, kb string, offset int, limit int) ([]*DocumentInfo, int64, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	docs, err := vikingRag.searchDocuments(kb, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	total := int64(len(docs))
+	if limit <= 0 || offset >= len(docs) {
+		return []*DocumentInfo{}, total, nil
+	}
+	return docs[offset:min(offset+limit, len(docs))], total, nil
+}
+
+// DeleteDocument 删除文档及其全部分块
+func (vikingRag *VikingDBRAG) DeleteDocument(ctx context.Context, kb string, docID string) error {
+	doc, err := vikingRag.GetDocument(ctx, kb, docID)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, doc.Chunks)
+	for i := 0; i < doc.Chunks; i++ {
+		ids = append(ids, dataChunkKey(kb, docID, i))
+	}
+	return vikingRag.delete(ids)
+}
+
+// DeleteDocumentsBySource 删除同一来源下的全部文档，返回删除的文档数
+func (vikingRag *VikingDBRAG) DeleteDocumentsBySource(ctx context.Context, kb string, source string) (int, error) {
+	docs, err := vikingRag.searchDocuments(kb, source)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, doc := range docs {
+		if err := vikingRag.DeleteDocument(ctx, kb, doc.ID); err != nil {
+			if errors.Is(err, ErrDocumentNotFound) {
+				continue
+			}
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// DropIndex 知识库共用同一个 VikingDB 索引，deleteData 为 true 时删除该知识库的全部数据
+func (vikingRag *VikingDBRAG) DropIndex(ctx context.Context, kb string, deleteData bool) error {
+	if !deleteData {
+		return nil
+	}
+	_, index, err := vikingRag.connect()
+	if err != nil {
+		return err
+	}
+	for {
+		opts := vikingdb.NewSearchOptions().SetLimit(vikingDBMaxLimit).SetFilter(vikingDBFilter(kb, Filter{})).SetOutputFields([]string{"id"})
+		datas, err := index.Search(nil, opts)
+		if err != nil {
+			return fmt.Errorf("list vikingdb data failed: %w", err)
+		}
+		if len(datas) == 0 {
+			return nil
+		}
+		ids := make([]string, 0, len(datas))
+		for _, data := range datas {
+			ids = append(ids, fmt.Sprint(data.Id))
+		}
+		if err := vikingRag.delete(ids); err != nil {
+			return err
+		}
+		if len(datas) < vikingDBMaxLimit {
+			return nil
+		}
+	}
+}
+
+// searchDocuments 标量检索知识库中的文档，source 不为空时只返回该来源的文档
+func (vikingRag *VikingDBRAG) searchDocuments(kb string, source string) ([]*DocumentInfo, error) {
+	_, index, err := vikingRag.connect()
+	if err != nil {
+		return nil, err
+	}
+	filter := Filter{}
+	if source != "" {
+		filter.Sources = []string{source}
+	}
+	dsl := vikingDBFilter(kb, filter)
+	dsl["conds"] = append(dsl["conds"].([]interface{}), map[string]interface{}{"op": "must", "field": "kind", "conds": []string{vikingDBKindDoc}})
+	opts := vikingdb.NewSearchOptions().SetLimit(vikingDBMaxLimit).SetFilter(dsl).SetOutputFields([]string{"doc_info"})
+	datas, err := index.Search(nil, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list vikingdb documents failed: %w", err)
+	}
+	docs := make([]*DocumentInfo, 0, len(datas))
+	for _, data := range datas {
+		if doc := parseVikingDBDocument(data.Fields); doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if !docs[i].UpdatedAt.Equal(docs[j].UpdatedAt) {
+			return docs[i].UpdatedAt.After(docs[j].UpdatedAt)
+		}
+		return docs[i].ID > docs[j].ID
+	})
+	return docs, nil
+}
+
+// chunkData 生成写入 VikingDB 的记录，doc 不为 nil 时第一个分块同时保存文档信息
+func (vikingRag *VikingDBRAG) chunkData(ctx context.Context, ids []string, records []Record, kb string, doc *DocumentInfo) ([]vikingdb.Data, error) {
+	var embeddings [][]float32
+	if vikingRag.Embedder != nil {
+		contents := make([]string, len(records))
+		for i, record := range records {
+			contents[i] = record.Content
+		}
+		var err error
+		if embeddings, err = vikingRag.getEmbeddings(ctx, contents); err != nil {
+			return nil, err
+		}
+	}
+	var docInfo string
+	if doc != nil {
+		encoded, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		docInfo = string(encoded)
+	}
+
+	data := make([]vikingdb.Data, len(records))
+	for i, record := range records {
+		metadata := record.Metadata
+		tags := metadata.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		fields := map[string]interface{}{
+			"id":          ids[i],
+			"content":     record.Content,
+			"kind":        vikingDBKindChunk,
+			"kb":          normalizeKnowledgeBase(kb),
+			"doc_id":      metadata.DocID,
+			"source":      metadata.Source,
+			"title":       metadata.Title,
+			"heading":     metadata.Heading,
+			"chunk_index": int64(metadata.ChunkIndex),
+			"tags":        tags,
+			"doc_info":    "",
+			"updated_at":  time.Now().UnixMilli(),
+		}
+		if doc != nil && i == 0 {
+			fields["kind"] = vikingDBKindDoc
+			fields["doc_info"] = docInfo
+		}
+		if embeddings != nil {
+			fields["embedding"] = float64Slice(embeddings[i])
+		}
+		data[i] = vikingdb.Data{Fields: fields}
+	}
+	return data, nil
+}
+
+func (vikingRag *VikingDBRAG) upsert(data []vikingdb.Data) error {
+	collection, _, err := vikingRag.connect()
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(data); start += DefaultEmbeddingBatchSize * 3 {
+		end := min(start+DefaultEmbeddingBatchSize*3, len(data))
+		if err := collection.UpsertData(data[start:end]); err != nil {
+			return fmt.Errorf("store vikingdb data failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func (vikingRag *VikingDBRAG) delete(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	collection, _, err := vikingRag.connect()
+	if err != nil {
+		return err
+	}
+	if err := collection.DeleteData(ids); err != nil {
+		return fmt.Errorf("delete vikingdb data failed: %w", err)
+	}
+	return nil
+}
+
+// vikingDBFilter 转为 VikingDB 的 filter DSL，总是限定知识库
+func vikingDBFilter(kb string, filter Filter) map[string]interface{} {
+	conds := []interface{}{
+		map[string]interface{}{"op": "must", "field": "kb", "conds": []string{normalizeKnowledgeBase(kb)}},
+	}
+	if sources := trimmedValues(filter.Sources); len(sources) > 0 {
+		conds = append(conds, map[string]interface{}{"op": "must", "field": "source", "conds": sources})
+	}
+	if tags := trimmedValues(filter.Tags); len(tags) > 0 {
+		conds = append(conds, map[string]interface{}{"op": "must", "field": "tags", "conds": tags})
+	}
+	return map[string]interface{}{"op": "and", "conds": conds}
+}
+
+func vikingDBResult(fields map[string]interface{}) *RagReturnedData {
+	str := func(name string) string {
+		v, _ := fields[name].(string)
+		return v
+	}
+	metadata := Metadata{
+		DocID:   str("doc_id"),
+		Source:  str("source"),
+		Title:   str("title"),
+		Heading: str("heading"),
+	}
+	if kb := str("kb"); !isDefaultKnowledgeBase(kb) {
+		metadata.KnowledgeBase = kb
+	}
+	switch v := fields["chunk_index"].(type) {
+	case float64:
+		metadata.ChunkIndex = int(v)
+	case json.Number:
+		n, _ := v.Int64()
+		metadata.ChunkIndex = int(n)
+	case string:
+		metadata.ChunkIndex, _ = strconv.Atoi(v)
+	}
+	if tags, ok := fields["tags"].([]interface{}); ok {
+		for _, tag := range tags {
+			if s, ok := tag.(string); ok {
+				metadata.Tags = append(metadata.Tags, s)
+			}
+		}
+	}
+	return &RagReturnedData{ID: str("id"), Content: str("content"), Metadata: metadata}
+}
+
+func parseVikingDBDocument(fields map[string]interface{}) *DocumentInfo {
+	raw, _ := fields["doc_info"].(string)
+	if raw == "" {
+		return nil
+	}
+	var doc DocumentInfo
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		return nil
+	}
+	return &doc
+}
+
+func float64Slice(values []float32) []float64 {
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[i] = float64(v)
+	}
+	return out
+}
@@ -3,6 +3,7 @@ package redis
 import (
 	"GopherAI/config"
 	"fmt"
+	"strings"
 )
 
 // github.com/go-redis/redis/v8
@@ -10,3 +11,58 @@ import (
 func GenerateCaptcha(email string) string {
 	return fmt.Sprintf(config.DefaultRedisKeyConfig.CaptchaPrefix, email)
 }
+
+// key:检查点ID -> Agent 中断时的检查点
+func GenerateCheckPointKey(checkPointID string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.CheckPointPrefix, checkPointID)
+}
+
+// key:审批ID -> 等待确认的工具调用
+func GenerateToolApprovalKey(approvalID string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.ToolApprovalPrefix, approvalID)
+}
+
+// key:用户账号 -> 该用户所有等待确认的审批ID集合
+func GenerateUserToolApprovalsKey(userName string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.UserToolApprovalsPrefix, userName)
+}
+
+// key:重置密码令牌 -> 用户ID
+func GeneratePasswordResetKey(token string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.PasswordResetPrefix, token)
+}
+
+// key:登录会话ID -> 会话信息（包含当前刷新令牌的哈希）
+func GenerateAuthSessionKey(sessionID string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.AuthSessionPrefix, sessionID)
+}
+
+// key:用户ID -> 该用户所有登录会话ID的集合
+func GenerateUserAuthSessionsKey(userID int64) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.UserAuthSessionsPrefix, userID)
+}
+
+// key:已撤销的登录会话ID，保留到该会话签发的访问令牌全部过期
+func GenerateRevokedSessionKey(sessionID string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.RevokedSessionPrefix, sessionID)
+}
+
+// key:限流规则名 + 限流对象（IP 或用户）-> 窗口内请求时间的有序集合
+func GenerateRateLimitKey(rule, subject string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.RateLimitPrefix, rule, subject)
+}
+
+// key:邮箱 -> 发送邮件的冷却标记
+func GenerateEmailCooldownKey(email string) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.EmailCooldownPrefix, strings.ToLower(email))
+}
+
+// key:用户ID -> 窗口内登录失败次数
+func GenerateLoginFailuresKey(userID int64) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.LoginFailuresPrefix, userID)
+}
+
+// key:用户ID -> 账号锁定标记
+func GenerateLoginLockKey(userID int64) string {
+	return fmt.Sprintf(config.DefaultRedisKeyConfig.LoginLockPrefix, userID)
+}
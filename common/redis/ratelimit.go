@@ -0,0 +1,108 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 滑动窗口限流：有序集合中保存窗口内每次请求的时间（毫秒）
+// 返回 {1, 0} 表示放行；{0, 等待毫秒数} 表示被限流
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+if redis.call('ZCARD', key) < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return {1, 0}
+end
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+local wait = window
+if oldest[2] then
+	wait = tonumber(oldest[2]) + window - now
+end
+return {0, wait}
+`)
+
+// 登录失败计数：计数与过期时间在同一脚本中设置，计数键不会因为进程中途退出而永不过期
+// KEYS[1] 失败次数，KEYS[2] 锁定标记；ARGV 依次为窗口毫秒数、最大失败次数、锁定毫秒数
+// 返回 1 表示本次失败后账号被锁定
+var loginFailureScript = redis.NewScript(`
+local failures = redis.call('INCR', KEYS[1])
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+if failures < tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('SET', KEYS[2], 1, 'PX', ARGV[3])
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// AllowSlidingWindow 在 window 内最多放行 limit 次，被限流时返回需要等待的时间
+func AllowSlidingWindow(rule, subject string, limit int, window time.Duration, member string) (bool, time.Duration, error) {
+	now := time.Now().UnixMilli()
+	res, err := slidingWindowScript.Run(ctx, Rdb, []string{GenerateRateLimitKey(rule, subject)},
+		now, window.Milliseconds(), limit, strconv.FormatInt(now, 10)+"-"+member).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(res) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit result %v", res)
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+// AcquireEmailCooldown 同一邮箱在 cooldown 内只能发送一次邮件，未获取到时返回剩余等待时间
+func AcquireEmailCooldown(email string, cooldown time.Duration) (bool, time.Duration, error) {
+	key := GenerateEmailCooldownKey(email)
+	ok, err := Rdb.SetNX(ctx, key, 1, cooldown).Result()
+	if err != nil || ok {
+		return ok, 0, err
+	}
+	ttl, err := Rdb.PTTL(ctx, key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	if ttl < 0 {
+		ttl = cooldown
+	}
+	return false, ttl, nil
+}
+
+// ReleaseEmailCooldown 邮件发送失败时释放冷却，允许立即重试
+func ReleaseEmailCooldown(email string) error {
+	return Rdb.Del(ctx, GenerateEmailCooldownKey(email)).Err()
+}
+
+// GetLoginLock 返回账号剩余的锁定时间，未锁定时为 0
+func GetLoginLock(userID int64) (time.Duration, error) {
+	ttl, err := Rdb.PTTL(ctx, GenerateLoginLockKey(userID)).Result()
+	if err != nil || ttl < 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// RecordLoginFailure 记录一次登录失败，window 内累计达到 maxFailures 次时锁定账号 lockFor
+// 窗口从第一次失败开始计算
+func RecordLoginFailure(userID int64, maxFailures int, window, lockFor time.Duration) (bool, error) {
+	locked, err := loginFailureScript.Run(ctx, Rdb,
+		[]string{GenerateLoginFailuresKey(userID), GenerateLoginLockKey(userID)},
+		window.Milliseconds(), maxFailures, lockFor.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return locked == 1, nil
+}
+
+// ClearLoginFailures 登录成功后清空失败次数
+func ClearLoginFailures(userID int64) error {
+	return Rdb.Del(ctx, GenerateLoginFailuresKey(userID)).Err()
+}
@@ -66,3 +66,168 @@ func CheckCaptchaForEmail(email, userInput string) (bool, error) {
 
 	return false, nil
 }
+
+// 重置密码令牌的有效期
+const passwordResetExpire = 15 * time.Minute
+
+func SetPasswordResetToken(token string, userID int64) error {
+	return Rdb.Set(ctx, GeneratePasswordResetKey(token), userID, passwordResetExpire).Err()
+}
+
+// TakePasswordResetToken 取出并删除令牌，令牌只能使用一次
+func TakePasswordResetToken(token string) (int64, bool, error) {
+	key := GeneratePasswordResetKey(token)
+	pipe := Rdb.TxPipeline()
+	get := pipe.Get(ctx, key)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, false, err
+	}
+	userID, err := get.Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return userID, true, nil
+}
+
+// SetAuthSession 保存登录会话，并记录到用户的会话集合中
+func SetAuthSession(sessionID string, userID int64, data []byte, expire time.Duration) error {
+	pipe := Rdb.TxPipeline()
+	pipe.Set(ctx, GenerateAuthSessionKey(sessionID), data, expire)
+	pipe.SAdd(ctx, GenerateUserAuthSessionsKey(userID), sessionID)
+	pipe.Expire(ctx, GenerateUserAuthSessionsKey(userID), expire)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func GetAuthSession(sessionID string) ([]byte, bool, error) {
+	data, err := Rdb.Get(ctx, GenerateAuthSessionKey(sessionID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// ReplaceAuthSession 仅当会话内容仍为 old 时替换为 data，用于刷新令牌轮换
+// 并发刷新同一会话时只有一个请求会成功
+func ReplaceAuthSession(sessionID string, userID int64, old, data []byte, expire time.Duration) (bool, error) {
+	key := GenerateAuthSessionKey(sessionID)
+	replaced := false
+	err := Rdb.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if string(current) != string(old) {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, expire)
+			pipe.Expire(ctx, GenerateUserAuthSessionsKey(userID), expire)
+			return nil
+		})
+		if err == nil {
+			replaced = true
+		}
+		return err
+	}, key)
+	if err == redis.TxFailedErr {
+		return false, nil
+	}
+	return replaced, err
+}
+
+// RevokeAuthSessions 删除登录会话并加入撤销列表，revokeFor 应不短于访问令牌的有效期
+func RevokeAuthSessions(userID int64, sessionIDs []string, revokeFor time.Duration) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	pipe := Rdb.TxPipeline()
+	for _, sessionID := range sessionIDs {
+		pipe.Del(ctx, GenerateAuthSessionKey(sessionID))
+		pipe.SRem(ctx, GenerateUserAuthSessionsKey(userID), sessionID)
+		pipe.Set(ctx, GenerateRevokedSessionKey(sessionID), 1, revokeFor)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func GetUserAuthSessionIDs(userID int64) ([]string, error) {
+	return Rdb.SMembers(ctx, GenerateUserAuthSessionsKey(userID)).Result()
+}
+
+// IsAuthSessionRevoked 会话是否在撤销列表中
+func IsAuthSessionRevoked(sessionID string) (bool, error) {
+	n, err := Rdb.Exists(ctx, GenerateRevokedSessionKey(sessionID)).Result()
+	return n > 0, err
+}
+
+// 检查点与待审批记录的保存时间
+const agentApprovalExpire = 24 * time.Hour
+
+func SetCheckPoint(checkPointID string, data []byte) error {
+	return Rdb.Set(ctx, GenerateCheckPointKey(checkPointID), data, agentApprovalExpire).Err()
+}
+
+func GetCheckPoint(checkPointID string) ([]byte, bool, error) {
+	data, err := Rdb.Get(ctx, GenerateCheckPointKey(checkPointID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func SetToolApproval(approvalID string, userName string, data []byte) error {
+	pipe := Rdb.TxPipeline()
+	pipe.Set(ctx, GenerateToolApprovalKey(approvalID), data, agentApprovalExpire)
+	pipe.SAdd(ctx, GenerateUserToolApprovalsKey(userName), approvalID)
+	pipe.Expire(ctx, GenerateUserToolApprovalsKey(userName), agentApprovalExpire)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func GetToolApproval(approvalID string) ([]byte, bool, error) {
+	data, err := Rdb.Get(ctx, GenerateToolApprovalKey(approvalID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// TakeToolApproval 取出并删除审批记录，保证同一审批只会被处理一次
+func TakeToolApproval(approvalID string, userName string) ([]byte, bool, error) {
+	data, err := Rdb.GetDel(ctx, GenerateToolApprovalKey(approvalID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if err := Rdb.SRem(ctx, GenerateUserToolApprovalsKey(userName), approvalID).Err(); err != nil {
+		log.Println("SRem tool approval failed:", err)
+	}
+	return data, true, nil
+}
+
+func GetUserToolApprovalIDs(userName string) ([]string, error) {
+	return Rdb.SMembers(ctx, GenerateUserToolApprovalsKey(userName)).Result()
+}
+
+func RemoveUserToolApprovalID(userName string, approvalID string) error {
+	return Rdb.SRem(ctx, GenerateUserToolApprovalsKey(userName), approvalID).Err()
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"GopherAI/common/rag"
+	"GopherAI/model"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -32,9 +33,19 @@ var (
 
 var (
 	ragRedisAddr  string
-	ragBaseURL    string
-	ragModelName  string
 	ragDefaultTop int
+	// RAG_BACKEND=file 时从 RAG_FILE_PATH 读取数据，无需 Redis Stack，文件被导入脚本更新后自动重新加载
+	// RAG_BACKEND=vikingdb 时使用 VIKINGDB_* 环境变量连接 VikingDB；为 nil 时连接 Redis
+	ragBackendDB rag.RAGDatabase
+	// 查询向量化使用的模型，需与导入时一致，进程内缓存重复查询的向量
+	ragEmbedder rag.Embedder
+	// 检索模式与重排配置，含义与服务端 [ragConfig] 一致
+	ragSearchMode   string
+	ragMinScore     float64
+	ragMetric       string
+	ragRerankURL    string
+	ragRerankModel  string
+	ragRerankAPIKey string
 )
 
 var (
@@ -49,15 +60,69 @@ func init() {
 	if ragRedisAddr == "" {
 		ragRedisAddr = "127.0.0.1:6381"
 	}
-	ragBaseURL = os.Getenv("OLLAMA_BASE_URL")
-	if ragBaseURL == "" {
-		ragBaseURL = "http://localhost:11434"
+	embeddingProvider := os.Getenv("EMBEDDING_PROVIDER")
+	embeddingBaseURL := os.Getenv("EMBEDDING_BASE_URL")
+	embeddingModel := os.Getenv("EMBEDDING_MODEL")
+	if embeddingProvider == "" || embeddingProvider == rag.EmbeddingProviderOllama {
+		if embeddingBaseURL == "" {
+			embeddingBaseURL = os.Getenv("OLLAMA_BASE_URL")
+		}
+		if embeddingBaseURL == "" {
+			embeddingBaseURL = "http://localhost:11434"
+		}
+		if embeddingModel == "" {
+			embeddingModel = os.Getenv("OLLAMA_EMBED_MODEL")
+		}
 	}
-	ragModelName = os.Getenv("OLLAMA_EMBED_MODEL")
-	if ragModelName == "" {
-		ragModelName = "nomic-embed-text"
+	embedder, err := rag.NewEmbedder(rag.EmbeddingConfig{
+		Provider: embeddingProvider,
+		BaseURL:  embeddingBaseURL,
+		Model:    embeddingModel,
+		APIKey:   os.Getenv("EMBEDDING_API_KEY"),
+		Cache:    rag.NewMemoryEmbeddingCache(0),
+	})
+	if err != nil {
+		log.Fatalf("create embedder failed: %v", err)
+	}
+	ragEmbedder = embedder
+	switch backend := os.Getenv("RAG_BACKEND"); backend {
+	case "", rag.BackendRedis:
+	case rag.BackendFile:
+		ragBackendDB, err = rag.OpenFileRAG(os.Getenv("RAG_FILE_PATH"), ragEmbedder)
+		if err != nil {
+			log.Fatalf("open rag file failed: %v", err)
+		}
+	case rag.BackendVikingDB:
+		// 默认由平台向量化，VIKINGDB_EMBEDDING=embedder 时使用上面的向量模型
+		var vikingEmbedder rag.Embedder
+		if os.Getenv("VIKINGDB_EMBEDDING") == rag.VikingDBEmbeddingEmbedder {
+			vikingEmbedder = ragEmbedder
+		}
+		ragBackendDB = rag.NewVikingDBRAG(rag.VikingDBConfig{
+			Host:       os.Getenv("VIKINGDB_HOST"),
+			Region:     os.Getenv("VIKINGDB_REGION"),
+			AK:         os.Getenv("VIKINGDB_AK"),
+			SK:         os.Getenv("VIKINGDB_SK"),
+			Collection: os.Getenv("VIKINGDB_COLLECTION"),
+			Index:      os.Getenv("VIKINGDB_INDEX"),
+		}, vikingEmbedder)
+	default:
+		log.Fatalf("unsupported rag backend: %s", backend)
 	}
 	ragDefaultTop = 3
+	ragSearchMode = os.Getenv("RAG_SEARCH_MODE")
+	if ragSearchMode == "" {
+		ragSearchMode = rag.SearchModeHybrid
+	}
+	if v := os.Getenv("RAG_MIN_SCORE"); v != "" {
+		if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+			ragMinScore = parsed
+		}
+	}
+	ragMetric = os.Getenv("RAG_DISTANCE_METRIC")
+	ragRerankURL = os.Getenv("RAG_RERANK_URL")
+	ragRerankModel = os.Getenv("RAG_RERANK_MODEL")
+	ragRerankAPIKey = os.Getenv("RAG_RERANK_API_KEY")
 	flag.StringVar(&transport, "transport", "sse", "The transport to use, should be \"stdio\" or \"sse\"")
 	flag.StringVar(&serverlisten, "server_listen", "localhost:8083", "The sse server listen address")
 	flag.Parse()
@@ -107,6 +172,22 @@ func main() {
 			"k",
 			mcp.Description("top k results, default 3"),
 		),
+		mcp.WithString(
+			"knowledge_bases",
+			mcp.Description("comma separated knowledge base ids to search, default knowledge base if empty"),
+		),
+		mcp.WithString(
+			"mode",
+			mcp.Description("vector, keyword or hybrid; use keyword or hybrid for exact names such as drug names, flight codes and places"),
+		),
+		mcp.WithString(
+			"sources",
+			mcp.Description("comma separated document sources to restrict the search to"),
+		),
+		mcp.WithString(
+			"tags",
+			mcp.Description("comma separated tags to restrict the search to"),
+		),
 	)
 	s.AddTool(ragTool, ragSearchHandler)
 
@@ -156,15 +237,34 @@ func googleSearchHandler(
 		return nil, errors.New("query must be a non-empty string")
 	}
 
-	result, err := googleSearch(ctx, query)
+	results, err := googleSearch(ctx, query)
 	if err != nil {
 		return nil, err
 	}
+	if len(results) == 0 {
+		return mcp.NewToolResultText("no search results"), nil
+	}
+
+	var sb strings.Builder
+	for i, item := range results {
+		sb.WriteString(fmt.Sprintf(
+			"%d. %s\n%s\n%s\n\n",
+			i+1,
+			item.Title,
+			item.Content,
+			item.URL,
+		))
+	}
+	// 结构化结果供服务端生成引用列表，文本结果兼容不解析结构化内容的客户端
+	return mcp.NewToolResultStructured(searchResults{Results: results}, sb.String()), nil
+}
 
-	return mcp.NewToolResultText(result), nil
+// searchResults rag_search 与 google_search 的结构化结果
+type searchResults struct {
+	Results []model.MessageCitation `json:"results"`
 }
 
-func googleSearch(ctx context.Context, query string) (string, error) {
+func googleSearch(ctx context.Context, query string) ([]model.MessageCitation, error) {
 
 	req, err := http.NewRequestWithContext(
 		ctx,
@@ -174,7 +274,7 @@ func googleSearch(ctx context.Context, query string) (string, error) {
 	)
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	q := req.URL.Query()
@@ -185,13 +285,13 @@ func googleSearch(ctx context.Context, query string) (string, error) {
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("google api error: %s", body)
+		return nil, fmt.Errorf("google api error: %s", body)
 	}
 
 	// ===== parse response =====
@@ -204,25 +304,19 @@ func googleSearch(ctx context.Context, query string) (string, error) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return "", err
-	}
-
-	if len(data.Items) == 0 {
-		return "no search results", nil
+		return nil, err
 	}
 
-	var sb strings.Builder
-	for i, item := range data.Items {
-		sb.WriteString(fmt.Sprintf(
-			"%d. %s\n%s\n%s\n\n",
-			i+1,
-			item.Title,
-			item.Snippet,
-			item.Link,
-		))
+	results := make([]model.MessageCitation, 0, len(data.Items))
+	for _, item := range data.Items {
+		results = append(results, model.MessageCitation{
+			Type:    model.CitationTypeWeb,
+			Title:   item.Title,
+			URL:     item.Link,
+			Content: item.Snippet,
+		})
 	}
-
-	return sb.String(), nil
+	return results, nil
 }
 
 func ragSearchHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -243,19 +337,38 @@ func ragSearchHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 		}
 		topK = parsed
 	}
-
-	redisRag := rag.InitRedisRAG(rag.RedisConfig{Addr: ragRedisAddr}, rag.OllamaConfig{
-		BaseURL:   ragBaseURL,
-		ModelName: ragModelName,
-	})
-	if err := redisRag.RedisClient.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("redis not available at %s: %w", ragRedisAddr, err)
+	knowledgeBases, _ := args["knowledge_bases"].(string)
+	scope := rag.ParseScope(knowledgeBases)
+	mode, _ := args["mode"].(string)
+	if strings.TrimSpace(mode) == "" {
+		mode = ragSearchMode
+	}
+	sources, _ := args["sources"].(string)
+	tags, _ := args["tags"].(string)
+	opts := rag.SearchOptions{
+		Mode:     mode,
+		K:        topK,
+		MinScore: ragMinScore,
+		// 与导入时的索引一致，用于把向量距离换算为相似度
+		DistanceMetric: ragMetric,
+		Filter: rag.Filter{
+			Sources: splitList(sources),
+			Tags:    splitList(tags),
+		},
 	}
-	if err := redisRag.RedisClient.Do(ctx, "FT.INFO", "idx:rag_data").Err(); err != nil {
-		return nil, fmt.Errorf("rag index not available (run common/rag/redis_init.sh): %w", err)
+	if ragRerankURL != "" {
+		opts.Reranker = &rag.CrossEncoderReranker{
+			URL:    ragRerankURL,
+			Model:  ragRerankModel,
+			APIKey: ragRerankAPIKey,
+		}
 	}
 
-	results, err := redisRag.GetKNN(ctx, query, topK)
+	db, err := ragDatabase(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	results, err := db.Search(ctx, query, scope, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -264,9 +377,55 @@ func ragSearchHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 	}
 
 	var sb strings.Builder
+	citations := make([]model.MessageCitation, 0, len(results))
 	for i, item := range results {
-		sb.WriteString(fmt.Sprintf("%d. %s\nscore: %.6f\n\n", i+1, item.Content, item.Score))
+		sb.WriteString(fmt.Sprintf("%d. %s\nscore: %.6f\n", i+1, item.Content, item.Score))
+		if item.Metadata.Source != "" {
+			sb.WriteString(fmt.Sprintf("source: %s\n", item.Metadata.Source))
+		}
+		sb.WriteString("\n")
+		citations = append(citations, model.MessageCitation{
+			Type:          model.CitationTypeRAG,
+			Title:         item.Metadata.Title,
+			Source:        item.Metadata.Source,
+			KnowledgeBase: item.Metadata.KnowledgeBase,
+			ChunkIndex:    item.Metadata.ChunkIndex,
+			Content:       item.Content,
+			Score:         item.Score,
+		})
+	}
+
+	return mcp.NewToolResultStructured(searchResults{Results: citations}, sb.String()), nil
+}
+
+// ragDatabase 按 RAG_BACKEND 返回检索使用的数据库，Redis 需要先检查连接与默认索引
+func ragDatabase(ctx context.Context, scope rag.Scope) (rag.RAGDatabase, error) {
+	if ragBackendDB != nil {
+		return ragBackendDB, nil
+	}
+	redisRag := rag.NewRedisRAG(rag.RedisConfig{Addr: ragRedisAddr}, ragEmbedder)
+	if err := redisRag.RedisClient.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("redis not available at %s: %w", ragRedisAddr, err)
+	}
+	// 其他知识库的索引在创建或上传时建立，未建立时视为空知识库
+	if len(scope.KnowledgeBases) == 0 {
+		indexInfo, err := redisRag.GetIndexInfo(ctx, rag.DefaultKnowledgeBase)
+		if err != nil {
+			return nil, err
+		}
+		if !indexInfo.Exists {
+			return nil, fmt.Errorf("rag index %s not available, start the GopherAI server or run data/script/ingest to create it", rag.DefaultIndexName)
+		}
 	}
+	return redisRag, nil
+}
 
-	return mcp.NewToolResultText(sb.String()), nil
+func splitList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
 }
@@ -7,9 +7,10 @@ import (
 )
 
 type MainConfig struct {
-	Port    int    `toml:"port"`
-	AppName string `toml:"appName"`
-	Host    string `toml:"host"`
+	Port           int      `toml:"port"`
+	AppName        string   `toml:"appName"`
+	Host           string   `toml:"host"`
+	TrustedProxies []string `toml:"trustedProxies"` // 只信任这些反向代理传来的 X-Forwarded-For，按 IP 限流依赖它
 }
 
 type EmailConfig struct {
@@ -33,11 +34,38 @@ type MysqlConfig struct {
 	MysqlCharset      string `toml:"charset"`
 }
 
+// JwtConfig 访问令牌短期有效，过期后用刷新令牌换取新的令牌对，0 表示使用默认值
 type JwtConfig struct {
-	ExpireDuration int    `toml:"expire_duration"`
-	Issuer         string `toml:"issuer"`
-	Subject        string `toml:"subject"`
-	Key            string `toml:"key"`
+	AccessExpireMinutes int    `toml:"access_expire_minutes"`
+	RefreshExpireHours  int    `toml:"refresh_expire_hours"`
+	Issuer              string `toml:"issuer"`
+	Subject             string `toml:"subject"`
+	Key                 string `toml:"key"`
+}
+
+// PasswordConfig 密码哈希参数，0 表示使用默认值
+type PasswordConfig struct {
+	Algorithm     string `toml:"algorithm"`     // bcrypt（默认）或 argon2id
+	BcryptCost    int    `toml:"bcryptCost"`    // 默认 10
+	Argon2Time    uint32 `toml:"argon2Time"`    // 迭代次数，默认 3
+	Argon2Memory  uint32 `toml:"argon2Memory"`  // 内存，单位 KiB，默认 65536
+	Argon2Threads uint8  `toml:"argon2Threads"` // 并行度，默认 2
+	MinLength     int    `toml:"minLength"`     // 最短长度，默认 8
+}
+
+// RateLimitConfig 限流与登录保护参数，0 表示使用默认值
+type RateLimitConfig struct {
+	CaptchaCooldownSeconds    int                      `toml:"captchaCooldownSeconds"`    // 同一邮箱两次发送邮件的最短间隔，默认 60
+	LoginMaxFailures          int                      `toml:"loginMaxFailures"`          // 连续登录失败多少次后锁定账号，默认 5
+	LoginFailureWindowMinutes int                      `toml:"loginFailureWindowMinutes"` // 统计登录失败次数的时间窗口，默认 15
+	LoginLockMinutes          int                      `toml:"loginLockMinutes"`          // 账号锁定时长，默认 15
+	Rules                     map[string]RateLimitRule `toml:"rules"`                     // 按名称覆盖路由上的默认限流规则
+}
+
+// RateLimitRule 滑动窗口内最多 Limit 次请求，Limit 为负数时关闭该规则
+type RateLimitRule struct {
+	Limit         int `toml:"limit"`
+	WindowSeconds int `toml:"windowSeconds"`
 }
 
 type Rabbitmq struct {
@@ -46,17 +74,68 @@ type Rabbitmq struct {
 	RabbitmqUsername string `toml:"username"`
 	RabbitmqPassword string `toml:"password"`
 	RabbitmqVhost    string `toml:"vhost"`
+	// 消费失败后的重试次数，超过后投递到死信队列
+	RabbitmqMaxRetry int `toml:"maxRetry"`
+	// 两次重试之间的间隔（毫秒）
+	RabbitmqRetryInterval int `toml:"retryInterval"`
+	// 等待 broker 确认发布的超时时间（毫秒）
+	RabbitmqConfirmTimeout int `toml:"confirmTimeout"`
+}
+
+type MessageStoreConfig struct {
+	// 消息持久化方式：rabbitmq（默认，异步）、sync（同步写库）、batch（进程内批量写库）
+	Backend string `toml:"backend"`
+	// 以下配置仅对 batch 生效
+	QueueSize      int `toml:"queueSize"`      // 内存队列容量
+	BatchSize      int `toml:"batchSize"`      // 每批最多写入条数
+	FlushInterval  int `toml:"flushInterval"`  // 最长攒批时间（毫秒）
+	EnqueueTimeout int `toml:"enqueueTimeout"` // 队列满时的最长等待时间（毫秒）
+	MaxRetry       int `toml:"maxRetry"`       // 批量写入失败重试次数
+	// 逐条重试后仍写入失败的消息保存到该文件，默认 data/messages.deadletter.jsonl
+	DeadLetterFile string `toml:"deadLetterFile"`
 }
 
 type ImageAIConfig struct {
+	Backend   string `toml:"backend"` // 识别后端：llm（默认）、onnx 或 stub
 	Key       string `toml:"key"`
 	ModelName string `toml:"modelname"`
 	BaseURL   string `toml:"baseurl"`
+	// 上传与预处理，0 表示使用默认值
+	MaxUploadSize int64 `toml:"maxUploadSize"` // 单张图片大小上限，单位 MB
+	MaxMegapixels int   `toml:"maxMegapixels"` // 像素数上限，单位百万像素
+	MaxEdge       int   `toml:"maxEdge"`       // 发送给模型前长边缩放到的像素
+	JPEGQuality   int   `toml:"jpegQuality"`
+	StripMetadata bool  `toml:"stripMetadata"` // 去除 EXIF（含 GPS 位置）等元数据
+	// 聊天附带图片的保存目录，消息中只保存图片的引用
+	StorageDir string `toml:"storageDir"`
+	// backend = "onnx" 时的本地分类模型
+	ModelPath       string    `toml:"modelPath"`
+	LabelPath       string    `toml:"labelPath"` // 每行一个标签
+	ONNXLibraryPath string    `toml:"onnxLibraryPath"`
+	InputSize       int       `toml:"inputSize"`
+	Mean            []float32 `toml:"mean"`
+	Std             []float32 `toml:"std"`
 }
 
 type OllamaConfig struct {
 	BaseURL   string `toml:"baseURL"`
 	ModelName string `toml:"modelName"`
+	Vision    bool   `toml:"vision"` // 模型是否支持图片输入
+}
+
+// EmbeddingConfig RAG 使用的向量模型，provider 为空时沿用 ollamaConfig
+type EmbeddingConfig struct {
+	EmbeddingProvider    string `toml:"provider"` // ollama / openai / fake
+	EmbeddingBaseURL     string `toml:"baseURL"`  // ollama 为服务地址，openai 为包含 /v1 的接口前缀
+	EmbeddingModel       string `toml:"model"`
+	EmbeddingAPIKey      string `toml:"apiKey"`
+	EmbeddingDimensions  int    `toml:"dimensions"`  // openai 的 dimensions 参数，fake 的向量维度
+	EmbeddingBatchSize   int    `toml:"batchSize"`   // 每次请求最多包含的文本数
+	EmbeddingConcurrency int    `toml:"concurrency"` // 同时进行的请求数
+	EmbeddingTimeout     int    `toml:"timeout"`     // 单次请求超时（秒）
+	EmbeddingCache       string `toml:"cache"`       // none / memory / redis，redis 使用 ragConfig 中的 Redis
+	EmbeddingCacheSize   int    `toml:"cacheSize"`   // memory 缓存条数
+	EmbeddingCacheTTL    int    `toml:"cacheTTL"`    // redis 缓存有效期（小时），0 表示不过期
 }
 
 type GoogleConfig struct {
@@ -69,27 +148,102 @@ type VikingDBConfig struct {
 	SK         string `toml:"SK"`
 	Collection string `toml:"collection"`
 	Index      string `toml:"index"`
+	Host       string `toml:"host"`   // 默认 api-vikingdb.volces.com
+	Region     string `toml:"region"` // 默认 cn-beijing
+	Scheme     string `toml:"scheme"` // 默认 https
+	// platform 由集合的 vectorize 配置在平台侧向量化；embedder 使用 embeddingConfig 中的向量模型写入 embedding 字段
+	Embedding string `toml:"embedding"`
+}
+
+type RAGConfig struct {
+	RAGBackend       string `toml:"backend"`   // redis / memory / file / vikingdb，默认 redis
+	RAGFilePath      string `toml:"filePath"`  // backend = file 时的数据文件
+	RAGRedisAddr     string `toml:"redisAddr"` // Redis Stack 地址，需支持向量索引
+	RAGRedisPassword string `toml:"redisPassword"`
+	RAGRedisDB       int    `toml:"redisDB"`
+	RAGChunkStrategy string `toml:"chunkStrategy"` // sentence / heading / cjk
+	RAGChunkSize     int    `toml:"chunkSize"`
+	RAGChunkOverlap  *int   `toml:"chunkOverlap"`  // 不配置时为分块大小的 10%（最多 50），0 表示不重叠
+	RAGMaxUploadSize int64  `toml:"maxUploadSize"` // 上传文档大小上限（MB）
+	// 向量索引配置，启动时按当前向量模型维度创建或迁移索引
+	RAGIndexAlgorithm     string `toml:"indexAlgorithm"` // HNSW / FLAT
+	RAGDistanceMetric     string `toml:"distanceMetric"` // COSINE / L2 / IP
+	RAGHNSWM              int    `toml:"hnswM"`
+	RAGHNSWEFConstruction int    `toml:"hnswEFConstruction"`
+	RAGHNSWEFRuntime      int    `toml:"hnswEFRuntime"`
+	RAGFlatBlockSize      int    `toml:"flatBlockSize"`
+	// 检索配置
+	RAGSearchMode  string  `toml:"searchMode"` // vector / keyword / hybrid
+	RAGCandidateK  int     `toml:"candidateK"` // 每一路召回的候选数
+	RAGRRFK        int     `toml:"rrfK"`       // RRF 常数
+	RAGMinScore    float64 `toml:"minScore"`   // 最低分数，取值 0-1，0 表示不过滤
+	RAGReranker    string  `toml:"reranker"`   // 为空不重排，cross-encoder / llm
+	RAGRerankURL   string  `toml:"rerankURL"`  // cross-encoder 重排接口地址
+	RAGRerankModel string  `toml:"rerankModel"`
+	RAGRerankKey   string  `toml:"rerankAPIKey"`
+	RAGRerankTopN  int     `toml:"rerankTopN"` // 参与重排的候选数
+}
+
+// RoleConfig 角色与权限，admin 与 user 为内置角色
+type RoleConfig struct {
+	Admins      []string            `toml:"admins"`      // 启动时设置为管理员的账号
+	Permissions map[string][]string `toml:"permissions"` // 按角色配置权限，会覆盖内置角色的默认权限
+}
+
+type ToolConfig struct {
+	DefaultTools []string            `toml:"defaultTools"` // 用户未保存偏好时默认启用的工具
+	RoleTools    map[string][]string `toml:"roleTools"`    // 按角色限制可用工具，未配置的角色不做限制
+	ConfirmTools []string            `toml:"confirmTools"` // 调用前需要用户确认的工具
 }
 
 type Config struct {
-	EmailConfig    `toml:"emailConfig"`
-	RedisConfig    `toml:"redisConfig"`
-	MysqlConfig    `toml:"mysqlConfig"`
-	JwtConfig      `toml:"jwtConfig"`
-	MainConfig     `toml:"mainConfig"`
-	Rabbitmq       `toml:"rabbitmqConfig"`
-	ImageAIConfig  `toml:"imageAIConfig"`
-	OllamaConfig   `toml:"ollamaConfig"`
-	GoogleConfig   `toml:"googleConfig"`
-	VikingDBConfig `toml:"vikingDBConfig"`
+	EmailConfig        `toml:"emailConfig"`
+	RedisConfig        `toml:"redisConfig"`
+	MysqlConfig        `toml:"mysqlConfig"`
+	JwtConfig          `toml:"jwtConfig"`
+	PasswordConfig     `toml:"passwordConfig"`
+	RateLimitConfig    `toml:"rateLimitConfig"`
+	MainConfig         `toml:"mainConfig"`
+	Rabbitmq           `toml:"rabbitmqConfig"`
+	MessageStoreConfig `toml:"messageStoreConfig"`
+	ImageAIConfig      `toml:"imageAIConfig"`
+	OllamaConfig       `toml:"ollamaConfig"`
+	EmbeddingConfig    `toml:"embeddingConfig"`
+	GoogleConfig       `toml:"googleConfig"`
+	VikingDBConfig     `toml:"vikingDBConfig"`
+	ToolConfig         `toml:"toolConfig"`
+	RoleConfig         `toml:"roleConfig"`
+	RAGConfig          `toml:"ragConfig"`
 }
 
 type RedisKeyConfig struct {
-	CaptchaPrefix string
+	CaptchaPrefix           string
+	CheckPointPrefix        string
+	ToolApprovalPrefix      string
+	UserToolApprovalsPrefix string
+	PasswordResetPrefix     string
+	AuthSessionPrefix       string
+	UserAuthSessionsPrefix  string
+	RevokedSessionPrefix    string
+	RateLimitPrefix         string
+	EmailCooldownPrefix     string
+	LoginFailuresPrefix     string
+	LoginLockPrefix         string
 }
 
 var DefaultRedisKeyConfig = RedisKeyConfig{
-	CaptchaPrefix: "captcha:%s",
+	CaptchaPrefix:           "captcha:%s",
+	CheckPointPrefix:        "agent:checkpoint:%s",
+	ToolApprovalPrefix:      "agent:approval:%s",
+	UserToolApprovalsPrefix: "agent:approvals:user:%s",
+	PasswordResetPrefix:     "password:reset:%s",
+	AuthSessionPrefix:       "auth:session:%s",
+	UserAuthSessionsPrefix:  "auth:sessions:user:%d",
+	RevokedSessionPrefix:    "auth:revoked:%s",
+	RateLimitPrefix:         "ratelimit:%s:%s",
+	EmailCooldownPrefix:     "email:cooldown:%s",
+	LoginFailuresPrefix:     "login:failures:%d",
+	LoginLockPrefix:         "login:lock:%d",
 }
 
 var config *Config
@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/controller"
+	"GopherAI/model"
+	"GopherAI/service/admin"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GetAuditLogsResponse struct {
+	Logs  []model.AuditLog `json:"logs"`
+	Total int64            `json:"total"` // 日志总数
+	controller.Response
+}
+
+// GetAuditLogs 分页查看审计日志
+// 查询参数：actor（操作人账号）、route（路由模板）、offset（默认 0）、limit（默认 20，最大 100）
+func GetAuditLogs(c *gin.Context) {
+	res := new(GetAuditLogsResponse)
+
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	logs, total, code_ := admin.ListAuditLogs(c.Query("actor"), c.Query("route"), offset, limit)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Logs = logs
+	res.Total = total
+	c.JSON(http.StatusOK, res)
+}
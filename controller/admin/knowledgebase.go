@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/controller"
+	"GopherAI/model"
+	"GopherAI/service/admin"
+	"GopherAI/service/rag"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	GetKnowledgeBasesResponse struct {
+		KnowledgeBases []model.KnowledgeBase `json:"knowledgeBases"`
+		Total          int64                 `json:"total"` // 知识库总数
+		controller.Response
+	}
+	KnowledgeBaseRequest struct {
+		Name        string `json:"name" binding:"required"` // 知识库名称
+		Description string `json:"description,omitempty"`   // 描述
+		Visibility  string `json:"visibility,omitempty"`    // private/public，默认 private
+	}
+	KnowledgeBaseResponse struct {
+		KnowledgeBase *model.KnowledgeBase `json:"knowledgeBase,omitempty"`
+		controller.Response
+	}
+	ReembedTaskResponse struct {
+		Task *model.ReembedTask `json:"task,omitempty"`
+		controller.Response
+	}
+)
+
+// GetKnowledgeBases 分页列出所有用户的知识库，不包含默认知识库
+// 查询参数：owner（只看该用户）、offset（默认 0）、limit（默认 20，最大 100）
+func GetKnowledgeBases(c *gin.Context) {
+	res := new(GetKnowledgeBasesResponse)
+
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	kbs, total, code_ := admin.ListKnowledgeBases(c.Query("owner"), offset, limit)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.KnowledgeBases = kbs
+	res.Total = total
+	c.JSON(http.StatusOK, res)
+}
+
+func UpdateKnowledgeBase(c *gin.Context) {
+	req := new(KnowledgeBaseRequest)
+	res := new(KnowledgeBaseResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	kb, code_ := admin.UpdateKnowledgeBase(c.Param("id"), rag.KnowledgeBaseRequest{
+		Name:        req.Name,
+		Description: req.Description,
+		Visibility:  req.Visibility,
+	})
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.KnowledgeBase = kb
+	c.JSON(http.StatusOK, res)
+}
+
+// StartReembed 在后台用当前向量模型重新向量化知识库，立即返回任务状态
+func StartReembed(c *gin.Context) {
+	res := new(ReembedTaskResponse)
+
+	task, code_ := admin.StartReembed(c.Param("id"), c.GetString("userName"))
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Task = &task
+	c.JSON(http.StatusOK, res)
+}
+
+// GetReembed 查看重新向量化任务的进度
+func GetReembed(c *gin.Context) {
+	res := new(ReembedTaskResponse)
+
+	task, code_ := admin.GetReembed(c.Param("id"))
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Task = &task
+	c.JSON(http.StatusOK, res)
+}
+
+func DeleteKnowledgeBase(c *gin.Context) {
+	res := new(controller.Response)
+
+	code_ := admin.DeleteKnowledgeBase(c.Request.Context(), c.Param("id"))
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
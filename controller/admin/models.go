@@ -0,0 +1,68 @@
+package admin
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/controller"
+	"GopherAI/model"
+	"GopherAI/service/admin"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	GetModelProfilesResponse struct {
+		Models []model.ModelProfile `json:"models"`
+		controller.Response
+	}
+	ModelProfileRequest struct {
+		Name        string   `json:"name,omitempty"`        // 展示名称，为空时使用默认名称
+		Description string   `json:"description,omitempty"` // 描述
+		Enabled     *bool    `json:"enabled" binding:"required"`
+		Roles       []string `json:"roles"` // 允许使用的角色，为空时不限制
+	}
+	ModelProfileResponse struct {
+		Model *model.ModelProfile `json:"model,omitempty"`
+		controller.Response
+	}
+)
+
+// GetModelProfiles 所有已注册的模型及其配置
+func GetModelProfiles(c *gin.Context) {
+	res := new(GetModelProfilesResponse)
+
+	profiles, code_ := admin.ListModelProfiles()
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Models = profiles
+	c.JSON(http.StatusOK, res)
+}
+
+// UpdateModelProfile 修改模型的名称、启用状态与允许使用的角色
+func UpdateModelProfile(c *gin.Context) {
+	req := new(ModelProfileRequest)
+	res := new(ModelProfileResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	profile, code_ := admin.UpdateModelProfile(c.Param("modelType"), admin.ModelProfileRequest{
+		Name:        req.Name,
+		Description: req.Description,
+		Enabled:     *req.Enabled,
+		Roles:       req.Roles,
+	})
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Model = profile
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/controller"
+	"GopherAI/model"
+	"GopherAI/service/admin"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	GetSessionsResponse struct {
+		Sessions []model.Session `json:"sessions"`
+		Total    int64           `json:"total"` // 会话总数
+		controller.Response
+	}
+	GetSessionResponse struct {
+		Session *model.Session  `json:"session,omitempty"`
+		History []model.History `json:"history"`
+		controller.Response
+	}
+	GetUsageResponse struct {
+		Usage []model.UserUsage `json:"usage"`
+		controller.Response
+	}
+)
+
+// GetSessions 分页列出会话
+// 查询参数：username（只看该用户）、offset（默认 0）、limit（默认 20，最大 100）
+func GetSessions(c *gin.Context) {
+	res := new(GetSessionsResponse)
+
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	sessions, total, code_ := admin.ListSessions(c.Query("username"), offset, limit)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Sessions = sessions
+	res.Total = total
+	c.JSON(http.StatusOK, res)
+}
+
+// GetSession 查看任意会话的消息记录
+func GetSession(c *gin.Context) {
+	res := new(GetSessionResponse)
+
+	sess, history, code_ := admin.GetSession(c.Param("sessionId"))
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Session = sess
+	res.History = history
+	c.JSON(http.StatusOK, res)
+}
+
+// GetUsage 按用户统计的用量
+// 查询参数：username（只看该用户）、days（最近天数，默认 30，最大 365）
+func GetUsage(c *gin.Context) {
+	res := new(GetUsageResponse)
+
+	days, _ := strconv.Atoi(c.Query("days"))
+	usage, code_ := admin.GetUsage(c.Query("username"), days)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Usage = usage
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,124 @@
+package admin
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/controller"
+	"GopherAI/model"
+	"GopherAI/service/admin"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	GetUsersResponse struct {
+		Users []*model.User `json:"users"`
+		Total int64         `json:"total"` // 用户总数
+		controller.Response
+	}
+	UpdateUserRoleRequest struct {
+		Role string `json:"role" binding:"required"` // 新角色
+	}
+)
+
+// actorOf 当前执行操作的管理员
+func actorOf(c *gin.Context) admin.Actor {
+	return admin.Actor{ID: c.GetInt64("userId"), Role: c.GetString("role")}
+}
+
+// userIDParam 解析路径中的用户 ID
+func userIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// GetUsers 分页列出用户
+// 查询参数：keyword（按账号或邮箱模糊搜索）、offset（默认 0）、limit（默认 20，最大 100）
+func GetUsers(c *gin.Context) {
+	res := new(GetUsersResponse)
+
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	users, total, code_ := admin.ListUsers(c.Query("keyword"), offset, limit)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Users = users
+	res.Total = total
+	c.JSON(http.StatusOK, res)
+}
+
+// DisableUser 禁用用户，该用户所有设备立即下线
+func DisableUser(c *gin.Context) {
+	setUserDisabled(c, true)
+}
+
+// EnableUser 解除禁用
+func EnableUser(c *gin.Context) {
+	setUserDisabled(c, false)
+}
+
+func setUserDisabled(c *gin.Context, disabled bool) {
+	res := new(controller.Response)
+	id, ok := userIDParam(c)
+	if !ok {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	code_ := admin.SetUserDisabled(actorOf(c), id, disabled)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// UpdateUserRole 修改用户角色，该用户需要重新登录
+func UpdateUserRole(c *gin.Context) {
+	req := new(UpdateUserRoleRequest)
+	res := new(controller.Response)
+	id, ok := userIDParam(c)
+	if !ok {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	code_ := admin.SetUserRole(actorOf(c), id, req.Role)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// DeleteUser 删除用户
+func DeleteUser(c *gin.Context) {
+	res := new(controller.Response)
+	id, ok := userIDParam(c)
+	if !ok {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	code_ := admin.DeleteUser(actorOf(c), id)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
@@ -1,6 +1,12 @@
 package controller
 
-import "GopherAI/common/code"
+import (
+	"GopherAI/common/code"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
 
 type Response struct {
 	StatusCode code.Code `json:"status_code"`
@@ -19,3 +25,19 @@ func (r *Response) CodeOf(code code.Code) Response {
 func (r *Response) Success() {
 	r.CodeOf(code.CodeSuccess)
 }
+
+// RetryResponse 被限流或账号被锁定时返回，RetryAfter 为需要等待的秒数
+type RetryResponse struct {
+	Response
+	RetryAfter int64 `json:"retryAfter,omitempty"`
+}
+
+// SetRetryAfter 设置 Retry-After 响应头，返回向上取整后的秒数
+func SetRetryAfter(c *gin.Context, retryAfter time.Duration) int64 {
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+	return seconds
+}
@@ -2,10 +2,13 @@ package image
 
 import (
 	"GopherAI/common/code"
+	image_recognizer "GopherAI/common/image"
 	"GopherAI/controller"
 	"GopherAI/service/image"
+	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,10 +18,15 @@ type (
 		ClassName string `json:"class_name,omitempty"` // AI回答
 		controller.Response
 	}
+	AnalyzeImageResponse struct {
+		Analysis *image_recognizer.ImageAnalysis `json:"analysis,omitempty"`
+		controller.Response
+	}
 )
 
 func RecognizeImage(c *gin.Context) {
 	res := new(RecognizeImageResponse)
+	limitBody(c)
 	fileHeader, err := c.FormFile("image")
 	if err != nil {
 		log.Println("FormFile fail ", err)
@@ -26,19 +34,60 @@ func RecognizeImage(c *gin.Context) {
 		return
 	}
 
-	file, err := fileHeader.Open()
-	if err != nil {
-		log.Println("Open file fail ", err)
-		c.JSON(http.StatusOK, res.CodeOf(code.CodeServerBusy))
+	buf, code_ := image.ReadUpload(fileHeader)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
 		return
 	}
 
-	defer file.Close()
+	className, code_ := image.RecognizeImage(c.Request.Context(), buf)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
 
-	className, err := image.RecognizeImage(c.Request.Context(), file)
+	res.Success()
+	res.ClassName = className
+	c.JSON(http.StatusOK, res)
+}
 
+// 图片结构化分析，表单字段：image 图片文件，prompt 关注的问题（可选），schema result 的 JSON Schema（可选）
+func AnalyzeImage(c *gin.Context) {
+	res := new(AnalyzeImageResponse)
+	limitBody(c)
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		log.Println("FormFile fail ", err)
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+	var schema json.RawMessage
+	if raw := strings.TrimSpace(c.PostForm("schema")); raw != "" {
+		if !json.Valid([]byte(raw)) {
+			c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+			return
+		}
+		schema = json.RawMessage(raw)
+	}
+
+	buf, code_ := image.ReadUpload(fileHeader)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	analysis, code_ := image.AnalyzeImage(c.Request.Context(), buf, c.PostForm("prompt"), schema)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
 
 	res.Success()
-	res.ClassName = className
+	res.Analysis = analysis
 	c.JSON(http.StatusOK, res)
 }
+
+// limitBody 限制请求体大小，多留一些空间给表单的其他字段
+func limitBody(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, image.MaxUploadBytes()+1<<20)
+}
@@ -0,0 +1,94 @@
+package rag
+
+import (
+	"GopherAI/common/code"
+	rag_common "GopherAI/common/rag"
+	"GopherAI/controller"
+	"GopherAI/service/rag"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	GetDocumentsResponse struct {
+		Documents []*rag_common.DocumentInfo `json:"documents"`
+		Total     int64                      `json:"total"` // 文档总数
+		controller.Response
+	}
+	DocumentResponse struct {
+		Document *rag_common.DocumentInfo `json:"document,omitempty"`
+		controller.Response
+	}
+	DeleteDocumentsResponse struct {
+		Deleted int `json:"deleted"` // 删除的文档数
+		controller.Response
+	}
+)
+
+// GetDocuments 分页列出知识库中的文档
+// 查询参数：offset（默认 0）、limit（默认 20，最大 100）
+func GetDocuments(c *gin.Context) {
+	res := new(GetDocumentsResponse)
+	userName := c.GetString("userName") // From JWT middleware
+
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	docs, total, code_ := rag.ListDocuments(c.Request.Context(), userName, c.Param("id"), offset, limit)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Documents = docs
+	res.Total = total
+	c.JSON(http.StatusOK, res)
+}
+
+func GetDocument(c *gin.Context) {
+	res := new(DocumentResponse)
+	userName := c.GetString("userName") // From JWT middleware
+
+	doc, code_ := rag.GetDocument(c.Request.Context(), userName, c.Param("id"), c.Param("docId"))
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Document = doc
+	c.JSON(http.StatusOK, res)
+}
+
+// DeleteDocument 删除文档及其全部分块
+func DeleteDocument(c *gin.Context) {
+	res := new(controller.Response)
+	userName := c.GetString("userName") // From JWT middleware
+
+	code_ := rag.DeleteDocument(c.Request.Context(), userName, c.Param("id"), c.Param("docId"))
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// DeleteDocuments 按来源删除文档，查询参数 source 必填
+func DeleteDocuments(c *gin.Context) {
+	res := new(DeleteDocumentsResponse)
+	userName := c.GetString("userName") // From JWT middleware
+
+	deleted, code_ := rag.DeleteDocumentsBySource(c.Request.Context(), userName, c.Param("id"), c.Query("source"))
+	res.Deleted = deleted
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
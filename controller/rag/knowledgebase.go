@@ -0,0 +1,142 @@
+package rag
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/controller"
+	"GopherAI/model"
+	"GopherAI/service/rag"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	KnowledgeBaseRequest struct {
+		Name        string `json:"name" binding:"required"` // 知识库名称
+		Description string `json:"description,omitempty"`   // 描述
+		Visibility  string `json:"visibility,omitempty"`    // private/public，默认 private
+	}
+	KnowledgeBaseResponse struct {
+		KnowledgeBase *model.KnowledgeBase `json:"knowledgeBase,omitempty"`
+		controller.Response
+	}
+	GetKnowledgeBasesResponse struct {
+		KnowledgeBases []model.KnowledgeBaseInfo `json:"knowledgeBases"`
+		controller.Response
+	}
+	ShareKnowledgeBaseRequest struct {
+		UserName string `json:"username" binding:"required"` // 被分享的用户
+	}
+)
+
+func (req *KnowledgeBaseRequest) toService() rag.KnowledgeBaseRequest {
+	return rag.KnowledgeBaseRequest{
+		Name:        req.Name,
+		Description: req.Description,
+		Visibility:  req.Visibility,
+	}
+}
+
+// GetKnowledgeBases 获取自己的、分享给自己的以及公开的知识库
+func GetKnowledgeBases(c *gin.Context) {
+	res := new(GetKnowledgeBasesResponse)
+	userName := c.GetString("userName") // From JWT middleware
+
+	kbs, code_ := rag.GetKnowledgeBases(userName)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.KnowledgeBases = kbs
+	c.JSON(http.StatusOK, res)
+}
+
+func CreateKnowledgeBase(c *gin.Context) {
+	req := new(KnowledgeBaseRequest)
+	res := new(KnowledgeBaseResponse)
+	userName := c.GetString("userName") // From JWT middleware
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	kb, code_ := rag.CreateKnowledgeBase(c.Request.Context(), userName, req.toService())
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.KnowledgeBase = kb
+	c.JSON(http.StatusOK, res)
+}
+
+func UpdateKnowledgeBase(c *gin.Context) {
+	req := new(KnowledgeBaseRequest)
+	res := new(KnowledgeBaseResponse)
+	userName := c.GetString("userName") // From JWT middleware
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	kb, code_ := rag.UpdateKnowledgeBase(userName, c.Param("id"), req.toService())
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.KnowledgeBase = kb
+	c.JSON(http.StatusOK, res)
+}
+
+// DeleteKnowledgeBase 删除知识库及其全部文档
+func DeleteKnowledgeBase(c *gin.Context) {
+	res := new(controller.Response)
+	userName := c.GetString("userName") // From JWT middleware
+
+	code_ := rag.DeleteKnowledgeBase(c.Request.Context(), userName, c.Param("id"))
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+func ShareKnowledgeBase(c *gin.Context) {
+	req := new(ShareKnowledgeBaseRequest)
+	res := new(controller.Response)
+	userName := c.GetString("userName") // From JWT middleware
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	code_ := rag.ShareKnowledgeBase(userName, c.Param("id"), req.UserName)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+func UnshareKnowledgeBase(c *gin.Context) {
+	res := new(controller.Response)
+	userName := c.GetString("userName") // From JWT middleware
+
+	code_ := rag.UnshareKnowledgeBase(userName, c.Param("id"), c.Param("userName"))
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
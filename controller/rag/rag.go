@@ -0,0 +1,133 @@
+package rag
+
+import (
+	"GopherAI/common/code"
+	rag_common "GopherAI/common/rag"
+	"GopherAI/common/rag/ingest"
+	"GopherAI/controller"
+	"GopherAI/service/rag"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	UploadDocumentResponse struct {
+		Result *ingest.Result `json:"result,omitempty"` // 导入结果
+		controller.Response
+	}
+	SearchRequest struct {
+		Query            string   `json:"query" binding:"required"`   // 检索内容
+		KnowledgeBaseIDs []string `json:"knowledgeBaseIds,omitempty"` // 检索的知识库，不传只检索默认知识库
+		K                int      `json:"k,omitempty"`                // 返回条数，默认 3
+		Mode             string   `json:"mode,omitempty"`             // vector / keyword / hybrid，不传使用配置
+		Sources          []string `json:"sources,omitempty"`          // 按来源过滤
+		Tags             []string `json:"tags,omitempty"`             // 按标签过滤
+		MinScore         float64  `json:"minScore,omitempty"`         // 最低分数，不传使用配置
+	}
+	SearchResponse struct {
+		Results []*rag_common.RagReturnedData `json:"results"`
+		controller.Response
+	}
+)
+
+// UploadDocument 上传文档并导入知识库
+// 表单字段：knowledgeBaseId（必填）、file（必填）、documentId、format、title、tags（逗号分隔）、chunkStrategy、chunkSize、chunkOverlap
+func UploadDocument(c *gin.Context) {
+	res := new(UploadDocumentResponse)
+	userName := c.GetString("userName") // From JWT middleware
+
+	maxBytes := rag.MaxUploadBytes()
+	// 多留一些空间给表单的其他字段
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		log.Println("FormFile fail ", err)
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+	if fileHeader.Size > maxBytes {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Println("Open file fail ", err)
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeServerBusy))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		log.Println("Read file fail ", err)
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeServerBusy))
+		return
+	}
+
+	chunkSize, _ := strconv.Atoi(c.PostForm("chunkSize"))
+	// 不传 chunkOverlap 时使用配置，传 0 表示不重叠
+	var chunkOverlap *int
+	if value, ok := c.GetPostForm("chunkOverlap"); ok && value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+			return
+		}
+		chunkOverlap = &parsed
+	}
+	result, code_ := rag.UploadDocument(c.Request.Context(), userName, rag.DocumentUpload{
+		KnowledgeBaseID: c.PostForm("knowledgeBaseId"),
+		DocumentID:      c.PostForm("documentId"),
+		FileName:        fileHeader.Filename,
+		Data:            data,
+		Format:          c.PostForm("format"),
+		Title:           c.PostForm("title"),
+		Tags:            ingest.ParseTags(c.PostForm("tags")),
+		ChunkStrategy:   c.PostForm("chunkStrategy"),
+		ChunkSize:       chunkSize,
+		ChunkOverlap:    chunkOverlap,
+	})
+	res.Result = result
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// Search 在知识库中检索，支持向量、关键词与混合检索
+func Search(c *gin.Context) {
+	req := new(SearchRequest)
+	res := new(SearchResponse)
+	userName := c.GetString("userName") // From JWT middleware
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	results, code_ := rag.Search(c.Request.Context(), userName, rag.SearchRequest{
+		Query:            req.Query,
+		KnowledgeBaseIDs: req.KnowledgeBaseIDs,
+		K:                req.K,
+		Mode:             req.Mode,
+		Sources:          req.Sources,
+		Tags:             req.Tags,
+		MinScore:         req.MinScore,
+	})
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Results = results
+	c.JSON(http.StatusOK, res)
+}
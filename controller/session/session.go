@@ -4,8 +4,11 @@ import (
 	"GopherAI/common/code"
 	"GopherAI/controller"
 	"GopherAI/model"
+	"GopherAI/service/image"
 	"GopherAI/service/session"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -17,31 +20,75 @@ type (
 		Sessions []model.SessionInfo `json:"sessions,omitempty"`
 	}
 	CreateSessionAndSendMessageRequest struct {
-		UserQuestion string `json:"question" binding:"required"`  // 用户问题;
-		ModelType    string `json:"modelType" binding:"required"` // 模型类型;
-		UsingGoogle  bool   `json:"usingGoogle,omitempty"`        // 是否使用Google搜索
-		UsingRAG     bool   `json:"usingRAG,omitempty"`           // 是否使用RAG检索
+		UserQuestion string   `json:"question" binding:"required"`  // 用户问题;
+		ModelType    string   `json:"modelType" binding:"required"` // 模型类型;
+		Tools        []string `json:"tools,omitempty"`              // 启用的工具，不传则使用用户默认设置
+		UsingGoogle  bool     `json:"usingGoogle,omitempty"`        // 是否使用Google搜索
+		UsingRAG     bool     `json:"usingRAG,omitempty"`           // 是否使用RAG检索
+		// 问题附带的图片，需要 modelType 对应的模型支持图片输入
+		Images []model.MessageImage `json:"images,omitempty"`
+		// 会话关联的知识库，不传则只检索默认知识库
+		KnowledgeBaseIDs []string `json:"knowledgeBaseIds,omitempty"`
 	}
 
 	CreateSessionAndSendMessageResponse struct {
-		AiInformation string `json:"Information,omitempty"` // AI回答
-		SessionID     string `json:"sessionId,omitempty"`   // 当前会话ID
+		AiInformation   string                  `json:"Information,omitempty"`     // AI回答
+		Citations       []model.MessageCitation `json:"citations,omitempty"`       // 回答引用的资料，与回答中的 [index] 对应
+		SessionID       string                  `json:"sessionId,omitempty"`       // 当前会话ID
+		PendingApproval *model.ToolApproval     `json:"pendingApproval,omitempty"` // 等待用户确认的工具调用
 		controller.Response
 	}
 
 	ChatSendRequest struct {
-		UserQuestion string `json:"question" binding:"required"`            // 用户问题;
-		ModelType    string `json:"modelType" binding:"required"`           // 模型类型;
-		SessionID    string `json:"sessionId,omitempty" binding:"required"` // 当前会话ID
-		UsingGoogle  bool   `json:"usingGoogle,omitempty"`                  // 是否使用Google搜索
-		UsingRAG     bool   `json:"usingRAG,omitempty"`                     // 是否使用RAG检索
+		UserQuestion string   `json:"question" binding:"required"`            // 用户问题;
+		ModelType    string   `json:"modelType" binding:"required"`           // 模型类型;
+		SessionID    string   `json:"sessionId,omitempty" binding:"required"` // 当前会话ID
+		Tools        []string `json:"tools,omitempty"`                        // 启用的工具，不传则使用用户默认设置
+		UsingGoogle  bool     `json:"usingGoogle,omitempty"`                  // 是否使用Google搜索
+		UsingRAG     bool     `json:"usingRAG,omitempty"`                     // 是否使用RAG检索
+		// 问题附带的图片，需要 modelType 对应的模型支持图片输入
+		Images []model.MessageImage `json:"images,omitempty"`
 	}
 
 	ChatSendResponse struct {
-		AiInformation string `json:"Information,omitempty"` // AI回答
+		AiInformation   string                  `json:"Information,omitempty"`     // AI回答
+		Citations       []model.MessageCitation `json:"citations,omitempty"`       // 回答引用的资料，与回答中的 [index] 对应
+		PendingApproval *model.ToolApproval     `json:"pendingApproval,omitempty"` // 等待用户确认的工具调用
 		controller.Response
 	}
 
+	GetChatToolsResponse struct {
+		Tools []model.ChatToolInfo `json:"tools"`
+		controller.Response
+	}
+	UpdateChatToolsRequest struct {
+		Tools []string `json:"tools"` // 默认启用的工具
+	}
+	UpdateChatToolsResponse struct {
+		controller.Response
+	}
+	GetChatModelsResponse struct {
+		Models []model.ModelProfile `json:"models"`
+		controller.Response
+	}
+
+	SessionKnowledgeBasesRequest struct {
+		KnowledgeBaseIDs []string `json:"knowledgeBaseIds"` // 会话关联的知识库，空列表表示只检索默认知识库
+	}
+	SessionKnowledgeBasesResponse struct {
+		KnowledgeBaseIDs []string `json:"knowledgeBaseIds"`
+		controller.Response
+	}
+
+	GetToolApprovalsResponse struct {
+		Approvals []model.ToolApproval `json:"approvals"`
+		controller.Response
+	}
+	DecideToolApprovalRequest struct {
+		Approved bool   `json:"approved"`         // 是否允许调用
+		Reason   string `json:"reason,omitempty"` // 拒绝原因
+	}
+
 	ChatHistoryRequest struct {
 		SessionID string `json:"sessionId,omitempty" binding:"required"` // 当前会话ID
 	}
@@ -118,6 +165,45 @@ func CreateTravelPlanningTask(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
+// CreatePhotoTravelPlanningTask 从照片开始旅行规划
+// 表单字段：photos 一张或多张照片（必填），description 补充说明（可选）
+func CreatePhotoTravelPlanningTask(c *gin.Context) {
+	res := new(CreateTravelPlanningTaskResponse)
+	// 多留一些空间给表单的其他字段
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, session.MaxTravelPhotos*image.MaxUploadBytes()+1<<20)
+	form, err := c.MultipartForm()
+	if err != nil {
+		log.Println("MultipartForm fail ", err)
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+	files := form.File["photos"]
+	if len(files) == 0 {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	photos := make([]session.TravelPhoto, 0, len(files))
+	for _, fileHeader := range files {
+		data, code_ := image.ReadUpload(fileHeader)
+		if code_ != code.CodeSuccess {
+			c.JSON(http.StatusOK, res.CodeOf(code_))
+			return
+		}
+		photos = append(photos, session.TravelPhoto{Name: fileHeader.Filename, Data: data})
+	}
+
+	task, code_ := session.StartTravelPlanningTaskFromPhotos(photos, c.PostForm("description"))
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Task = task
+	c.JSON(http.StatusOK, res)
+}
+
 func GetTravelPlanningTask(c *gin.Context) {
 	res := new(GetTravelPlanningTaskResponse)
 	taskID := c.Param("taskId")
@@ -141,12 +227,13 @@ func CreateSessionAndSendMessage(c *gin.Context) {
 	req := new(CreateSessionAndSendMessageRequest)
 	res := new(CreateSessionAndSendMessageResponse)
 	userName := c.GetString("userName") // From JWT middleware
-	if err := c.ShouldBindJSON(req); err != nil {
-		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+	role := c.GetString("role")
+	if code_ := bindChatRequest(c, req); code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
 		return
 	}
 	//内部会创建会话并发送消息，并会将AI回答、当前会话返回
-	session_id, aiInformation, code_ := session.CreateSessionAndSendMessage(userName, req.UserQuestion, req.ModelType, req.UsingGoogle, req.UsingRAG)
+	session_id, aiResponse, approval, code_ := session.CreateSessionAndSendMessage(userName, role, req.UserQuestion, req.Images, req.ModelType, req.Tools, req.UsingGoogle, req.UsingRAG, req.KnowledgeBaseIDs)
 
 	if code_ != code.CodeSuccess {
 		c.JSON(http.StatusOK, res.CodeOf(code_))
@@ -154,15 +241,20 @@ func CreateSessionAndSendMessage(c *gin.Context) {
 	}
 
 	res.Success()
-	res.AiInformation = aiInformation
+	if aiResponse != nil {
+		res.AiInformation = aiResponse.Content
+		res.Citations = aiResponse.Citations
+	}
 	res.SessionID = session_id
+	res.PendingApproval = approval
 	c.JSON(http.StatusOK, res)
 }
 
 func CreateStreamSessionAndSendMessage(c *gin.Context) {
 	req := new(CreateSessionAndSendMessageRequest)
 	userName := c.GetString("userName") // From JWT middleware
-	if err := c.ShouldBindJSON(req); err != nil {
+	role := c.GetString("role")
+	if code_ := bindChatRequest(c, req); code_ != code.CodeSuccess {
 		c.JSON(http.StatusOK, gin.H{"error": "Invalid parameters"})
 		return
 	}
@@ -175,7 +267,7 @@ func CreateStreamSessionAndSendMessage(c *gin.Context) {
 	c.Header("X-Accel-Buffering", "no") // 禁止代理缓存
 
 	// 先创建会话并立即把 sessionId 下发给前端，随后再开始流式输出
-	sessionID, code_ := session.CreateStreamSessionOnly(userName, req.UserQuestion)
+	sessionID, code_ := session.CreateStreamSessionOnly(userName, req.UserQuestion, req.KnowledgeBaseIDs)
 	if code_ != code.CodeSuccess {
 		c.SSEvent("error", gin.H{"message": "Failed to create session"})
 		return
@@ -186,7 +278,7 @@ func CreateStreamSessionAndSendMessage(c *gin.Context) {
 	c.Writer.Flush()
 
 	// 然后开始把本次回答进行流式发送（包含最后的 [DONE]）
-	code_ = session.StreamMessageToExistingSession(userName, sessionID, req.UserQuestion, req.ModelType, http.ResponseWriter(c.Writer))
+	code_ = session.StreamMessageToExistingSession(userName, role, sessionID, req.UserQuestion, req.Images, req.ModelType, req.Tools, req.UsingGoogle, req.UsingRAG, http.ResponseWriter(c.Writer))
 	if code_ != code.CodeSuccess {
 		c.SSEvent("error", gin.H{"message": "Failed to send message"})
 		return
@@ -197,12 +289,13 @@ func ChatSend(c *gin.Context) {
 	req := new(ChatSendRequest)
 	res := new(ChatSendResponse)
 	userName := c.GetString("userName") // From JWT middleware
-	if err := c.ShouldBindJSON(req); err != nil {
-		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+	role := c.GetString("role")
+	if code_ := bindChatRequest(c, req); code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
 		return
 	}
 	// 发送消息，并会将AI回答返回
-	aiInformation, code_ := session.ChatSend(userName, req.SessionID, req.UserQuestion, req.ModelType, req.UsingGoogle, req.UsingRAG)
+	aiResponse, approval, code_ := session.ChatSend(userName, role, req.SessionID, req.UserQuestion, req.Images, req.ModelType, req.Tools, req.UsingGoogle, req.UsingRAG)
 
 	if code_ != code.CodeSuccess {
 		c.JSON(http.StatusOK, res.CodeOf(code_))
@@ -210,14 +303,19 @@ func ChatSend(c *gin.Context) {
 	}
 
 	res.Success()
-	res.AiInformation = aiInformation
+	if aiResponse != nil {
+		res.AiInformation = aiResponse.Content
+		res.Citations = aiResponse.Citations
+	}
+	res.PendingApproval = approval
 	c.JSON(http.StatusOK, res)
 }
 
 func ChatStreamSend(c *gin.Context) {
 	req := new(ChatSendRequest)
 	userName := c.GetString("userName") // From JWT middleware
-	if err := c.ShouldBindJSON(req); err != nil {
+	role := c.GetString("role")
+	if code_ := bindChatRequest(c, req); code_ != code.CodeSuccess {
 		c.JSON(http.StatusOK, gin.H{"error": "Invalid parameters"})
 		return
 	}
@@ -229,7 +327,7 @@ func ChatStreamSend(c *gin.Context) {
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Header("X-Accel-Buffering", "no") // 禁止代理缓存
 
-	code_ := session.ChatStreamSend(userName, req.SessionID, req.UserQuestion, req.ModelType, http.ResponseWriter(c.Writer))
+	code_ := session.ChatStreamSend(userName, role, req.SessionID, req.UserQuestion, req.Images, req.ModelType, req.Tools, req.UsingGoogle, req.UsingRAG, http.ResponseWriter(c.Writer))
 	if code_ != code.CodeSuccess {
 		c.SSEvent("error", gin.H{"message": "Failed to send message"})
 		return
@@ -237,6 +335,139 @@ func ChatStreamSend(c *gin.Context) {
 
 }
 
+// 获取当前角色可以使用的聊天模型
+func GetChatModels(c *gin.Context) {
+	res := new(GetChatModelsResponse)
+	role := c.GetString("role")
+
+	models, code_ := session.GetChatModels(role)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Models = models
+	c.JSON(http.StatusOK, res)
+}
+
+// 获取当前用户可用的工具及默认启用状态
+func GetChatTools(c *gin.Context) {
+	res := new(GetChatToolsResponse)
+	userName := c.GetString("userName") // From JWT middleware
+	role := c.GetString("role")
+
+	tools, code_ := session.GetChatTools(userName, role)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Tools = tools
+	c.JSON(http.StatusOK, res)
+}
+
+// 保存当前用户默认启用的工具
+func UpdateChatTools(c *gin.Context) {
+	req := new(UpdateChatToolsRequest)
+	res := new(UpdateChatToolsResponse)
+	userName := c.GetString("userName") // From JWT middleware
+	role := c.GetString("role")
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	code_ := session.UpdateChatTools(userName, role, req.Tools)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// 获取会话关联的知识库
+func GetSessionKnowledgeBases(c *gin.Context) {
+	res := new(SessionKnowledgeBasesResponse)
+	userName := c.GetString("userName") // From JWT middleware
+
+	kbs, code_ := session.GetSessionKnowledgeBases(userName, c.Param("sessionId"))
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.KnowledgeBaseIDs = kbs
+	c.JSON(http.StatusOK, res)
+}
+
+// 修改会话关联的知识库，之后的 rag_search 只在这些知识库中检索
+func UpdateSessionKnowledgeBases(c *gin.Context) {
+	req := new(SessionKnowledgeBasesRequest)
+	res := new(SessionKnowledgeBasesResponse)
+	userName := c.GetString("userName") // From JWT middleware
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	kbs, code_ := session.UpdateSessionKnowledgeBases(userName, c.Param("sessionId"), req.KnowledgeBaseIDs)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.KnowledgeBaseIDs = kbs
+	c.JSON(http.StatusOK, res)
+}
+
+// 获取当前用户等待确认的工具调用
+func GetToolApprovals(c *gin.Context) {
+	res := new(GetToolApprovalsResponse)
+	userName := c.GetString("userName") // From JWT middleware
+
+	approvals, code_ := session.ListToolApprovals(userName)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Approvals = approvals
+	c.JSON(http.StatusOK, res)
+}
+
+// 确认或拒绝工具调用，并返回恢复生成后的AI回答
+func DecideToolApproval(c *gin.Context) {
+	req := new(DecideToolApprovalRequest)
+	res := new(ChatSendResponse)
+	userName := c.GetString("userName") // From JWT middleware
+	approvalID := c.Param("approvalId")
+	if err := c.ShouldBindJSON(req); err != nil || approvalID == "" {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	aiResponse, approval, code_ := session.DecideToolApproval(userName, approvalID, req.Approved, req.Reason)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	if aiResponse != nil {
+		res.AiInformation = aiResponse.Content
+		res.Citations = aiResponse.Citations
+	}
+	res.PendingApproval = approval
+	c.JSON(http.StatusOK, res)
+}
+
 // 获取聊天历史记录
 func ChatHistory(c *gin.Context) {
 	req := new(ChatHistoryRequest)
@@ -256,3 +487,31 @@ func ChatHistory(c *gin.Context) {
 	res.History = history
 	c.JSON(http.StatusOK, res)
 }
+
+// bindChatRequest 限制请求体大小后解析聊天请求，超过上限时返回 CodeImageTooLarge
+func bindChatRequest(c *gin.Context, req any) code.Code {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, session.MaxChatRequestBytes())
+	if err := c.ShouldBindJSON(req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return code.CodeImageTooLarge
+		}
+		return code.CodeInvalidParams
+	}
+	return code.CodeSuccess
+}
+
+// 读取聊天消息引用的图片，只能读取自己上传的图片
+func GetChatImage(c *gin.Context) {
+	res := new(controller.Response)
+	userName := c.GetString("userName") // From JWT middleware
+	key := c.Param("owner") + "/" + c.Param("name")
+	data, mimeType, code_ := session.GetChatImage(userName, key)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+	// 内容按 key 的哈希寻址，不会变化
+	c.Header("Cache-Control", "private, max-age=31536000, immutable")
+	c.Data(http.StatusOK, mimeType, data)
+}
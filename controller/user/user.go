@@ -10,15 +10,20 @@ import (
 )
 
 type (
-	//这里的Username只能是账号登录，和我做的另一个项目有区别（邮箱账号均可)
+	//Username 可以是账号，也可以是注册邮箱
 	LoginRequest struct {
 		Username string `json:"username"`
-		Password string `json:password`
+		Password string `json:"password"`
 	}
 	// omitempty当字段为空的时候，不返回这个东西
+	//token 为短期的访问令牌，过期后用 refreshToken 换取新的令牌对
+	//账号被临时锁定时 retryAfter 为剩余的锁定秒数
 	LoginResponse struct {
 		controller.Response
-		Token string `json:"token,omitempty"`
+		Token        string `json:"token,omitempty"`
+		RefreshToken string `json:"refreshToken,omitempty"`
+		ExpiresIn    int64  `json:"expiresIn,omitempty"`
+		RetryAfter   int64  `json:"retryAfter,omitempty"`
 	}
 	//验证码由后端生成，存放到redis中，固然需要先发送一次请求CaptchaRequest,然后用返回的验证码
 	//邮箱以及密码进行注册，后续再将账号进行返回
@@ -30,15 +35,71 @@ type (
 	//注册成功之后，直接让其进行登录状态
 	RegisterResponse struct {
 		controller.Response
-		Token string `json:"token,omitempty"`
+		Token        string `json:"token,omitempty"`
+		RefreshToken string `json:"refreshToken,omitempty"`
+		ExpiresIn    int64  `json:"expiresIn,omitempty"`
 	}
 
 	CaptchaRequest struct {
 		Email string `json:"email" binding:"required"`
 	}
 
+	//同一邮箱发送过于频繁时 retryAfter 为需要等待的秒数
 	CaptchaResponse struct {
 		controller.Response
+		RetryAfter int64 `json:"retryAfter,omitempty"`
+	}
+
+	ChangePasswordRequest struct {
+		OldPassword string `json:"oldPassword" binding:"required"`
+		NewPassword string `json:"newPassword" binding:"required"`
+	}
+	ChangePasswordResponse struct {
+		controller.Response
+	}
+	//修改密码后旧的令牌全部失效，客户端需要改用这里返回的新令牌
+	ChangePasswordTokenResponse struct {
+		controller.Response
+		Token        string `json:"token,omitempty"`
+		RefreshToken string `json:"refreshToken,omitempty"`
+		ExpiresIn    int64  `json:"expiresIn,omitempty"`
+		RetryAfter   int64  `json:"retryAfter,omitempty"`
+	}
+
+	//忘记密码：先发送验证码，校验通过后拿到一次性的 resetToken，再用它设置新密码
+	ForgotPasswordRequest struct {
+		Email string `json:"email" binding:"required"`
+	}
+	//同一邮箱对应多个账号时需要通过 username 指定要重置的账号
+	VerifyResetCaptchaRequest struct {
+		Email    string `json:"email" binding:"required"`
+		Captcha  string `json:"captcha" binding:"required"`
+		Username string `json:"username"`
+	}
+	VerifyResetCaptchaResponse struct {
+		controller.Response
+		ResetToken string `json:"resetToken,omitempty"`
+	}
+	ResetPasswordRequest struct {
+		ResetToken  string `json:"resetToken" binding:"required"`
+		NewPassword string `json:"newPassword" binding:"required"`
+	}
+	ResendAccountRequest struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	//刷新令牌只能使用一次，刷新成功后需要保存新返回的 refreshToken
+	RefreshTokenRequest struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	RefreshTokenResponse struct {
+		controller.Response
+		Token        string `json:"token,omitempty"`
+		RefreshToken string `json:"refreshToken,omitempty"`
+		ExpiresIn    int64  `json:"expiresIn,omitempty"`
+	}
+	LogoutResponse struct {
+		controller.Response
 	}
 )
 
@@ -51,14 +112,20 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	token, code_ := user.Login(req.Username, req.Password)
+	tokens, retryAfter, code_ := user.Login(req.Username, req.Password)
 	if code_ != code.CodeSuccess {
-		c.JSON(http.StatusOK, res.CodeOf(code_))
+		res.CodeOf(code_)
+		if retryAfter > 0 {
+			res.RetryAfter = controller.SetRetryAfter(c, retryAfter)
+		}
+		c.JSON(http.StatusOK, res)
 		return
 	}
 
 	res.Success()
-	res.Token = token
+	res.Token = tokens.AccessToken
+	res.RefreshToken = tokens.RefreshToken
+	res.ExpiresIn = tokens.ExpiresIn
 	c.JSON(http.StatusOK, res)
 
 }
@@ -72,14 +139,16 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	token, code_ := user.Register(req.Email, req.Password, req.Captcha)
+	tokens, code_ := user.Register(req.Email, req.Password, req.Captcha)
 	if code_ != code.CodeSuccess {
 		c.JSON(http.StatusOK, res.CodeOf(code_))
 		return
 	}
 
 	res.Success()
-	res.Token = token
+	res.Token = tokens.AccessToken
+	res.RefreshToken = tokens.RefreshToken
+	res.ExpiresIn = tokens.ExpiresIn
 	c.JSON(http.StatusOK, res)
 }
 
@@ -93,9 +162,13 @@ func HandleCaptcha(c *gin.Context) {
 	}
 
 	//给service层进行处理
-	code_ := user.SendCaptcha(req.Email)
+	retryAfter, code_ := user.SendCaptcha(req.Email)
 	if code_ != code.CodeSuccess {
-		c.JSON(http.StatusOK, res.CodeOf(code_))
+		res.CodeOf(code_)
+		if retryAfter > 0 {
+			res.RetryAfter = controller.SetRetryAfter(c, retryAfter)
+		}
+		c.JSON(http.StatusOK, res)
 		return
 	}
 	//匿名字段，其实本身res.Success()调用就是res.Response.Success()
@@ -103,3 +176,168 @@ func HandleCaptcha(c *gin.Context) {
 	res.Success()
 	c.JSON(http.StatusOK, res)
 }
+
+// 修改密码，需要登录
+func ChangePassword(c *gin.Context) {
+	req := new(ChangePasswordRequest)
+	res := new(ChangePasswordTokenResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	userName := c.GetString("userName") // From JWT middleware
+	tokens, retryAfter, code_ := user.ChangePassword(userName, req.OldPassword, req.NewPassword)
+	if code_ != code.CodeSuccess {
+		res.CodeOf(code_)
+		if retryAfter > 0 {
+			res.RetryAfter = controller.SetRetryAfter(c, retryAfter)
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Success()
+	res.Token = tokens.AccessToken
+	res.RefreshToken = tokens.RefreshToken
+	res.ExpiresIn = tokens.ExpiresIn
+	c.JSON(http.StatusOK, res)
+}
+
+// 忘记密码，向注册邮箱发送验证码
+func ForgotPassword(c *gin.Context) {
+	req := new(ForgotPasswordRequest)
+	res := new(CaptchaResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	retryAfter, code_ := user.SendPasswordResetCaptcha(req.Email)
+	if code_ != code.CodeSuccess {
+		res.CodeOf(code_)
+		if retryAfter > 0 {
+			res.RetryAfter = controller.SetRetryAfter(c, retryAfter)
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// 校验忘记密码的验证码，返回重置令牌
+func VerifyResetCaptcha(c *gin.Context) {
+	req := new(VerifyResetCaptchaRequest)
+	res := new(VerifyResetCaptchaResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	resetToken, code_ := user.VerifyPasswordResetCaptcha(req.Email, req.Captcha, req.Username)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.ResetToken = resetToken
+	c.JSON(http.StatusOK, res)
+}
+
+// 使用重置令牌设置新密码
+func ResetPassword(c *gin.Context) {
+	req := new(ResetPasswordRequest)
+	res := new(ChangePasswordResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	code_ := user.ResetPassword(req.ResetToken, req.NewPassword)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// 把邮箱对应的账号重新发送到邮箱
+func ResendAccount(c *gin.Context) {
+	req := new(ResendAccountRequest)
+	res := new(CaptchaResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	retryAfter, code_ := user.ResendAccountID(req.Email)
+	if code_ != code.CodeSuccess {
+		res.CodeOf(code_)
+		if retryAfter > 0 {
+			res.RetryAfter = controller.SetRetryAfter(c, retryAfter)
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// 用刷新令牌换取新的访问令牌与刷新令牌
+func RefreshToken(c *gin.Context) {
+	req := new(RefreshTokenRequest)
+	res := new(RefreshTokenResponse)
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidParams))
+		return
+	}
+
+	tokens, code_ := user.RefreshTokens(req.RefreshToken)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	res.Token = tokens.AccessToken
+	res.RefreshToken = tokens.RefreshToken
+	res.ExpiresIn = tokens.ExpiresIn
+	c.JSON(http.StatusOK, res)
+}
+
+// 退出当前登录会话，需要登录
+func Logout(c *gin.Context) {
+	res := new(LogoutResponse)
+
+	userID := c.GetInt64("userId")        // From JWT middleware
+	sessionID := c.GetString("sessionId") // From JWT middleware
+	code_ := user.Logout(userID, sessionID)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
+
+// 退出所有设备上的登录会话，需要登录
+func LogoutAll(c *gin.Context) {
+	res := new(LogoutResponse)
+
+	userID := c.GetInt64("userId") // From JWT middleware
+	code_ := user.LogoutAll(userID)
+	if code_ != code.CodeSuccess {
+		c.JSON(http.StatusOK, res.CodeOf(code_))
+		return
+	}
+
+	res.Success()
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,36 @@
+package audit
+
+import (
+	"GopherAI/common/mysql"
+	"GopherAI/model"
+)
+
+// AuditLogQuery 审计日志的筛选条件，空值表示不筛选
+type AuditLogQuery struct {
+	ActorName string
+	Route     string
+	Offset    int
+	Limit     int
+}
+
+func CreateAuditLog(entry *model.AuditLog) error {
+	return mysql.DB.Create(entry).Error
+}
+
+// ListAuditLogs 按时间倒序返回审计日志
+func ListAuditLogs(q AuditLogQuery) ([]model.AuditLog, int64, error) {
+	var logs []model.AuditLog
+	var total int64
+	query := mysql.DB.Model(&model.AuditLog{})
+	if q.ActorName != "" {
+		query = query.Where("actor_name = ?", q.ActorName)
+	}
+	if q.Route != "" {
+		query = query.Where("route = ?", q.Route)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id desc").Offset(q.Offset).Limit(q.Limit).Find(&logs).Error
+	return logs, total, err
+}
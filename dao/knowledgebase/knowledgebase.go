@@ -0,0 +1,101 @@
+package knowledgebase
+
+import (
+	"GopherAI/common/mysql"
+	"GopherAI/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func CreateKnowledgeBase(kb *model.KnowledgeBase) (*model.KnowledgeBase, error) {
+	err := mysql.DB.Create(kb).Error
+	return kb, err
+}
+
+func GetKnowledgeBaseByID(id string) (*model.KnowledgeBase, error) {
+	var kb model.KnowledgeBase
+	err := mysql.DB.Where("id = ?", id).First(&kb).Error
+	return &kb, err
+}
+
+func GetKnowledgeBasesByIDs(ids []string) ([]model.KnowledgeBase, error) {
+	var kbs []model.KnowledgeBase
+	if len(ids) == 0 {
+		return kbs, nil
+	}
+	err := mysql.DB.Where("id IN ?", ids).Find(&kbs).Error
+	return kbs, err
+}
+
+// GetAccessibleKnowledgeBases 用户自己的、分享给用户的以及公开的知识库
+func GetAccessibleKnowledgeBases(userName string) ([]model.KnowledgeBase, error) {
+	var kbs []model.KnowledgeBase
+	shared := mysql.DB.Model(&model.KnowledgeBaseShare{}).Select("knowledge_base_id").Where("user_name = ?", userName)
+	// 分组条件，避免与软删除条件混在一起
+	accessible := mysql.DB.Where("owner_name = ?", userName).
+		Or("visibility = ?", model.KnowledgeBasePublic).
+		Or("id IN (?)", shared)
+	err := mysql.DB.Where(accessible).
+		Order("created_at asc").
+		Find(&kbs).Error
+	return kbs, err
+}
+
+// ListKnowledgeBases 所有用户的知识库，ownerName 为空时不按所有者筛选
+func ListKnowledgeBases(ownerName string, offset, limit int) ([]model.KnowledgeBase, int64, error) {
+	var kbs []model.KnowledgeBase
+	var total int64
+	query := mysql.DB.Model(&model.KnowledgeBase{})
+	if ownerName != "" {
+		query = query.Where("owner_name = ?", ownerName)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at asc").Offset(offset).Limit(limit).Find(&kbs).Error
+	return kbs, total, err
+}
+
+func UpdateKnowledgeBase(kb *model.KnowledgeBase) error {
+	return mysql.DB.Model(kb).Select("name", "description", "visibility").Updates(kb).Error
+}
+
+// DeleteKnowledgeBase 删除知识库及其分享记录
+func DeleteKnowledgeBase(id string) error {
+	return mysql.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("knowledge_base_id = ?", id).Delete(&model.KnowledgeBaseShare{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&model.KnowledgeBase{}).Error
+	})
+}
+
+func IsSharedWith(id string, userName string) (bool, error) {
+	var count int64
+	err := mysql.DB.Model(&model.KnowledgeBaseShare{}).
+		Where("knowledge_base_id = ? AND user_name = ?", id, userName).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func GetShares(id string) ([]string, error) {
+	var users []string
+	err := mysql.DB.Model(&model.KnowledgeBaseShare{}).
+		Where("knowledge_base_id = ?", id).
+		Order("created_at asc").
+		Pluck("user_name", &users).Error
+	return users, err
+}
+
+// AddShare 重复分享时忽略
+func AddShare(id string, userName string) error {
+	return mysql.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.KnowledgeBaseShare{
+		KnowledgeBaseID: id,
+		UserName:        userName,
+	}).Error
+}
+
+func RemoveShare(id string, userName string) error {
+	return mysql.DB.Where("knowledge_base_id = ? AND user_name = ?", id, userName).Delete(&model.KnowledgeBaseShare{}).Error
+}
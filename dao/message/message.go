@@ -3,11 +3,14 @@ package message
 import (
 	"GopherAI/common/mysql"
 	"GopherAI/model"
+	"time"
+
+	"gorm.io/gorm/clause"
 )
 
 func GetMessagesBySessionID(sessionID string) ([]model.Message, error) {
 	var msgs []model.Message
-	err := mysql.DB.Where("session_id = ?", sessionID).Order("created_at asc").Find(&msgs).Error
+	err := mysql.DB.Where("session_id = ?", sessionID).Order("created_at asc, id asc").Find(&msgs).Error
 	return msgs, err
 }
 
@@ -16,7 +19,7 @@ func GetMessagesBySessionIDs(sessionIDs []string) ([]model.Message, error) {
 	if len(sessionIDs) == 0 {
 		return msgs, nil
 	}
-	err := mysql.DB.Where("session_id IN ?", sessionIDs).Order("created_at asc").Find(&msgs).Error
+	err := mysql.DB.Where("session_id IN ?", sessionIDs).Order("created_at asc, id asc").Find(&msgs).Error
 	return msgs, err
 }
 
@@ -25,8 +28,38 @@ func CreateMessage(message *model.Message) (*model.Message, error) {
 	return message, err
 }
 
+// CreateMessagesIdempotent 批量按 MessageKey 幂等插入
+func CreateMessagesIdempotent(messages []*model.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	return mysql.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&messages).Error
+}
+
+// CreateMessageIdempotent 按 MessageKey 幂等插入，重复投递的消息直接忽略
+func CreateMessageIdempotent(message *model.Message) (*model.Message, error) {
+	err := mysql.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(message).Error
+	return message, err
+}
+
 func GetAllMessages() ([]model.Message, error) {
 	var msgs []model.Message
-	err := mysql.DB.Order("created_at asc").Find(&msgs).Error
+	err := mysql.DB.Order("created_at asc, id asc").Find(&msgs).Error
 	return msgs, err
 }
+
+// GetUserUsage 按用户汇总 since 之后的消息，userName 为空时统计所有用户
+func GetUserUsage(userName string, since time.Time) ([]model.UserUsage, error) {
+	var usage []model.UserUsage
+	query := mysql.DB.Model(&model.Message{}).
+		Select("user_name, COUNT(DISTINCT session_id) AS sessions, COUNT(*) AS messages, "+
+			"SUM(CASE WHEN is_user THEN 1 ELSE 0 END) AS questions, "+
+			"SUM(CASE WHEN role = ? THEN 1 ELSE 0 END) AS tool_messages, "+
+			"MAX(created_at) AS last_active_at", model.MessageRoleTool).
+		Where("created_at >= ?", since)
+	if userName != "" {
+		query = query.Where("user_name = ?", userName)
+	}
+	err := query.Group("user_name").Order("messages desc").Scan(&usage).Error
+	return usage, err
+}
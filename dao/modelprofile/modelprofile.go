@@ -0,0 +1,29 @@
+package modelprofile
+
+import (
+	"GopherAI/common/mysql"
+	"GopherAI/model"
+
+	"gorm.io/gorm/clause"
+)
+
+func GetModelProfiles() ([]model.ModelProfile, error) {
+	var profiles []model.ModelProfile
+	err := mysql.DB.Order("model_type asc").Find(&profiles).Error
+	return profiles, err
+}
+
+func GetModelProfile(modelType string) (*model.ModelProfile, error) {
+	var profile model.ModelProfile
+	err := mysql.DB.Where("model_type = ?", modelType).First(&profile).Error
+	return &profile, err
+}
+
+// 不存在则创建，存在则覆盖全部可修改的字段
+func SaveModelProfile(profile *model.ModelProfile) (*model.ModelProfile, error) {
+	err := mysql.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "model_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "description", "enabled", "roles", "updated_at"}),
+	}).Create(profile).Error
+	return profile, err
+}
@@ -0,0 +1,23 @@
+package preference
+
+import (
+	"GopherAI/common/mysql"
+	"GopherAI/model"
+
+	"gorm.io/gorm/clause"
+)
+
+func GetToolPreference(userName string) (*model.ToolPreference, error) {
+	var pref model.ToolPreference
+	err := mysql.DB.Where("user_name = ?", userName).First(&pref).Error
+	return &pref, err
+}
+
+// 不存在则创建，存在则覆盖工具列表
+func SaveToolPreference(pref *model.ToolPreference) (*model.ToolPreference, error) {
+	err := mysql.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"tools", "updated_at"}),
+	}).Create(pref).Error
+	return pref, err
+}
@@ -27,3 +27,24 @@ func GetAllSessions() ([]model.Session, error) {
 	err := mysql.DB.Find(&sessions).Error
 	return sessions, err
 }
+
+func UpdateSessionKnowledgeBases(sessionID string, knowledgeBases []string) error {
+	// 通过结构体更新才会走 json 序列化，Select 保证空列表也会写入
+	return mysql.DB.Model(&model.Session{ID: sessionID}).Select("knowledge_bases").
+		Updates(&model.Session{KnowledgeBases: knowledgeBases}).Error
+}
+
+// ListSessions 按更新时间倒序分页，userName 为空时返回所有用户的会话
+func ListSessions(userName string, offset, limit int) ([]model.Session, int64, error) {
+	var sessions []model.Session
+	var total int64
+	query := mysql.DB.Model(&model.Session{})
+	if userName != "" {
+		query = query.Where("user_name = ?", userName)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("updated_at desc").Offset(offset).Limit(limit).Find(&sessions).Error
+	return sessions, total, err
+}
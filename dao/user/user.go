@@ -3,7 +3,7 @@ package user
 import (
 	"GopherAI/common/mysql"
 	"GopherAI/model"
-	"GopherAI/utils"
+	passwordutil "GopherAI/utils/password"
 	"context"
 	"log"
 
@@ -17,7 +17,7 @@ const (
 
 var ctx = context.Background()
 
-// 这边只能通过账号进行登录
+// IsExistUser 按账号查找用户
 func IsExistUser(username string) (bool, *model.User) {
 
 	user, err := mysql.GetUserByUsername(username)
@@ -29,12 +29,43 @@ func IsExistUser(username string) (bool, *model.User) {
 	return true, user
 }
 
+// GetUsersByEmail 按邮箱查找用户，查询失败时返回空
+func GetUsersByEmail(email string) []*model.User {
+	users, err := mysql.GetUsersByEmail(email)
+	if err != nil {
+		log.Println("GetUsersByEmail err:", err)
+		return nil
+	}
+	return users
+}
+
+// IsExistEmail 邮箱是否已经注册
+func IsExistEmail(email string) bool {
+	return len(GetUsersByEmail(email)) > 0
+}
+
+// IsUsernameTaken 账号是否已被占用，查询失败时视为已占用
+func IsUsernameTaken(username string) bool {
+	exists, err := mysql.UsernameExists(username)
+	if err != nil {
+		log.Println("UsernameExists err:", err)
+		return true
+	}
+	return exists
+}
+
 func Register(username, email, password string) (*model.User, bool) {
+	hashed, err := passwordutil.Default().Hash(password)
+	if err != nil {
+		log.Println("Hash password err:", err)
+		return nil, false
+	}
 	if user, err := mysql.InsertUser(&model.User{
 		Email:    email,
 		Name:     username,
 		Username: username,
-		Password: utils.MD5(password),
+		Password: hashed,
+		Role:     model.RoleUser,
 	}); err != nil {
 		log.Println("InsertUser err:", err)
 		return nil, false
@@ -43,3 +74,42 @@ func Register(username, email, password string) (*model.User, bool) {
 		return user, true
 	}
 }
+
+// UpdatePassword 保存新密码的哈希
+func UpdatePassword(id int64, password string) bool {
+	hashed, err := passwordutil.Default().Hash(password)
+	if err != nil {
+		log.Println("Hash password err:", err)
+		return false
+	}
+	if err := mysql.UpdateUserPassword(id, hashed); err != nil {
+		log.Println("UpdateUserPassword err:", err)
+		return false
+	}
+	return true
+}
+
+func GetUserByID(id int64) (*model.User, error) {
+	return mysql.GetUserByID(id)
+}
+
+func ListUsers(keyword string, offset, limit int) ([]*model.User, int64, error) {
+	return mysql.ListUsers(keyword, offset, limit)
+}
+
+func UpdateRole(id int64, role string) error {
+	return mysql.UpdateUserRole(id, role)
+}
+
+func UpdateDisabled(id int64, disabled bool) error {
+	return mysql.UpdateUserDisabled(id, disabled)
+}
+
+func DeleteUser(id int64) error {
+	return mysql.DeleteUser(id)
+}
+
+// PromoteUsers 把配置中的账号设置为指定角色
+func PromoteUsers(usernames []string, role string) (int64, error) {
+	return mysql.UpdateRoleByUsernames(usernames, role)
+}
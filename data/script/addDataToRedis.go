@@ -89,6 +89,17 @@ func main() {
 	if err := redisRag.RedisClient.Ping(ctx).Err(); err != nil {
 		log.Fatalf("redis ping failed: %v", err)
 	}
+	// 确保索引存在且维度与当前模型一致
+	if err := redisRag.EnsureIndex(ctx, rag.DefaultKnowledgeBase, rag.IndexConfig{
+		Algorithm:      cfg.RAGIndexAlgorithm,
+		DistanceMetric: cfg.RAGDistanceMetric,
+		M:              cfg.RAGHNSWM,
+		EFConstruction: cfg.RAGHNSWEFConstruction,
+		EFRuntime:      cfg.RAGHNSWEFRuntime,
+		BlockSize:      cfg.RAGFlatBlockSize,
+	}); err != nil {
+		log.Fatalf("ensure rag index failed: %v", err)
+	}
 
 	inserted := 0
 	skipped := 0
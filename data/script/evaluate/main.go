@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"GopherAI/common/rag"
+	"GopherAI/common/rag/eval"
+	"GopherAI/config"
+)
+
+//  example command:
+// 	go run ./data/script/evaluate \
+//   --queries data/eval/queries.jsonl \
+//   --knowledge-base kb-uuid \
+//   --modes vector,hybrid --k 5
+//
+// 查询集每行一个 JSON：{"query": "成都有什么好吃的", "expected": ["3f2a9c0d1e4b5a67"]}
+// expected 为文档ID（导入时输出，也可通过 --action list 查看），没有文档ID的旧数据可以写来源
+//
+// 对比分块或向量模型的改动时，把两种方式导入到不同的知识库，再用 --configs 指定两组配置：
+// 	[{"name": "old", "knowledgeBases": ["kb-old"]},
+// 	 {"name": "new", "knowledgeBases": ["kb-new"], "embeddingModel": "bge-m3"}]
+
+// runConfig 配置文件中的一组检索配置，未填写的字段使用命令行参数或 config.toml
+type runConfig struct {
+	Name              string   `json:"name"`
+	KnowledgeBases    []string `json:"knowledgeBases"`
+	Mode              string   `json:"mode"`
+	CandidateK        int      `json:"candidateK"`
+	RRFK              int      `json:"rrfK"`
+	MinScore          float64  `json:"minScore"`
+	RerankURL         string   `json:"rerankURL"` // 配置后使用 cross-encoder 重排
+	RerankModel       string   `json:"rerankModel"`
+	RerankAPIKey      string   `json:"rerankAPIKey"`
+	RerankTopN        int      `json:"rerankTopN"`
+	EmbeddingProvider string   `json:"embeddingProvider"`
+	EmbeddingBaseURL  string   `json:"embeddingBaseURL"`
+	EmbeddingModel    string   `json:"embeddingModel"`
+	EmbeddingAPIKey   string   `json:"embeddingAPIKey"`
+}
+
+func loadRunConfigs(path string) ([]runConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs []runConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func main() {
+	// 读取配置文件作为默认值
+	cfg := config.GetConfig()
+
+	queriesPath := flag.String("queries", "", "Labelled query set, JSON array or JSONL.")
+	configsPath := flag.String("configs", "", "JSON file with the configurations to compare, the first one is the baseline.")
+	modes := flag.String("modes", cfg.RAGSearchMode, "Comma separated search modes to compare when --configs is not set.")
+	knowledgeBases := flag.String("knowledge-base", rag.DefaultKnowledgeBase, "Comma separated knowledge base IDs to search.")
+	k := flag.Int("k", eval.DefaultK, "Number of documents considered for recall@k and nDCG@k.")
+	format := flag.String("format", eval.FormatMarkdown, "Report format: markdown or json.")
+	output := flag.String("output", "", "Write the report to this file instead of stdout.")
+	backend := flag.String("backend", firstNonEmpty(cfg.RAGBackend, rag.BackendRedis), "Storage backend: redis, file or vikingdb.")
+	filePath := flag.String("file", cfg.RAGFilePath, "Data file for the file backend.")
+	redisAddr := flag.String("redis-addr", cfg.RAGRedisAddr, "Redis Stack addr, e.g. 127.0.0.1:6381.")
+	redisPassword := flag.String("redis-password", cfg.RAGRedisPassword, "Redis password.")
+	redisDB := flag.Int("redis-db", cfg.RAGRedisDB, "Redis DB.")
+	flag.Parse()
+
+	if *queriesPath == "" {
+		log.Fatal("queries is empty")
+	}
+	if *format != eval.FormatMarkdown && *format != "md" && *format != eval.FormatJSON {
+		log.Fatalf("unsupported format: %s", *format)
+	}
+	queries, err := eval.LoadQueries(*queriesPath)
+	if err != nil {
+		log.Fatalf("load queries failed: %v", err)
+	}
+	if len(queries) == 0 {
+		log.Fatal("query set is empty")
+	}
+
+	var configs []runConfig
+	if *configsPath != "" {
+		configs, err = loadRunConfigs(*configsPath)
+		if err != nil {
+			log.Fatalf("load configs failed: %v", err)
+		}
+	} else {
+		for _, mode := range splitList(*modes) {
+			configs = append(configs, runConfig{Name: mode, Mode: mode})
+		}
+	}
+	if len(configs) == 0 {
+		log.Fatal("no configuration to evaluate")
+	}
+
+	ctx := context.Background()
+	// 同一向量模型的查询向量在各组配置之间复用
+	cache := rag.NewMemoryEmbeddingCache(0)
+	reports := make([]*eval.Report, 0, len(configs))
+	for i, rc := range configs {
+		if rc.Name == "" {
+			rc.Name = "config-" + string(rune('A'+i))
+		}
+		if len(rc.KnowledgeBases) == 0 {
+			rc.KnowledgeBases = splitList(*knowledgeBases)
+		}
+
+		embeddingConf := rag.EmbeddingConfig{
+			Provider:    firstNonEmpty(rc.EmbeddingProvider, cfg.EmbeddingProvider),
+			BaseURL:     firstNonEmpty(rc.EmbeddingBaseURL, cfg.EmbeddingBaseURL),
+			Model:       firstNonEmpty(rc.EmbeddingModel, cfg.EmbeddingModel),
+			APIKey:      firstNonEmpty(rc.EmbeddingAPIKey, cfg.EmbeddingAPIKey),
+			Dimensions:  cfg.EmbeddingDimensions,
+			BatchSize:   cfg.EmbeddingBatchSize,
+			Concurrency: cfg.EmbeddingConcurrency,
+			Timeout:     time.Duration(cfg.EmbeddingTimeout) * time.Second,
+			Cache:       cache,
+		}
+		if embeddingConf.Provider == "" || embeddingConf.Provider == rag.EmbeddingProviderOllama {
+			embeddingConf.BaseURL = firstNonEmpty(embeddingConf.BaseURL, cfg.OllamaConfig.BaseURL)
+			embeddingConf.Model = firstNonEmpty(embeddingConf.Model, cfg.OllamaConfig.ModelName)
+		}
+		embedder, err := rag.NewEmbedder(embeddingConf)
+		if err != nil {
+			log.Fatalf("config %s: create embedder failed: %v", rc.Name, err)
+		}
+		var db rag.RAGDatabase
+		switch *backend {
+		case rag.BackendRedis:
+			redisRag := rag.NewRedisRAG(rag.RedisConfig{
+				Addr:     *redisAddr,
+				Password: *redisPassword,
+				DB:       *redisDB,
+			}, embedder)
+			if err := redisRag.RedisClient.Ping(ctx).Err(); err != nil {
+				log.Fatalf("redis ping failed: %v", err)
+			}
+			db = redisRag
+		case rag.BackendFile:
+			db, err = rag.OpenFileRAG(*filePath, embedder)
+			if err != nil {
+				log.Fatalf("open rag file failed: %v", err)
+			}
+		case rag.BackendVikingDB:
+			viking := cfg.VikingDBConfig
+			if strings.ToLower(strings.TrimSpace(viking.Embedding)) != rag.VikingDBEmbeddingEmbedder {
+				embedder = nil
+			}
+			db = rag.NewVikingDBRAG(rag.VikingDBConfig{
+				Host:       viking.Host,
+				Region:     viking.Region,
+				Scheme:     viking.Scheme,
+				AK:         viking.AK,
+				SK:         viking.SK,
+				Collection: viking.Collection,
+				Index:      viking.Index,
+			}, embedder)
+		default:
+			log.Fatalf("unsupported backend: %s", *backend)
+		}
+
+		opts := rag.SearchOptions{
+			Mode:       firstNonEmpty(rc.Mode, cfg.RAGSearchMode),
+			CandidateK: firstPositive(rc.CandidateK, cfg.RAGCandidateK),
+			RRFK:       firstPositive(rc.RRFK, cfg.RAGRRFK),
+			MinScore:   rc.MinScore,
+			RerankTopN: firstPositive(rc.RerankTopN, cfg.RAGRerankTopN),
+			// 与导入时的索引一致，用于把向量距离换算为相似度
+			DistanceMetric: cfg.RAGDistanceMetric,
+		}
+		if rc.RerankURL != "" {
+			opts.Reranker = &rag.CrossEncoderReranker{URL: rc.RerankURL, Model: rc.RerankModel, APIKey: rc.RerankAPIKey}
+		}
+
+		report, err := eval.Run(ctx, eval.Config{
+			Name:    rc.Name,
+			DB:      db,
+			Scope:   rag.Scope{KnowledgeBases: rc.KnowledgeBases},
+			Options: opts,
+		}, queries, *k)
+		if err != nil {
+			log.Fatalf("config %s: evaluate failed: %v", rc.Name, err)
+		}
+		log.Printf("%s: recall@%d=%.4f mrr=%.4f ndcg@%d=%.4f failed=%d", report.Name, report.K, report.Recall, report.MRR, report.K, report.NDCG, report.Failed)
+		reports = append(reports, report)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("create output failed: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := eval.Compare(reports...).Write(w, *format); err != nil {
+		log.Fatalf("write report failed: %v", err)
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstPositive(values ...int) int {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
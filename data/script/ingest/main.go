@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"GopherAI/common/rag"
+	"GopherAI/common/rag/ingest"
+	"GopherAI/config"
+)
+
+//  example command:
+// 	go run ./data/script/ingest \
+//   --path docs/ \
+//   --tags travel,guide \
+//   --strategy heading --size 500 --overlap 50
+//
+// 文档ID默认由文件路径生成，再次导入同一文件会替换原文档；其他操作：
+// 	go run ./data/script/ingest --action list --knowledge-base <id>
+// 	go run ./data/script/ingest --action delete --id <docID>
+// 	go run ./data/script/ingest --action delete-source --source docs/guide.md
+// 	go run ./data/script/ingest --action reembed --all   # 更换向量模型后重新向量化
+//
+// 使用 --dry-run 只打印分块结果，不调用向量模型也不写入 Redis
+// 使用 --backend file --file data/rag/rag.json 写入本地文件，无需 Redis Stack，配合 --embedding-provider fake 可完全离线运行
+// 默认按内容哈希在 Redis 中缓存向量，重复导入未修改的文档不会再次请求向量模型
+
+const (
+	actionIngest       = "ingest"
+	actionList         = "list"
+	actionDelete       = "delete"
+	actionDeleteSource = "delete-source"
+	actionReembed      = "reembed"
+)
+
+// 收集需要导入的文件，目录会递归遍历并跳过无法识别格式的文件
+func collectFiles(path string, format ingest.Format) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if format == "" {
+			if _, err := ingest.ParseFormat(filepath.Ext(p)); err != nil {
+				return nil
+			}
+		}
+		files = append(files, p)
+		return nil
+	})
+	return files, err
+}
+
+func main() {
+	// 读取配置文件作为默认值
+	cfg := config.GetConfig()
+
+	action := flag.String("action", actionIngest, "Action: ingest, list, delete, delete-source or reembed.")
+	path := flag.String("path", "", "File or directory to ingest (md, txt, html, pdf).")
+	formatName := flag.String("format", "", "Force document format, detected from extension when empty.")
+	docID := flag.String("id", "", "Document ID. For ingest it only applies to a single file and defaults to a hash of the path.")
+	source := flag.String("source", "", "Document source for delete-source, the file path used at ingest time.")
+	title := flag.String("title", "", "Document title, only used when ingesting a single file.")
+	tags := flag.String("tags", "", "Comma separated tags stored with every chunk.")
+	strategy := flag.String("strategy", cfg.RAGChunkStrategy, "Chunk strategy: sentence, heading or cjk.")
+	size := flag.Int("size", cfg.RAGChunkSize, "Chunk size (runes, or estimated tokens for cjk).")
+	defaultOverlap := -1
+	if cfg.RAGChunkOverlap != nil {
+		defaultOverlap = *cfg.RAGChunkOverlap
+	}
+	overlap := flag.Int("overlap", defaultOverlap, "Chunk overlap, same unit as size; 0 disables overlap, -1 uses 10% of size (at most 50).")
+	knowledgeBase := flag.String("knowledge-base", rag.DefaultKnowledgeBase, "Target knowledge base ID.")
+	allKnowledgeBases := flag.Bool("all", false, "Re-embed every knowledge base that has an index.")
+	offset := flag.Int("offset", 0, "List offset.")
+	limit := flag.Int("limit", 20, "List page size.")
+	dryRun := flag.Bool("dry-run", false, "Print chunks without embedding or storing them.")
+	backend := flag.String("backend", firstNonEmpty(cfg.RAGBackend, rag.BackendRedis), "Storage backend: redis, file or vikingdb.")
+	filePath := flag.String("file", cfg.RAGFilePath, "Data file for the file backend.")
+	redisAddr := flag.String("redis-addr", cfg.RAGRedisAddr, "Redis Stack addr, e.g. 127.0.0.1:6381.")
+	redisPassword := flag.String("redis-password", cfg.RAGRedisPassword, "Redis password.")
+	redisDB := flag.Int("redis-db", cfg.RAGRedisDB, "Redis DB.")
+	embeddingProvider := flag.String("embedding-provider", cfg.EmbeddingProvider, "Embedding provider: ollama, openai or fake.")
+	embeddingBaseURL := flag.String("embedding-base-url", cfg.EmbeddingBaseURL, "Embedding base URL, falls back to ollamaConfig for ollama.")
+	embeddingModel := flag.String("embedding-model", cfg.EmbeddingModel, "Embedding model name, falls back to ollamaConfig for ollama.")
+	embeddingAPIKey := flag.String("embedding-api-key", cfg.EmbeddingAPIKey, "Embedding API key for openai compatible providers.")
+	embeddingCache := flag.String("embedding-cache", cfg.EmbeddingCache, "Embedding cache: none, memory or redis. Redis lets re-runs skip unchanged chunks.")
+	indexAlgorithm := flag.String("index-algorithm", cfg.RAGIndexAlgorithm, "Vector index algorithm: HNSW or FLAT.")
+	distanceMetric := flag.String("distance-metric", cfg.RAGDistanceMetric, "Vector distance metric: COSINE, L2 or IP.")
+	flag.Parse()
+
+	ctx := context.Background()
+	indexConf := rag.IndexConfig{
+		Algorithm:      *indexAlgorithm,
+		DistanceMetric: *distanceMetric,
+		M:              cfg.RAGHNSWM,
+		EFConstruction: cfg.RAGHNSWEFConstruction,
+		EFRuntime:      cfg.RAGHNSWEFRuntime,
+		BlockSize:      cfg.RAGFlatBlockSize,
+	}
+	// 按参数连接存储并创建向量模型
+	connect := func() rag.RAGDatabase {
+		var redisRag *rag.RedisRAG
+		switch *backend {
+		case rag.BackendRedis:
+			if *redisAddr == "" {
+				log.Fatal("redis addr is empty")
+			}
+			redisRag = rag.NewRedisRAG(rag.RedisConfig{
+				Addr:     *redisAddr,
+				Password: *redisPassword,
+				DB:       *redisDB,
+			}, nil)
+		case rag.BackendFile:
+			if *filePath == "" {
+				log.Fatal("file is empty")
+			}
+		case rag.BackendVikingDB:
+		default:
+			// memory 后端的数据在脚本退出后就会丢失，没有意义
+			log.Fatalf("unsupported backend: %s", *backend)
+		}
+		embeddingConf := rag.EmbeddingConfig{
+			Provider:    *embeddingProvider,
+			BaseURL:     *embeddingBaseURL,
+			Model:       *embeddingModel,
+			APIKey:      *embeddingAPIKey,
+			Dimensions:  cfg.EmbeddingDimensions,
+			BatchSize:   cfg.EmbeddingBatchSize,
+			Concurrency: cfg.EmbeddingConcurrency,
+			Timeout:     time.Duration(cfg.EmbeddingTimeout) * time.Second,
+		}
+		if embeddingConf.Provider == "" || embeddingConf.Provider == rag.EmbeddingProviderOllama {
+			if embeddingConf.BaseURL == "" {
+				embeddingConf.BaseURL = cfg.OllamaConfig.BaseURL
+			}
+			if embeddingConf.Model == "" {
+				embeddingConf.Model = cfg.OllamaConfig.ModelName
+			}
+		}
+		switch *embeddingCache {
+		case "memory":
+			embeddingConf.Cache = rag.NewMemoryEmbeddingCache(cfg.EmbeddingCacheSize)
+		case "redis":
+			if redisRag != nil {
+				embeddingConf.Cache = &rag.RedisEmbeddingCache{
+					Client: redisRag.RedisClient,
+					TTL:    time.Duration(cfg.EmbeddingCacheTTL) * time.Hour,
+				}
+			}
+		}
+		embedder, err := rag.NewEmbedder(embeddingConf)
+		if err != nil {
+			log.Fatalf("create embedder failed: %v", err)
+		}
+		if *backend == rag.BackendVikingDB {
+			return vikingDBRAG(cfg, embedder)
+		}
+		if redisRag == nil {
+			fileRag, err := rag.OpenFileRAG(*filePath, embedder)
+			if err != nil {
+				log.Fatalf("open rag file failed: %v", err)
+			}
+			return fileRag
+		}
+		redisRag.Embedder = embedder
+		// 提前检查 Redis 可用性
+		if err := redisRag.RedisClient.Ping(ctx).Err(); err != nil {
+			log.Fatalf("redis ping failed: %v", err)
+		}
+		return redisRag
+	}
+
+	switch *action {
+	case actionIngest:
+	case actionList:
+		listDocuments(ctx, connect(), *knowledgeBase, *offset, *limit)
+		return
+	case actionDelete:
+		if *docID == "" {
+			log.Fatal("id is empty")
+		}
+		if err := connect().DeleteDocument(ctx, *knowledgeBase, *docID); err != nil {
+			log.Fatalf("delete %s failed: %v", *docID, err)
+		}
+		log.Printf("deleted document %s", *docID)
+		return
+	case actionDeleteSource:
+		if *source == "" {
+			log.Fatal("source is empty")
+		}
+		deleted, err := connect().DeleteDocumentsBySource(ctx, *knowledgeBase, *source)
+		if err != nil {
+			log.Fatalf("delete source %s failed: %v", *source, err)
+		}
+		log.Printf("deleted %d documents from source %s", deleted, *source)
+		return
+	case actionReembed:
+		reembed(ctx, connect(), *knowledgeBase, *allKnowledgeBases, indexConf)
+		return
+	default:
+		log.Fatalf("unsupported action: %s", *action)
+	}
+
+	if *path == "" {
+		log.Fatal("path is empty")
+	}
+
+	var format ingest.Format
+	if *formatName != "" {
+		parsed, err := ingest.ParseFormat(*formatName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		format = parsed
+	}
+	parsedStrategy, err := ingest.ParseStrategy(*strategy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *size <= 0 {
+		*size = ingest.DefaultChunkSize
+	}
+	if *overlap < 0 {
+		*overlap = ingest.DefaultOverlapFor(*size)
+	}
+	opts := ingest.ChunkOptions{Strategy: parsedStrategy, Size: *size, Overlap: *overlap}
+
+	files, err := collectFiles(*path, format)
+	if err != nil {
+		log.Fatalf("collect files failed: %v", err)
+	}
+	if len(files) == 0 {
+		log.Fatal("no supported files found")
+	}
+	if *docID != "" && len(files) > 1 {
+		log.Fatal("id only applies to a single file")
+	}
+
+	var pipeline *ingest.Pipeline
+	if !*dryRun {
+		db := connect()
+		// 写入前确保索引存在且维度与当前模型一致
+		if redisRag, ok := db.(*rag.RedisRAG); ok {
+			if err := redisRag.EnsureIndex(ctx, *knowledgeBase, indexConf); err != nil {
+				log.Fatalf("ensure rag index failed: %v", err)
+			}
+		}
+		pipeline = ingest.NewPipeline(db, opts)
+	}
+
+	docTags := ingest.ParseTags(*tags)
+	documents, chunks, failed := 0, 0, 0
+	for _, file := range files {
+		doc, err := ingest.LoadFile(file, format)
+		if err != nil {
+			log.Printf("load %s failed: %v", file, err)
+			failed++
+			continue
+		}
+		if *title != "" && len(files) == 1 {
+			doc.Title = *title
+		}
+		doc.ID = *docID
+		doc.Tags = docTags
+		doc.KnowledgeBase = *knowledgeBase
+
+		if *dryRun {
+			parts, err := ingest.Split(doc, opts)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("== %s (%s, title=%q) %d chunks\n", doc.Source, doc.Format, doc.Title, len(parts))
+			for _, part := range parts {
+				fmt.Printf("-- #%d %s\n%s\n", part.Index, part.Heading, strings.TrimSpace(part.Content))
+			}
+			documents++
+			chunks += len(parts)
+			continue
+		}
+
+		result, err := pipeline.Ingest(ctx, doc)
+		if err != nil {
+			log.Printf("ingest %s failed: %v", file, err)
+			failed++
+			continue
+		}
+		documents++
+		chunks += result.Stored
+		log.Printf("ingested %s as %s: %d chunks", result.Source, result.DocumentID, result.Stored)
+	}
+
+	log.Printf("done. documents=%d chunks=%d failed=%d", documents, chunks, failed)
+}
+
+func listDocuments(ctx context.Context, db rag.RAGDatabase, kb string, offset int, limit int) {
+	docs, total, err := db.ListDocuments(ctx, kb, offset, limit)
+	if err != nil {
+		log.Fatalf("list documents failed: %v", err)
+	}
+	for _, doc := range docs {
+		fmt.Printf("%s\t%s\t%d chunks\t%s\t%s\n", doc.ID, doc.Source, doc.Chunks, doc.Embedder, doc.UpdatedAt.Format(time.DateTime))
+	}
+	fmt.Printf("total=%d offset=%d\n", total, offset)
+}
+
+// reembed 更换向量模型后重新向量化，all 为 true 时处理所有已建立索引的知识库
+func reembed(ctx context.Context, db rag.RAGDatabase, kb string, all bool, conf rag.IndexConfig) {
+	kbs := []string{kb}
+	var reembedAll func(ctx context.Context, kb string, conf rag.IndexConfig) (int, error)
+	switch db := db.(type) {
+	case *rag.RedisRAG:
+		reembedAll = db.ReembedAll
+		if all {
+			listed, err := db.ListIndexedKnowledgeBases(ctx)
+			if err != nil {
+				log.Fatalf("list knowledge bases failed: %v", err)
+			}
+			kbs = listed
+		}
+	case *rag.MemoryRAG:
+		reembedAll = db.ReembedAll
+		if all {
+			kbs = db.KnowledgeBases()
+		}
+	default:
+		log.Fatal("backend does not support reembed")
+	}
+	for _, kb := range kbs {
+		count, err := reembedAll(ctx, kb, conf)
+		if err != nil {
+			log.Fatalf("reembed %s failed after %d chunks: %v", kb, count, err)
+		}
+		log.Printf("reembedded %s: %d chunks", kb, count)
+	}
+}
+
+// vikingDBRAG 按 vikingDBConfig 连接，embedding = platform 时不使用本地向量模型
+func vikingDBRAG(cfg *config.Config, embedder rag.Embedder) *rag.VikingDBRAG {
+	viking := cfg.VikingDBConfig
+	if strings.ToLower(strings.TrimSpace(viking.Embedding)) != rag.VikingDBEmbeddingEmbedder {
+		embedder = nil
+	}
+	return rag.NewVikingDBRAG(rag.VikingDBConfig{
+		Host:       viking.Host,
+		Region:     viking.Region,
+		Scheme:     viking.Scheme,
+		AK:         viking.AK,
+		SK:         viking.SK,
+		Collection: viking.Collection,
+		Index:      viking.Index,
+	}, embedder)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"GopherAI/common/aihelper"
+	"GopherAI/common/imagestore"
+	"GopherAI/common/messagestore"
 	"GopherAI/common/mysql"
 	"GopherAI/common/rabbitmq"
 	"GopherAI/common/redis"
@@ -10,15 +12,52 @@ import (
 	"GopherAI/dao/message"
 	"GopherAI/dao/session"
 	"GopherAI/router"
+	rag_service "GopherAI/service/rag"
+	user_service "GopherAI/service/user"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
-func StartServer(addr string, port int) error {
+// 收到退出信号后等待进行中请求结束的最长时间，超时后强制关闭连接
+const shutdownTimeout = 30 * time.Second
+
+// StartServer 启动 HTTP 服务并阻塞，ctx 结束后停止接收新请求并等待进行中的请求结束
+func StartServer(ctx context.Context, addr string, port int) error {
 	r := router.InitRouter()
 	//服务器静态资源路径映射关系，这里目前不需要
 	// r.Static(config.GetConfig().HttpFilePath, config.GetConfig().MusicFilePath)
-	return r.Run(fmt.Sprintf("%s:%d", addr, port))
+	srv := &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", addr, port),
+		Handler: r,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("shutting down server ...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
 // 从数据库加载消息并初始化 AIHelperManager
@@ -60,7 +99,7 @@ func readDataFromDB() error {
 		}
 		log.Println("readDataFromDB init:  ", helper.SessionID)
 		// 添加消息到内存中(不开启存储功能)
-		helper.AddMessage(m.Content, m.UserName, m.IsUser, false)
+		helper.RestoreMessage(m)
 	}
 
 	log.Println("AIHelperManager init success ")
@@ -79,6 +118,8 @@ func main() {
 	}
 	//初始化AIHelperManager
 	readDataFromDB()
+	// 配置中指定的管理员账号
+	user_service.SyncConfiguredAdmins()
 
 	//初始化redis
 	if err := redis.Init(); err != nil {
@@ -86,8 +127,32 @@ func main() {
 		return
 	}
 	log.Println("redis init success  ")
-	rabbitmq.InitRabbitMQ()
-	log.Println("rabbitmq init success  ")
+	// 只有 rabbitmq 后端需要连接消息队列
+	if conf.MessageStoreConfig.Backend == "" || conf.MessageStoreConfig.Backend == messagestore.BackendRabbitMQ {
+		rabbitmq.InitRabbitMQ()
+		log.Println("rabbitmq init success  ")
+	}
+	if err := messagestore.Init(); err != nil {
+		log.Println("InitMessageStore error , " + err.Error())
+		return
+	}
+	// HTTP 服务退出后再关闭，确保已接收请求产生的消息都写入存储
+	defer func() {
+		if err := messagestore.Close(); err != nil {
+			log.Println("CloseMessageStore error , " + err.Error())
+		}
+	}()
+	log.Println("message store init success, backend: " + messagestore.Default().Name())
+
+	// 创建或迁移 RAG 向量索引，RAG 为可选能力，失败时只记录日志
+	if err := rag_service.InitIndex(context.Background()); err != nil {
+		log.Println("InitRAGIndex error , " + err.Error())
+	} else {
+		log.Println("rag index init success  ")
+	}
+
+	// 聊天附带的图片保存在本地目录，消息中只保存引用
+	imagestore.Init()
 
 	// 初始化 Tools
 	if err := tools.InitTools(); err != nil {
@@ -95,8 +160,13 @@ func main() {
 		return
 	}
 
-	err := StartServer(host, port) // 启动 HTTP 服务
-	if err != nil {
-		panic(err)
+	// SIGINT / SIGTERM 时优雅退出，让上面的 defer 有机会执行
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := StartServer(ctx, host, port); err != nil { // 启动 HTTP 服务
+		log.Println("StartServer error , " + err.Error())
+		return
 	}
+	log.Println("server exited")
 }
@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"GopherAI/dao/audit"
+	"GopherAI/model"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 请求体超过该长度时截断
+	maxBodyLen = 4 << 10
+	// 只保留响应开头用于解析业务状态码
+	maxCaptureLen = 256
+	maskedValue   = "***"
+)
+
+var statusCodePattern = regexp.MustCompile(`"status_code":\s*(\d+)`)
+
+// Log 记录 /admin 下的每个请求，需要放在 jwt.Auth() 之后
+// 请求体中的密码、令牌等字段会被替换为 ***，写入失败只记录日志不影响请求
+func Log() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := readBody(c)
+		writer := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		entry := &model.AuditLog{
+			ActorID:    c.GetInt64("userId"),
+			ActorName:  c.GetString("userName"),
+			ActorRole:  c.GetString("role"),
+			Method:     c.Request.Method,
+			Route:      c.FullPath(),
+			Params:     requestParams(c),
+			Body:       body,
+			ClientIP:   c.ClientIP(),
+			StatusCode: writer.statusCode(),
+		}
+		if entry.Route == "" {
+			entry.Route = c.Request.URL.Path
+		}
+		if err := audit.CreateAuditLog(entry); err != nil {
+			log.Println("CreateAuditLog failed:", err)
+		}
+	}
+}
+
+// readBody 读取 JSON 请求体后放回，供后续的处理函数继续读取
+func readBody(c *gin.Context) string {
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return ""
+	}
+	raw, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+	if err != nil || len(raw) == 0 {
+		return ""
+	}
+	body := maskBody(raw)
+	if len(body) > maxBodyLen {
+		body = body[:maxBodyLen] + "...(truncated)"
+	}
+	return body
+}
+
+// maskBody 隐藏敏感字段，无法解析的请求体不记录内容
+func maskBody(raw []byte) string {
+	var data interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return "(invalid json)"
+	}
+	masked, err := json.Marshal(maskValue(data))
+	if err != nil {
+		return ""
+	}
+	return string(masked)
+}
+
+func maskValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if isSensitiveKey(k) {
+				val[k] = maskedValue
+				continue
+			}
+			val[k] = maskValue(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = maskValue(item)
+		}
+	}
+	return v
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"password", "token", "secret", "captcha", "apikey", "api_key"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestParams 路径参数与查询参数，都没有时返回空
+func requestParams(c *gin.Context) string {
+	params := make(map[string]interface{})
+	for _, p := range c.Params {
+		params[p.Key] = p.Value
+	}
+	for k, v := range c.Request.URL.Query() {
+		if isSensitiveKey(k) {
+			params[k] = maskedValue
+			continue
+		}
+		if len(v) == 1 {
+			params[k] = v[0]
+		} else {
+			params[k] = v
+		}
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// captureWriter 保存响应开头的内容，用于解析 status_code
+type captureWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *captureWriter) capture(data []byte) {
+	if remaining := maxCaptureLen - w.buf.Len(); remaining > 0 {
+		if len(data) > remaining {
+			data = data[:remaining]
+		}
+		w.buf.Write(data)
+	}
+}
+
+// statusCode 响应中的业务状态码，无法解析时返回 HTTP 状态码
+func (w *captureWriter) statusCode() int64 {
+	if m := statusCodePattern.FindSubmatch(w.buf.Bytes()); m != nil {
+		if v, err := strconv.ParseInt(string(m[1]), 10, 64); err == nil {
+			return v
+		}
+	}
+	return int64(w.ResponseWriter.Status())
+}
@@ -2,7 +2,9 @@ package jwt
 
 import (
 	"GopherAI/common/code"
+	myredis "GopherAI/common/redis"
 	"GopherAI/controller"
+	"GopherAI/model"
 	"GopherAI/utils/myjwt"
 	"log"
 	"net/http"
@@ -12,17 +14,15 @@ import (
 )
 
 // 读取jwt
+// 只接受 Authorization 请求头中的访问令牌，令牌内容不会写入日志
 func Auth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		res := new(controller.Response)
 
 		var token string
 		authHeader := c.GetHeader("Authorization")
-		if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
-			token = strings.TrimPrefix(authHeader, "Bearer ")
-		} else {
-			// 兼容 URL 参数传 token
-			token = c.Query("token")
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			token = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
 		}
 
 		if token == "" {
@@ -31,15 +31,35 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 
-		log.Println("token is ", token)
-		userName, ok := myjwt.ParseToken(token)
+		claims, ok := myjwt.ParseToken(token)
 		if !ok {
 			c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidToken))
 			c.Abort()
 			return
 		}
 
-		c.Set("userName", userName)
+		// 已退出登录的会话在撤销列表中，其访问令牌即使未过期也不再可用
+		revoked, err := myredis.IsAuthSessionRevoked(claims.SessionID)
+		if err != nil {
+			log.Println("IsAuthSessionRevoked failed:", err)
+			c.JSON(http.StatusOK, res.CodeOf(code.CodeServerBusy))
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusOK, res.CodeOf(code.CodeInvalidToken))
+			c.Abort()
+			return
+		}
+
+		role := claims.Role
+		if role == "" {
+			role = model.RoleUser
+		}
+		c.Set("userName", claims.Username)
+		c.Set("userId", claims.ID)
+		c.Set("role", role)
+		c.Set("sessionId", claims.SessionID)
 		c.Next()
 	}
 }
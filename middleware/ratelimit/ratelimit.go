@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"GopherAI/common/code"
+	myredis "GopherAI/common/redis"
+	"GopherAI/config"
+	"GopherAI/controller"
+	"GopherAI/utils"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc 返回限流对象，同一对象共享一个滑动窗口
+type KeyFunc func(c *gin.Context) string
+
+// ByIP 按客户端 IP 限流
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser 按登录用户限流，需要放在 jwt.Auth() 之后，未登录时按 IP 限流
+func ByUser(c *gin.Context) string {
+	if userName := c.GetString("userName"); userName != "" {
+		return "user:" + userName
+	}
+	return ByIP(c)
+}
+
+// Rule 路由上的默认限流规则：Window 内最多 Limit 次请求
+// 可以通过 [rateLimitConfig.rules.<Name>] 覆盖 Limit 与 Window
+type Rule struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	Key    KeyFunc
+}
+
+func (r Rule) resolve() (int, time.Duration) {
+	limit, window := r.Limit, r.Window
+	if override, ok := config.GetConfig().RateLimitConfig.Rules[r.Name]; ok {
+		if override.Limit != 0 {
+			limit = override.Limit
+		}
+		if override.WindowSeconds > 0 {
+			window = time.Duration(override.WindowSeconds) * time.Second
+		}
+	}
+	return limit, window
+}
+
+// Limit Redis 滑动窗口限流，超出时返回 CodeTooManyRequests 与 Retry-After
+// Redis 不可用时放行，避免限流影响正常服务
+func Limit(rule Rule) gin.HandlerFunc {
+	if rule.Key == nil {
+		rule.Key = ByIP
+	}
+	return func(c *gin.Context) {
+		limit, window := rule.resolve()
+		if limit < 0 || window <= 0 {
+			c.Next()
+			return
+		}
+
+		allowed, retryAfter, err := myredis.AllowSlidingWindow(rule.Name, rule.Key(c), limit, window, utils.GenerateUUID())
+		if err != nil {
+			log.Println("AllowSlidingWindow failed:", err)
+			c.Next()
+			return
+		}
+		if !allowed {
+			res := new(controller.RetryResponse)
+			res.CodeOf(code.CodeTooManyRequests)
+			res.RetryAfter = controller.SetRetryAfter(c, retryAfter)
+			c.JSON(http.StatusOK, res)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
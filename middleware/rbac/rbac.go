@@ -0,0 +1,40 @@
+package rbac
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/controller"
+	"GopherAI/utils/myrbac"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Require 当前用户的角色需要拥有 perm 权限，需要放在 jwt.Auth() 之后
+func Require(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !myrbac.Default().Has(c.GetString("role"), perm) {
+			res := new(controller.Response)
+			c.JSON(http.StatusOK, res.CodeOf(code.CodeForbidden))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAny 拥有任意一个权限即可，用于 /admin 分组的入口
+func RequireAny(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := myrbac.Default()
+		role := c.GetString("role")
+		for _, perm := range perms {
+			if policy.Has(role, perm) {
+				c.Next()
+				return
+			}
+		}
+		res := new(controller.Response)
+		c.JSON(http.StatusOK, res.CodeOf(code.CodeForbidden))
+		c.Abort()
+	}
+}
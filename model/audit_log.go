@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// AuditLog 管理员操作记录，/admin 下的每个请求都会记录一条
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ActorID    int64     `gorm:"index;not null" json:"actorId"`
+	ActorName  string    `gorm:"index;type:varchar(50);not null" json:"actorName"`
+	ActorRole  string    `gorm:"type:varchar(20)" json:"actorRole"`
+	Method     string    `gorm:"type:varchar(10);not null" json:"method"`
+	Route      string    `gorm:"index;type:varchar(100);not null" json:"route"` // 路由模板，如 /api/v1/admin/users/:id
+	Params     string    `gorm:"type:text" json:"params,omitempty"`             // 路径参数与查询参数，JSON
+	Body       string    `gorm:"type:text" json:"body,omitempty"`               // 请求体，超长时截断
+	ClientIP   string    `gorm:"type:varchar(64)" json:"clientIp"`
+	StatusCode int64     `json:"statusCode"` // 业务状态码
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 知识库可见性
+const (
+	KnowledgeBasePrivate = "private" // 仅所有者与被分享的用户可用
+	KnowledgeBasePublic  = "public"  // 所有登录用户可用
+)
+
+// KnowledgeBase 用户创建的知识库，数据在 Redis 中使用独立的索引与 key 前缀
+type KnowledgeBase struct {
+	ID          string         `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	Name        string         `gorm:"type:varchar(100);not null" json:"name"`
+	Description string         `gorm:"type:varchar(500)" json:"description"`
+	OwnerName   string         `gorm:"index;type:varchar(20);not null" json:"owner"`
+	Visibility  string         `gorm:"type:varchar(20);not null;default:private" json:"visibility"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// KnowledgeBaseShare 知识库分享记录，被分享的用户可以检索该知识库
+type KnowledgeBaseShare struct {
+	ID              uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	KnowledgeBaseID string    `gorm:"uniqueIndex:idx_kb_share;type:varchar(36);not null" json:"knowledgeBaseId"`
+	UserName        string    `gorm:"uniqueIndex:idx_kb_share;index;type:varchar(20);not null" json:"username"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// KnowledgeBaseInfo 返回给用户的知识库信息
+type KnowledgeBaseInfo struct {
+	KnowledgeBase
+	IsOwner    bool     `json:"isOwner"`
+	SharedWith []string `json:"sharedWith,omitempty"` // 仅所有者可见
+}
+
+// 重新向量化任务的状态
+const (
+	ReembedStateRunning   = "running"
+	ReembedStateSucceeded = "succeeded"
+	ReembedStateFailed    = "failed"
+)
+
+// ReembedTask 知识库重新向量化任务，在后台执行
+type ReembedTask struct {
+	KnowledgeBaseID string `json:"knowledgeBaseId"`
+	State           string `json:"state"`
+	Reembedded      int    `json:"reembedded"` // 已重新向量化的分块数
+	ErrorMessage    string `json:"errorMessage,omitempty"`
+	StartedBy       string `json:"startedBy"`
+	StartedAt       int64  `json:"startedAt"`
+	FinishedAt      int64  `json:"finishedAt,omitempty"`
+}
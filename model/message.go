@@ -4,16 +4,84 @@ import (
 	"time"
 )
 
+// 消息角色，与模型侧的角色保持一致
+const (
+	MessageRoleUser      = "user"
+	MessageRoleAssistant = "assistant"
+	MessageRoleTool      = "tool"
+	MessageRoleSystem    = "system"
+)
+
 type Message struct {
-	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	SessionID string    `gorm:"index;not null;type:varchar(36)" json:"session_id"`
-	UserName  string    `gorm:"type:varchar(20)" json:"username"`
-	Content   string    `gorm:"type:text" json:"content"`
-	IsUser    bool      `gorm:"not null;" json:"is_user"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         uint              `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageKey string            `gorm:"uniqueIndex;type:varchar(36);default:null" json:"-"` // 幂等键，重复投递时避免重复插入
+	SessionID  string            `gorm:"index;not null;type:varchar(36)" json:"session_id"`
+	UserName   string            `gorm:"type:varchar(20)" json:"username"`
+	Role       string            `gorm:"type:varchar(20)" json:"role"`
+	Content    string            `gorm:"type:text" json:"content"`
+	IsUser     bool              `gorm:"not null;" json:"is_user"`
+	ToolCalls  []MessageToolCall `gorm:"serializer:json;type:text" json:"tool_calls,omitempty"` // assistant 发起的工具调用
+	ToolCallID string            `gorm:"type:varchar(64)" json:"tool_call_id,omitempty"`        // tool 消息对应的工具调用ID
+	ToolName   string            `gorm:"type:varchar(64)" json:"tool_name,omitempty"`           // tool 消息对应的工具名称
+	Citations  []MessageCitation `gorm:"serializer:json;type:text" json:"citations,omitempty"`  // assistant 回答引用的资料
+	Images     []MessageImage    `gorm:"serializer:json;type:longtext" json:"images,omitempty"` // user 消息附带图片的引用，旧数据中可能内联了图片
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// MessageImage 聊天消息附带的图片，图片本身保存在 imagestore 中，消息只保存 Key
+// 后续轮次仍会发送给支持图片输入的模型
+type MessageImage struct {
+	MIMEType string `json:"mimeType,omitempty"` // 请求中可省略，服务端按内容识别
+	Key      string `json:"key,omitempty"`      // 图片在 imagestore 中的引用
+	Data     string `json:"data,omitempty"`     // base64 编码，请求中也可以是 data URL；保存后为空
+	Name     string `json:"name,omitempty"`     // 文件名，仅用于展示
+}
+
+type MessageToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// 引用来源类型
+const (
+	CitationTypeRAG = "rag" // 知识库检索结果
+	CitationTypeWeb = "web" // 网页搜索结果
+)
+
+// MessageCitation 回答引用的资料，回答中的 [Index] 对应列表中的同一编号
+// 也是 rag_search / google_search 工具返回的结构化结果格式
+type MessageCitation struct {
+	Index         int     `json:"index"`
+	Type          string  `json:"type"`
+	Title         string  `json:"title,omitempty"`
+	URL           string  `json:"url,omitempty"`           // 网页地址
+	Source        string  `json:"source,omitempty"`        // 知识库文档来源
+	KnowledgeBase string  `json:"knowledgeBase,omitempty"` // 所属知识库
+	ChunkIndex    int     `json:"chunkIndex,omitempty"`
+	Content       string  `json:"content,omitempty"` // 检索到的片段或搜索摘要
+	Score         float64 `json:"score,omitempty"`
+	Cited         bool    `json:"cited,omitempty"` // 回答中是否出现了该编号
+}
+
+// GetRole 获取消息角色，兼容没有 Role 字段的历史数据
+func (m *Message) GetRole() string {
+	if m.Role != "" {
+		return m.Role
+	}
+	if m.IsUser {
+		return MessageRoleUser
+	}
+	return MessageRoleAssistant
 }
 
 type History struct {
-	IsUser  bool   `json:"is_user"`
-	Content string `json:"content"`
+	IsUser     bool              `json:"is_user"`
+	Role       string            `json:"role"`
+	Content    string            `json:"content"`
+	ToolCalls  []MessageToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string            `json:"tool_call_id,omitempty"`
+	ToolName   string            `json:"tool_name,omitempty"`
+	Citations  []MessageCitation `json:"citations,omitempty"`
+	Images     []MessageImage    `json:"images,omitempty"`
 }
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// ModelProfile 管理员对聊天模型的配置，没有记录的模型默认对所有角色启用
+type ModelProfile struct {
+	ModelType   string    `gorm:"primaryKey;type:varchar(20)" json:"modelType"`
+	Name        string    `gorm:"type:varchar(100)" json:"name"`
+	Description string    `gorm:"type:varchar(500)" json:"description"`
+	Enabled     bool      `gorm:"not null" json:"enabled"`
+	Roles       []string  `gorm:"serializer:json;type:text" json:"roles"` // 允许使用的角色，为空时不限制
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AllowsRole 模型是否对该角色可用
+func (p *ModelProfile) AllowsRole(role string) bool {
+	if !p.Enabled {
+		return false
+	}
+	if len(p.Roles) == 0 {
+		return true
+	}
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -14,6 +14,8 @@ type Session struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 	ModelType string         `gorm:"type:varchar(50);not null" json:"model_type"`
+	// 会话关联的知识库，rag_search 只在这些知识库中检索
+	KnowledgeBases []string `gorm:"serializer:json;type:text" json:"knowledge_bases"`
 }
 
 type SessionInfo struct {
@@ -0,0 +1,19 @@
+package model
+
+// ToolApproval 一次 Agent 运行中等待用户确认的工具调用
+// ApprovalID 即 Agent 检查点ID，恢复运行时据此加载检查点
+type ToolApproval struct {
+	ApprovalID string            `json:"approvalId"`
+	SessionID  string            `json:"sessionId"`
+	UserName   string            `json:"username"`
+	ModelType  string            `json:"modelType"`
+	Tools      []string          `json:"tools"` // 生成时交给 Agent 的工具，恢复时据此重建 Agent
+	ToolCalls  []PendingToolCall `json:"toolCalls"`
+	CreatedAt  int64             `json:"createdAt"`
+}
+
+type PendingToolCall struct {
+	InterruptID string `json:"interruptId"`
+	ToolName    string `json:"toolName"`
+	Arguments   string `json:"arguments"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// ToolPreference 用户保存的默认工具选择
+type ToolPreference struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserName  string    `gorm:"uniqueIndex;type:varchar(20);not null" json:"username"`
+	Tools     []string  `gorm:"serializer:json;type:text" json:"tools"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ChatToolInfo struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
@@ -62,7 +62,20 @@ type TravelPlanningTaskSnapshot struct {
 	ErrorMessage      string                `json:"error_message,omitempty"`
 	Stages            []TravelPlanningStage `json:"stages,omitempty"`
 	Plan              TravelPlanPayload     `json:"plan,omitempty"`
+	PhotoPlaces       []TravelPhotoPlace    `json:"photo_places,omitempty"` // 从照片开始规划时，每张照片推测出的地点
 	CreatedAt         int64                 `json:"created_at,omitempty"`
 	UpdatedAt         int64                 `json:"updated_at,omitempty"`
 	CompletedAt       int64                 `json:"completed_at,omitempty"`
 }
+
+// TravelPhotoPlace 从一张照片中推测出的地点
+type TravelPhotoPlace struct {
+	PhotoIndex int     `json:"photo_index"` // 照片在上传顺序中的位置，从 0 开始
+	PhotoName  string  `json:"photo_name,omitempty"`
+	Caption    string  `json:"caption,omitempty"` // 照片内容描述
+	Place      string  `json:"place,omitempty"`   // 推测的景点或地标，无法判断时为空
+	City       string  `json:"city,omitempty"`
+	Country    string  `json:"country,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Error      string  `json:"error,omitempty"` // 照片识别失败的原因
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// UserUsage 按用户统计的聊天用量，由消息记录汇总得到
+type UserUsage struct {
+	UserName     string    `json:"username"`
+	Sessions     int64     `json:"sessions"`
+	Messages     int64     `json:"messages"`
+	Questions    int64     `json:"questions"`    // 用户提问数
+	ToolMessages int64     `json:"toolMessages"` // 工具调用结果数
+	LastActiveAt time.Time `json:"lastActiveAt"`
+}
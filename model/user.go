@@ -6,13 +6,29 @@ import (
 	"gorm.io/gorm"
 )
 
+// 用户角色，其他角色可以在 [roleConfig] permissions 中配置
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID        int64          `gorm:"primaryKey" json:"id"`
 	Name      string         `gorm:"type:varchar(50)" json:"name"`
 	Email     string         `gorm:"type:varchar(100);index" json:"email"`
 	Username  string         `gorm:"type:varchar(50);uniqueIndex" json:"username"` // 唯一索引
 	Password  string         `gorm:"type:varchar(255)" json:"-"`                   // 不返回给前端
-	CreatedAt time.Time      `json:"created_at"`                                   // 自动时间戳
+	Role      string         `gorm:"type:varchar(20);not null;default:user" json:"role"`
+	Disabled  bool           `gorm:"not null;default:false" json:"disabled"` // 被禁用的用户无法登录和刷新令牌
+	CreatedAt time.Time      `json:"created_at"`                             // 自动时间戳
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"` // 支持软删除
 }
+
+// GetRole 获取用户角色，兼容没有 Role 字段的历史数据
+func (u *User) GetRole() string {
+	if u.Role != "" {
+		return u.Role
+	}
+	return RoleUser
+}
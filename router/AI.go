@@ -2,23 +2,37 @@ package router
 
 import (
 	"GopherAI/controller/session"
+	"GopherAI/middleware/ratelimit"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func AIRouter(r *gin.RouterGroup) {
+	// 调用模型的接口按用户限流，查询类接口不限流
+	chatLimit := ratelimit.Limit(ratelimit.Rule{Name: "ai_chat", Limit: 30, Window: time.Minute, Key: ratelimit.ByUser})
+	travelLimit := ratelimit.Limit(ratelimit.Rule{Name: "ai_travel", Limit: 10, Window: time.Minute, Key: ratelimit.ByUser})
 
 	// 聊天相关接口
 	{
 		r.GET("/chat/sessions", session.GetUserSessionsByUserName)
-		r.POST("/chat/send-new-session", session.CreateSessionAndSendMessage)
-		r.POST("/chat/send", session.ChatSend)
+		r.POST("/chat/send-new-session", chatLimit, session.CreateSessionAndSendMessage)
+		r.POST("/chat/send", chatLimit, session.ChatSend)
 		r.POST("/chat/history", session.ChatHistory)
+		r.GET("/chat/images/:owner/:name", session.GetChatImage)
+		r.GET("/chat/sessions/:sessionId/knowledge-bases", session.GetSessionKnowledgeBases)
+		r.POST("/chat/sessions/:sessionId/knowledge-bases", session.UpdateSessionKnowledgeBases)
+		r.GET("/chat/models", session.GetChatModels)
+		r.GET("/chat/tools", session.GetChatTools)
+		r.POST("/chat/tools", session.UpdateChatTools)
+		r.GET("/chat/approvals", session.GetToolApprovals)
+		r.POST("/chat/approvals/:approvalId", chatLimit, session.DecideToolApproval)
 		// r.POST("/chat/tts", AI.ChatSpeech)                  // ChatSpeechHandler
-		r.POST("/chat/send-stream-new-session", session.CreateStreamSessionAndSendMessage)
-		r.POST("/chat/send-stream", session.ChatStreamSend)
-		r.POST("/agent/travel_plan", session.GenerateTravelPlan)
-		r.POST("/agent/travel_plan/tasks", session.CreateTravelPlanningTask)
+		r.POST("/chat/send-stream-new-session", chatLimit, session.CreateStreamSessionAndSendMessage)
+		r.POST("/chat/send-stream", chatLimit, session.ChatStreamSend)
+		r.POST("/agent/travel_plan", travelLimit, session.GenerateTravelPlan)
+		r.POST("/agent/travel_plan/tasks", travelLimit, session.CreateTravelPlanningTask)
+		r.POST("/agent/travel_plan/tasks/photos", travelLimit, session.CreatePhotoTravelPlanningTask)
 		r.GET("/agent/travel_plan/tasks/:taskId", session.GetTravelPlanningTask)
 	}
 }
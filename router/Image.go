@@ -2,11 +2,15 @@ package router
 
 import (
 	"GopherAI/controller/image"
+	"GopherAI/middleware/ratelimit"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func ImageRouter(r *gin.RouterGroup) {
+	imageLimit := ratelimit.Limit(ratelimit.Rule{Name: "image", Limit: 20, Window: time.Minute, Key: ratelimit.ByUser})
 
-	r.POST("/recognize", image.RecognizeImage)
+	r.POST("/recognize", imageLimit, image.RecognizeImage)
+	r.POST("/analyze", imageLimit, image.AnalyzeImage)
 }
@@ -0,0 +1,29 @@
+package router
+
+import (
+	"GopherAI/controller/rag"
+	"GopherAI/middleware/ratelimit"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RAGRouter(r *gin.RouterGroup) {
+	// 上传文档需要解析并调用向量模型，按用户限流
+	uploadLimit := ratelimit.Limit(ratelimit.Rule{Name: "rag_upload", Limit: 10, Window: time.Minute, Key: ratelimit.ByUser})
+
+	r.POST("/documents", uploadLimit, rag.UploadDocument)
+	r.POST("/search", rag.Search)
+
+	r.GET("/knowledge-bases", rag.GetKnowledgeBases)
+	r.POST("/knowledge-bases", rag.CreateKnowledgeBase)
+	r.PUT("/knowledge-bases/:id", rag.UpdateKnowledgeBase)
+	r.DELETE("/knowledge-bases/:id", rag.DeleteKnowledgeBase)
+	r.POST("/knowledge-bases/:id/shares", rag.ShareKnowledgeBase)
+	r.DELETE("/knowledge-bases/:id/shares/:userName", rag.UnshareKnowledgeBase)
+
+	r.GET("/knowledge-bases/:id/documents", rag.GetDocuments)
+	r.DELETE("/knowledge-bases/:id/documents", rag.DeleteDocuments)
+	r.GET("/knowledge-bases/:id/documents/:docId", rag.GetDocument)
+	r.DELETE("/knowledge-bases/:id/documents/:docId", rag.DeleteDocument)
+}
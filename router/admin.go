@@ -0,0 +1,38 @@
+package router
+
+import (
+	"GopherAI/controller/admin"
+	"GopherAI/middleware/rbac"
+	"GopherAI/utils/myrbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminRouter 每个接口单独校验权限，分组入口已经记录审计日志
+func AdminRouter(r *gin.RouterGroup) {
+	users := rbac.Require(myrbac.PermManageUsers)
+	r.GET("/users", users, admin.GetUsers)
+	r.POST("/users/:id/disable", users, admin.DisableUser)
+	r.POST("/users/:id/enable", users, admin.EnableUser)
+	r.PUT("/users/:id/role", users, admin.UpdateUserRole)
+	r.DELETE("/users/:id", users, admin.DeleteUser)
+
+	sessions := rbac.Require(myrbac.PermViewSessions)
+	r.GET("/sessions", sessions, admin.GetSessions)
+	r.GET("/sessions/:sessionId", sessions, admin.GetSession)
+
+	r.GET("/usage", rbac.Require(myrbac.PermViewUsage), admin.GetUsage)
+
+	kbs := rbac.Require(myrbac.PermManageKnowledgeBases)
+	r.GET("/knowledge-bases", kbs, admin.GetKnowledgeBases)
+	r.PUT("/knowledge-bases/:id", kbs, admin.UpdateKnowledgeBase)
+	r.DELETE("/knowledge-bases/:id", kbs, admin.DeleteKnowledgeBase)
+	r.POST("/knowledge-bases/:id/reembed", kbs, admin.StartReembed)
+	r.GET("/knowledge-bases/:id/reembed", kbs, admin.GetReembed)
+
+	models := rbac.Require(myrbac.PermManageModels)
+	r.GET("/model-profiles", models, admin.GetModelProfiles)
+	r.PUT("/model-profiles/:modelType", models, admin.UpdateModelProfile)
+
+	r.GET("/audit-logs", rbac.Require(myrbac.PermViewAuditLogs), admin.GetAuditLogs)
+}
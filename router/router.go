@@ -1,7 +1,12 @@
 package router
 
 import (
+	"GopherAI/config"
+	"GopherAI/middleware/audit"
 	"GopherAI/middleware/jwt"
+	"GopherAI/middleware/rbac"
+	"GopherAI/utils/myrbac"
+	"log"
 
 	"github.com/gin-gonic/gin"
 )
@@ -9,6 +14,10 @@ import (
 func InitRouter() *gin.Engine {
 
 	r := gin.Default()
+	// 限流按客户端 IP 统计，不能信任任意来源的 X-Forwarded-For
+	if err := r.SetTrustedProxies(config.GetConfig().TrustedProxies); err != nil {
+		log.Println("SetTrustedProxies failed:", err)
+	}
 	enterRouter := r.Group("/api/v1")
 	{
 		RegisterUserRouter(enterRouter.Group("/user"))
@@ -26,5 +35,18 @@ func InitRouter() *gin.Engine {
 		ImageRouter(ImageGroup)
 	}
 
+	{
+		RAGGroup := enterRouter.Group("/rag")
+		RAGGroup.Use(jwt.Auth())
+		RAGRouter(RAGGroup)
+	}
+
+	// 管理接口：先记录审计日志，没有任何管理权限的请求同样会被记录
+	{
+		AdminGroup := enterRouter.Group("/admin")
+		AdminGroup.Use(jwt.Auth(), audit.Log(), rbac.RequireAny(myrbac.AllPermissions...))
+		AdminRouter(AdminGroup)
+	}
+
 	return r
 }
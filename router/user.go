@@ -2,14 +2,33 @@ package router
 
 import (
 	"GopherAI/controller/user"
+	"GopherAI/middleware/jwt"
+	"GopherAI/middleware/ratelimit"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterUserRouter(r *gin.RouterGroup) {
+	// 未登录接口按 IP 限流，发送邮件的接口另外按邮箱设置了冷却
+	loginLimit := ratelimit.Limit(ratelimit.Rule{Name: "login", Limit: 20, Window: time.Minute})
+	registerLimit := ratelimit.Limit(ratelimit.Rule{Name: "register", Limit: 10, Window: time.Minute})
+	emailLimit := ratelimit.Limit(ratelimit.Rule{Name: "email", Limit: 10, Window: time.Hour})
+	resetLimit := ratelimit.Limit(ratelimit.Rule{Name: "password_reset", Limit: 10, Window: 10 * time.Minute})
+	refreshLimit := ratelimit.Limit(ratelimit.Rule{Name: "token_refresh", Limit: 30, Window: time.Minute})
+	// 修改密码需要登录，按用户限流，旧密码错误还会计入登录失败次数
+	passwordLimit := ratelimit.Limit(ratelimit.Rule{Name: "password_change", Limit: 5, Window: 10 * time.Minute, Key: ratelimit.ByUser})
 	{
-		r.POST("/register", user.Register)
-		r.POST("/login", user.Login)
-		r.POST("/captcha", user.HandleCaptcha)
+		r.POST("/register", registerLimit, user.Register)
+		r.POST("/login", loginLimit, user.Login)
+		r.POST("/captcha", emailLimit, user.HandleCaptcha)
+		r.POST("/password", jwt.Auth(), passwordLimit, user.ChangePassword)
+		r.POST("/password/forgot", emailLimit, user.ForgotPassword)
+		r.POST("/password/reset/verify", resetLimit, user.VerifyResetCaptcha)
+		r.POST("/password/reset", resetLimit, user.ResetPassword)
+		r.POST("/account/resend", emailLimit, user.ResendAccount)
+		r.POST("/token/refresh", refreshLimit, user.RefreshToken)
+		r.POST("/logout", jwt.Auth(), user.Logout)
+		r.POST("/logout/all", jwt.Auth(), user.LogoutAll)
 	}
 }
@@ -0,0 +1,17 @@
+package admin
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// normalizePage 分页参数，limit 默认 20、最大 100
+func normalizePage(offset int, limit int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	return offset, min(limit, maxPageSize)
+}
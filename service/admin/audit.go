@@ -0,0 +1,25 @@
+package admin
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/dao/audit"
+	"GopherAI/model"
+	"log"
+	"strings"
+)
+
+// ListAuditLogs 按时间倒序查看审计日志，可按操作人与路由筛选
+func ListAuditLogs(actorName string, route string, offset int, limit int) ([]model.AuditLog, int64, code.Code) {
+	offset, limit = normalizePage(offset, limit)
+	logs, total, err := audit.ListAuditLogs(audit.AuditLogQuery{
+		ActorName: strings.TrimSpace(actorName),
+		Route:     strings.TrimSpace(route),
+		Offset:    offset,
+		Limit:     limit,
+	})
+	if err != nil {
+		log.Println("ListAuditLogs error:", err)
+		return nil, 0, code.CodeServerBusy
+	}
+	return logs, total, code.CodeSuccess
+}
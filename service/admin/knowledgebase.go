@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/dao/knowledgebase"
+	"GopherAI/model"
+	"GopherAI/service/rag"
+	"context"
+	"log"
+	"strings"
+)
+
+// ListKnowledgeBases 所有用户的知识库，ownerName 不为空时只返回该用户的知识库
+func ListKnowledgeBases(ownerName string, offset int, limit int) ([]model.KnowledgeBase, int64, code.Code) {
+	offset, limit = normalizePage(offset, limit)
+	kbs, total, err := knowledgebase.ListKnowledgeBases(strings.TrimSpace(ownerName), offset, limit)
+	if err != nil {
+		log.Println("ListKnowledgeBases error:", err)
+		return nil, 0, code.CodeServerBusy
+	}
+	return kbs, total, code.CodeSuccess
+}
+
+// UpdateKnowledgeBase 修改任意知识库的名称、描述与可见性
+func UpdateKnowledgeBase(id string, req rag.KnowledgeBaseRequest) (*model.KnowledgeBase, code.Code) {
+	return rag.AdminUpdateKnowledgeBase(id, req)
+}
+
+// StartReembed 在后台重新向量化知识库，default 表示默认知识库
+func StartReembed(id string, actorName string) (model.ReembedTask, code.Code) {
+	return rag.StartReembedTask(id, actorName)
+}
+
+// GetReembed 知识库最近一次重新向量化任务的进度
+func GetReembed(id string) (model.ReembedTask, code.Code) {
+	return rag.GetReembedTask(id)
+}
+
+// DeleteKnowledgeBase 删除任意知识库及其向量数据
+func DeleteKnowledgeBase(ctx context.Context, id string) code.Code {
+	return rag.AdminDeleteKnowledgeBase(ctx, id)
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"GopherAI/common/aihelper"
+	"GopherAI/common/code"
+	"GopherAI/dao/modelprofile"
+	"GopherAI/model"
+	"GopherAI/service/session"
+	"GopherAI/utils/myrbac"
+	"log"
+	"slices"
+	"strings"
+)
+
+// ModelProfileRequest 修改模型配置的参数
+type ModelProfileRequest struct {
+	Name        string
+	Description string
+	Enabled     bool
+	Roles       []string // 允许使用的角色，为空时不限制
+}
+
+func (req *ModelProfileRequest) normalize() bool {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	if len(req.Name) > 100 || len(req.Description) > 500 {
+		return false
+	}
+	policy := myrbac.Default()
+	roles := make([]string, 0, len(req.Roles))
+	for _, role := range req.Roles {
+		role = strings.TrimSpace(role)
+		if !policy.IsRole(role) {
+			return false
+		}
+		if !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	req.Roles = roles
+	return true
+}
+
+// ListModelProfiles 所有已注册模型及其配置
+func ListModelProfiles() ([]model.ModelProfile, code.Code) {
+	profiles, err := session.ListModelProfiles()
+	if err != nil {
+		log.Println("ListModelProfiles error:", err)
+		return nil, code.CodeServerBusy
+	}
+	return profiles, code.CodeSuccess
+}
+
+// UpdateModelProfile 保存模型的名称、启用状态与允许使用的角色
+func UpdateModelProfile(modelType string, req ModelProfileRequest) (*model.ModelProfile, code.Code) {
+	if !slices.Contains(aihelper.GetGlobalFactory().ModelTypes(), modelType) {
+		return nil, code.CodeRecordNotFound
+	}
+	if !req.normalize() {
+		return nil, code.CodeInvalidParams
+	}
+	profile, err := modelprofile.SaveModelProfile(&model.ModelProfile{
+		ModelType:   modelType,
+		Name:        req.Name,
+		Description: req.Description,
+		Enabled:     req.Enabled,
+		Roles:       req.Roles,
+	})
+	if err != nil {
+		log.Println("UpdateModelProfile SaveModelProfile error:", err)
+		return nil, code.CodeServerBusy
+	}
+	return profile, code.CodeSuccess
+}
@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/dao/message"
+	"GopherAI/dao/session"
+	"GopherAI/model"
+	"errors"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ListSessions 所有用户的会话，userName 不为空时只返回该用户的会话
+func ListSessions(userName string, offset int, limit int) ([]model.Session, int64, code.Code) {
+	offset, limit = normalizePage(offset, limit)
+	sessions, total, err := session.ListSessions(strings.TrimSpace(userName), offset, limit)
+	if err != nil {
+		log.Println("ListSessions error:", err)
+		return nil, 0, code.CodeServerBusy
+	}
+	return sessions, total, code.CodeSuccess
+}
+
+// GetSession 查看任意会话及其消息记录，消息从数据库读取，不依赖会话是否已加载到内存
+func GetSession(sessionID string) (*model.Session, []model.History, code.Code) {
+	sess, err := session.GetSessionByID(sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, code.CodeRecordNotFound
+		}
+		log.Println("GetSession GetSessionByID error:", err)
+		return nil, nil, code.CodeServerBusy
+	}
+
+	msgs, err := message.GetMessagesBySessionID(sessionID)
+	if err != nil {
+		log.Println("GetSession GetMessagesBySessionID error:", err)
+		return nil, nil, code.CodeServerBusy
+	}
+	history := make([]model.History, 0, len(msgs))
+	for i := range msgs {
+		msg := &msgs[i]
+		history = append(history, model.History{
+			IsUser:     msg.IsUser,
+			Role:       msg.GetRole(),
+			Content:    msg.Content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
+			ToolName:   msg.ToolName,
+			Citations:  msg.Citations,
+			Images:     msg.Images,
+		})
+	}
+	return sess, history, code.CodeSuccess
+}
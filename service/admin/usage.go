@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/dao/message"
+	"GopherAI/model"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	defaultUsageDays = 30
+	maxUsageDays     = 365
+)
+
+// GetUsage 最近 days 天按用户汇总的用量，userName 为空时统计所有用户
+func GetUsage(userName string, days int) ([]model.UserUsage, code.Code) {
+	if days <= 0 {
+		days = defaultUsageDays
+	}
+	days = min(days, maxUsageDays)
+	since := time.Now().AddDate(0, 0, -days)
+
+	usage, err := message.GetUserUsage(strings.TrimSpace(userName), since)
+	if err != nil {
+		log.Println("GetUsage GetUserUsage error:", err)
+		return nil, code.CodeServerBusy
+	}
+	return usage, code.CodeSuccess
+}
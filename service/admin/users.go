@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/dao/user"
+	"GopherAI/model"
+	user_service "GopherAI/service/user"
+	"GopherAI/utils/myrbac"
+	"errors"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ListUsers 按账号或邮箱搜索用户
+func ListUsers(keyword string, offset int, limit int) ([]*model.User, int64, code.Code) {
+	offset, limit = normalizePage(offset, limit)
+	users, total, err := user.ListUsers(strings.TrimSpace(keyword), offset, limit)
+	if err != nil {
+		log.Println("ListUsers error:", err)
+		return nil, 0, code.CodeServerBusy
+	}
+	return users, total, code.CodeSuccess
+}
+
+// Actor 执行管理操作的用户
+type Actor struct {
+	ID   int64
+	Role string
+}
+
+// getTargetUser 管理员不能对自己执行禁用、删除和修改角色，避免误操作后无人可以管理
+// 目标用户的角色拥有操作人没有的权限时返回 CodeForbidden
+func getTargetUser(actor Actor, id int64) (*model.User, code.Code) {
+	if id == actor.ID {
+		return nil, code.CodeInvalidParams
+	}
+	u, err := user.GetUserByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, code.CodeUserNotExist
+		}
+		log.Println("getTargetUser GetUserByID error:", err)
+		return nil, code.CodeServerBusy
+	}
+	if !myrbac.Default().Covers(actor.Role, u.GetRole()) {
+		return nil, code.CodeForbidden
+	}
+	return u, code.CodeSuccess
+}
+
+// SetUserDisabled 禁用或启用用户，禁用后该用户的所有登录会话立即失效
+func SetUserDisabled(actor Actor, id int64, disabled bool) code.Code {
+	u, code_ := getTargetUser(actor, id)
+	if code_ != code.CodeSuccess {
+		return code_
+	}
+	if err := user.UpdateDisabled(u.ID, disabled); err != nil {
+		log.Println("SetUserDisabled UpdateDisabled error:", err)
+		return code.CodeServerBusy
+	}
+	if !disabled {
+		return code.CodeSuccess
+	}
+	return user_service.LogoutAll(u.ID)
+}
+
+// SetUserRole 修改用户角色，角色保存在访问令牌中，修改后需要重新登录
+// 只能授予不超过操作人权限的角色
+func SetUserRole(actor Actor, id int64, role string) code.Code {
+	role = strings.TrimSpace(role)
+	policy := myrbac.Default()
+	if !policy.IsRole(role) {
+		return code.CodeInvalidParams
+	}
+	if !policy.Covers(actor.Role, role) {
+		return code.CodeForbidden
+	}
+	u, code_ := getTargetUser(actor, id)
+	if code_ != code.CodeSuccess {
+		return code_
+	}
+	if u.GetRole() == role {
+		return code.CodeSuccess
+	}
+	if err := user.UpdateRole(u.ID, role); err != nil {
+		log.Println("SetUserRole UpdateRole error:", err)
+		return code.CodeServerBusy
+	}
+	return user_service.LogoutAll(u.ID)
+}
+
+// DeleteUser 软删除用户并使其登录会话失效，会话与知识库等数据保留
+func DeleteUser(actor Actor, id int64) code.Code {
+	u, code_ := getTargetUser(actor, id)
+	if code_ != code.CodeSuccess {
+		return code_
+	}
+	if err := user.DeleteUser(u.ID); err != nil {
+		log.Println("DeleteUser error:", err)
+		return code.CodeServerBusy
+	}
+	return user_service.LogoutAll(u.ID)
+}
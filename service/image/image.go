@@ -1,24 +1,165 @@
 package image
 
 import (
+	"GopherAI/common/code"
 	image_recognizer "GopherAI/common/image"
+	"GopherAI/config"
 	"context"
-	"image"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log"
+	"mime/multipart"
+	"strings"
+	"sync"
 )
 
-func RecognizeImage(ctx context.Context, r io.Reader) (string, error) {
-	img, _, err := image.Decode(r)
+const defaultMaxUploadSizeMB = 10
+
+var (
+	recognizerMu sync.Mutex
+	recognizer   image_recognizer.ImageRecognizer
+)
+
+// getRecognizer 所有请求共用一个识别器，创建失败时下次请求重试
+func getRecognizer(ctx context.Context) (image_recognizer.ImageRecognizer, error) {
+	recognizerMu.Lock()
+	defer recognizerMu.Unlock()
+	if recognizer != nil {
+		return recognizer, nil
+	}
+	ri, err := newRecognizer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("image recognizer backend: %s", ri.Name())
+	recognizer = ri
+	return recognizer, nil
+}
+
+// newRecognizer 按 [imageAIConfig] backend 创建识别器
+func newRecognizer(ctx context.Context) (image_recognizer.ImageRecognizer, error) {
+	conf := config.GetConfig().ImageAIConfig
+	switch strings.ToLower(strings.TrimSpace(conf.Backend)) {
+	case "", image_recognizer.BackendLLM:
+		return image_recognizer.NewLLMRecognizer(ctx, PrepareOptions())
+	case image_recognizer.BackendONNX:
+		return image_recognizer.NewONNXClassifier(image_recognizer.ClassifierConfig{
+			ModelPath:   conf.ModelPath,
+			LabelPath:   conf.LabelPath,
+			LibraryPath: conf.ONNXLibraryPath,
+			InputSize:   conf.InputSize,
+			Mean:        conf.Mean,
+			Std:         conf.Std,
+		}, PrepareOptions())
+	case image_recognizer.BackendStub:
+		return image_recognizer.NewStubRecognizer(nil, PrepareOptions()), nil
+	default:
+		return nil, fmt.Errorf("unknown image recognizer backend %q", conf.Backend)
+	}
+}
+
+// MaxUploadBytes 单张图片的大小上限
+func MaxUploadBytes() int64 {
+	size := config.GetConfig().ImageAIConfig.MaxUploadSize
+	if size <= 0 {
+		size = defaultMaxUploadSizeMB
+	}
+	return size << 20
+}
+
+// ReadUpload 读取上传的图片文件，超过大小上限时返回 CodeImageTooLarge
+func ReadUpload(fileHeader *multipart.FileHeader) ([]byte, code.Code) {
+	maxBytes := MaxUploadBytes()
+	if fileHeader.Size > maxBytes {
+		return nil, code.CodeImageTooLarge
+	}
+	file, err := fileHeader.Open()
 	if err != nil {
-		return "", err
+		log.Println("Open file fail ", err)
+		return nil, code.CodeServerBusy
 	}
+	defer file.Close()
 
-	// TODO: 目前去除了 Buffer 之后补全
-	ri, err := image_recognizer.NewImageRecognizer(ctx)
+	buf, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
 	if err != nil {
-		return "", err
+		log.Println("Read file fail ", err)
+		return nil, code.CodeServerBusy
 	}
-	defer ri.Close()
+	if int64(len(buf)) > maxBytes {
+		return nil, code.CodeImageTooLarge
+	}
+	return buf, code.CodeSuccess
+}
+
+// PrepareOptions 发送给模型前的图片预处理参数，识别与聊天共用
+func PrepareOptions() image_recognizer.PrepareOptions {
+	conf := config.GetConfig().ImageAIConfig
+	return image_recognizer.PrepareOptions{
+		MaxPixels:     int64(conf.MaxMegapixels) * 1_000_000,
+		MaxEdge:       conf.MaxEdge,
+		JPEGQuality:   conf.JPEGQuality,
+		StripMetadata: conf.StripMetadata,
+	}
+}
 
-	return ri.PredictFromFile(ctx, img)
+func RecognizeImage(ctx context.Context, buf []byte) (string, code.Code) {
+	ri, err := getRecognizer(ctx)
+	if err != nil {
+		log.Println("RecognizeImage NewImageRecognizer error:", err)
+		return "", code.AIModelCannotOpen
+	}
+
+	className, err := ri.PredictFromBuffer(ctx, buf)
+	if err != nil {
+		return "", analyzeErrorCode("RecognizeImage", err)
+	}
+	return className, code.CodeSuccess
+}
+
+// AnalyzeImage 返回图片的描述、物体、文字与地标推测，schema 不为空时按其生成 result
+func AnalyzeImage(ctx context.Context, buf []byte, prompt string, schema json.RawMessage) (*image_recognizer.ImageAnalysis, code.Code) {
+	ri, err := getRecognizer(ctx)
+	if err != nil {
+		log.Println("AnalyzeImage NewImageRecognizer error:", err)
+		return nil, code.AIModelCannotOpen
+	}
+
+	analysis, err := ri.Analyze(ctx, buf, image_recognizer.AnalyzeOptions{Prompt: prompt, Schema: schema})
+	if err != nil {
+		return nil, analyzeErrorCode("AnalyzeImage", err)
+	}
+	return analysis, code.CodeSuccess
+}
+
+func analyzeErrorCode(op string, err error) code.Code {
+	log.Printf("%s error: %v", op, err)
+	if code_, ok := PrepareErrorCode(err); ok {
+		return code_
+	}
+	switch {
+	case errors.Is(err, image_recognizer.ErrInvalidSchema):
+		return code.CodeInvalidParams
+	case errors.Is(err, image_recognizer.ErrNotSupported):
+		return code.AIModelUnsupported
+	case errors.Is(err, image_recognizer.ErrInvalidOutput):
+		return code.AIModelBadOutput
+	default:
+		return code.AIModelFail
+	}
+}
+
+// PrepareErrorCode 图片预处理错误对应的响应码，不是预处理错误时返回 false
+func PrepareErrorCode(err error) (code.Code, bool) {
+	switch {
+	case errors.Is(err, image_recognizer.ErrInvalidImage):
+		return code.CodeInvalidImage, true
+	case errors.Is(err, image_recognizer.ErrTooManyPixels):
+		return code.CodeImageTooLarge, true
+	case errors.Is(err, image_recognizer.ErrUnsupportedFormat):
+		return code.CodeUnsupportedImage, true
+	default:
+		return 0, false
+	}
 }
@@ -0,0 +1,97 @@
+package rag
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/common/rag"
+	"context"
+	"errors"
+	"log"
+	"strings"
+)
+
+const (
+	defaultDocumentPageSize = 20
+	maxDocumentPageSize     = 100
+)
+
+// documentErrorCode 把文档操作的错误转换为错误码
+func documentErrorCode(err error) code.Code {
+	switch {
+	case errors.Is(err, rag.ErrDocumentNotFound):
+		return code.CodeRecordNotFound
+	case errors.Is(err, rag.ErrInvalidDocumentID):
+		return code.CodeInvalidParams
+	default:
+		return code.CodeServerBusy
+	}
+}
+
+// ListDocuments 分页列出知识库中的文档，有检索权限即可查看
+func ListDocuments(ctx context.Context, userName string, kbID string, offset int, limit int) ([]*rag.DocumentInfo, int64, code.Code) {
+	if _, code_ := ResolveReadableKnowledgeBases(userName, []string{kbID}); code_ != code.CodeSuccess {
+		return nil, 0, code_
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = defaultDocumentPageSize
+	}
+	limit = min(limit, maxDocumentPageSize)
+
+	docs, total, err := getRAGDatabase().ListDocuments(ctx, kbID, offset, limit)
+	if err != nil {
+		log.Printf("ListDocuments kb=%s error: %v", kbID, err)
+		return nil, 0, code.CodeServerBusy
+	}
+	return docs, total, code.CodeSuccess
+}
+
+// GetDocument 获取文档信息，有检索权限即可查看
+func GetDocument(ctx context.Context, userName string, kbID string, docID string) (*rag.DocumentInfo, code.Code) {
+	if _, code_ := ResolveReadableKnowledgeBases(userName, []string{kbID}); code_ != code.CodeSuccess {
+		return nil, code_
+	}
+	doc, err := getRAGDatabase().GetDocument(ctx, kbID, docID)
+	if err != nil {
+		if code_ := documentErrorCode(err); code_ != code.CodeServerBusy {
+			return nil, code_
+		}
+		log.Printf("GetDocument kb=%s doc=%s error: %v", kbID, docID, err)
+		return nil, code.CodeServerBusy
+	}
+	return doc, code.CodeSuccess
+}
+
+// DeleteDocument 删除文档及其全部分块，只有知识库所有者可以删除
+func DeleteDocument(ctx context.Context, userName string, kbID string, docID string) code.Code {
+	if _, code_ := getOwnedKnowledgeBase(userName, kbID); code_ != code.CodeSuccess {
+		return code_
+	}
+	if err := getRAGDatabase().DeleteDocument(ctx, kbID, docID); err != nil {
+		if code_ := documentErrorCode(err); code_ != code.CodeServerBusy {
+			return code_
+		}
+		log.Printf("DeleteDocument kb=%s doc=%s error: %v", kbID, docID, err)
+		return code.CodeServerBusy
+	}
+	log.Printf("DeleteDocument user=%s kb=%s doc=%s", userName, kbID, docID)
+	return code.CodeSuccess
+}
+
+// DeleteDocumentsBySource 删除同一来源下的全部文档，返回删除的文档数
+func DeleteDocumentsBySource(ctx context.Context, userName string, kbID string, source string) (int, code.Code) {
+	if strings.TrimSpace(source) == "" {
+		return 0, code.CodeInvalidParams
+	}
+	if _, code_ := getOwnedKnowledgeBase(userName, kbID); code_ != code.CodeSuccess {
+		return 0, code_
+	}
+	deleted, err := getRAGDatabase().DeleteDocumentsBySource(ctx, kbID, source)
+	if err != nil {
+		log.Printf("DeleteDocumentsBySource kb=%s source=%s error: %v", kbID, source, err)
+		return deleted, code.CodeServerBusy
+	}
+	log.Printf("DeleteDocumentsBySource user=%s kb=%s source=%s deleted=%d", userName, kbID, source, deleted)
+	return deleted, code.CodeSuccess
+}
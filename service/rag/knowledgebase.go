@@ -0,0 +1,306 @@
+package rag
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/common/rag"
+	"GopherAI/dao/knowledgebase"
+	"GopherAI/dao/user"
+	"GopherAI/model"
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const defaultKnowledgeBaseName = "默认知识库"
+
+// KnowledgeBaseRequest 创建或修改知识库的参数
+type KnowledgeBaseRequest struct {
+	Name        string
+	Description string
+	Visibility  string
+}
+
+func (req *KnowledgeBaseRequest) normalize() bool {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	if req.Visibility == "" {
+		req.Visibility = model.KnowledgeBasePrivate
+	}
+	if req.Name == "" || len(req.Name) > 100 || len(req.Description) > 500 {
+		return false
+	}
+	return req.Visibility == model.KnowledgeBasePrivate || req.Visibility == model.KnowledgeBasePublic
+}
+
+// 默认知识库不在数据库中，所有用户可读，只能通过导入脚本写入
+func defaultKnowledgeBaseInfo() model.KnowledgeBaseInfo {
+	return model.KnowledgeBaseInfo{
+		KnowledgeBase: model.KnowledgeBase{
+			ID:         rag.DefaultKnowledgeBase,
+			Name:       defaultKnowledgeBaseName,
+			Visibility: model.KnowledgeBasePublic,
+		},
+	}
+}
+
+func canReadKnowledgeBase(userName string, kb *model.KnowledgeBase) (bool, error) {
+	if kb.OwnerName == userName || kb.Visibility == model.KnowledgeBasePublic {
+		return true, nil
+	}
+	return knowledgebase.IsSharedWith(kb.ID, userName)
+}
+
+func getKnowledgeBase(id string) (*model.KnowledgeBase, code.Code) {
+	kb, err := knowledgebase.GetKnowledgeBaseByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, code.CodeRecordNotFound
+		}
+		log.Println("getKnowledgeBase GetKnowledgeBaseByID error:", err)
+		return nil, code.CodeServerBusy
+	}
+	return kb, code.CodeSuccess
+}
+
+// 只有所有者可以修改、删除、分享知识库以及上传文档
+func getOwnedKnowledgeBase(userName string, id string) (*model.KnowledgeBase, code.Code) {
+	kb, code_ := getKnowledgeBase(id)
+	if code_ != code.CodeSuccess {
+		return nil, code_
+	}
+	if kb.OwnerName != userName {
+		return nil, code.CodeForbidden
+	}
+	return kb, code.CodeSuccess
+}
+
+// indexDropper 删除知识库时清理索引与数据，RedisRAG 与 MemoryRAG 都实现了该方法
+type indexDropper interface {
+	DropIndex(ctx context.Context, kb string, deleteData bool) error
+}
+
+func ensureKnowledgeBaseIndex(ctx context.Context, id string) error {
+	redisRag, ok := getRAGDatabase().(*rag.RedisRAG)
+	if !ok {
+		return nil
+	}
+	return redisRag.EnsureIndex(ctx, id, IndexConfig())
+}
+
+// CreateKnowledgeBase 创建知识库，创建者为所有者
+func CreateKnowledgeBase(ctx context.Context, userName string, req KnowledgeBaseRequest) (*model.KnowledgeBase, code.Code) {
+	if !req.normalize() {
+		return nil, code.CodeInvalidParams
+	}
+	kb, err := knowledgebase.CreateKnowledgeBase(&model.KnowledgeBase{
+		ID:          uuid.New().String(),
+		Name:        req.Name,
+		Description: req.Description,
+		OwnerName:   userName,
+		Visibility:  req.Visibility,
+	})
+	if err != nil {
+		log.Println("CreateKnowledgeBase error:", err)
+		return nil, code.CodeServerBusy
+	}
+	// 索引在首次上传时也会创建，这里失败不影响知识库本身
+	if err := ensureKnowledgeBaseIndex(ctx, kb.ID); err != nil {
+		log.Printf("CreateKnowledgeBase kb=%s EnsureIndex error: %v", kb.ID, err)
+	}
+	return kb, code.CodeSuccess
+}
+
+// GetKnowledgeBases 用户可用的知识库，默认知识库排在最前
+func GetKnowledgeBases(userName string) ([]model.KnowledgeBaseInfo, code.Code) {
+	kbs, err := knowledgebase.GetAccessibleKnowledgeBases(userName)
+	if err != nil {
+		log.Println("GetKnowledgeBases GetAccessibleKnowledgeBases error:", err)
+		return nil, code.CodeServerBusy
+	}
+
+	infos := make([]model.KnowledgeBaseInfo, 0, len(kbs)+1)
+	infos = append(infos, defaultKnowledgeBaseInfo())
+	for _, kb := range kbs {
+		info := model.KnowledgeBaseInfo{KnowledgeBase: kb, IsOwner: kb.OwnerName == userName}
+		if info.IsOwner {
+			shares, err := knowledgebase.GetShares(kb.ID)
+			if err != nil {
+				log.Println("GetKnowledgeBases GetShares error:", err)
+				return nil, code.CodeServerBusy
+			}
+			info.SharedWith = shares
+		}
+		infos = append(infos, info)
+	}
+	return infos, code.CodeSuccess
+}
+
+// UpdateKnowledgeBase 修改知识库名称、描述与可见性
+func UpdateKnowledgeBase(userName string, id string, req KnowledgeBaseRequest) (*model.KnowledgeBase, code.Code) {
+	if !req.normalize() {
+		return nil, code.CodeInvalidParams
+	}
+	kb, code_ := getOwnedKnowledgeBase(userName, id)
+	if code_ != code.CodeSuccess {
+		return nil, code_
+	}
+	return updateKnowledgeBase(kb, req)
+}
+
+// AdminUpdateKnowledgeBase 管理员修改任意用户的知识库
+func AdminUpdateKnowledgeBase(id string, req KnowledgeBaseRequest) (*model.KnowledgeBase, code.Code) {
+	if !req.normalize() {
+		return nil, code.CodeInvalidParams
+	}
+	kb, code_ := getKnowledgeBase(id)
+	if code_ != code.CodeSuccess {
+		return nil, code_
+	}
+	return updateKnowledgeBase(kb, req)
+}
+
+func updateKnowledgeBase(kb *model.KnowledgeBase, req KnowledgeBaseRequest) (*model.KnowledgeBase, code.Code) {
+	kb.Name = req.Name
+	kb.Description = req.Description
+	kb.Visibility = req.Visibility
+	if err := knowledgebase.UpdateKnowledgeBase(kb); err != nil {
+		log.Println("UpdateKnowledgeBase error:", err)
+		return nil, code.CodeServerBusy
+	}
+	return kb, code.CodeSuccess
+}
+
+// DeleteKnowledgeBase 删除知识库，同时删除向量索引和数据
+func DeleteKnowledgeBase(ctx context.Context, userName string, id string) code.Code {
+	if _, code_ := getOwnedKnowledgeBase(userName, id); code_ != code.CodeSuccess {
+		return code_
+	}
+	return deleteKnowledgeBase(ctx, id)
+}
+
+// AdminDeleteKnowledgeBase 管理员删除任意用户的知识库
+func AdminDeleteKnowledgeBase(ctx context.Context, id string) code.Code {
+	if _, code_ := getKnowledgeBase(id); code_ != code.CodeSuccess {
+		return code_
+	}
+	return deleteKnowledgeBase(ctx, id)
+}
+
+func deleteKnowledgeBase(ctx context.Context, id string) code.Code {
+	if dropper, ok := getRAGDatabase().(indexDropper); ok {
+		if err := dropper.DropIndex(ctx, id, true); err != nil {
+			log.Printf("DeleteKnowledgeBase kb=%s DropIndex error: %v", id, err)
+			return code.CodeServerBusy
+		}
+	}
+	if err := knowledgebase.DeleteKnowledgeBase(id); err != nil {
+		log.Println("DeleteKnowledgeBase error:", err)
+		return code.CodeServerBusy
+	}
+	return code.CodeSuccess
+}
+
+// ShareKnowledgeBase 把知识库分享给其他用户
+func ShareKnowledgeBase(userName string, id string, target string) code.Code {
+	target = strings.TrimSpace(target)
+	if target == "" || target == userName {
+		return code.CodeInvalidParams
+	}
+	if _, code_ := getOwnedKnowledgeBase(userName, id); code_ != code.CodeSuccess {
+		return code_
+	}
+	if ok, _ := user.IsExistUser(target); !ok {
+		return code.CodeUserNotExist
+	}
+	if err := knowledgebase.AddShare(id, target); err != nil {
+		log.Println("ShareKnowledgeBase AddShare error:", err)
+		return code.CodeServerBusy
+	}
+	return code.CodeSuccess
+}
+
+// UnshareKnowledgeBase 取消分享
+func UnshareKnowledgeBase(userName string, id string, target string) code.Code {
+	if _, code_ := getOwnedKnowledgeBase(userName, id); code_ != code.CodeSuccess {
+		return code_
+	}
+	if err := knowledgebase.RemoveShare(id, target); err != nil {
+		log.Println("UnshareKnowledgeBase RemoveShare error:", err)
+		return code.CodeServerBusy
+	}
+	return code.CodeSuccess
+}
+
+// ResolveReadableKnowledgeBases 校验用户能否检索这些知识库，返回去重后的ID
+// 任意一个不存在或无权访问时返回对应错误码
+func ResolveReadableKnowledgeBases(userName string, ids []string) ([]string, code.Code) {
+	readable, denied, err := splitReadableKnowledgeBases(userName, ids)
+	if err != nil {
+		log.Println("ResolveReadableKnowledgeBases error:", err)
+		return nil, code.CodeServerBusy
+	}
+	if denied != code.CodeSuccess {
+		return nil, denied
+	}
+	return readable, code.CodeSuccess
+}
+
+// FilterReadableKnowledgeBases 过滤掉已删除或不再有权访问的知识库
+// 用于对话时重新校验会话绑定的知识库，分享被取消后立即生效
+func FilterReadableKnowledgeBases(userName string, ids []string) []string {
+	readable, _, err := splitReadableKnowledgeBases(userName, ids)
+	if err != nil {
+		log.Println("FilterReadableKnowledgeBases error:", err)
+		return nil
+	}
+	return readable
+}
+
+func splitReadableKnowledgeBases(userName string, ids []string) ([]string, code.Code, error) {
+	denied := code.CodeSuccess
+	readable := make([]string, 0, len(ids))
+	lookup := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		if id == rag.DefaultKnowledgeBase {
+			readable = append(readable, id)
+			continue
+		}
+		lookup = append(lookup, id)
+	}
+
+	kbs, err := knowledgebase.GetKnowledgeBasesByIDs(lookup)
+	if err != nil {
+		return nil, denied, err
+	}
+	found := make(map[string]*model.KnowledgeBase, len(kbs))
+	for i := range kbs {
+		found[kbs[i].ID] = &kbs[i]
+	}
+	for _, id := range lookup {
+		kb, ok := found[id]
+		if !ok {
+			denied = code.CodeRecordNotFound
+			continue
+		}
+		ok, err := canReadKnowledgeBase(userName, kb)
+		if err != nil {
+			return nil, denied, err
+		}
+		if !ok {
+			denied = code.CodeForbidden
+			continue
+		}
+		readable = append(readable, id)
+	}
+	return readable, denied, nil
+}
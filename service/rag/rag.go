@@ -0,0 +1,245 @@
+package rag
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/common/rag"
+	"GopherAI/common/rag/ingest"
+	"GopherAI/config"
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMaxUploadSizeMB = 10
+
+var (
+	ragDB   rag.RAGDatabase
+	ragOnce sync.Once
+)
+
+// DocumentUpload 上传文档的参数，分块参数为空时使用配置
+type DocumentUpload struct {
+	KnowledgeBaseID string
+	DocumentID      string // 为空时由文件名生成，相同ID的文档会被替换
+	FileName        string
+	Data            []byte
+	Format          string
+	Title           string
+	Tags            []string
+	ChunkStrategy   string
+	ChunkSize       int
+	ChunkOverlap    *int // nil 表示未指定，0 表示不重叠
+}
+
+// 按配置创建 RAG 数据库，首次使用时才连接
+// 启动时 InitIndex 会先调用一次，file 后端的数据文件无法读取时进程在启动阶段退出
+func getRAGDatabase() rag.RAGDatabase {
+	ragOnce.Do(func() {
+		conf := config.GetConfig()
+		switch strings.ToLower(strings.TrimSpace(conf.RAGConfig.RAGBackend)) {
+		case rag.BackendMemory:
+			ragDB = rag.NewMemoryRAG(newEmbedder(nil))
+		case rag.BackendFile:
+			fileRag, err := rag.OpenFileRAG(conf.RAGConfig.RAGFilePath, newEmbedder(nil))
+			if err != nil {
+				// 退回内存存储会让之后写入的数据在重启后丢失，文件无法读取时直接拒绝启动
+				log.Fatalf("getRAGDatabase OpenFileRAG %s error: %v", conf.RAGConfig.RAGFilePath, err)
+			}
+			ragDB = fileRag
+		case rag.BackendVikingDB:
+			viking := conf.VikingDBConfig
+			var embedder rag.Embedder
+			if strings.ToLower(strings.TrimSpace(viking.Embedding)) == rag.VikingDBEmbeddingEmbedder {
+				embedder = newEmbedder(nil)
+			}
+			ragDB = rag.NewVikingDBRAG(rag.VikingDBConfig{
+				Host:       viking.Host,
+				Region:     viking.Region,
+				Scheme:     viking.Scheme,
+				AK:         viking.AK,
+				SK:         viking.SK,
+				Collection: viking.Collection,
+				Index:      viking.Index,
+			}, embedder)
+		default:
+			redisRag := rag.NewRedisRAG(rag.RedisConfig{
+				Addr:     conf.RAGConfig.RAGRedisAddr,
+				Password: conf.RAGConfig.RAGRedisPassword,
+				DB:       conf.RAGConfig.RAGRedisDB,
+			}, nil)
+			redisRag.Embedder = newEmbedder(redisRag)
+			ragDB = redisRag
+		}
+	})
+	return ragDB
+}
+
+// newEmbedder 按配置创建向量模型，配置错误时退回 ollamaConfig，首次向量化时再报错
+func newEmbedder(redisRag *rag.RedisRAG) rag.Embedder {
+	embedder, err := rag.NewEmbedder(EmbeddingConfig(redisRag))
+	if err != nil {
+		log.Println("getRAGDatabase NewEmbedder error:", err)
+		conf := config.GetConfig()
+		return &rag.OllamaEmbedder{BaseURL: conf.OllamaConfig.BaseURL, Model: conf.OllamaConfig.ModelName}
+	}
+	return embedder
+}
+
+// EmbeddingConfig 按配置生成向量模型参数，redis 缓存使用 redisRag 的连接，redisRag 为 nil 时退回内存缓存
+func EmbeddingConfig(redisRag *rag.RedisRAG) rag.EmbeddingConfig {
+	conf := config.GetConfig()
+	embeddingConf := rag.EmbeddingConfig{
+		Provider:    conf.EmbeddingProvider,
+		BaseURL:     conf.EmbeddingBaseURL,
+		Model:       conf.EmbeddingModel,
+		APIKey:      conf.EmbeddingAPIKey,
+		Dimensions:  conf.EmbeddingDimensions,
+		BatchSize:   conf.EmbeddingBatchSize,
+		Concurrency: conf.EmbeddingConcurrency,
+		Timeout:     time.Duration(conf.EmbeddingTimeout) * time.Second,
+	}
+	// 未配置 embeddingConfig 时沿用 ollamaConfig
+	if embeddingConf.Provider == "" || embeddingConf.Provider == rag.EmbeddingProviderOllama {
+		if embeddingConf.BaseURL == "" {
+			embeddingConf.BaseURL = conf.OllamaConfig.BaseURL
+		}
+		if embeddingConf.Model == "" {
+			embeddingConf.Model = conf.OllamaConfig.ModelName
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(conf.EmbeddingCache)) {
+	case "memory":
+		embeddingConf.Cache = rag.NewMemoryEmbeddingCache(conf.EmbeddingCacheSize)
+	case "redis":
+		if redisRag == nil {
+			embeddingConf.Cache = rag.NewMemoryEmbeddingCache(conf.EmbeddingCacheSize)
+			break
+		}
+		embeddingConf.Cache = &rag.RedisEmbeddingCache{
+			Client: redisRag.RedisClient,
+			TTL:    time.Duration(conf.EmbeddingCacheTTL) * time.Hour,
+		}
+	}
+	return embeddingConf
+}
+
+// IndexConfig 按配置生成向量索引参数
+func IndexConfig() rag.IndexConfig {
+	conf := config.GetConfig().RAGConfig
+	return rag.IndexConfig{
+		Algorithm:      conf.RAGIndexAlgorithm,
+		DistanceMetric: conf.RAGDistanceMetric,
+		M:              conf.RAGHNSWM,
+		EFConstruction: conf.RAGHNSWEFConstruction,
+		EFRuntime:      conf.RAGHNSWEFRuntime,
+		BlockSize:      conf.RAGFlatBlockSize,
+	}
+}
+
+// InitIndex 启动时打开 RAG 数据库，并创建或迁移向量索引
+func InitIndex(ctx context.Context) error {
+	redisRag, ok := getRAGDatabase().(*rag.RedisRAG)
+	if !ok {
+		return nil
+	}
+	return redisRag.EnsureIndex(ctx, rag.DefaultKnowledgeBase, IndexConfig())
+}
+
+// MaxUploadBytes 上传文档的大小上限
+func MaxUploadBytes() int64 {
+	size := config.GetConfig().RAGConfig.RAGMaxUploadSize
+	if size <= 0 {
+		size = defaultMaxUploadSizeMB
+	}
+	return size << 20
+}
+
+// ChunkOptions 请求中的分块参数优先，未指定的使用配置
+// overlap 为 nil 表示未指定，0 表示不重叠；重叠长度是否小于分块大小在补全默认值后由 ingest 校验
+func ChunkOptions(strategy string, size int, overlap *int) (ingest.ChunkOptions, error) {
+	conf := config.GetConfig().RAGConfig
+	if strings.TrimSpace(strategy) == "" {
+		strategy = conf.RAGChunkStrategy
+	}
+	if size <= 0 {
+		size = conf.RAGChunkSize
+	}
+	if size <= 0 {
+		size = ingest.DefaultChunkSize
+	}
+	if overlap == nil {
+		overlap = conf.RAGChunkOverlap
+	}
+	resolved := ingest.DefaultOverlapFor(size)
+	if overlap != nil {
+		resolved = *overlap
+	}
+	parsed, err := ingest.ParseStrategy(strategy)
+	if err != nil {
+		return ingest.ChunkOptions{}, err
+	}
+	opts := ingest.ChunkOptions{Strategy: parsed, Size: size, Overlap: resolved}
+	if err := opts.Validate(); err != nil {
+		return ingest.ChunkOptions{}, err
+	}
+	return opts, nil
+}
+
+// UploadDocument 解析、分块并写入知识库，同一知识库中文档ID相同时替换原文档
+// 只有知识库所有者可以上传，默认知识库只能通过导入脚本写入
+func UploadDocument(ctx context.Context, userName string, upload DocumentUpload) (*ingest.Result, code.Code) {
+	if int64(len(upload.Data)) > MaxUploadBytes() {
+		return nil, code.CodeInvalidParams
+	}
+	if strings.TrimSpace(upload.KnowledgeBaseID) == "" {
+		return nil, code.CodeInvalidParams
+	}
+	if _, code_ := getOwnedKnowledgeBase(userName, upload.KnowledgeBaseID); code_ != code.CodeSuccess {
+		return nil, code_
+	}
+
+	var format ingest.Format
+	if strings.TrimSpace(upload.Format) != "" {
+		parsed, err := ingest.ParseFormat(upload.Format)
+		if err != nil {
+			log.Println("UploadDocument ParseFormat error:", err)
+			return nil, code.CodeInvalidParams
+		}
+		format = parsed
+	}
+
+	opts, err := ChunkOptions(upload.ChunkStrategy, upload.ChunkSize, upload.ChunkOverlap)
+	if err != nil {
+		log.Println("UploadDocument ChunkOptions error:", err)
+		return nil, code.CodeInvalidParams
+	}
+
+	doc, err := ingest.Parse(upload.FileName, upload.Data, format)
+	if err != nil {
+		log.Printf("UploadDocument user=%s parse %s error: %v", userName, upload.FileName, err)
+		return nil, code.CodeInvalidParams
+	}
+	if strings.TrimSpace(upload.Title) != "" {
+		doc.Title = strings.TrimSpace(upload.Title)
+	}
+	doc.ID = strings.TrimSpace(upload.DocumentID)
+	if doc.ID != "" && !rag.ValidDocumentID(doc.ID) {
+		return nil, code.CodeInvalidParams
+	}
+	doc.Tags = upload.Tags
+	doc.KnowledgeBase = upload.KnowledgeBaseID
+
+	if err := ensureKnowledgeBaseIndex(ctx, upload.KnowledgeBaseID); err != nil {
+		log.Printf("UploadDocument kb=%s EnsureIndex error: %v", upload.KnowledgeBaseID, err)
+		return nil, code.CodeServerBusy
+	}
+	result, err := ingest.NewPipeline(getRAGDatabase(), opts).Ingest(ctx, doc)
+	if err != nil {
+		log.Printf("UploadDocument user=%s ingest %s error: %v", userName, upload.FileName, err)
+		return result, code.CodeServerBusy
+	}
+	log.Printf("UploadDocument user=%s kb=%s doc=%s source=%s chunks=%d", userName, upload.KnowledgeBaseID, result.DocumentID, result.Source, result.Stored)
+	return result, code.CodeSuccess
+}
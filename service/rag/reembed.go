@@ -0,0 +1,79 @@
+package rag
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/common/rag"
+	"GopherAI/model"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// reembedder 支持重新向量化的 RAG 数据库
+type reembedder interface {
+	ReembedAll(ctx context.Context, kb string, conf rag.IndexConfig) (int, error)
+}
+
+// 重新向量化任务只记录在当前进程中，同一知识库同时只运行一个任务
+var reembedTasks = struct {
+	mu    sync.Mutex
+	tasks map[string]*model.ReembedTask
+}{tasks: make(map[string]*model.ReembedTask)}
+
+// StartReembedTask 在后台用当前向量模型重新向量化知识库，更换向量模型后使用
+// 重新向量化期间知识库仍使用旧索引检索；该知识库已有任务在运行时返回正在运行的任务
+func StartReembedTask(kbID string, startedBy string) (model.ReembedTask, code.Code) {
+	if kbID != rag.DefaultKnowledgeBase {
+		if _, code_ := getKnowledgeBase(kbID); code_ != code.CodeSuccess {
+			return model.ReembedTask{}, code_
+		}
+	}
+	db, ok := getRAGDatabase().(reembedder)
+	if !ok {
+		return model.ReembedTask{}, code.CodeServerBusy
+	}
+
+	reembedTasks.mu.Lock()
+	defer reembedTasks.mu.Unlock()
+	if task, ok := reembedTasks.tasks[kbID]; ok && task.State == model.ReembedStateRunning {
+		return *task, code.CodeSuccess
+	}
+	task := &model.ReembedTask{
+		KnowledgeBaseID: kbID,
+		State:           model.ReembedStateRunning,
+		StartedBy:       startedBy,
+		StartedAt:       time.Now().Unix(),
+	}
+	reembedTasks.tasks[kbID] = task
+	go runReembedTask(db, kbID)
+	return *task, code.CodeSuccess
+}
+
+func runReembedTask(db reembedder, kbID string) {
+	count, err := db.ReembedAll(context.Background(), kbID, IndexConfig())
+
+	reembedTasks.mu.Lock()
+	defer reembedTasks.mu.Unlock()
+	task := reembedTasks.tasks[kbID]
+	task.Reembedded = count
+	task.FinishedAt = time.Now().Unix()
+	if err != nil {
+		log.Printf("ReembedAll kb=%s failed after %d chunks: %v", kbID, count, err)
+		task.State = model.ReembedStateFailed
+		task.ErrorMessage = "重新向量化失败，可以重新发起。"
+		return
+	}
+	task.State = model.ReembedStateSucceeded
+}
+
+// GetReembedTask 知识库最近一次重新向量化任务
+func GetReembedTask(kbID string) (model.ReembedTask, code.Code) {
+	reembedTasks.mu.Lock()
+	defer reembedTasks.mu.Unlock()
+	task, ok := reembedTasks.tasks[kbID]
+	if !ok {
+		return model.ReembedTask{}, code.CodeRecordNotFound
+	}
+	return *task, code.CodeSuccess
+}
@@ -0,0 +1,110 @@
+package rag
+
+import (
+	"GopherAI/common/code"
+	"GopherAI/common/rag"
+	"GopherAI/config"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+)
+
+const (
+	rerankerCrossEncoder = "cross-encoder"
+	rerankerLLM          = "llm"
+)
+
+var (
+	reranker     rag.Reranker
+	rerankerOnce sync.Once
+)
+
+// SearchRequest 检索参数，未指定的使用配置
+type SearchRequest struct {
+	Query            string
+	KnowledgeBaseIDs []string
+	K                int
+	Mode             string
+	Sources          []string
+	Tags             []string
+	MinScore         float64
+}
+
+// 按配置创建重排器，未配置时返回 nil
+func getReranker() rag.Reranker {
+	rerankerOnce.Do(func() {
+		conf := config.GetConfig().RAGConfig
+		switch strings.ToLower(strings.TrimSpace(conf.RAGReranker)) {
+		case "":
+		case rerankerCrossEncoder:
+			reranker = &rag.CrossEncoderReranker{
+				URL:    conf.RAGRerankURL,
+				Model:  conf.RAGRerankModel,
+				APIKey: conf.RAGRerankKey,
+			}
+		case rerankerLLM:
+			llm, err := openai.NewChatModel(context.Background(), &openai.ChatModelConfig{
+				BaseURL: os.Getenv("OPENAI_BASE_URL_ALIYUN"),
+				Model:   os.Getenv("OPENAI_MODEL_NAME"),
+				APIKey:  os.Getenv("OPENAI_API_KEY"),
+			})
+			if err != nil {
+				log.Println("getReranker NewChatModel error:", err)
+				return
+			}
+			reranker = &rag.LLMReranker{Model: llm}
+		default:
+			log.Println("getReranker unsupported reranker:", conf.RAGReranker)
+		}
+	})
+	return reranker
+}
+
+// SearchOptions 按配置生成检索参数，请求中的参数优先
+func SearchOptions(mode string, k int, minScore float64, filter rag.Filter) rag.SearchOptions {
+	conf := config.GetConfig().RAGConfig
+	if strings.TrimSpace(mode) == "" {
+		mode = conf.RAGSearchMode
+	}
+	if minScore == 0 {
+		minScore = conf.RAGMinScore
+	}
+	return rag.SearchOptions{
+		Mode:           mode,
+		K:              k,
+		CandidateK:     conf.RAGCandidateK,
+		RRFK:           conf.RAGRRFK,
+		MinScore:       minScore,
+		DistanceMetric: conf.RAGDistanceMetric,
+		Filter:         filter,
+		Reranker:       getReranker(),
+		RerankTopN:     conf.RAGRerankTopN,
+	}
+}
+
+// Search 在用户有权检索的知识库中检索，不指定知识库时只检索默认知识库
+func Search(ctx context.Context, userName string, req SearchRequest) ([]*rag.RagReturnedData, code.Code) {
+	if strings.TrimSpace(req.Query) == "" || req.MinScore < 0 || req.MinScore > 1 {
+		return nil, code.CodeInvalidParams
+	}
+	kbs, code_ := ResolveReadableKnowledgeBases(userName, req.KnowledgeBaseIDs)
+	if code_ != code.CodeSuccess {
+		return nil, code_
+	}
+
+	opts := SearchOptions(req.Mode, req.K, req.MinScore, rag.Filter{Sources: req.Sources, Tags: req.Tags})
+	results, err := getRAGDatabase().Search(ctx, req.Query, rag.Scope{KnowledgeBases: kbs}, opts)
+	if err != nil {
+		log.Printf("Search user=%s error: %v", userName, err)
+		if errors.Is(err, rag.ErrUnsupportedSearchMode) {
+			return nil, code.CodeInvalidParams
+		}
+		return nil, code.CodeServerBusy
+	}
+	return results, code.CodeSuccess
+}
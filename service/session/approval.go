@@ -0,0 +1,139 @@
+package session
+
+import (
+	"GopherAI/common/aihelper"
+	"GopherAI/common/code"
+	myredis "GopherAI/common/redis"
+	"GopherAI/config"
+	"GopherAI/model"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+// 生成回复出错时判断是否为等待确认的工具调用，是则保存审批记录并返回
+func handleGenerateError(userName string, sessionID string, modelType string, err error) (*model.ToolApproval, code.Code) {
+	var pending *aihelper.ToolApprovalPendingError
+	if !errors.As(err, &pending) {
+		return nil, code.AIModelFail
+	}
+
+	approval := &model.ToolApproval{
+		ApprovalID: pending.CheckPointID,
+		SessionID:  sessionID,
+		UserName:   userName,
+		ModelType:  modelType,
+		Tools:      pending.Tools,
+		ToolCalls:  pending.ToolCalls,
+		CreatedAt:  time.Now().Unix(),
+	}
+	if err := saveToolApproval(approval); err != nil {
+		log.Println("handleGenerateError saveToolApproval error:", err)
+		return nil, code.CodeServerBusy
+	}
+	return approval, code.CodeSuccess
+}
+
+func saveToolApproval(approval *model.ToolApproval) error {
+	data, err := json.Marshal(approval)
+	if err != nil {
+		return err
+	}
+	return myredis.SetToolApproval(approval.ApprovalID, approval.UserName, data)
+}
+
+// ListToolApprovals 获取用户所有等待确认的工具调用
+func ListToolApprovals(userName string) ([]model.ToolApproval, code.Code) {
+	ids, err := myredis.GetUserToolApprovalIDs(userName)
+	if err != nil {
+		log.Println("ListToolApprovals GetUserToolApprovalIDs error:", err)
+		return nil, code.CodeServerBusy
+	}
+
+	approvals := make([]model.ToolApproval, 0, len(ids))
+	for _, id := range ids {
+		data, ok, err := myredis.GetToolApproval(id)
+		if err != nil {
+			log.Println("ListToolApprovals GetToolApproval error:", err)
+			return nil, code.CodeServerBusy
+		}
+		if !ok {
+			// 审批记录已过期，顺便清理索引
+			_ = myredis.RemoveUserToolApprovalID(userName, id)
+			continue
+		}
+		var approval model.ToolApproval
+		if err := json.Unmarshal(data, &approval); err != nil {
+			log.Println("ListToolApprovals Unmarshal error:", err)
+			continue
+		}
+		approvals = append(approvals, approval)
+	}
+	return approvals, code.CodeSuccess
+}
+
+// DecideToolApproval 用户确认或拒绝工具调用，并从检查点恢复生成
+// 如果恢复后又遇到需要确认的工具，会返回新的审批记录
+func DecideToolApproval(userName string, approvalID string, approved bool, reason string) (*model.Message, *model.ToolApproval, code.Code) {
+	data, ok, err := myredis.GetToolApproval(approvalID)
+	if err != nil {
+		log.Println("DecideToolApproval GetToolApproval error:", err)
+		return nil, nil, code.CodeServerBusy
+	}
+	if !ok {
+		return nil, nil, code.CodeRecordNotFound
+	}
+	var approval model.ToolApproval
+	if err := json.Unmarshal(data, &approval); err != nil {
+		log.Println("DecideToolApproval Unmarshal error:", err)
+		return nil, nil, code.CodeServerBusy
+	}
+	if approval.UserName != userName {
+		return nil, nil, code.CodeForbidden
+	}
+
+	// 取出记录，避免同一审批被重复处理
+	if _, ok, err := myredis.TakeToolApproval(approvalID, userName); err != nil || !ok {
+		if err != nil {
+			log.Println("DecideToolApproval TakeToolApproval error:", err)
+			return nil, nil, code.CodeServerBusy
+		}
+		return nil, nil, code.CodeRecordNotFound
+	}
+
+	manager := aihelper.GetGlobalManager()
+	modelConfig := map[string]interface{}{
+		"apiKey": "your-api-key", // TODO: 从配置中获取
+	}
+	helper, err := manager.GetOrCreateAIHelper(userName, approval.SessionID, approval.ModelType, modelConfig)
+	if err != nil {
+		log.Println("DecideToolApproval GetOrCreateAIHelper error:", err)
+		_ = saveToolApproval(&approval)
+		return nil, nil, code.AIModelFail
+	}
+
+	interruptIDs := make([]string, 0, len(approval.ToolCalls))
+	for _, call := range approval.ToolCalls {
+		interruptIDs = append(interruptIDs, call.InterruptID)
+	}
+	result := &aihelper.ToolApprovalResult{Approved: approved, Reason: reason}
+	opts := []aihelper.ToolOption{
+		aihelper.WithTools(approval.Tools...),
+		aihelper.WithConfirmTools(config.GetConfig().ToolConfig.ConfirmTools...),
+		knowledgeBaseToolOption(userName, approval.SessionID),
+	}
+
+	aiResponse, err := helper.ResumeResponse(userName, ctx, approval.ApprovalID, interruptIDs, result, opts...)
+	if err != nil {
+		next, code_ := handleGenerateError(userName, approval.SessionID, approval.ModelType, err)
+		if code_ != code.CodeSuccess {
+			log.Println("DecideToolApproval ResumeResponse error:", err)
+			// 恢复失败时保留审批记录，允许用户重试
+			_ = saveToolApproval(&approval)
+		}
+		return nil, next, code_
+	}
+
+	return aiResponse, nil, code.CodeSuccess
+}
@@ -0,0 +1,107 @@
+package session
+
+import (
+	"GopherAI/common/aihelper"
+	"GopherAI/common/code"
+	image_recognizer "GopherAI/common/image"
+	"GopherAI/common/imagestore"
+	"GopherAI/model"
+	image_service "GopherAI/service/image"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strings"
+)
+
+// 单条消息最多附带的图片数
+const maxChatImages = 4
+
+// MaxChatRequestBytes 聊天请求体的大小上限：最多 maxChatImages 张 base64 编码的图片，另加 1MB 文字
+func MaxChatRequestBytes() int64 {
+	return maxChatImages*(image_service.MaxUploadBytes()/3+1)*4 + 1<<20
+}
+
+// normalizeChatImages 校验聊天附带的图片，去掉 data URL 前缀，
+// 并与图片识别使用相同的预处理：按内容识别格式、摆正方向、缩小尺寸、按配置去除元数据
+// 处理后的图片保存到 imagestore，返回的图片只带引用，不再带 base64 数据
+func normalizeChatImages(userName string, images []model.MessageImage) ([]model.MessageImage, code.Code) {
+	if len(images) == 0 {
+		return nil, code.CodeSuccess
+	}
+	if len(images) > maxChatImages {
+		return nil, code.CodeInvalidParams
+	}
+	maxSize := image_service.MaxUploadBytes()
+	opts := image_service.PrepareOptions()
+	out := make([]model.MessageImage, 0, len(images))
+	for _, img := range images {
+		data := strings.TrimSpace(img.Data)
+		// data:image/png;base64,xxxx
+		if strings.HasPrefix(data, "data:") {
+			comma := strings.Index(data, ",")
+			if comma < 0 || !strings.HasSuffix(data[:comma], ";base64") {
+				return nil, code.CodeInvalidParams
+			}
+			data = data[comma+1:]
+		}
+		if data == "" {
+			return nil, code.CodeInvalidParams
+		}
+		if int64(base64.StdEncoding.DecodedLen(len(data))) > maxSize+3 {
+			return nil, code.CodeImageTooLarge
+		}
+		raw, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, code.CodeInvalidParams
+		}
+		if int64(len(raw)) > maxSize {
+			return nil, code.CodeImageTooLarge
+		}
+		prepared, err := image_recognizer.Prepare(raw, opts)
+		if err != nil {
+			log.Printf("normalizeChatImages prepare error: %v", err)
+			if code_, ok := image_service.PrepareErrorCode(err); ok {
+				return nil, code_
+			}
+			return nil, code.CodeServerBusy
+		}
+		key, err := imagestore.Put(userName, prepared.Data, prepared.MIMEType)
+		if err != nil {
+			log.Printf("normalizeChatImages save image error: %v", err)
+			return nil, code.CodeServerBusy
+		}
+		out = append(out, model.MessageImage{
+			MIMEType: prepared.MIMEType,
+			Key:      key,
+			Name:     img.Name,
+		})
+	}
+	return out, code.CodeSuccess
+}
+
+// GetChatImage 读取聊天消息引用的图片，只能读取自己上传的图片
+func GetChatImage(userName string, key string) ([]byte, string, code.Code) {
+	if !imagestore.ValidKey(key) {
+		return nil, "", code.CodeInvalidParams
+	}
+	if imagestore.Owner(key) != userName {
+		return nil, "", code.CodeForbidden
+	}
+	data, err := imagestore.Get(key)
+	if err != nil {
+		if errors.Is(err, imagestore.ErrNotFound) {
+			return nil, "", code.CodeRecordNotFound
+		}
+		log.Printf("GetChatImage %s error: %v", key, err)
+		return nil, "", code.CodeServerBusy
+	}
+	return data, imagestore.MIMEType(key), code.CodeSuccess
+}
+
+// checkVision 模型不支持图片输入时拒绝带图片的消息
+func checkVision(helper *aihelper.AIHelper, images []model.MessageImage) code.Code {
+	if len(images) > 0 && !helper.SupportsVision() {
+		return code.AIModelNoVision
+	}
+	return code.CodeSuccess
+}
@@ -0,0 +1,65 @@
+package session
+
+import (
+	"GopherAI/common/aihelper"
+	"GopherAI/common/code"
+	"GopherAI/dao/session"
+	rag_service "GopherAI/service/rag"
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// 获取属于当前用户的会话
+func getOwnedSession(userName string, sessionID string) ([]string, code.Code) {
+	s, err := session.GetSessionByID(sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, code.CodeRecordNotFound
+		}
+		log.Println("getOwnedSession GetSessionByID error:", err)
+		return nil, code.CodeServerBusy
+	}
+	if s.UserName != userName {
+		return nil, code.CodeForbidden
+	}
+	return s.KnowledgeBases, code.CodeSuccess
+}
+
+// GetSessionKnowledgeBases 获取会话关联的知识库
+func GetSessionKnowledgeBases(userName string, sessionID string) ([]string, code.Code) {
+	kbs, code_ := getOwnedSession(userName, sessionID)
+	if code_ != code.CodeSuccess {
+		return nil, code_
+	}
+	if kbs == nil {
+		kbs = []string{}
+	}
+	return kbs, code.CodeSuccess
+}
+
+// UpdateSessionKnowledgeBases 修改会话关联的知识库，只能关联有权检索的知识库
+func UpdateSessionKnowledgeBases(userName string, sessionID string, ids []string) ([]string, code.Code) {
+	if _, code_ := getOwnedSession(userName, sessionID); code_ != code.CodeSuccess {
+		return nil, code_
+	}
+	kbs, code_ := rag_service.ResolveReadableKnowledgeBases(userName, ids)
+	if code_ != code.CodeSuccess {
+		return nil, code_
+	}
+	if err := session.UpdateSessionKnowledgeBases(sessionID, kbs); err != nil {
+		log.Println("UpdateSessionKnowledgeBases error:", err)
+		return nil, code.CodeServerBusy
+	}
+	return kbs, code.CodeSuccess
+}
+
+// 对话时重新校验会话关联的知识库，删除或取消分享的知识库不再参与检索
+func knowledgeBaseToolOption(userName string, sessionID string) aihelper.ToolOption {
+	kbs, code_ := getOwnedSession(userName, sessionID)
+	if code_ != code.CodeSuccess {
+		return aihelper.WithKnowledgeBases()
+	}
+	return aihelper.WithKnowledgeBases(rag_service.FilterReadableKnowledgeBases(userName, kbs)...)
+}
@@ -0,0 +1,77 @@
+package session
+
+import (
+	"GopherAI/common/aihelper"
+	"GopherAI/common/code"
+	"GopherAI/dao/modelprofile"
+	"GopherAI/model"
+	"errors"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// 管理员没有配置名称时使用的默认名称
+var defaultModelNames = map[string]string{
+	"1": "OpenAI",
+	"2": "Ollama",
+	"3": "视觉模型",
+}
+
+// ListModelProfiles 所有已注册模型的配置，没有保存过配置的模型默认启用且不限制角色
+func ListModelProfiles() ([]model.ModelProfile, error) {
+	saved, err := modelprofile.GetModelProfiles()
+	if err != nil {
+		return nil, err
+	}
+	byType := make(map[string]model.ModelProfile, len(saved))
+	for _, p := range saved {
+		byType[p.ModelType] = p
+	}
+
+	modelTypes := aihelper.GetGlobalFactory().ModelTypes()
+	profiles := make([]model.ModelProfile, 0, len(modelTypes))
+	for _, modelType := range modelTypes {
+		p, ok := byType[modelType]
+		if !ok {
+			p = model.ModelProfile{ModelType: modelType, Enabled: true}
+		}
+		if p.Name == "" {
+			p.Name = defaultModelNames[modelType]
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// GetChatModels 当前角色可以使用的聊天模型
+func GetChatModels(role string) ([]model.ModelProfile, code.Code) {
+	profiles, err := ListModelProfiles()
+	if err != nil {
+		log.Println("GetChatModels ListModelProfiles error:", err)
+		return nil, code.CodeServerBusy
+	}
+	available := make([]model.ModelProfile, 0, len(profiles))
+	for i := range profiles {
+		if profiles[i].AllowsRole(role) {
+			available = append(available, profiles[i])
+		}
+	}
+	return available, code.CodeSuccess
+}
+
+// checkModelAllowed 模型被管理员停用或不允许当前角色使用时返回 CodeForbidden
+func checkModelAllowed(role string, modelType string) code.Code {
+	profile, err := modelprofile.GetModelProfile(modelType)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return code.CodeSuccess
+		}
+		log.Println("checkModelAllowed GetModelProfile error:", err)
+		return code.CodeServerBusy
+	}
+	if !profile.AllowsRole(role) {
+		return code.CodeForbidden
+	}
+	return code.CodeSuccess
+}
@@ -6,6 +6,7 @@ import (
 	"GopherAI/dao/session"
 
 	"GopherAI/model"
+	rag_service "GopherAI/service/rag"
 	"context"
 	"encoding/json"
 	"log"
@@ -46,18 +47,34 @@ func GetUserSessionsByUserName(userName string) ([]model.SessionInfo, error) {
 	return Sessions, nil
 }
 
-func CreateSessionAndSendMessage(userName string, userQuestion string, modelType string, usingGoogle bool, usingRAG bool) (string, string, code.Code) {
+// 如果模型调用了需要确认的工具，会返回对应的审批记录，AI回答为空
+// knowledgeBases 为会话关联的知识库，rag_search 只在其中检索
+// images 为问题附带的图片，只能发送给支持图片输入的模型
+func CreateSessionAndSendMessage(userName string, role string, userQuestion string, images []model.MessageImage, modelType string, tools []string, usingGoogle bool, usingRAG bool, knowledgeBases []string) (string, *model.Message, *model.ToolApproval, code.Code) {
+	if code_ := checkModelAllowed(role, modelType); code_ != code.CodeSuccess {
+		return "", nil, nil, code_
+	}
+	images, code_ := normalizeChatImages(userName, images)
+	if code_ != code.CodeSuccess {
+		return "", nil, nil, code_
+	}
+	kbs, code_ := rag_service.ResolveReadableKnowledgeBases(userName, knowledgeBases)
+	if code_ != code.CodeSuccess {
+		return "", nil, nil, code_
+	}
+
 	//1：创建一个新的会话
 	newSession := &model.Session{
-		ID:        uuid.New().String(),
-		UserName:  userName,
-		Title:     userQuestion, // 可以根据需求设置标题，这边暂时用用户第一次的问题作为标题
-		ModelType: modelType,
+		ID:             uuid.New().String(),
+		UserName:       userName,
+		Title:          userQuestion, // 可以根据需求设置标题，这边暂时用用户第一次的问题作为标题
+		ModelType:      modelType,
+		KnowledgeBases: kbs,
 	}
 	createdSession, err := session.CreateSession(newSession)
 	if err != nil {
 		log.Println("CreateSessionAndSendMessage CreateSession error:", err)
-		return "", "", code.CodeServerBusy
+		return "", nil, nil, code.CodeServerBusy
 	}
 
 	//2：获取AIHelper并通过其管理消息
@@ -68,24 +85,38 @@ func CreateSessionAndSendMessage(userName string, userQuestion string, modelType
 	helper, err := manager.GetOrCreateAIHelper(userName, createdSession.ID, modelType, config)
 	if err != nil {
 		log.Println("CreateSessionAndSendMessage GetOrCreateAIHelper error:", err)
-		return "", "", code.AIModelFail
+		return "", nil, nil, code.AIModelFail
+	}
+	if code_ := checkVision(helper, images); code_ != code.CodeSuccess {
+		return createdSession.ID, nil, nil, code_
 	}
 
 	//3：生成AI回复
-	aiResponse, err_ := helper.GenerateResponse(userName, ctx, userQuestion, usingGoogle, usingRAG)
+	toolOpts := buildChatToolOptions(userName, role, tools, usingGoogle, usingRAG)
+	toolOpts = append(toolOpts, aihelper.WithKnowledgeBases(kbs...))
+	aiResponse, err_ := helper.GenerateResponse(userName, ctx, userQuestion, images, toolOpts...)
 	if err_ != nil {
-		log.Println("CreateSessionAndSendMessage GenerateResponse error:", err_)
-		return "", "", code.AIModelFail
+		approval, code_ := handleGenerateError(userName, createdSession.ID, modelType, err_)
+		if code_ != code.CodeSuccess {
+			log.Println("CreateSessionAndSendMessage GenerateResponse error:", err_)
+		}
+		return createdSession.ID, nil, approval, code_
 	}
 
-	return createdSession.ID, aiResponse.Content, code.CodeSuccess
+	return createdSession.ID, aiResponse, nil, code.CodeSuccess
 }
 
-func CreateStreamSessionOnly(userName string, userQuestion string) (string, code.Code) {
+// knowledgeBases 为会话关联的知识库，rag_search 只在其中检索
+func CreateStreamSessionOnly(userName string, userQuestion string, knowledgeBases []string) (string, code.Code) {
+	kbs, code_ := rag_service.ResolveReadableKnowledgeBases(userName, knowledgeBases)
+	if code_ != code.CodeSuccess {
+		return "", code_
+	}
 	newSession := &model.Session{
-		ID:       uuid.New().String(),
-		UserName: userName,
-		Title:    userQuestion,
+		ID:             uuid.New().String(),
+		UserName:       userName,
+		Title:          userQuestion,
+		KnowledgeBases: kbs,
 	}
 	createdSession, err := session.CreateSession(newSession)
 	if err != nil {
@@ -171,13 +202,21 @@ func parseTravelPlanPayload(content string) model.TravelPlanPayload {
 	}
 }
 
-func StreamMessageToExistingSession(userName string, sessionID string, userQuestion string, modelType string, writer http.ResponseWriter) code.Code {
+// tools、usingGoogle、usingRAG 与 ChatSend 相同，决定本次回复可以使用哪些工具
+func StreamMessageToExistingSession(userName string, role string, sessionID string, userQuestion string, images []model.MessageImage, modelType string, tools []string, usingGoogle bool, usingRAG bool, writer http.ResponseWriter) code.Code {
+	if code_ := checkModelAllowed(role, modelType); code_ != code.CodeSuccess {
+		return code_
+	}
 	// 确保 writer 支持 Flush
 	flusher, ok := writer.(http.Flusher)
 	if !ok {
 		log.Println("StreamMessageToExistingSession: streaming unsupported")
 		return code.CodeServerBusy
 	}
+	images, code_ := normalizeChatImages(userName, images)
+	if code_ != code.CodeSuccess {
+		return code_
+	}
 
 	manager := aihelper.GetGlobalManager()
 	config := map[string]interface{}{
@@ -188,6 +227,9 @@ func StreamMessageToExistingSession(userName string, sessionID string, userQuest
 		log.Println("StreamMessageToExistingSession GetOrCreateAIHelper error:", err)
 		return code.AIModelFail
 	}
+	if code_ := checkVision(helper, images); code_ != code.CodeSuccess {
+		return code_
+	}
 
 	cb := func(msg string) {
 		// 直接发送数据，不转义
@@ -202,10 +244,21 @@ func StreamMessageToExistingSession(userName string, sessionID string, userQuest
 		log.Println("[SSE] Flushed")
 	}
 
-	_, err_ := helper.StreamResponse(userName, ctx, cb, userQuestion)
+	toolOpts := buildChatToolOptions(userName, role, tools, usingGoogle, usingRAG)
+	toolOpts = append(toolOpts, knowledgeBaseToolOption(userName, sessionID))
+	_, err_ := helper.StreamResponse(userName, ctx, cb, userQuestion, images, toolOpts...)
 	if err_ != nil {
-		log.Println("StreamMessageToExistingSession StreamResponse error:", err_)
-		return code.AIModelFail
+		approval, code_ := handleGenerateError(userName, sessionID, modelType, err_)
+		if code_ != code.CodeSuccess {
+			log.Println("StreamMessageToExistingSession StreamResponse error:", err_)
+			return code_
+		}
+		// 模型调用了需要确认的工具，通过 approval 事件下发审批记录，用户确认后走 /chat/approvals 恢复生成
+		if err := writeApprovalEvent(writer, approval); err != nil {
+			log.Println("StreamMessageToExistingSession write approval error:", err)
+			return code.AIModelFail
+		}
+		flusher.Flush()
 	}
 
 	_, err = writer.Write([]byte("data: [DONE]\n\n"))
@@ -218,14 +271,28 @@ func StreamMessageToExistingSession(userName string, sessionID string, userQuest
 	return code.CodeSuccess
 }
 
-func CreateStreamSessionAndSendMessage(userName string, userQuestion string, modelType string, writer http.ResponseWriter) (string, code.Code) {
+// 向前端发送等待确认的工具调用
+// SSE 格式：event: approval\ndata: <审批记录 JSON>\n\n
+func writeApprovalEvent(writer http.ResponseWriter, approval *model.ToolApproval) error {
+	data, err := json.Marshal(approval)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write([]byte("event: approval\ndata: " + string(data) + "\n\n"))
+	return err
+}
+
+func CreateStreamSessionAndSendMessage(userName string, role string, userQuestion string, images []model.MessageImage, modelType string, tools []string, usingGoogle bool, usingRAG bool, knowledgeBases []string, writer http.ResponseWriter) (string, code.Code) {
+	if code_ := checkModelAllowed(role, modelType); code_ != code.CodeSuccess {
+		return "", code_
+	}
 
-	sessionID, code_ := CreateStreamSessionOnly(userName, userQuestion)
+	sessionID, code_ := CreateStreamSessionOnly(userName, userQuestion, knowledgeBases)
 	if code_ != code.CodeSuccess {
 		return "", code_
 	}
 
-	code_ = StreamMessageToExistingSession(userName, sessionID, userQuestion, modelType, writer)
+	code_ = StreamMessageToExistingSession(userName, role, sessionID, userQuestion, images, modelType, tools, usingGoogle, usingRAG, writer)
 	if code_ != code.CodeSuccess {
 
 		return sessionID, code_
@@ -234,7 +301,16 @@ func CreateStreamSessionAndSendMessage(userName string, userQuestion string, mod
 	return sessionID, code.CodeSuccess
 }
 
-func ChatSend(userName string, sessionID string, userQuestion string, modelType string, usingGoogle bool, usingRAG bool) (string, code.Code) {
+// 如果模型调用了需要确认的工具，会返回对应的审批记录，AI回答为空
+// 之前消息中的图片会随历史一起发送，后续问题可以继续询问这些图片
+func ChatSend(userName string, role string, sessionID string, userQuestion string, images []model.MessageImage, modelType string, tools []string, usingGoogle bool, usingRAG bool) (*model.Message, *model.ToolApproval, code.Code) {
+	if code_ := checkModelAllowed(role, modelType); code_ != code.CodeSuccess {
+		return nil, nil, code_
+	}
+	images, code_ := normalizeChatImages(userName, images)
+	if code_ != code.CodeSuccess {
+		return nil, nil, code_
+	}
 	//1：获取AIHelper
 	manager := aihelper.GetGlobalManager()
 	config := map[string]interface{}{
@@ -243,17 +319,25 @@ func ChatSend(userName string, sessionID string, userQuestion string, modelType
 	helper, err := manager.GetOrCreateAIHelper(userName, sessionID, modelType, config)
 	if err != nil {
 		log.Println("ChatSend GetOrCreateAIHelper error:", err)
-		return "", code.AIModelFail
+		return nil, nil, code.AIModelFail
+	}
+	if code_ := checkVision(helper, images); code_ != code.CodeSuccess {
+		return nil, nil, code_
 	}
 
 	//2：生成AI回复
-	aiResponse, err_ := helper.GenerateResponse(userName, ctx, userQuestion, usingGoogle, usingRAG)
+	toolOpts := buildChatToolOptions(userName, role, tools, usingGoogle, usingRAG)
+	toolOpts = append(toolOpts, knowledgeBaseToolOption(userName, sessionID))
+	aiResponse, err_ := helper.GenerateResponse(userName, ctx, userQuestion, images, toolOpts...)
 	if err_ != nil {
-		log.Println("ChatSend GenerateResponse error:", err_)
-		return "", code.AIModelFail
+		approval, code_ := handleGenerateError(userName, sessionID, modelType, err_)
+		if code_ != code.CodeSuccess {
+			log.Println("ChatSend GenerateResponse error:", err_)
+		}
+		return nil, approval, code_
 	}
 
-	return aiResponse.Content, code.CodeSuccess
+	return aiResponse, nil, code.CodeSuccess
 }
 
 func GetChatHistory(userName string, sessionID string) ([]model.History, code.Code) {
@@ -271,15 +355,21 @@ func GetChatHistory(userName string, sessionID string) ([]model.History, code.Co
 	for _, msg := range messages {
 		// isUser := i%2 == 0
 		history = append(history, model.History{
-			IsUser:  msg.IsUser,
-			Content: msg.Content,
+			IsUser:     msg.IsUser,
+			Role:       msg.GetRole(),
+			Content:    msg.Content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
+			ToolName:   msg.ToolName,
+			Citations:  msg.Citations,
+			Images:     msg.Images,
 		})
 	}
 
 	return history, code.CodeSuccess
 }
 
-func ChatStreamSend(userName string, sessionID string, userQuestion string, modelType string, writer http.ResponseWriter) code.Code {
+func ChatStreamSend(userName string, role string, sessionID string, userQuestion string, images []model.MessageImage, modelType string, tools []string, usingGoogle bool, usingRAG bool, writer http.ResponseWriter) code.Code {
 
-	return StreamMessageToExistingSession(userName, sessionID, userQuestion, modelType, writer)
+	return StreamMessageToExistingSession(userName, role, sessionID, userQuestion, images, modelType, tools, usingGoogle, usingRAG, writer)
 }
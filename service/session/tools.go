@@ -0,0 +1,116 @@
+package session
+
+import (
+	"GopherAI/common/aihelper"
+	"GopherAI/common/code"
+	"GopherAI/config"
+	"GopherAI/dao/preference"
+	"GopherAI/model"
+	"errors"
+	"log"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// 角色允许使用的工具，返回 nil 表示不做限制
+func allowedToolsForRole(role string) []string {
+	tools, ok := config.GetConfig().ToolConfig.RoleTools[role]
+	if !ok {
+		return nil
+	}
+	return tools
+}
+
+// 用户默认启用的工具：优先使用用户保存的偏好，其次使用配置中的默认值
+func defaultToolsForUser(userName string) []string {
+	pref, err := preference.GetToolPreference(userName)
+	if err == nil {
+		return pref.Tools
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Println("defaultToolsForUser GetToolPreference error:", err)
+	}
+	return config.GetConfig().ToolConfig.DefaultTools
+}
+
+// 计算本次对话的工具选择
+// requested 不为 nil 时以请求为准，否则使用用户默认值；usingGoogle/usingRAG 为旧字段，会在此基础上追加对应工具
+// role 为用户角色，决定可以使用哪些工具
+func buildChatToolOptions(userName string, role string, requested []string, usingGoogle bool, usingRAG bool) []aihelper.ToolOption {
+	enabled := requested
+	if enabled == nil {
+		enabled = defaultToolsForUser(userName)
+	}
+
+	var opts []aihelper.ToolOption
+	if enabled != nil {
+		opts = append(opts, aihelper.WithTools(enabled...))
+	}
+	if usingGoogle {
+		opts = append(opts, aihelper.WithGoogleTool())
+	}
+	if usingRAG {
+		opts = append(opts, aihelper.WithRAGTool())
+	}
+	if allowed := allowedToolsForRole(role); allowed != nil {
+		opts = append(opts, aihelper.WithAllowedTools(allowed...))
+	}
+	opts = append(opts, aihelper.WithConfirmTools(config.GetConfig().ToolConfig.ConfirmTools...))
+	return opts
+}
+
+// 当前角色可用的工具列表
+func availableChatTools(role string) []string {
+	allowed := allowedToolsForRole(role)
+	if allowed == nil {
+		return aihelper.ChatBoxTools
+	}
+	tools := make([]string, 0, len(aihelper.ChatBoxTools))
+	for _, name := range aihelper.ChatBoxTools {
+		if slices.Contains(allowed, name) {
+			tools = append(tools, name)
+		}
+	}
+	return tools
+}
+
+// GetChatTools 获取用户可用的工具及默认启用状态
+func GetChatTools(userName string, role string) ([]model.ChatToolInfo, code.Code) {
+	enabled := defaultToolsForUser(userName)
+	available := availableChatTools(role)
+
+	tools := make([]model.ChatToolInfo, 0, len(available))
+	for _, name := range available {
+		tools = append(tools, model.ChatToolInfo{
+			Name:    name,
+			Enabled: enabled == nil || slices.Contains(enabled, name),
+		})
+	}
+	return tools, code.CodeSuccess
+}
+
+// UpdateChatTools 保存用户默认启用的工具
+func UpdateChatTools(userName string, role string, tools []string) code.Code {
+	available := availableChatTools(role)
+	for _, name := range tools {
+		if !slices.Contains(aihelper.ChatBoxTools, name) {
+			return code.CodeInvalidParams
+		}
+		if !slices.Contains(available, name) {
+			return code.CodeForbidden
+		}
+	}
+
+	if tools == nil {
+		tools = []string{}
+	}
+	if _, err := preference.SaveToolPreference(&model.ToolPreference{
+		UserName: userName,
+		Tools:    tools,
+	}); err != nil {
+		log.Println("UpdateChatTools SaveToolPreference error:", err)
+		return code.CodeServerBusy
+	}
+	return code.CodeSuccess
+}
@@ -0,0 +1,181 @@
+package session
+
+import (
+	"GopherAI/common/aihelper"
+	"GopherAI/common/code"
+	image_recognizer "GopherAI/common/image"
+	"GopherAI/model"
+	image_service "GopherAI/service/image"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	// MaxTravelPhotos 一次最多上传的照片数
+	MaxTravelPhotos = 6
+	// 地点推测的置信度低于该值时在描述中注明不确定
+	travelPhotoLowConfidence = 0.5
+
+	travelPhotoStageKey   = "photo_recognition"
+	travelPhotoStageLabel = "照片地点识别"
+
+	travelPhotoPrompt = "这张照片可能拍摄于哪里？请尽量推测具体的景点或地标、所在城市与国家，并在 caption 中描述照片里的景色特点。"
+)
+
+// TravelPhoto 用于生成旅行规划的照片
+type TravelPhoto struct {
+	Name string
+	Data []byte
+}
+
+// StartTravelPlanningTaskFromPhotos 先用视觉模型识别每张照片中的地点，再以此生成描述开始旅行规划
+// note 为用户补充的文字，可为空；识别结果写入任务快照的 photo_places
+func StartTravelPlanningTaskFromPhotos(photos []TravelPhoto, note string) (model.TravelPlanningTaskSnapshot, code.Code) {
+	if len(photos) == 0 || len(photos) > MaxTravelPhotos {
+		return model.TravelPlanningTaskSnapshot{}, code.CodeInvalidParams
+	}
+	// 先同步完成格式与大小校验，识别时使用预处理后的图片
+	opts := image_service.PrepareOptions()
+	prepared := make([]TravelPhoto, 0, len(photos))
+	for _, photo := range photos {
+		img, err := image_recognizer.Prepare(photo.Data, opts)
+		if err != nil {
+			log.Printf("StartTravelPlanningTaskFromPhotos prepare %s error: %v", photo.Name, err)
+			if code_, ok := image_service.PrepareErrorCode(err); ok {
+				return model.TravelPlanningTaskSnapshot{}, code_
+			}
+			return model.TravelPlanningTaskSnapshot{}, code.CodeServerBusy
+		}
+		prepared = append(prepared, TravelPhoto{Name: photo.Name, Data: img.Data})
+	}
+
+	stages := append([]model.TravelPlanningStage{{
+		Key:    travelPhotoStageKey,
+		Label:  travelPhotoStageLabel,
+		Status: travelStagePending,
+	}}, buildTravelPlanningStages()...)
+	task := createTravelTask(strings.TrimSpace(note), stages)
+	go runPhotoTravelPlanningTask(task.TaskID, prepared, note)
+	return task, code.CodeSuccess
+}
+
+func runPhotoTravelPlanningTask(taskID string, photos []TravelPhoto, note string) {
+	ctx := context.Background()
+	places := make([]model.TravelPhotoPlace, 0, len(photos))
+	recognized := 0
+	for i, photo := range photos {
+		applyTravelTaskProgress(taskID, aihelper.TravelPlanningProgress{
+			Stage:  travelPhotoStageKey,
+			Label:  travelPhotoStageLabel,
+			Status: travelStageRunning,
+			Detail: fmt.Sprintf("正在识别第 %d/%d 张照片。", i+1, len(photos)),
+		})
+
+		place := model.TravelPhotoPlace{PhotoIndex: i, PhotoName: photo.Name}
+		analysis, code_ := image_service.AnalyzeImage(ctx, photo.Data, travelPhotoPrompt, nil)
+		if code_ != code.CodeSuccess {
+			place.Error = code_.Msg()
+		} else {
+			recognized++
+			place.Caption = analysis.Caption
+			if analysis.Landmark != nil {
+				place.Place = analysis.Landmark.Name
+				place.City = analysis.Landmark.City
+				place.Country = analysis.Landmark.Country
+				place.Confidence = analysis.Landmark.Confidence
+			}
+		}
+		places = append(places, place)
+		updateTravelTask(taskID, func(task *model.TravelPlanningTaskSnapshot) {
+			task.PhotoPlaces = append([]model.TravelPhotoPlace(nil), places...)
+		})
+	}
+
+	description := buildPhotoTravelDescription(places, note)
+	if description == "" {
+		message := "未能从照片中识别出地点或景色，请补充文字描述后重试。"
+		applyTravelTaskProgress(taskID, aihelper.TravelPlanningProgress{
+			Stage:  travelPhotoStageKey,
+			Label:  travelPhotoStageLabel,
+			Status: travelStageFailed,
+			Detail: fmt.Sprintf("已识别 %d/%d 张照片，%s", recognized, len(photos), message),
+		})
+		failTravelTask(taskID, message)
+		return
+	}
+
+	applyTravelTaskProgress(taskID, aihelper.TravelPlanningProgress{
+		Stage:  travelPhotoStageKey,
+		Label:  travelPhotoStageLabel,
+		Status: travelStageCompleted,
+		Detail: fmt.Sprintf("已识别 %d/%d 张照片。", recognized, len(photos)),
+	})
+	updateTravelTask(taskID, func(task *model.TravelPlanningTaskSnapshot) {
+		task.Description = description
+	})
+	runTravelPlanningTask(taskID, description)
+}
+
+// buildPhotoTravelDescription 把每张照片推测出的地点整理成旅行需求描述，没有任何可用信息时返回空字符串
+func buildPhotoTravelDescription(places []model.TravelPhotoPlace, note string) string {
+	var lines []string
+	hasPlace := false
+	for _, place := range places {
+		label := fmt.Sprintf("照片 %d", place.PhotoIndex+1)
+		if place.PhotoName != "" {
+			label += "（" + place.PhotoName + "）"
+		}
+		switch {
+		case place.Place != "":
+			hasPlace = true
+			location := place.Place
+			if where := joinNonEmpty("，", place.City, place.Country); where != "" {
+				location += "（" + where + "）"
+			}
+			if place.Confidence < travelPhotoLowConfidence {
+				location = "可能是" + location
+			}
+			line := label + "：" + location
+			if place.Caption != "" {
+				line += "，画面：" + place.Caption
+			}
+			lines = append(lines, line)
+		case place.Caption != "":
+			lines = append(lines, label+"：无法确定具体地点，画面："+place.Caption)
+		}
+	}
+
+	note = strings.TrimSpace(note)
+	if len(lines) == 0 {
+		return note
+	}
+	var b strings.Builder
+	if note != "" {
+		b.WriteString(note)
+		b.WriteString("\n\n")
+	}
+	fmt.Fprintf(&b, "我上传了 %d 张想去的地方的照片，根据照片推测：\n", len(places))
+	for _, line := range lines {
+		b.WriteString("- ")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	if hasPlace {
+		b.WriteString("请以这些地点为目的地规划行程。")
+	} else {
+		b.WriteString("请推荐与照片中景色相似的目的地并规划行程。")
+	}
+	return b.String()
+}
+
+func joinNonEmpty(sep string, values ...string) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, sep)
+}
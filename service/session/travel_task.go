@@ -48,6 +48,13 @@ var globalTravelTaskManager = &travelTaskManager{
 }
 
 func StartTravelPlanningTask(description string) (model.TravelPlanningTaskSnapshot, code.Code) {
+	task := createTravelTask(description, buildTravelPlanningStages())
+	go runTravelPlanningTask(task.TaskID, description)
+	return task, code.CodeSuccess
+}
+
+// createTravelTask 登记一个等待开始的任务，返回快照
+func createTravelTask(description string, stages []model.TravelPlanningStage) model.TravelPlanningTaskSnapshot {
 	taskID := uuid.New().String()
 	now := time.Now().Unix()
 	task := &model.TravelPlanningTaskSnapshot{
@@ -58,16 +65,14 @@ func StartTravelPlanningTask(description string) (model.TravelPlanningTaskSnapsh
 		ProgressPercent: 0,
 		CreatedAt:       now,
 		UpdatedAt:       now,
-		Stages:          buildTravelPlanningStages(),
+		Stages:          stages,
 	}
 
 	globalTravelTaskManager.mu.Lock()
 	globalTravelTaskManager.tasks[taskID] = task
 	globalTravelTaskManager.mu.Unlock()
 
-	go runTravelPlanningTask(taskID, description)
-
-	return cloneTravelTask(task), code.CodeSuccess
+	return cloneTravelTask(task)
 }
 
 func GetTravelPlanningTask(taskID string) (model.TravelPlanningTaskSnapshot, code.Code) {
@@ -219,6 +224,9 @@ func cloneTravelTask(task *model.TravelPlanningTaskSnapshot) model.TravelPlannin
 	if task.Stages != nil {
 		cloned.Stages = append([]model.TravelPlanningStage(nil), task.Stages...)
 	}
+	if task.PhotoPlaces != nil {
+		cloned.PhotoPlaces = append([]model.TravelPhotoPlace(nil), task.PhotoPlaces...)
+	}
 	if task.Plan.DailyPlans != nil {
 		cloned.Plan.DailyPlans = append([]model.TravelDayPlan(nil), task.Plan.DailyPlans...)
 	}
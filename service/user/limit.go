@@ -0,0 +1,101 @@
+package user
+
+import (
+	"GopherAI/common/code"
+	myredis "GopherAI/common/redis"
+	"GopherAI/config"
+	"GopherAI/model"
+	"log"
+	"time"
+)
+
+const (
+	defaultEmailCooldown      = 60 * time.Second
+	defaultLoginMaxFailures   = 5
+	defaultLoginFailureWindow = 15 * time.Minute
+	defaultLoginLockDuration  = 15 * time.Minute
+)
+
+func emailCooldown() time.Duration {
+	if seconds := config.GetConfig().CaptchaCooldownSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultEmailCooldown
+}
+
+func loginProtection() (maxFailures int, window, lockFor time.Duration) {
+	conf := config.GetConfig().RateLimitConfig
+	maxFailures, window, lockFor = defaultLoginMaxFailures, defaultLoginFailureWindow, defaultLoginLockDuration
+	if conf.LoginMaxFailures > 0 {
+		maxFailures = conf.LoginMaxFailures
+	}
+	if conf.LoginFailureWindowMinutes > 0 {
+		window = time.Duration(conf.LoginFailureWindowMinutes) * time.Minute
+	}
+	if conf.LoginLockMinutes > 0 {
+		lockFor = time.Duration(conf.LoginLockMinutes) * time.Minute
+	}
+	return
+}
+
+// acquireEmailCooldown 同一邮箱发送邮件的冷却，验证码、忘记密码与找回账号共用
+func acquireEmailCooldown(email string) (time.Duration, code.Code) {
+	ok, retryAfter, err := myredis.AcquireEmailCooldown(email, emailCooldown())
+	if err != nil {
+		log.Println("AcquireEmailCooldown failed:", err)
+		return 0, code.CodeServerBusy
+	}
+	if !ok {
+		return retryAfter, code.CodeTooManyRequests
+	}
+	return 0, code.CodeSuccess
+}
+
+// 邮件没有发出去时释放冷却，允许用户立即重试
+func releaseEmailCooldown(email string) {
+	if err := myredis.ReleaseEmailCooldown(email); err != nil {
+		log.Println("ReleaseEmailCooldown failed:", err)
+	}
+}
+
+// loginLockRemaining 候选账号中任意一个被锁定时返回剩余锁定时间，Redis 出错时不阻止登录
+func loginLockRemaining(users []*model.User) time.Duration {
+	for _, u := range users {
+		remaining, err := myredis.GetLoginLock(u.ID)
+		if err != nil {
+			log.Println("GetLoginLock failed:", err)
+			continue
+		}
+		if remaining > 0 {
+			return remaining
+		}
+	}
+	return 0
+}
+
+// recordLoginFailure 记录登录失败，返回因此被锁定的时长
+func recordLoginFailure(users []*model.User) time.Duration {
+	maxFailures, window, lockFor := loginProtection()
+	var locked bool
+	for _, u := range users {
+		ok, err := myredis.RecordLoginFailure(u.ID, maxFailures, window, lockFor)
+		if err != nil {
+			log.Println("RecordLoginFailure failed:", err)
+			continue
+		}
+		if ok {
+			log.Printf("user %d locked after %d failed logins", u.ID, maxFailures)
+			locked = true
+		}
+	}
+	if locked {
+		return lockFor
+	}
+	return 0
+}
+
+func clearLoginFailures(userID int64) {
+	if err := myredis.ClearLoginFailures(userID); err != nil {
+		log.Println("ClearLoginFailures failed:", err)
+	}
+}
@@ -0,0 +1,161 @@
+package user
+
+import (
+	"GopherAI/common/code"
+	myredis "GopherAI/common/redis"
+	"GopherAI/dao/user"
+	"GopherAI/model"
+	"GopherAI/utils"
+	"GopherAI/utils/myjwt"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TokenPair 登录、注册与刷新后返回给客户端的令牌
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	// 访问令牌的有效秒数
+	ExpiresIn int64
+}
+
+// authSession 保存在 Redis 中的登录会话，只保存当前刷新令牌的哈希
+type authSession struct {
+	UserID      int64  `json:"userId"`
+	Username    string `json:"username"`
+	RefreshHash string `json:"refreshHash"`
+	CreatedAt   int64  `json:"createdAt"`
+}
+
+// issueTokens 创建新的登录会话并签发令牌
+func issueTokens(u *model.User) (TokenPair, error) {
+	sessionID := utils.GenerateUUID()
+	refreshToken, hash, err := myjwt.GenerateRefreshToken(sessionID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	data, err := json.Marshal(authSession{
+		UserID:      u.ID,
+		Username:    u.Username,
+		RefreshHash: hash,
+		CreatedAt:   time.Now().Unix(),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if err := myredis.SetAuthSession(sessionID, u.ID, data, myjwt.RefreshExpire()); err != nil {
+		return TokenPair{}, err
+	}
+	return newTokenPair(u, sessionID, refreshToken)
+}
+
+func newTokenPair(u *model.User, sessionID, refreshToken string) (TokenPair, error) {
+	accessToken, err := myjwt.GenerateToken(u.ID, u.Username, u.GetRole(), sessionID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(myjwt.AccessExpire() / time.Second),
+	}, nil
+}
+
+// RefreshTokens 用刷新令牌换取新的令牌对，旧的刷新令牌随即失效
+// 已被轮换掉的刷新令牌再次出现时，说明令牌可能已经泄露，直接撤销整个会话
+func RefreshTokens(refreshToken string) (TokenPair, code.Code) {
+	sessionID, hash, ok := myjwt.ParseRefreshToken(refreshToken)
+	if !ok {
+		return TokenPair{}, code.CodeInvalidToken
+	}
+	data, ok, err := myredis.GetAuthSession(sessionID)
+	if err != nil {
+		log.Println("GetAuthSession failed:", err)
+		return TokenPair{}, code.CodeServerBusy
+	}
+	if !ok {
+		return TokenPair{}, code.CodeInvalidToken
+	}
+	var session authSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		log.Println("unmarshal auth session failed:", err)
+		return TokenPair{}, code.CodeInvalidToken
+	}
+	if subtle.ConstantTimeCompare([]byte(session.RefreshHash), []byte(hash)) != 1 {
+		log.Printf("refresh token reused, revoke session of user %d", session.UserID)
+		if err := myredis.RevokeAuthSessions(session.UserID, []string{sessionID}, myjwt.AccessExpire()); err != nil {
+			log.Println("RevokeAuthSessions failed:", err)
+		}
+		return TokenPair{}, code.CodeInvalidToken
+	}
+	// 每次刷新都重新读取用户，被禁用或删除的用户无法继续刷新，角色变化也会体现在新令牌中
+	userInformation, err := user.GetUserByID(session.UserID)
+	if err != nil || userInformation.Disabled {
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println("GetUserByID failed:", err)
+			return TokenPair{}, code.CodeServerBusy
+		}
+		if err := myredis.RevokeAuthSessions(session.UserID, []string{sessionID}, myjwt.AccessExpire()); err != nil {
+			log.Println("RevokeAuthSessions failed:", err)
+		}
+		return TokenPair{}, code.CodeInvalidToken
+	}
+
+	newRefreshToken, newHash, err := myjwt.GenerateRefreshToken(sessionID)
+	if err != nil {
+		log.Println("GenerateRefreshToken failed:", err)
+		return TokenPair{}, code.CodeServerBusy
+	}
+	session.RefreshHash = newHash
+	newData, err := json.Marshal(session)
+	if err != nil {
+		return TokenPair{}, code.CodeServerBusy
+	}
+	replaced, err := myredis.ReplaceAuthSession(sessionID, session.UserID, data, newData, myjwt.RefreshExpire())
+	if err != nil {
+		log.Println("ReplaceAuthSession failed:", err)
+		return TokenPair{}, code.CodeServerBusy
+	}
+	// 同一刷新令牌的并发请求只有一个能成功
+	if !replaced {
+		return TokenPair{}, code.CodeInvalidToken
+	}
+
+	pair, err := newTokenPair(userInformation, sessionID, newRefreshToken)
+	if err != nil {
+		log.Println("myjwt.GenerateToken failed:", err)
+		return TokenPair{}, code.CodeServerBusy
+	}
+	return pair, code.CodeSuccess
+}
+
+// Logout 退出当前会话，该会话的刷新令牌与已签发的访问令牌立即失效
+func Logout(userID int64, sessionID string) code.Code {
+	if err := myredis.RevokeAuthSessions(userID, []string{sessionID}, myjwt.AccessExpire()); err != nil {
+		log.Println("RevokeAuthSessions failed:", err)
+		return code.CodeServerBusy
+	}
+	return code.CodeSuccess
+}
+
+// LogoutAll 退出该用户在所有设备上的会话
+func LogoutAll(userID int64) code.Code {
+	if err := revokeAllSessions(userID); err != nil {
+		log.Println("revokeAllSessions failed:", err)
+		return code.CodeServerBusy
+	}
+	return code.CodeSuccess
+}
+
+func revokeAllSessions(userID int64) error {
+	sessionIDs, err := myredis.GetUserAuthSessionIDs(userID)
+	if err != nil {
+		return err
+	}
+	return myredis.RevokeAuthSessions(userID, sessionIDs, myjwt.AccessExpire())
+}
@@ -4,93 +4,302 @@ import (
 	"GopherAI/common/code"
 	myemail "GopherAI/common/email"
 	myredis "GopherAI/common/redis"
+	"GopherAI/config"
 	"GopherAI/dao/user"
 	"GopherAI/model"
 	"GopherAI/utils"
-	"GopherAI/utils/myjwt"
+	passwordutil "GopherAI/utils/password"
 	"log"
+	"strings"
+	"time"
 )
 
-func Login(username, password string) (string, code.Code) {
-	var userInformation *model.User
-	var ok bool
-	//1:判断用户是否存在
-	if ok, userInformation = user.IsExistUser(username); !ok {
+// 生成账号时检查重复的最大次数
+const usernameAttempts = 5
+
+// Login 使用账号或邮箱登录
+// 连续登录失败次数过多时账号会被临时锁定，retryAfter 为剩余的锁定时间
+func Login(account, password string) (TokenPair, time.Duration, code.Code) {
+	account = strings.TrimSpace(account)
+	//1:判断用户是否存在，包含 @ 时按邮箱查找
+	var candidates []*model.User
+	if strings.Contains(account, "@") {
+		candidates = user.GetUsersByEmail(account)
+	} else if ok, userInformation := user.IsExistUser(account); ok {
+		candidates = []*model.User{userInformation}
+	}
+	if len(candidates) == 0 {
+		return TokenPair{}, 0, code.CodeUserNotExist
+	}
+	if remaining := loginLockRemaining(candidates); remaining > 0 {
+		return TokenPair{}, remaining, code.CodeAccountLocked
+	}
 
-		return "", code.CodeUserNotExist
+	//2:判断用户是否密码账号正确，同一邮箱对应多个旧账号时以密码匹配的账号为准
+	var userInformation *model.User
+	var needsRehash bool
+	hasher := passwordutil.Default()
+	for _, candidate := range candidates {
+		if ok, rehash := hasher.Verify(password, candidate.Password); ok {
+			userInformation, needsRehash = candidate, rehash
+			break
+		}
+	}
+	if userInformation == nil {
+		if lockFor := recordLoginFailure(candidates); lockFor > 0 {
+			return TokenPair{}, lockFor, code.CodeAccountLocked
+		}
+		return TokenPair{}, 0, code.CodeInvalidPassword
 	}
-	//2:判断用户是否密码账号正确
-	if userInformation.Password != utils.MD5(password) {
-		return "", code.CodeInvalidPassword
+	clearLoginFailures(userInformation.ID)
+	if userInformation.Disabled {
+		return TokenPair{}, 0, code.CodeUserDisabled
 	}
-	//3:返回一个Token
-	token, err := myjwt.GenerateToken(userInformation.ID, userInformation.Username)
+	// 旧的 MD5 或参数与当前配置不同的哈希，登录成功后重新哈希，失败不影响登录
+	if needsRehash && !user.UpdatePassword(userInformation.ID, password) {
+		log.Println("upgrade password hash failed:", userInformation.Username)
+	}
+	//3:创建登录会话并返回令牌
+	tokens, err := issueTokens(userInformation)
 
 	if err != nil {
-		return "", code.CodeServerBusy
+		log.Println("issueTokens failed:", err)
+		return TokenPair{}, 0, code.CodeServerBusy
 	}
-	return token, code.CodeSuccess
+	return tokens, 0, code.CodeSuccess
 }
 
-func Register(email, password, captcha string) (string, code.Code) {
+func Register(email, password, captcha string) (TokenPair, code.Code) {
 
 	var ok bool
 	var userInformation *model.User
 
-	//1:先判断用户是否已经存在了
-	if ok, _ := user.IsExistUser(email); ok {
-		log.Println("user.IsExistUser failed")
-		return "", code.CodeUserExist
+	if err := passwordutil.Default().CheckStrength(password); err != nil {
+		log.Println("CheckStrength failed:", err)
+		return TokenPair{}, code.CodeWeakPassword
+	}
+
+	//1:先判断邮箱是否已经注册了
+	if user.IsExistEmail(email) {
+		log.Println("user.IsExistEmail failed")
+		return TokenPair{}, code.CodeUserExist
 	}
 
 	//2:从redis中验证验证码是否有效
 	if ok, _ := myredis.CheckCaptchaForEmail(email, captcha); !ok {
 		log.Println("myredis.CheckCaptchaForEmail failed")
-		return "", code.CodeInvalidCaptcha
+		return TokenPair{}, code.CodeInvalidCaptcha
 	}
 
-	// TODO: 这里生成的 11 位账号可能会重复，后续需要改进为循环检测直到不重复为止
-	//3：生成11位的账号
-	username := utils.GetRandomNumbers(11)
+	//3：生成11位的账号，重复时重新生成
+	username, ok := generateUsername()
+	if !ok {
+		log.Println("generateUsername failed")
+		return TokenPair{}, code.CodeServerBusy
+	}
 
 	//4：注册到数据库中
 	if userInformation, ok = user.Register(username, email, password); !ok {
 		log.Println("user.Register failed")
-		return "", code.CodeServerBusy
+		return TokenPair{}, code.CodeServerBusy
 	}
 
-	//5：将账号一并发送到对应邮箱上去，后续需要账号登录
-	if err := myemail.SendCaptcha(email, username, user.UserNameMsg); err != nil {
+	//5：将账号一并发送到对应邮箱上去，后续可以用账号或邮箱登录
+	if err := myemail.SendCaptcha(email, username, myemail.UserNameMsg); err != nil {
 		log.Println("myemail.SendCaptcha failed")
-		return "", code.CodeServerBusy
+		return TokenPair{}, code.CodeServerBusy
 	}
 
-	// 6:生成Token
-	token, err := myjwt.GenerateToken(userInformation.ID, userInformation.Username)
+	// 6:创建登录会话并返回令牌
+	tokens, err := issueTokens(userInformation)
 
 	if err != nil {
-		log.Println("myjwt.GenerateToken failed")
-		return "", code.CodeServerBusy
+		log.Println("issueTokens failed:", err)
+		return TokenPair{}, code.CodeServerBusy
 	}
 
-	return token, code.CodeSuccess
+	return tokens, code.CodeSuccess
+}
+
+// generateUsername 生成未被占用的 11 位账号
+func generateUsername() (string, bool) {
+	for i := 0; i < usernameAttempts; i++ {
+		username := utils.GetRandomNumbers(11)
+		if !user.IsUsernameTaken(username) {
+			return username, true
+		}
+	}
+	return "", false
+}
+
+// ChangePassword 校验旧密码后修改为新密码
+// 旧密码错误与登录失败计入同一个计数，达到上限后账号被锁定，retryAfter 为剩余的锁定时间
+// 修改成功后撤销该用户的全部会话（包括当前会话），并为当前设备签发新的令牌
+func ChangePassword(username, oldPassword, newPassword string) (TokenPair, time.Duration, code.Code) {
+	ok, userInformation := user.IsExistUser(username)
+	if !ok {
+		return TokenPair{}, 0, code.CodeUserNotExist
+	}
+	candidates := []*model.User{userInformation}
+	if remaining := loginLockRemaining(candidates); remaining > 0 {
+		return TokenPair{}, remaining, code.CodeAccountLocked
+	}
+	if ok, _ := passwordutil.Default().Verify(oldPassword, userInformation.Password); !ok {
+		if lockFor := recordLoginFailure(candidates); lockFor > 0 {
+			return TokenPair{}, lockFor, code.CodeAccountLocked
+		}
+		return TokenPair{}, 0, code.CodeInvalidPassword
+	}
+	clearLoginFailures(userInformation.ID)
+	if oldPassword == newPassword {
+		return TokenPair{}, 0, code.CodeInvalidParams
+	}
+	if err := passwordutil.Default().CheckStrength(newPassword); err != nil {
+		log.Println("CheckStrength failed:", err)
+		return TokenPair{}, 0, code.CodeWeakPassword
+	}
+	if !user.UpdatePassword(userInformation.ID, newPassword) {
+		return TokenPair{}, 0, code.CodeServerBusy
+	}
+	// 密码可能已经泄露，其他设备上的会话必须全部失效
+	if err := revokeAllSessions(userInformation.ID); err != nil {
+		log.Println("revokeAllSessions failed:", err)
+		return TokenPair{}, 0, code.CodeServerBusy
+	}
+	tokens, err := issueTokens(userInformation)
+	if err != nil {
+		log.Println("issueTokens failed:", err)
+		return TokenPair{}, 0, code.CodeServerBusy
+	}
+	return tokens, 0, code.CodeSuccess
 }
 
 // 往指定邮箱发送验证码
 // 分为以下任务：
-// 1：先存放redis
-// 2：再进行远程发送
-func SendCaptcha(email_ string) code.Code {
+// 1：检查同一邮箱的发送冷却
+// 2：先存放redis
+// 3：再进行远程发送
+func SendCaptcha(email_ string) (time.Duration, code.Code) {
+	if retryAfter, code_ := acquireEmailCooldown(email_); code_ != code.CodeSuccess {
+		return retryAfter, code_
+	}
+	return 0, sendCaptcha(email_)
+}
+
+func sendCaptcha(email_ string) code.Code {
 	send_code := utils.GetRandomNumbers(6)
 	//1:先存放到redis
 	if err := myredis.SetCaptchaForEmail(email_, send_code); err != nil {
+		releaseEmailCooldown(email_)
 		return code.CodeServerBusy
 	}
 
 	//2:再进行远程发送
 	if err := myemail.SendCaptcha(email_, send_code, myemail.CodeMsg); err != nil {
+		releaseEmailCooldown(email_)
 		return code.CodeServerBusy
 	}
 
 	return code.CodeSuccess
 }
+
+// SendPasswordResetCaptcha 忘记密码时向注册邮箱发送验证码
+// 邮箱未注册时同样返回成功并计入冷却，避免通过该接口判断邮箱是否注册
+func SendPasswordResetCaptcha(email string) (time.Duration, code.Code) {
+	if retryAfter, code_ := acquireEmailCooldown(email); code_ != code.CodeSuccess {
+		return retryAfter, code_
+	}
+	if !user.IsExistEmail(email) {
+		log.Println("SendPasswordResetCaptcha email not registered")
+		return 0, code.CodeSuccess
+	}
+	return 0, sendCaptcha(email)
+}
+
+// VerifyPasswordResetCaptcha 校验忘记密码的验证码，成功后返回一次性的重置令牌
+// 同一邮箱对应多个旧账号时需要指定 username
+func VerifyPasswordResetCaptcha(email, captcha, username string) (string, code.Code) {
+	// 先校验验证码，邮箱未注册、验证码错误、未指定账号都返回同样的结果，避免泄露邮箱下的账号情况
+	if ok, _ := myredis.CheckCaptchaForEmail(email, captcha); !ok {
+		return "", code.CodeInvalidCaptcha
+	}
+	users := user.GetUsersByEmail(email)
+	var target *model.User
+	for _, u := range users {
+		if len(users) == 1 || u.Username == username {
+			target = u
+			break
+		}
+	}
+	if target == nil {
+		return "", code.CodeInvalidCaptcha
+	}
+
+	token := utils.GenerateUUID()
+	if err := myredis.SetPasswordResetToken(token, target.ID); err != nil {
+		log.Println("SetPasswordResetToken failed:", err)
+		return "", code.CodeServerBusy
+	}
+	return token, code.CodeSuccess
+}
+
+// ResetPassword 使用重置令牌设置新密码
+func ResetPassword(token, newPassword string) code.Code {
+	if err := passwordutil.Default().CheckStrength(newPassword); err != nil {
+		log.Println("CheckStrength failed:", err)
+		return code.CodeWeakPassword
+	}
+	userID, ok, err := myredis.TakePasswordResetToken(token)
+	if err != nil {
+		log.Println("TakePasswordResetToken failed:", err)
+		return code.CodeServerBusy
+	}
+	if !ok {
+		return code.CodeInvalidToken
+	}
+	if !user.UpdatePassword(userID, newPassword) {
+		return code.CodeServerBusy
+	}
+	// 密码被重置后，之前登录的设备全部下线
+	if err := revokeAllSessions(userID); err != nil {
+		log.Println("revokeAllSessions failed:", err)
+	}
+	return code.CodeSuccess
+}
+
+// ResendAccountID 把邮箱对应的账号重新发送到该邮箱，邮箱未注册时同样返回成功
+func ResendAccountID(email string) (time.Duration, code.Code) {
+	if retryAfter, code_ := acquireEmailCooldown(email); code_ != code.CodeSuccess {
+		return retryAfter, code_
+	}
+	users := user.GetUsersByEmail(email)
+	if len(users) == 0 {
+		log.Println("ResendAccountID email not registered")
+		return 0, code.CodeSuccess
+	}
+	usernames := make([]string, 0, len(users))
+	for _, u := range users {
+		usernames = append(usernames, u.Username)
+	}
+	if err := myemail.SendCaptcha(email, strings.Join(usernames, "、"), myemail.UserNameMsg); err != nil {
+		log.Println("myemail.SendCaptcha failed")
+		releaseEmailCooldown(email)
+		return 0, code.CodeServerBusy
+	}
+	return 0, code.CodeSuccess
+}
+
+// SyncConfiguredAdmins 启动时把 [roleConfig] admins 中的账号设置为管理员
+// 只会提升角色，从配置中移除的账号需要通过管理接口修改
+func SyncConfiguredAdmins() {
+	admins := config.GetConfig().RoleConfig.Admins
+	if len(admins) == 0 {
+		return
+	}
+	updated, err := user.PromoteUsers(admins, model.RoleAdmin)
+	if err != nil {
+		log.Println("SyncConfiguredAdmins PromoteUsers failed:", err)
+		return
+	}
+	log.Printf("SyncConfiguredAdmins: %d user(s) promoted to admin", updated)
+}
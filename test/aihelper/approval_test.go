@@ -0,0 +1,255 @@
+package aihelper_test
+
+import (
+	"GopherAI/common/aihelper"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// scriptedChatModel 绑定了 call 指定的工具时先调用该工具，拿到工具结果后再回答
+type scriptedChatModel struct {
+	mu    sync.Mutex
+	call  string
+	args  string
+	bound []string // 最近一次 WithTools 绑定的工具
+}
+
+func (m *scriptedChatModel) Generate(_ context.Context, messages []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	last := messages[len(messages)-1]
+	if last.Role == schema.Tool {
+		return schema.AssistantMessage("工具结果："+last.Content, nil), nil
+	}
+	if m.call == "" || !slices.Contains(m.bound, m.call) {
+		return schema.AssistantMessage("直接回答", nil), nil
+	}
+	return schema.AssistantMessage("", []schema.ToolCall{{
+		ID:       "call_1",
+		Type:     "function",
+		Function: schema.FunctionCall{Name: m.call, Arguments: m.args},
+	}}), nil
+}
+
+func (m *scriptedChatModel) Stream(ctx context.Context, messages []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.Generate(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func (m *scriptedChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bound = m.bound[:0]
+	for _, t := range tools {
+		m.bound = append(m.bound, t.Name)
+	}
+	return m, nil
+}
+
+func (m *scriptedChatModel) boundTools() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.bound...)
+}
+
+// fakeTool 记录每次实际执行时收到的参数
+type fakeTool struct {
+	name   string
+	result string
+	mu     sync.Mutex
+	calls  []string
+}
+
+func (t *fakeTool) Info(context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: t.name, Desc: t.name}, nil
+}
+
+func (t *fakeTool) InvokableRun(_ context.Context, argumentsInJSON string, _ ...tool.Option) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls = append(t.calls, argumentsInJSON)
+	return t.result, nil
+}
+
+func (t *fakeTool) executed() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.calls...)
+}
+
+type memoryCheckPointStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (s *memoryCheckPointStore) Get(_ context.Context, id string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.data[id]
+	return data, ok, nil
+}
+
+func (s *memoryCheckPointStore) Set(_ context.Context, id string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[id] = data
+	return nil
+}
+
+type approvalFixture struct {
+	llm    *scriptedChatModel
+	search *fakeTool
+	clock  *fakeTool
+	model  *aihelper.OpenAIModel
+}
+
+// 模型会调用 call 指定的工具，可用工具为 current time 与 google_search
+func newApprovalFixture(call string, args string) *approvalFixture {
+	f := &approvalFixture{
+		llm:    &scriptedChatModel{call: call, args: args},
+		search: &fakeTool{name: aihelper.ToolGoogleSearch, result: "成都今天晴"},
+		clock:  &fakeTool{name: aihelper.ToolCurrentTime, result: "12:00"},
+	}
+	source := func(context.Context) ([]tool.BaseTool, error) {
+		return []tool.BaseTool{f.clock, f.search}, nil
+	}
+	f.model = aihelper.NewOpenAIModelWithChatModel(f.llm, false, source, &memoryCheckPointStore{data: map[string][]byte{}})
+	return f
+}
+
+func interruptIDs(pending *aihelper.ToolApprovalPendingError) []string {
+	ids := make([]string, 0, len(pending.ToolCalls))
+	for _, call := range pending.ToolCalls {
+		ids = append(ids, call.InterruptID)
+	}
+	return ids
+}
+
+func TestApprovalInterruptAndResume(t *testing.T) {
+	ctx := context.Background()
+	f := newApprovalFixture(aihelper.ToolGoogleSearch, `{"query":"成都天气"}`)
+	opts := []aihelper.ToolOption{aihelper.WithConfirmTools(aihelper.ToolGoogleSearch)}
+
+	_, err := f.model.GenerateResponse(ctx, []*schema.Message{schema.UserMessage("成都天气怎么样")}, opts...)
+	var pending *aihelper.ToolApprovalPendingError
+	if !errors.As(err, &pending) {
+		t.Fatalf("expected ToolApprovalPendingError, got %v", err)
+	}
+	if len(pending.ToolCalls) != 1 || pending.ToolCalls[0].ToolName != aihelper.ToolGoogleSearch ||
+		pending.ToolCalls[0].Arguments != `{"query":"成都天气"}` || pending.ToolCalls[0].InterruptID == "" {
+		t.Fatalf("unexpected pending tool calls: %+v", pending.ToolCalls)
+	}
+	if !slices.Equal(pending.Tools, []string{aihelper.ToolCurrentTime, aihelper.ToolGoogleSearch}) {
+		t.Fatalf("unexpected pending tools: %v", pending.Tools)
+	}
+	if calls := f.search.executed(); len(calls) != 0 {
+		t.Fatalf("tool must not run before approval, got %v", calls)
+	}
+
+	msg, err := f.model.ResumeResponse(ctx, pending.CheckPointID, interruptIDs(pending), &aihelper.ToolApprovalResult{Approved: true}, opts...)
+	if err != nil {
+		t.Fatalf("ResumeResponse failed: %v", err)
+	}
+	if msg.Content != "工具结果：成都今天晴" {
+		t.Fatalf("unexpected answer after approval: %q", msg.Content)
+	}
+	if calls := f.search.executed(); !slices.Equal(calls, []string{`{"query":"成都天气"}`}) {
+		t.Fatalf("approved tool should run once with the stored arguments, got %v", calls)
+	}
+}
+
+func TestApprovalDenied(t *testing.T) {
+	ctx := context.Background()
+	f := newApprovalFixture(aihelper.ToolGoogleSearch, `{"query":"成都天气"}`)
+	opts := []aihelper.ToolOption{aihelper.WithConfirmTools(aihelper.ToolGoogleSearch)}
+
+	_, err := f.model.GenerateResponse(ctx, []*schema.Message{schema.UserMessage("成都天气怎么样")}, opts...)
+	var pending *aihelper.ToolApprovalPendingError
+	if !errors.As(err, &pending) {
+		t.Fatalf("expected ToolApprovalPendingError, got %v", err)
+	}
+
+	msg, err := f.model.ResumeResponse(ctx, pending.CheckPointID, interruptIDs(pending), &aihelper.ToolApprovalResult{Approved: false, Reason: "不需要联网"}, opts...)
+	if err != nil {
+		t.Fatalf("ResumeResponse failed: %v", err)
+	}
+	if !strings.Contains(msg.Content, "用户拒绝") || !strings.Contains(msg.Content, "不需要联网") {
+		t.Fatalf("denied tool result should reach the model, got %q", msg.Content)
+	}
+	if calls := f.search.executed(); len(calls) != 0 {
+		t.Fatalf("denied tool must not run, got %v", calls)
+	}
+}
+
+func TestStreamResponseInterruptsForApproval(t *testing.T) {
+	ctx := context.Background()
+	f := newApprovalFixture(aihelper.ToolGoogleSearch, `{"query":"成都天气"}`)
+
+	var chunks []string
+	cb := func(msg string) { chunks = append(chunks, msg) }
+	_, err := f.model.StreamResponse(ctx, []*schema.Message{schema.UserMessage("成都天气怎么样")}, cb,
+		aihelper.WithConfirmTools(aihelper.ToolGoogleSearch))
+	var pending *aihelper.ToolApprovalPendingError
+	if !errors.As(err, &pending) || len(pending.ToolCalls) != 1 {
+		t.Fatalf("expected ToolApprovalPendingError from stream, got %v", err)
+	}
+	if calls := f.search.executed(); len(calls) != 0 {
+		t.Fatalf("tool must not run before approval, got %v", calls)
+	}
+
+	// 不需要确认的工具直接执行，回答通过 cb 流式输出
+	chunks = nil
+	content, err := f.model.StreamResponse(ctx, []*schema.Message{schema.UserMessage("成都天气怎么样")}, cb)
+	if err != nil {
+		t.Fatalf("StreamResponse failed: %v", err)
+	}
+	if content != "工具结果：成都今天晴" || strings.Join(chunks, "") != content {
+		t.Fatalf("unexpected streamed answer: content=%q chunks=%v", content, chunks)
+	}
+}
+
+func TestFilterToolsAllowList(t *testing.T) {
+	ctx := context.Background()
+	f := newApprovalFixture(aihelper.ToolGoogleSearch, `{"query":"成都天气"}`)
+
+	// 请求启用了两个工具，但角色只允许 current time，google_search 不会交给模型
+	msg, err := f.model.GenerateResponse(ctx, []*schema.Message{schema.UserMessage("成都天气怎么样")},
+		aihelper.WithTools(aihelper.ToolCurrentTime, aihelper.ToolGoogleSearch),
+		aihelper.WithAllowedTools(aihelper.ToolCurrentTime),
+		aihelper.WithConfirmTools(aihelper.ToolGoogleSearch))
+	if err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+	if bound := f.llm.boundTools(); !slices.Equal(bound, []string{aihelper.ToolCurrentTime}) {
+		t.Fatalf("expected only current time bound to the model, got %v", bound)
+	}
+	if msg.Content != "直接回答" || len(f.search.executed()) != 0 {
+		t.Fatalf("filtered tool must not be called, got %q", msg.Content)
+	}
+}
+
+func TestFilterToolsConfirmWrapsOnlyListedTools(t *testing.T) {
+	ctx := context.Background()
+	f := newApprovalFixture(aihelper.ToolCurrentTime, `{}`)
+
+	// current time 不在确认列表中，直接执行而不会中断
+	msg, err := f.model.GenerateResponse(ctx, []*schema.Message{schema.UserMessage("现在几点")},
+		aihelper.WithConfirmTools(aihelper.ToolGoogleSearch))
+	if err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+	if msg.Content != "工具结果：12:00" || len(f.clock.executed()) != 1 {
+		t.Fatalf("unconfirmed tool should run directly, got %q calls=%v", msg.Content, f.clock.executed())
+	}
+}
@@ -0,0 +1,29 @@
+package aihelper_test
+
+import (
+	"GopherAI/common/aihelper"
+	"GopherAI/model"
+	"testing"
+)
+
+func TestCitationCollectorResolve(t *testing.T) {
+	collector := aihelper.NewCitationCollector(
+		model.MessageCitation{Index: 1, Type: model.CitationTypeRAG, Source: "chengdu.md"},
+		model.MessageCitation{Index: 2, Type: model.CitationTypeWeb, URL: "https://example.com"},
+		model.MessageCitation{Index: 3, Type: model.CitationTypeWeb, URL: "https://example.org"},
+	)
+
+	citations := collector.Resolve("成都火锅很有名[1]，航班每天都有 [3]。")
+	if len(citations) != 3 {
+		t.Fatalf("expected all retrieved citations, got %d", len(citations))
+	}
+	if !citations[0].Cited || citations[1].Cited || !citations[2].Cited {
+		t.Fatalf("unexpected cited flags: %+v", citations)
+	}
+}
+
+func TestCitationCollectorEmpty(t *testing.T) {
+	if got := aihelper.NewCitationCollector().Resolve("没有引用 [1]"); got != nil {
+		t.Fatalf("expected no citations, got %+v", got)
+	}
+}
@@ -0,0 +1,66 @@
+package aihelper_test
+
+import (
+	"GopherAI/model"
+	"GopherAI/utils"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestConvertToSchemaMessagesDropsUnansweredToolCalls(t *testing.T) {
+	msgs := []*model.Message{
+		{Role: model.MessageRoleUser, Content: "成都天气和现在时间"},
+		{Role: model.MessageRoleAssistant, ToolCalls: []model.MessageToolCall{
+			{ID: "call_a", Name: "current time", Arguments: "{}"},
+			{ID: "call_b", Name: "google_search", Arguments: `{"query":"成都天气"}`},
+		}},
+		{Role: model.MessageRoleTool, ToolCallID: "call_a", ToolName: "current time", Content: "12:00"},
+		{Role: model.MessageRoleAssistant, Content: "现在是 12:00"},
+		// 用户没有处理待确认的调用，历史中只留下了没有结果的工具调用
+		{Role: model.MessageRoleUser, Content: "再查一下天气"},
+		{Role: model.MessageRoleAssistant, ToolCalls: []model.MessageToolCall{
+			{ID: "call_c", Name: "google_search", Arguments: `{"query":"成都天气"}`},
+		}},
+		{Role: model.MessageRoleUser, Content: "算了"},
+	}
+
+	got := utils.ConvertToSchemaMessages(msgs, false)
+
+	wantRoles := []schema.RoleType{schema.User, schema.Assistant, schema.Tool, schema.Assistant, schema.User, schema.User}
+	if len(got) != len(wantRoles) {
+		t.Fatalf("expected %d messages, got %d: %+v", len(wantRoles), len(got), got)
+	}
+	for i, role := range wantRoles {
+		if got[i].Role != role {
+			t.Fatalf("message %d: expected role %s, got %s", i, role, got[i].Role)
+		}
+	}
+	calls := got[1].ToolCalls
+	if len(calls) != 1 || calls[0].ID != "call_a" || calls[0].Function.Name != "current time" {
+		t.Fatalf("only the answered tool call should remain, got %+v", calls)
+	}
+	if got[2].ToolCallID != "call_a" || got[2].ToolName != "current time" {
+		t.Fatalf("tool result lost its call id: %+v", got[2])
+	}
+	if got[5].Content != "算了" {
+		t.Fatalf("unexpected last message: %+v", got[5])
+	}
+}
+
+func TestConvertToSchemaMessagesKeepsContentOfUnansweredCall(t *testing.T) {
+	msgs := []*model.Message{
+		{Role: model.MessageRoleUser, Content: "查一下天气"},
+		{Role: model.MessageRoleAssistant, Content: "我先搜索一下", ToolCalls: []model.MessageToolCall{
+			{ID: "call_a", Name: "google_search", Arguments: `{"query":"天气"}`},
+		}},
+	}
+
+	got := utils.ConvertToSchemaMessages(msgs, false)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(got))
+	}
+	if got[1].Content != "我先搜索一下" || len(got[1].ToolCalls) != 0 {
+		t.Fatalf("assistant text should be kept without the unanswered call, got %+v", got[1])
+	}
+}
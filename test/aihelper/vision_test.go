@@ -0,0 +1,140 @@
+package aihelper_test
+
+import (
+	"GopherAI/common/aihelper"
+	"GopherAI/common/imagestore"
+	"GopherAI/model"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// recordingModel 记录最后一次收到的消息
+type recordingModel struct {
+	vision   bool
+	received []*schema.Message
+}
+
+func (m *recordingModel) GenerateResponse(_ context.Context, messages []*schema.Message, _ ...aihelper.ToolOption) (*schema.Message, error) {
+	m.received = messages
+	return &schema.Message{Role: schema.Assistant, Content: "ok"}, nil
+}
+
+func (m *recordingModel) ResumeResponse(context.Context, string, []string, *aihelper.ToolApprovalResult, ...aihelper.ToolOption) (*schema.Message, error) {
+	return nil, nil
+}
+
+func (m *recordingModel) StreamResponse(_ context.Context, messages []*schema.Message, cb aihelper.StreamCallback, _ ...aihelper.ToolOption) (string, error) {
+	m.received = messages
+	cb("ok")
+	return "ok", nil
+}
+
+func (m *recordingModel) GenerateTravelPlanResponse(context.Context, string) (*schema.Message, error) {
+	return nil, nil
+}
+
+func (m *recordingModel) GenerateTravelPlanResponseWithProgress(context.Context, string, aihelper.TravelPlanningProgressCallback) (*schema.Message, error) {
+	return nil, nil
+}
+
+func (m *recordingModel) GetModelType() string { return "recording" }
+
+func (m *recordingModel) SupportsVision() bool { return m.vision }
+
+func newRecordingHelper(vision bool) (*aihelper.AIHelper, *recordingModel) {
+	llm := &recordingModel{vision: vision}
+	helper := aihelper.NewAIHelper(llm, "session-1", "test", time.Now())
+	helper.SetSaveFunc(func(msg *model.Message) (*model.Message, error) { return msg, nil })
+	return helper, llm
+}
+
+func TestGenerateResponseSendsImagesToVisionModel(t *testing.T) {
+	helper, llm := newRecordingHelper(true)
+	images := []model.MessageImage{{MIMEType: "image/png", Data: "aGVsbG8="}}
+	if _, err := helper.GenerateResponse("alice", context.Background(), "这是哪里？", images); err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+	// 后续问题仍会带上之前的图片
+	if _, err := helper.GenerateResponse("alice", context.Background(), "附近有什么好吃的？", nil); err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+
+	first := llm.received[0]
+	if first.Content != "" || len(first.UserInputMultiContent) != 2 {
+		t.Fatalf("expected text and image parts, got %+v", first)
+	}
+	part := first.UserInputMultiContent[1]
+	if part.Type != schema.ChatMessagePartTypeImageURL || *part.Image.Base64Data != "aGVsbG8=" || part.Image.MIMEType != "image/png" {
+		t.Fatalf("unexpected image part %+v", part)
+	}
+	if last := llm.received[len(llm.received)-1]; last.Content != "附近有什么好吃的？" || len(last.UserInputMultiContent) != 0 {
+		t.Fatalf("unexpected follow-up message %+v", last)
+	}
+	if got := helper.GetMessages()[0].Images; len(got) != 1 {
+		t.Fatalf("expected image stored with the message, got %+v", got)
+	}
+}
+
+func TestNonVisionModelGetsImageNotice(t *testing.T) {
+	helper, llm := newRecordingHelper(false)
+	if helper.SupportsVision() {
+		t.Fatalf("expected model without vision")
+	}
+	helper.RestoreMessage(&model.Message{
+		Role:    model.MessageRoleUser,
+		IsUser:  true,
+		Content: "看看这张照片",
+		Images:  []model.MessageImage{{MIMEType: "image/jpeg", Data: "aGVsbG8="}},
+	})
+	if _, err := helper.StreamResponse("alice", context.Background(), func(string) {}, "继续", nil); err != nil {
+		t.Fatalf("StreamResponse failed: %v", err)
+	}
+	first := llm.received[0]
+	if len(first.UserInputMultiContent) != 0 || first.Content != "看看这张照片\n[用户上传了 1 张图片，当前模型无法查看图片内容]" {
+		t.Fatalf("expected image replaced with a notice, got %+v", first)
+	}
+}
+
+func TestHistoryImagesAreLoadedAndCapped(t *testing.T) {
+	imagestore.SetDefault(imagestore.NewFileStore(t.TempDir()))
+	defer imagestore.SetDefault(nil)
+
+	helper, llm := newRecordingHelper(true)
+	// 三条历史消息各带 2 张图片，只有最近的 MaxHistoryImages 张会发送给模型
+	for i := 0; i < 3; i++ {
+		images := make([]model.MessageImage, 0, 2)
+		for j := 0; j < 2; j++ {
+			key, err := imagestore.Put("alice", []byte(fmt.Sprintf("image-%d-%d", i, j)), "image/png")
+			if err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			images = append(images, model.MessageImage{MIMEType: "image/png", Key: key})
+		}
+		helper.RestoreMessage(&model.Message{Role: model.MessageRoleUser, IsUser: true, Content: fmt.Sprintf("照片 %d", i), Images: images})
+		helper.RestoreMessage(&model.Message{Role: model.MessageRoleAssistant, Content: "收到"})
+	}
+	if _, err := helper.GenerateResponse("alice", context.Background(), "这些地方在哪里？", nil); err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+
+	oldest := llm.received[0]
+	if len(oldest.UserInputMultiContent) != 0 || !strings.Contains(oldest.Content, "已省略") {
+		t.Fatalf("expected the oldest images to be omitted, got %+v", oldest)
+	}
+	for _, i := range []int{2, 4} {
+		msg := llm.received[i]
+		if len(msg.UserInputMultiContent) != 3 {
+			t.Fatalf("expected text and 2 images in message %d, got %+v", i, msg)
+		}
+		want := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("image-%d-0", i/2)))
+		if got := *msg.UserInputMultiContent[1].Image.Base64Data; got != want {
+			t.Fatalf("expected image loaded from the store, got %q", got)
+		}
+	}
+}
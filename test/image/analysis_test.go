@@ -0,0 +1,93 @@
+package image_test
+
+import (
+	image_recognizer "GopherAI/common/image"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// cannedModel 返回固定的模型输出，并记录收到的消息
+type cannedModel struct {
+	content  string
+	received []*schema.Message
+}
+
+func (m *cannedModel) GenerateImageDescription(_ context.Context, messages []*schema.Message) (*schema.Message, error) {
+	m.received = messages
+	return &schema.Message{Role: schema.Assistant, Content: m.content}, nil
+}
+
+func (m *cannedModel) GetModelType() (string, error) { return "canned", nil }
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	img.Set(1, 1, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestAnalyze(t *testing.T) {
+	llm := &cannedModel{content: "```json\n" + `{
+		"caption": "雪山下的寺庙",
+		"objects": [{"name": "寺庙", "confidence": 0.92}, {"name": "雪山", "confidence": 1.4}],
+		"text": "",
+		"landmark": {"name": "布达拉宫", "city": "拉萨", "country": "中国", "confidence": 0.8},
+		"result": {"season": "冬季"}
+	}` + "\n```"}
+	ri := image_recognizer.NewLLMRecognizerWithModel(llm, image_recognizer.PrepareOptions{})
+
+	analysis, err := ri.Analyze(context.Background(), testPNG(t), image_recognizer.AnalyzeOptions{
+		Prompt: "这是什么季节？",
+		Schema: json.RawMessage(`{"type": "object", "required": ["season"], "properties": {"season": {"type": "string"}}}`),
+	})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if analysis.Caption != "雪山下的寺庙" || len(analysis.Objects) != 2 || analysis.Objects[1].Confidence != 1 {
+		t.Fatalf("unexpected analysis %+v", analysis)
+	}
+	if analysis.Landmark == nil || analysis.Landmark.City != "拉萨" {
+		t.Fatalf("unexpected landmark %+v", analysis.Landmark)
+	}
+	if string(analysis.Result) != `{"season": "冬季"}` {
+		t.Fatalf("unexpected result %s", analysis.Result)
+	}
+	user := llm.received[len(llm.received)-1]
+	if len(user.UserInputMultiContent) != 2 || user.UserInputMultiContent[0].Text != "这是什么季节？" {
+		t.Fatalf("expected prompt and image parts, got %+v", user.UserInputMultiContent)
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	ctx := context.Background()
+	ri := image_recognizer.NewLLMRecognizerWithModel(&cannedModel{content: `{"caption": "风景", "objects": []}`}, image_recognizer.PrepareOptions{})
+
+	if _, err := ri.Analyze(ctx, []byte("not an image"), image_recognizer.AnalyzeOptions{}); !errors.Is(err, image_recognizer.ErrInvalidImage) {
+		t.Fatalf("expected ErrInvalidImage, got %v", err)
+	}
+	if _, err := ri.Analyze(ctx, testPNG(t), image_recognizer.AnalyzeOptions{Schema: json.RawMessage(`[1]`)}); !errors.Is(err, image_recognizer.ErrInvalidSchema) {
+		t.Fatalf("expected ErrInvalidSchema, got %v", err)
+	}
+	// 提供 schema 时缺少 result 视为输出错误
+	schemaDoc := json.RawMessage(`{"type": "object", "required": ["season"]}`)
+	if _, err := ri.Analyze(ctx, testPNG(t), image_recognizer.AnalyzeOptions{Schema: schemaDoc}); !errors.Is(err, image_recognizer.ErrInvalidOutput) {
+		t.Fatalf("expected ErrInvalidOutput, got %v", err)
+	}
+
+	plain := image_recognizer.NewLLMRecognizerWithModel(&cannedModel{content: "这是一张风景照"}, image_recognizer.PrepareOptions{})
+	if _, err := plain.Analyze(ctx, testPNG(t), image_recognizer.AnalyzeOptions{}); !errors.Is(err, image_recognizer.ErrInvalidOutput) {
+		t.Fatalf("expected ErrInvalidOutput for non-json output, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package image_test
+
+import (
+	image_recognizer "GopherAI/common/image"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// exifSegment 构造只包含方向和一段 GPS 占位数据的 APP1 段
+func exifSegment(orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM\x00\x2a")
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+	tiff.WriteString("GPS-PLACEHOLDER")
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// testJPEG 左半红、右半蓝的 16x8 图片，带 EXIF 方向
+func testJPEG(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 8 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	raw := buf.Bytes()
+	// 紧跟 SOI 插入 APP1
+	return append(append(append([]byte{}, raw[:2]...), exifSegment(orientation)...), raw[2:]...)
+}
+
+func decode(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode prepared image: %v", err)
+	}
+	return img
+}
+
+func TestPrepareAppliesOrientation(t *testing.T) {
+	prepared, err := image_recognizer.Prepare(testJPEG(t, 6), image_recognizer.PrepareOptions{})
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if prepared.Width != 8 || prepared.Height != 16 || prepared.MIMEType != "image/jpeg" {
+		t.Fatalf("expected rotated 8x16 jpeg, got %dx%d %s", prepared.Width, prepared.Height, prepared.MIMEType)
+	}
+	// 顺时针旋转 90° 后，原来的左半（红）在上方
+	img := decode(t, prepared.Data)
+	if r, _, b, _ := img.At(4, 2).RGBA(); r < b {
+		t.Fatalf("expected red on top, got r=%d b=%d", r, b)
+	}
+	if r, _, b, _ := img.At(4, 13).RGBA(); b < r {
+		t.Fatalf("expected blue at bottom, got r=%d b=%d", r, b)
+	}
+	if bytes.Contains(prepared.Data, []byte("GPS-PLACEHOLDER")) {
+		t.Fatalf("re-encoded image should not keep exif")
+	}
+}
+
+func TestPrepareStripMetadata(t *testing.T) {
+	src := testJPEG(t, 1)
+
+	kept, err := image_recognizer.Prepare(src, image_recognizer.PrepareOptions{})
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if !bytes.Equal(kept.Data, src) {
+		t.Fatalf("image without changes should be returned as is")
+	}
+
+	stripped, err := image_recognizer.Prepare(src, image_recognizer.PrepareOptions{StripMetadata: true})
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if bytes.Contains(stripped.Data, []byte("Exif")) || bytes.Contains(stripped.Data, []byte("GPS-PLACEHOLDER")) {
+		t.Fatalf("expected exif removed")
+	}
+	if b := decode(t, stripped.Data).Bounds(); b.Dx() != 16 || b.Dy() != 8 {
+		t.Fatalf("unexpected size after stripping %v", b)
+	}
+}
+
+func TestPrepareDownscale(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 300, 100))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	prepared, err := image_recognizer.Prepare(buf.Bytes(), image_recognizer.PrepareOptions{MaxEdge: 150})
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if prepared.Width != 150 || prepared.Height != 50 || prepared.MIMEType != "image/png" {
+		t.Fatalf("expected 150x50 png, got %dx%d %s", prepared.Width, prepared.Height, prepared.MIMEType)
+	}
+
+	_, err = image_recognizer.Prepare(buf.Bytes(), image_recognizer.PrepareOptions{MaxPixels: 10_000})
+	if !errors.Is(err, image_recognizer.ErrTooManyPixels) {
+		t.Fatalf("expected ErrTooManyPixels, got %v", err)
+	}
+}
+
+func TestPrepareFormats(t *testing.T) {
+	// 1x1 无损 WebP
+	webp, _ := base64.StdEncoding.DecodeString("UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==")
+	prepared, err := image_recognizer.Prepare(webp, image_recognizer.PrepareOptions{})
+	if err != nil {
+		t.Fatalf("Prepare webp failed: %v", err)
+	}
+	if prepared.MIMEType == "image/webp" || prepared.Width != 1 {
+		t.Fatalf("expected webp converted, got %s %dx%d", prepared.MIMEType, prepared.Width, prepared.Height)
+	}
+
+	heic := []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+	if _, err := image_recognizer.Prepare(heic, image_recognizer.PrepareOptions{}); !errors.Is(err, image_recognizer.ErrUnsupportedFormat) {
+		t.Fatalf("expected ErrUnsupportedFormat for heic, got %v", err)
+	}
+	if _, err := image_recognizer.Prepare([]byte("hello"), image_recognizer.PrepareOptions{}); !errors.Is(err, image_recognizer.ErrInvalidImage) {
+		t.Fatalf("expected ErrInvalidImage, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package image_test
+
+import (
+	image_recognizer "GopherAI/common/image"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var (
+	_ image_recognizer.ImageRecognizer = (*image_recognizer.LLMRecognizer)(nil)
+	_ image_recognizer.ImageRecognizer = (*image_recognizer.StubRecognizer)(nil)
+	_ image_recognizer.ImageRecognizer = (*image_recognizer.Classifier)(nil)
+)
+
+func TestStubRecognizer(t *testing.T) {
+	ctx := context.Background()
+	ri := image_recognizer.NewStubRecognizer([]string{"猫", "狗", "鸟"}, image_recognizer.PrepareOptions{})
+
+	first, err := ri.PredictFromBuffer(ctx, testPNG(t))
+	if err != nil {
+		t.Fatalf("PredictFromBuffer failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if again, _ := ri.PredictFromBuffer(ctx, testPNG(t)); again != first {
+			t.Fatalf("stub should be deterministic, got %q then %q", first, again)
+		}
+	}
+
+	analysis, err := ri.Analyze(ctx, testJPEG(t, 1), image_recognizer.AnalyzeOptions{})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if len(analysis.Objects) != 1 || analysis.Objects[0].Name != analysis.Caption {
+		t.Fatalf("unexpected analysis %+v", analysis)
+	}
+
+	if _, err := ri.PredictFromBuffer(ctx, []byte("not an image")); !errors.Is(err, image_recognizer.ErrInvalidImage) {
+		t.Fatalf("expected ErrInvalidImage, got %v", err)
+	}
+	schemaDoc := json.RawMessage(`{"type": "object"}`)
+	if _, err := ri.Analyze(ctx, testPNG(t), image_recognizer.AnalyzeOptions{Schema: schemaDoc}); !errors.Is(err, image_recognizer.ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
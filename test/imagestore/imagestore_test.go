@@ -0,0 +1,56 @@
+package imagestore_test
+
+import (
+	"GopherAI/common/imagestore"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFileStorePutGet(t *testing.T) {
+	store := imagestore.NewFileStore(t.TempDir())
+	data := []byte("fake png")
+	key, err := store.Put("13800000000", data, "image/png")
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if !imagestore.ValidKey(key) || imagestore.Owner(key) != "13800000000" || !strings.HasSuffix(key, ".png") {
+		t.Fatalf("unexpected key %q", key)
+	}
+	if imagestore.MIMEType(key) != "image/png" {
+		t.Fatalf("unexpected mime type %q", imagestore.MIMEType(key))
+	}
+	got, err := store.Get(key)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get returned %q %v", got, err)
+	}
+
+	// 同一用户的相同图片只保存一份
+	again, err := store.Put("13800000000", data, "image/png")
+	if err != nil || again != key {
+		t.Fatalf("expected the same key, got %q %v", again, err)
+	}
+	other, err := store.Put("13900000000", data, "image/png")
+	if err != nil || other == key {
+		t.Fatalf("expected a different key for another user, got %q %v", other, err)
+	}
+}
+
+func TestFileStoreRejectsInvalidKeys(t *testing.T) {
+	store := imagestore.NewFileStore(t.TempDir())
+	for _, key := range []string{"", "../etc/passwd", "user/../../secret.png", "user/abc.png", "a/b/" + strings.Repeat("0", 64) + ".png"} {
+		if _, err := store.Get(key); !errors.Is(err, imagestore.ErrInvalidKey) {
+			t.Fatalf("expected ErrInvalidKey for %q, got %v", key, err)
+		}
+	}
+	if _, err := store.Get("user/" + strings.Repeat("0", 64) + ".png"); !errors.Is(err, imagestore.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := store.Put("user", []byte("x"), "image/heic"); err == nil {
+		t.Fatal("expected error for unsupported type")
+	}
+	if _, err := store.Put("../user", []byte("x"), "image/png"); err == nil {
+		t.Fatal("expected error for invalid owner")
+	}
+}
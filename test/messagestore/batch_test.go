@@ -0,0 +1,205 @@
+package messagestore_test
+
+import (
+	"GopherAI/common/messagestore"
+	"GopherAI/model"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingWriter struct {
+	mu      sync.Mutex
+	batches [][]*model.Message
+	block   chan struct{}
+	fail    bool
+	bad     string // 包含该 MessageKey 的批次写入失败
+}
+
+func (w *recordingWriter) write(msgs []*model.Message) error {
+	if w.block != nil {
+		<-w.block
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail {
+		return errors.New("write failed")
+	}
+	for _, msg := range msgs {
+		if w.bad != "" && msg.MessageKey == w.bad {
+			return errors.New("bad message")
+		}
+	}
+	w.batches = append(w.batches, append([]*model.Message(nil), msgs...))
+	return nil
+}
+
+func (w *recordingWriter) written() []*model.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]*model.Message, 0)
+	for _, b := range w.batches {
+		out = append(out, b...)
+	}
+	return out
+}
+
+func TestBatchStoreWritesInOrder(t *testing.T) {
+	w := &recordingWriter{}
+	store := messagestore.Instrument(messagestore.NewBatchStore(w.write, messagestore.BatchOptions{
+		BatchSize:     3,
+		FlushInterval: 10 * time.Millisecond,
+	}))
+
+	for i := 0; i < 7; i++ {
+		if err := store.Save(&model.Message{MessageKey: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Save returned error: %v", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	got := w.written()
+	if len(got) != 7 {
+		t.Fatalf("expected 7 messages written, got %d", len(got))
+	}
+	for i, msg := range got {
+		if msg.MessageKey != fmt.Sprint(i) {
+			t.Fatalf("expected message %d at position %d, got %s", i, i, msg.MessageKey)
+		}
+	}
+
+	stats := store.Stats()
+	if stats.Accepted != 7 || stats.Persisted != 7 || stats.Pending != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestBatchStoreQueueFull(t *testing.T) {
+	w := &recordingWriter{block: make(chan struct{})}
+	store := messagestore.Instrument(messagestore.NewBatchStore(w.write, messagestore.BatchOptions{
+		QueueSize:     1,
+		BatchSize:     1,
+		FlushInterval: time.Millisecond,
+	}))
+
+	// 第一条被后台取走并阻塞在写入，第二条占满队列
+	_ = store.Save(&model.Message{MessageKey: "0"})
+	time.Sleep(20 * time.Millisecond)
+	_ = store.Save(&model.Message{MessageKey: "1"})
+
+	err := store.Save(&model.Message{MessageKey: "2"})
+	if !errors.Is(err, messagestore.ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	close(w.block)
+	_ = store.Close()
+
+	if err := store.Save(&model.Message{MessageKey: "3"}); !errors.Is(err, messagestore.ErrClosed) {
+		t.Fatalf("expected ErrClosed after Close, got %v", err)
+	}
+	stats := store.Stats()
+	if stats.Rejected != 2 || stats.Persisted != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestBatchStoreWriteFailure(t *testing.T) {
+	w := &recordingWriter{fail: true}
+	store := messagestore.Instrument(messagestore.NewBatchStore(w.write, messagestore.BatchOptions{
+		FlushInterval: time.Millisecond,
+		MaxRetry:      -1,
+	}))
+
+	if err := store.Save(&model.Message{MessageKey: "0"}); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	_ = store.Close()
+
+	stats := store.Stats()
+	if stats.WriteFailed != 1 || stats.LastError == "" {
+		t.Fatalf("expected write failure to be recorded, got %+v", stats)
+	}
+}
+
+func TestBatchStoreRetriesRowByRow(t *testing.T) {
+	w := &recordingWriter{bad: "2"}
+	deadLetterFile := filepath.Join(t.TempDir(), "deadletter.jsonl")
+	store := messagestore.Instrument(messagestore.NewBatchStore(w.write, messagestore.BatchOptions{
+		BatchSize:      5,
+		FlushInterval:  time.Millisecond,
+		MaxRetry:       -1,
+		DeadLetterFile: deadLetterFile,
+	}))
+
+	for i := 0; i < 5; i++ {
+		if err := store.Save(&model.Message{MessageKey: fmt.Sprint(i), SessionID: "s1"}); err != nil {
+			t.Fatalf("Save returned error: %v", err)
+		}
+	}
+	_ = store.Close()
+
+	// 整批写入失败后，只有坏消息没有写入
+	got := w.written()
+	if len(got) != 4 {
+		t.Fatalf("expected 4 messages written row by row, got %d", len(got))
+	}
+	for _, msg := range got {
+		if msg.MessageKey == "2" {
+			t.Fatalf("bad message should not be written")
+		}
+	}
+	stats := store.Stats()
+	if stats.Persisted != 4 || stats.WriteFailed != 1 || stats.Pending != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	f, err := os.Open(deadLetterFile)
+	if err != nil {
+		t.Fatalf("open dead letter file failed: %v", err)
+	}
+	defer f.Close()
+	type deadLetter struct {
+		MessageKey string        `json:"messageKey"`
+		Message    model.Message `json:"message"`
+	}
+	var records []deadLetter
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record deadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("decode dead letter record failed: %v", err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 1 || records[0].MessageKey != "2" || records[0].Message.SessionID != "s1" {
+		t.Fatalf("expected the bad message in the dead letter file, got %+v", records)
+	}
+}
+
+func TestBatchStorePendingNeverNegative(t *testing.T) {
+	w := &recordingWriter{}
+	store := messagestore.Instrument(messagestore.NewBatchStore(w.write, messagestore.BatchOptions{
+		BatchSize:     1,
+		FlushInterval: time.Millisecond,
+	}))
+
+	for i := 0; i < 200; i++ {
+		_ = store.Save(&model.Message{MessageKey: fmt.Sprint(i)})
+		if stats := store.Stats(); stats.Pending < 0 {
+			t.Fatalf("pending became negative: %+v", stats)
+		}
+	}
+	_ = store.Close()
+	if stats := store.Stats(); stats.Pending != 0 {
+		t.Fatalf("expected no pending messages after Close, got %+v", stats)
+	}
+}
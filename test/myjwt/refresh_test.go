@@ -0,0 +1,38 @@
+package myjwt_test
+
+import (
+	"GopherAI/utils/myjwt"
+	"strings"
+	"testing"
+)
+
+func TestRefreshTokenRoundTrip(t *testing.T) {
+	token, hash, err := myjwt.GenerateRefreshToken("session-1")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken failed: %v", err)
+	}
+	if !strings.HasPrefix(token, "session-1.") {
+		t.Fatalf("token should start with session id, got %q", token)
+	}
+	if strings.Contains(hash, strings.TrimPrefix(token, "session-1.")) {
+		t.Fatalf("hash must not contain the secret")
+	}
+
+	sessionID, parsedHash, ok := myjwt.ParseRefreshToken(token)
+	if !ok || sessionID != "session-1" || parsedHash != hash {
+		t.Fatalf("unexpected parse result: %q %q %v", sessionID, parsedHash, ok)
+	}
+
+	other, otherHash, _ := myjwt.GenerateRefreshToken("session-1")
+	if other == token || otherHash == hash {
+		t.Fatalf("refresh tokens should be random")
+	}
+}
+
+func TestParseRefreshTokenRejectsMalformed(t *testing.T) {
+	for _, token := range []string{"", "session-1", "session-1.", ".secret"} {
+		if _, _, ok := myjwt.ParseRefreshToken(token); ok {
+			t.Fatalf("expected %q to be rejected", token)
+		}
+	}
+}
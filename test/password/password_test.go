@@ -0,0 +1,77 @@
+package password_test
+
+import (
+	"GopherAI/utils"
+	passwordutil "GopherAI/utils/password"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	for _, h := range []*passwordutil.Hasher{
+		{Algorithm: passwordutil.AlgorithmBcrypt, BcryptCost: 4},
+		{Algorithm: passwordutil.AlgorithmArgon2id, Argon2Time: 1, Argon2Memory: 1024, Argon2Threads: 1},
+	} {
+		t.Run(h.Algorithm, func(t *testing.T) {
+			hashed, err := h.Hash("travel2025")
+			if err != nil {
+				t.Fatalf("Hash failed: %v", err)
+			}
+			again, _ := h.Hash("travel2025")
+			if hashed == again {
+				t.Fatalf("hashes should be salted")
+			}
+			if ok, rehash := h.Verify("travel2025", hashed); !ok || rehash {
+				t.Fatalf("expected valid hash without rehash, got ok=%v rehash=%v", ok, rehash)
+			}
+			if ok, _ := h.Verify("travel2026", hashed); ok {
+				t.Fatalf("wrong password accepted")
+			}
+		})
+	}
+}
+
+func TestVerifyUpgradesOldHashes(t *testing.T) {
+	h := &passwordutil.Hasher{Algorithm: passwordutil.AlgorithmArgon2id, Argon2Time: 1, Argon2Memory: 1024, Argon2Threads: 1}
+
+	// 旧版本注册的无盐 MD5
+	ok, rehash := h.Verify("travel2025", utils.MD5("travel2025"))
+	if !ok || !rehash {
+		t.Fatalf("expected md5 accepted and marked for rehash, got ok=%v rehash=%v", ok, rehash)
+	}
+	if ok, _ := h.Verify("wrong", utils.MD5("travel2025")); ok {
+		t.Fatalf("wrong password accepted for md5 hash")
+	}
+
+	// 算法或参数变化后同样需要重新哈希
+	old := &passwordutil.Hasher{Algorithm: passwordutil.AlgorithmBcrypt, BcryptCost: 4}
+	hashed, _ := old.Hash("travel2025")
+	if ok, rehash := h.Verify("travel2025", hashed); !ok || !rehash {
+		t.Fatalf("expected bcrypt hash marked for rehash, got ok=%v rehash=%v", ok, rehash)
+	}
+	stronger := &passwordutil.Hasher{Algorithm: passwordutil.AlgorithmArgon2id, Argon2Time: 2, Argon2Memory: 1024, Argon2Threads: 1}
+	hashed, _ = h.Hash("travel2025")
+	if ok, rehash := stronger.Verify("travel2025", hashed); !ok || !rehash {
+		t.Fatalf("expected argon2 hash with old params marked for rehash, got ok=%v rehash=%v", ok, rehash)
+	}
+
+	if ok, _ := h.Verify("travel2025", "$argon2id$broken"); ok {
+		t.Fatalf("malformed hash accepted")
+	}
+}
+
+func TestCheckStrength(t *testing.T) {
+	h := &passwordutil.Hasher{}
+	for _, weak := range []string{"", "abc123", "abcdefgh", "12345678", "abcd 1234", strings.Repeat("a1", 40)} {
+		if err := h.CheckStrength(weak); !errors.Is(err, passwordutil.ErrWeakPassword) {
+			t.Fatalf("expected %q rejected, got %v", weak, err)
+		}
+	}
+	if err := h.CheckStrength("travel2025"); err != nil {
+		t.Fatalf("expected strong password accepted, got %v", err)
+	}
+	if err := (&passwordutil.Hasher{MinLength: 12}).CheckStrength("travel2025"); err == nil {
+		t.Fatalf("expected configured min length enforced")
+	}
+}
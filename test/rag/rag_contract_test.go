@@ -0,0 +1,218 @@
+package rag_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"GopherAI/common/rag"
+)
+
+// ragContract 每个 RAGDatabase 实现都需要通过的行为约定
+type ragContract struct {
+	db rag.RAGDatabase
+	kb string
+	// 写入后需要等待索引更新的实现（如 VikingDB）在该时间内轮询，为 0 时只检查一次
+	wait time.Duration
+}
+
+// TestRAGContract 在各存储后端上运行同一组用例，Redis 与 VikingDB 不可用时跳过
+func TestRAGContract(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("memory", func(t *testing.T) {
+		runRAGContract(t, ragContract{db: rag.NewMemoryRAG(&rag.FakeEmbedder{Dimension: 64}), kb: "contract-kb"})
+	})
+
+	t.Run("file", func(t *testing.T) {
+		fileRag, err := rag.OpenFileRAG(filepath.Join(t.TempDir(), "rag.json"), &rag.FakeEmbedder{Dimension: 64})
+		if err != nil {
+			t.Fatalf("OpenFileRAG failed: %v", err)
+		}
+		runRAGContract(t, ragContract{db: fileRag, kb: "contract-kb"})
+	})
+
+	t.Run("redis", func(t *testing.T) {
+		redisAddr := os.Getenv("REDIS_RAG_ADDR")
+		if redisAddr == "" {
+			redisAddr = "127.0.0.1:6381"
+		}
+		redisRag := rag.NewRedisRAG(rag.RedisConfig{Addr: redisAddr}, &rag.FakeEmbedder{Dimension: 64})
+		if err := redisRag.RedisClient.Ping(ctx).Err(); err != nil {
+			t.Skipf("redis not available at %s: %v", redisAddr, err)
+		}
+		const kb = "test-contract-kb"
+		if err := redisRag.EnsureIndex(ctx, kb, rag.IndexConfig{}); err != nil {
+			t.Skipf("rag index not available: %v", err)
+		}
+		defer redisRag.DropIndex(ctx, kb, true)
+		runRAGContract(t, ragContract{db: redisRag, kb: kb, wait: time.Second})
+	})
+
+	// 需要事先创建好集合与索引，字段见 rag.VikingDBRAG 的说明
+	t.Run("vikingdb", func(t *testing.T) {
+		conf := rag.VikingDBConfig{
+			Host:       os.Getenv("VIKINGDB_HOST"),
+			Region:     os.Getenv("VIKINGDB_REGION"),
+			AK:         os.Getenv("VIKINGDB_AK"),
+			SK:         os.Getenv("VIKINGDB_SK"),
+			Collection: os.Getenv("VIKINGDB_COLLECTION"),
+			Index:      os.Getenv("VIKINGDB_INDEX"),
+		}
+		if conf.AK == "" || conf.Collection == "" || conf.Index == "" {
+			t.Skip("VIKINGDB_AK, VIKINGDB_COLLECTION or VIKINGDB_INDEX not set")
+		}
+		vikingRag := rag.NewVikingDBRAG(conf, nil)
+		const kb = "test-contract-kb"
+		defer vikingRag.DropIndex(ctx, kb, true)
+		runRAGContract(t, ragContract{db: vikingRag, kb: kb, wait: time.Minute})
+	})
+}
+
+func runRAGContract(t *testing.T, c ragContract) {
+	ctx := context.Background()
+	db, kb := c.db, c.kb
+	scope := rag.Scope{KnowledgeBases: []string{kb}}
+
+	guide, err := db.UpsertDocument(ctx, rag.DocumentInfo{KnowledgeBase: kb, Source: "guide.md", Title: "Guide"}, []rag.Record{
+		{Content: "chengdu hotpot is spicy", Metadata: rag.Metadata{Source: "guide.md", Tags: []string{"food"}}},
+		{Content: "kuanzhai alley has old courtyards", Metadata: rag.Metadata{Source: "guide.md"}},
+		{Content: "giant panda research base", Metadata: rag.Metadata{Source: "guide.md"}},
+	})
+	if err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+	if guide.ID != rag.DocumentID("guide.md") || guide.Chunks != 3 || guide.KnowledgeBase != kb {
+		t.Fatalf("unexpected document %+v", guide)
+	}
+	tea, err := db.UpsertDocument(ctx, rag.DocumentInfo{KnowledgeBase: kb, Source: "tea.md"}, []rag.Record{
+		{Content: "teahouse culture in people's park", Metadata: rag.Metadata{Source: "tea.md", Tags: []string{"culture"}}},
+	})
+	if err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+
+	got, err := db.GetDocument(ctx, kb, guide.ID)
+	if err != nil || got.Chunks != 3 || got.Title != "Guide" || got.Source != "guide.md" {
+		t.Fatalf("GetDocument: %+v %v", got, err)
+	}
+
+	// 替换文档后多出的旧分块不再被检索到，创建时间不变
+	replaced, err := db.UpsertDocument(ctx, rag.DocumentInfo{KnowledgeBase: kb, Source: "guide.md", Title: "Guide"}, []rag.Record{
+		{Content: "chengdu hotpot is spicy", Metadata: rag.Metadata{Source: "guide.md", Tags: []string{"food"}}},
+	})
+	if err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+	if replaced.ID != guide.ID || replaced.Chunks != 1 || !replaced.CreatedAt.Equal(guide.CreatedAt) {
+		t.Fatalf("unexpected replaced document %+v", replaced)
+	}
+
+	c.eventually(t, "list documents", func() error {
+		docs, total, err := db.ListDocuments(ctx, kb, 0, 10)
+		if err != nil {
+			return err
+		}
+		if total != 2 || len(docs) != 2 {
+			return fmt.Errorf("expected 2 documents, got %d %+v", total, docs)
+		}
+		if page, _, err := db.ListDocuments(ctx, kb, 1, 10); err != nil || len(page) != 1 {
+			return fmt.Errorf("expected offset to skip a document, got %+v %v", page, err)
+		}
+		return nil
+	})
+
+	c.eventually(t, "vector search", func() error {
+		results, err := db.Search(ctx, "giant panda research base", scope, rag.SearchOptions{Mode: rag.SearchModeVector, K: 5})
+		if err != nil {
+			return err
+		}
+		if len(results) != 2 {
+			return fmt.Errorf("expected replaced chunks to be gone, got %+v", results)
+		}
+		for _, result := range results {
+			if result.ID == "" || result.Metadata.DocID == "" || result.Metadata.KnowledgeBase != kb {
+				return fmt.Errorf("result is missing its id or metadata: %+v", result)
+			}
+		}
+		return nil
+	})
+
+	c.eventually(t, "filtered search", func() error {
+		results, err := db.Search(ctx, "teahouse culture", scope, rag.SearchOptions{
+			Mode:   rag.SearchModeVector,
+			Filter: rag.Filter{Sources: []string{"guide.md"}},
+		})
+		if err != nil {
+			return err
+		}
+		if len(results) != 1 || results[0].Metadata.DocID != guide.ID {
+			return fmt.Errorf("expected only guide.md with source filter, got %+v", results)
+		}
+		return nil
+	})
+
+	// 其他知识库检索不到该知识库的数据
+	other, err := db.Search(ctx, "teahouse culture", rag.Scope{KnowledgeBases: []string{kb + "-other"}}, rag.SearchOptions{Mode: rag.SearchModeVector})
+	if err != nil || len(other) != 0 {
+		t.Fatalf("expected no results from another knowledge base, got %+v %v", other, err)
+	}
+
+	if _, err := db.GetDocument(ctx, kb, "bad:id"); !errors.Is(err, rag.ErrInvalidDocumentID) {
+		t.Fatalf("expected ErrInvalidDocumentID, got %v", err)
+	}
+	if _, err := db.UpsertDocument(ctx, rag.DocumentInfo{KnowledgeBase: kb}, []rag.Record{{Content: "x"}}); !errors.Is(err, rag.ErrInvalidDocumentID) {
+		t.Fatalf("expected ErrInvalidDocumentID without id and source, got %v", err)
+	}
+
+	if err := db.DeleteDocument(ctx, kb, tea.ID); err != nil {
+		t.Fatalf("DeleteDocument failed: %v", err)
+	}
+	if _, err := db.GetDocument(ctx, kb, tea.ID); !errors.Is(err, rag.ErrDocumentNotFound) {
+		t.Fatalf("expected ErrDocumentNotFound, got %v", err)
+	}
+	if err := db.DeleteDocument(ctx, kb, tea.ID); !errors.Is(err, rag.ErrDocumentNotFound) {
+		t.Fatalf("expected ErrDocumentNotFound deleting twice, got %v", err)
+	}
+
+	c.eventually(t, "delete by source", func() error {
+		deleted, err := db.DeleteDocumentsBySource(ctx, kb, "guide.md")
+		if err != nil {
+			return err
+		}
+		if deleted != 1 {
+			return fmt.Errorf("expected 1 deleted document, got %d", deleted)
+		}
+		return nil
+	})
+	c.eventually(t, "empty knowledge base", func() error {
+		docs, total, err := db.ListDocuments(ctx, kb, 0, 10)
+		if err != nil {
+			return err
+		}
+		if total != 0 || len(docs) != 0 {
+			return fmt.Errorf("expected no documents, got %d %+v", total, docs)
+		}
+		return nil
+	})
+}
+
+// eventually 在 wait 时间内重试 check，直到返回 nil
+func (c ragContract) eventually(t *testing.T, name string, check func() error) {
+	t.Helper()
+	deadline := time.Now().Add(c.wait)
+	for {
+		err := check()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: %v", name, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
@@ -0,0 +1,131 @@
+package rag_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"GopherAI/common/rag"
+)
+
+func TestValidDocumentID(t *testing.T) {
+	id := rag.DocumentID("docs/guide.md")
+	if id != rag.DocumentID("docs/guide.md") || !rag.ValidDocumentID(id) {
+		t.Fatalf("DocumentID should be stable and valid, got %s", id)
+	}
+	for _, bad := range []string{"", "a:b", "a*", "中文"} {
+		if rag.ValidDocumentID(bad) {
+			t.Fatalf("expected %q to be invalid", bad)
+		}
+	}
+}
+
+func TestUpsertAndDeleteDocument(t *testing.T) {
+	ctx := context.Background()
+	redisAddr := os.Getenv("REDIS_RAG_ADDR")
+	if redisAddr == "" {
+		redisAddr = "127.0.0.1:6381"
+	}
+	redisRag := rag.NewRedisRAG(rag.RedisConfig{Addr: redisAddr}, &rag.FakeEmbedder{Dimension: 32})
+	if err := redisRag.RedisClient.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available at %s: %v", redisAddr, err)
+	}
+	const kb = "test-document-kb"
+	defer redisRag.DropIndex(ctx, kb, true)
+
+	doc := rag.DocumentInfo{KnowledgeBase: kb, Source: "guide.md", Title: "Guide"}
+	first, err := redisRag.UpsertDocument(ctx, doc, []rag.Record{
+		{Content: "成都火锅"}, {Content: "宽窄巷子"}, {Content: "大熊猫基地"},
+	})
+	if err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+	if first.ID != rag.DocumentID("guide.md") || first.Chunks != 3 {
+		t.Fatalf("unexpected document %+v", first)
+	}
+
+	// 再次写入同一来源会替换原文档，多出的旧分块被删除
+	second, err := redisRag.UpsertDocument(ctx, doc, []rag.Record{{Content: "成都火锅"}})
+	if err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+	if second.ID != first.ID || second.Chunks != 1 || !second.CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("unexpected replaced document %+v", second)
+	}
+	keys, err := redisRag.RedisClient.Keys(ctx, "rag:kb:"+kb+":data:*").Result()
+	if err != nil {
+		t.Fatalf("Keys failed: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected 1 chunk after replace, got %v", keys)
+	}
+
+	docs, total, err := redisRag.ListDocuments(ctx, kb, 0, 10)
+	if err != nil || total != 1 || len(docs) != 1 || docs[0].Source != "guide.md" {
+		t.Fatalf("unexpected list: %v %d %v", docs, total, err)
+	}
+
+	deleted, err := redisRag.DeleteDocumentsBySource(ctx, kb, "guide.md")
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteDocumentsBySource: deleted=%d err=%v", deleted, err)
+	}
+	if _, err := redisRag.GetDocument(ctx, kb, first.ID); !errors.Is(err, rag.ErrDocumentNotFound) {
+		t.Fatalf("expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+// 更换向量模型后重新向量化：新索引建立完成后才切换，切换后按新维度检索，旧索引被删除
+func TestReembedSwitchesIndex(t *testing.T) {
+	ctx := context.Background()
+	redisAddr := os.Getenv("REDIS_RAG_ADDR")
+	if redisAddr == "" {
+		redisAddr = "127.0.0.1:6381"
+	}
+	oldRag := rag.NewRedisRAG(rag.RedisConfig{Addr: redisAddr}, &rag.FakeEmbedder{Dimension: 32})
+	if err := oldRag.RedisClient.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available at %s: %v", redisAddr, err)
+	}
+	const kb = "test-reembed-kb"
+	defer oldRag.DropIndex(ctx, kb, true)
+
+	if err := oldRag.EnsureIndex(ctx, kb, rag.IndexConfig{}); err != nil {
+		t.Fatalf("EnsureIndex failed: %v", err)
+	}
+	doc := rag.DocumentInfo{KnowledgeBase: kb, Source: "guide.md"}
+	if _, err := oldRag.UpsertDocument(ctx, doc, []rag.Record{{Content: "成都火锅"}, {Content: "宽窄巷子"}}); err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+
+	newRag := rag.NewRedisRAG(rag.RedisConfig{Addr: redisAddr}, &rag.FakeEmbedder{Dimension: 48})
+	count, err := newRag.ReembedAll(ctx, kb, rag.IndexConfig{})
+	if err != nil || count != 2 {
+		t.Fatalf("ReembedAll: count=%d err=%v", count, err)
+	}
+	info, err := newRag.GetIndexInfo(ctx, kb)
+	if err != nil || !info.Exists || info.Dimension != 48 {
+		t.Fatalf("unexpected index after reembed: %+v err=%v", info, err)
+	}
+	results, err := newRag.GetKNN(ctx, "成都火锅", 2, rag.Scope{KnowledgeBases: []string{kb}})
+	if err != nil || len(results) == 0 {
+		t.Fatalf("GetKNN after reembed: %d results err=%v", len(results), err)
+	}
+
+	// 切换后写入的文档使用新的向量字段
+	if _, err := newRag.UpsertDocument(ctx, rag.DocumentInfo{KnowledgeBase: kb, Source: "food.md"}, []rag.Record{{Content: "担担面"}}); err != nil {
+		t.Fatalf("UpsertDocument after reembed failed: %v", err)
+	}
+	kbs, err := newRag.ListIndexedKnowledgeBases(ctx)
+	if err != nil {
+		t.Fatalf("ListIndexedKnowledgeBases failed: %v", err)
+	}
+	found := 0
+	for _, id := range kbs {
+		if id == kb {
+			found++
+		}
+	}
+	if found != 1 {
+		t.Fatalf("knowledge base should be listed once after reembed, got %v", kbs)
+	}
+}
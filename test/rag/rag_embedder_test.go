@@ -0,0 +1,185 @@
+package rag_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"GopherAI/common/rag"
+)
+
+// countingEmbedder 记录请求次数与同时进行的最大请求数
+type countingEmbedder struct {
+	rag.FakeEmbedder
+	mu       sync.Mutex
+	texts    []string
+	calls    int32
+	inFlight int32
+	maxSeen  int32
+}
+
+func (c *countingEmbedder) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	atomic.AddInt32(&c.calls, 1)
+	current := atomic.AddInt32(&c.inFlight, 1)
+	defer atomic.AddInt32(&c.inFlight, -1)
+	for {
+		seen := atomic.LoadInt32(&c.maxSeen)
+		if current <= seen || atomic.CompareAndSwapInt32(&c.maxSeen, seen, current) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	c.mu.Lock()
+	c.texts = append(c.texts, texts...)
+	c.mu.Unlock()
+	return c.FakeEmbedder.EmbedBatch(ctx, texts)
+}
+
+func TestFakeEmbedder(t *testing.T) {
+	embedder := &rag.FakeEmbedder{Dimension: 64}
+	vectors, err := embedder.EmbedBatch(context.Background(), []string{"成都 火锅", "成都 火锅", "python tutorial"})
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if len(vectors) != 3 || len(vectors[0]) != 64 {
+		t.Fatalf("unexpected vectors: %d x %d", len(vectors), len(vectors[0]))
+	}
+	for i := range vectors[0] {
+		if vectors[0][i] != vectors[1][i] {
+			t.Fatalf("same text should produce the same vector")
+		}
+	}
+	if embedder.Name() != "fake:64" {
+		t.Fatalf("unexpected name %s", embedder.Name())
+	}
+}
+
+func TestBatchEmbedderKeepsOrderAndLimitsConcurrency(t *testing.T) {
+	inner := &countingEmbedder{}
+	embedder := &rag.BatchEmbedder{Embedder: inner, BatchSize: 2, Concurrency: 2}
+	texts := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"}
+
+	vectors, err := embedder.EmbedBatch(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if inner.calls != 5 {
+		t.Fatalf("expected 5 batches, got %d", inner.calls)
+	}
+	if inner.maxSeen > 2 {
+		t.Fatalf("expected at most 2 concurrent requests, got %d", inner.maxSeen)
+	}
+	want, _ := (&rag.FakeEmbedder{}).EmbedBatch(context.Background(), texts)
+	for i := range texts {
+		for j := range want[i] {
+			if vectors[i][j] != want[i][j] {
+				t.Fatalf("vector %d out of order", i)
+			}
+		}
+	}
+}
+
+func TestCachedEmbedderSkipsUnchangedContent(t *testing.T) {
+	inner := &countingEmbedder{}
+	embedder := &rag.CachedEmbedder{Embedder: inner, Cache: rag.NewMemoryEmbeddingCache(100)}
+	ctx := context.Background()
+
+	if _, err := embedder.EmbedBatch(ctx, []string{"火锅", "茶馆", "火锅"}); err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if len(inner.texts) != 2 {
+		t.Fatalf("expected duplicate text embedded once, got %v", inner.texts)
+	}
+	vectors, err := embedder.EmbedBatch(ctx, []string{"茶馆", "熊猫", "火锅"})
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if len(inner.texts) != 3 || inner.texts[2] != "熊猫" {
+		t.Fatalf("expected only the new text embedded, got %v", inner.texts)
+	}
+	if len(vectors) != 3 || vectors[0] == nil || vectors[2] == nil {
+		t.Fatalf("cached vectors missing: %v", vectors)
+	}
+}
+
+func TestMemoryEmbeddingCacheEvicts(t *testing.T) {
+	cache := rag.NewMemoryEmbeddingCache(2)
+	ctx := context.Background()
+	_ = cache.SetMulti(ctx, []string{"a", "b"}, [][]float32{{1}, {2}})
+	// 访问 a 后 b 成为最久未使用的
+	_, _ = cache.GetMulti(ctx, []string{"a"})
+	_ = cache.SetMulti(ctx, []string{"c"}, [][]float32{{3}})
+
+	vectors, _ := cache.GetMulti(ctx, []string{"a", "b", "c"})
+	if vectors[0] == nil || vectors[1] != nil || vectors[2] == nil {
+		t.Fatalf("unexpected cache content: %v", vectors)
+	}
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("missing api key")
+		}
+		var body struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Model != "text-embedding-3-small" || len(body.Input) != 2 {
+			t.Errorf("unexpected request %+v", body)
+		}
+		// 返回顺序与输入不同，按 index 还原
+		_, _ = w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer server.Close()
+
+	embedder := &rag.OpenAIEmbedder{BaseURL: server.URL + "/v1", APIKey: "secret"}
+	vectors, err := embedder.EmbedBatch(context.Background(), []string{"first", "second"})
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Fatalf("unexpected vectors %v", vectors)
+	}
+}
+
+func TestOllamaEmbedderFallsBackToLegacyAPI(t *testing.T) {
+	var legacyCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/embed":
+			http.NotFound(w, r)
+		case "/api/embeddings":
+			atomic.AddInt32(&legacyCalls, 1)
+			_, _ = w.Write([]byte(`{"embedding":[0.5,0.5]}`))
+		}
+	}))
+	defer server.Close()
+
+	embedder := &rag.OllamaEmbedder{BaseURL: server.URL}
+	vectors, err := embedder.EmbedBatch(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("EmbedBatch failed: %v", err)
+	}
+	if len(vectors) != 2 || legacyCalls != 2 {
+		t.Fatalf("expected 2 legacy calls, got %d", legacyCalls)
+	}
+	if embedder.Name() != "ollama:nomic-embed-text" {
+		t.Fatalf("unexpected name %s", embedder.Name())
+	}
+}
+
+func TestNewEmbedderRejectsUnknownProvider(t *testing.T) {
+	if _, err := rag.NewEmbedder(rag.EmbeddingConfig{Provider: "unknown"}); err == nil {
+		t.Fatalf("expected error for unknown provider")
+	}
+}
@@ -0,0 +1,108 @@
+package rag_test
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"GopherAI/common/rag"
+	"GopherAI/common/rag/eval"
+)
+
+// staticRAG 按查询返回固定的检索结果
+type staticRAG struct {
+	recordingRAG
+	results map[string][]string // 查询 -> 按排名排列的文档ID
+}
+
+func (s *staticRAG) Search(_ context.Context, query string, _ rag.Scope, opts rag.SearchOptions) ([]*rag.RagReturnedData, error) {
+	out := make([]*rag.RagReturnedData, 0)
+	for _, id := range s.results[query] {
+		out = append(out, &rag.RagReturnedData{Metadata: rag.Metadata{DocID: id}})
+	}
+	if len(out) > opts.K {
+		out = out[:opts.K]
+	}
+	return out, nil
+}
+
+func TestScore(t *testing.T) {
+	recall, rr, ndcg, rank := eval.Score([]string{"x", "a", "y", "b"}, []string{"a", "b"}, 3)
+	if recall != 0.5 || rr != 0.5 || rank != 2 {
+		t.Fatalf("unexpected recall=%f rr=%f rank=%d", recall, rr, rank)
+	}
+	// DCG = 1/log2(3)，IDCG = 1 + 1/log2(3)
+	want := (1 / math.Log2(3)) / (1 + 1/math.Log2(3))
+	if math.Abs(ndcg-want) > 1e-9 {
+		t.Fatalf("expected ndcg %f, got %f", want, ndcg)
+	}
+
+	if recall, rr, ndcg, rank := eval.Score([]string{"x"}, []string{"a"}, 3); recall != 0 || rr != 0 || ndcg != 0 || rank != 0 {
+		t.Fatalf("expected zero scores on a miss")
+	}
+}
+
+func TestParseQueriesJSONL(t *testing.T) {
+	queries, err := eval.ParseQueries([]byte(`# comment
+{"query": "成都美食", "expected": ["a"]}
+
+{"id": "q2", "query": "熊猫", "expected": ["b", "c"]}`))
+	if err != nil {
+		t.Fatalf("ParseQueries failed: %v", err)
+	}
+	if len(queries) != 2 || queries[1].ID != "q2" || len(queries[1].Expected) != 2 {
+		t.Fatalf("unexpected queries %+v", queries)
+	}
+	if _, err := eval.ParseQueries([]byte(`[{"query": "no expected"}]`)); err == nil {
+		t.Fatalf("expected error for query without expected documents")
+	}
+}
+
+func TestRunAndCompare(t *testing.T) {
+	queries := []eval.Query{
+		{Query: "q1", Expected: []string{"a"}},
+		{Query: "q2", Expected: []string{"b"}},
+	}
+	baseline, err := eval.Run(context.Background(), eval.Config{
+		Name: "vector",
+		DB:   &staticRAG{results: map[string][]string{"q1": {"x", "a"}, "q2": {"x", "y"}}},
+	}, queries, 2)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	candidate, err := eval.Run(context.Background(), eval.Config{
+		Name: "hybrid",
+		// 同一文档的多个分块只计一次
+		DB: &staticRAG{results: map[string][]string{"q1": {"a", "a", "x"}, "q2": {"b"}}},
+	}, queries, 2)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if baseline.Recall != 0.5 || baseline.MRR != 0.25 {
+		t.Fatalf("unexpected baseline %+v", baseline)
+	}
+	if candidate.Recall != 1 || candidate.MRR != 1 || candidate.NDCG != 1 {
+		t.Fatalf("unexpected candidate %+v", candidate)
+	}
+	if got := candidate.Results[0].Retrieved; len(got) != 2 || got[0] != "a" || got[1] != "x" {
+		t.Fatalf("expected retrieved documents deduplicated, got %v", got)
+	}
+
+	comparison := eval.Compare(baseline, candidate)
+	if len(comparison.Deltas) != 1 || comparison.Deltas[0].Improved != 2 || comparison.Deltas[0].Regressed != 0 {
+		t.Fatalf("unexpected deltas %+v", comparison.Deltas)
+	}
+	var md bytes.Buffer
+	if err := comparison.Write(&md, eval.FormatMarkdown); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	if !strings.Contains(md.String(), "| hybrid | 1.0000 (+0.5000) |") {
+		t.Fatalf("markdown missing delta:\n%s", md.String())
+	}
+	var js bytes.Buffer
+	if err := comparison.Write(&js, eval.FormatJSON); err != nil || !strings.Contains(js.String(), `"deltas"`) {
+		t.Fatalf("unexpected json output %v:\n%s", err, js.String())
+	}
+}
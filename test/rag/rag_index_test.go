@@ -0,0 +1,97 @@
+package rag_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"GopherAI/common/rag"
+)
+
+func TestEnsureIndex(t *testing.T) {
+	ctx := context.Background()
+	redisAddr := os.Getenv("REDIS_RAG_ADDR")
+	if redisAddr == "" {
+		redisAddr = "127.0.0.1:6381"
+	}
+	baseURL := os.Getenv("OLLAMA_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+
+	// 使用独立的索引与前缀，避免影响正式数据
+	redisRag := rag.InitRedisRAG(rag.RedisConfig{Addr: redisAddr}, rag.OllamaConfig{
+		BaseURL:   baseURL,
+		ModelName: "nomic-embed-text",
+	})
+	redisRag.IndexName = "idx:rag_data_test"
+	redisRag.KeyPrefix = "rag:data_test:"
+	if err := redisRag.RedisClient.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available at %s: %v", redisAddr, err)
+	}
+	dim, err := redisRag.EmbeddingDimension(ctx)
+	if err != nil {
+		t.Skipf("embedding model not available: %v", err)
+	}
+	defer redisRag.DropIndex(ctx, "", false)
+
+	if err := redisRag.EnsureIndex(ctx, "", rag.IndexConfig{Algorithm: rag.IndexAlgorithmFLAT, DistanceMetric: "L2"}); err != nil {
+		t.Fatalf("EnsureIndex failed: %v", err)
+	}
+	info, err := redisRag.GetIndexInfo(ctx, "")
+	if err != nil {
+		t.Fatalf("GetIndexInfo failed: %v", err)
+	}
+	if !info.Exists || info.Dimension != dim || info.Algorithm != rag.IndexAlgorithmFLAT || info.DistanceMetric != "L2" || info.Language != "chinese" {
+		t.Fatalf("unexpected index info: %+v (model dim %d)", info, dim)
+	}
+
+	// 配置变化时应迁移索引
+	if err := redisRag.EnsureIndex(ctx, "", rag.IndexConfig{Algorithm: rag.IndexAlgorithmHNSW, DistanceMetric: "COSINE"}); err != nil {
+		t.Fatalf("EnsureIndex migrate failed: %v", err)
+	}
+	info, err = redisRag.GetIndexInfo(ctx, "")
+	if err != nil {
+		t.Fatalf("GetIndexInfo failed: %v", err)
+	}
+	if info.Algorithm != rag.IndexAlgorithmHNSW || info.DistanceMetric != "COSINE" {
+		t.Fatalf("index was not migrated: %+v", info)
+	}
+
+	// 只修改 HNSW 参数时同样需要迁移
+	if err := redisRag.EnsureIndex(ctx, "", rag.IndexConfig{Algorithm: rag.IndexAlgorithmHNSW, DistanceMetric: "COSINE", M: 32, EFRuntime: 20}); err != nil {
+		t.Fatalf("EnsureIndex migrate params failed: %v", err)
+	}
+	info, err = redisRag.GetIndexInfo(ctx, "")
+	if err != nil {
+		t.Fatalf("GetIndexInfo failed: %v", err)
+	}
+	if !strings.Contains(info.Params, "M 32") || !strings.Contains(info.Params, "EF_RUNTIME 20") {
+		t.Fatalf("index params were not migrated: %+v", info)
+	}
+}
+
+// 多个请求共享同一个 RedisRAG，并发探测维度时结果一致，go test -race 下不应报告数据竞争
+func TestEmbeddingDimensionConcurrent(t *testing.T) {
+	redisRag := rag.NewRedisRAG(rag.RedisConfig{}, &rag.FakeEmbedder{Dimension: 48})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	dims := make([]int, 16)
+	errs := make([]error, len(dims))
+	for i := range dims {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dims[i], errs[i] = redisRag.EmbeddingDimension(ctx)
+		}(i)
+	}
+	wg.Wait()
+	for i := range dims {
+		if errs[i] != nil || dims[i] != 48 {
+			t.Fatalf("EmbeddingDimension #%d: dim=%d err=%v", i, dims[i], errs[i])
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package rag_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"GopherAI/common/rag"
+	"GopherAI/common/rag/ingest"
+)
+
+// recordingRAG 只记录写入内容，不做向量化
+type recordingRAG struct {
+	contents []string
+	metas    []rag.Metadata
+}
+
+func (r *recordingRAG) AddOneData(ctx context.Context, content string) error {
+	return r.AddData(ctx, content, rag.Metadata{})
+}
+
+func (r *recordingRAG) AddData(_ context.Context, content string, metadata rag.Metadata) error {
+	r.contents = append(r.contents, content)
+	r.metas = append(r.metas, metadata)
+	return nil
+}
+
+func (r *recordingRAG) GetEmbedding(context.Context, string) ([]float32, error) {
+	return nil, nil
+}
+
+func (r *recordingRAG) GetKNN(context.Context, string, int, rag.Scope) ([]*rag.RagReturnedData, error) {
+	return nil, nil
+}
+
+func (r *recordingRAG) Search(context.Context, string, rag.Scope, rag.SearchOptions) ([]*rag.RagReturnedData, error) {
+	return nil, nil
+}
+
+func (r *recordingRAG) UpsertDocument(ctx context.Context, doc rag.DocumentInfo, records []rag.Record) (*rag.DocumentInfo, error) {
+	if doc.ID == "" {
+		doc.ID = rag.DocumentID(doc.Source)
+	}
+	for _, record := range records {
+		_ = r.AddData(ctx, record.Content, record.Metadata)
+	}
+	doc.Chunks = len(records)
+	return &doc, nil
+}
+
+func (r *recordingRAG) GetDocument(context.Context, string, string) (*rag.DocumentInfo, error) {
+	return nil, rag.ErrDocumentNotFound
+}
+
+func (r *recordingRAG) ListDocuments(context.Context, string, int, int) ([]*rag.DocumentInfo, int64, error) {
+	return nil, 0, nil
+}
+
+func (r *recordingRAG) DeleteDocument(context.Context, string, string) error {
+	return nil
+}
+
+func (r *recordingRAG) DeleteDocumentsBySource(context.Context, string, string) (int, error) {
+	return 0, nil
+}
+
+func TestParseMarkdownTitle(t *testing.T) {
+	doc, err := ingest.Parse("guide.md", []byte("# 成都攻略\n\n## 美食\n火锅很好吃。"), "")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if doc.Format != ingest.FormatMarkdown || doc.Title != "成都攻略" {
+		t.Fatalf("unexpected document: format=%s title=%s", doc.Format, doc.Title)
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	page := `<html><head><title>Chengdu</title><style>p{}</style></head>
+<body><h1>Food</h1><p>Hotpot is <b>spicy</b>.</p><script>alert(1)</script><h2>Tea</h2><p>Try the tea houses.</p></body></html>`
+	doc, err := ingest.Parse("page.html", []byte(page), "")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if doc.Title != "Chengdu" {
+		t.Fatalf("expected title Chengdu, got %q", doc.Title)
+	}
+	if strings.Contains(doc.Content, "alert") || strings.Contains(doc.Content, "p{}") {
+		t.Fatalf("script or style leaked into content: %q", doc.Content)
+	}
+	if !strings.Contains(doc.Content, "# Food") || !strings.Contains(doc.Content, "## Tea") || !strings.Contains(doc.Content, "Hotpot is spicy.") {
+		t.Fatalf("unexpected html content: %q", doc.Content)
+	}
+}
+
+func TestParsePDF(t *testing.T) {
+	var stream bytes.Buffer
+	w := zlib.NewWriter(&stream)
+	_, _ = w.Write([]byte("BT /F1 12 Tf 72 712 Td (Hello PDF) Tj 0 -14 Td [(Wor) -10 (ld) -300 (again)] TJ ET"))
+	_ = w.Close()
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n1 0 obj\n<< /Title (Sample) >>\nendobj\n")
+	fmt.Fprintf(&pdf, "2 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", stream.Len())
+	pdf.Write(stream.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n%%EOF")
+
+	doc, err := ingest.Parse("sample.pdf", pdf.Bytes(), "")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if doc.Title != "Sample" {
+		t.Fatalf("expected title Sample, got %q", doc.Title)
+	}
+	if doc.Content != "Hello PDF\nWorld again" {
+		t.Fatalf("unexpected pdf content: %q", doc.Content)
+	}
+}
+
+func TestSplitSentenceOverlap(t *testing.T) {
+	doc := &ingest.Document{Content: "One two three. Four five six. Seven eight nine. Ten eleven twelve."}
+	chunks, err := ingest.Split(doc, ingest.ChunkOptions{Strategy: ingest.StrategySentence, Size: 32, Overlap: 16})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("expected multiple chunks, got %d", len(chunks))
+	}
+	for i, c := range chunks {
+		if utf8.RuneCountInString(c.Content) > 32 {
+			t.Fatalf("chunk %d exceeds size: %q", i, c.Content)
+		}
+		if c.Index != i {
+			t.Fatalf("chunk index mismatch: %d != %d", c.Index, i)
+		}
+	}
+	// 相邻分块之间应保留句子重叠
+	if !strings.HasPrefix(chunks[1].Content, "Four five six.") {
+		t.Fatalf("expected overlap in second chunk, got %q", chunks[1].Content)
+	}
+}
+
+func TestSplitHeadingAware(t *testing.T) {
+	doc := &ingest.Document{Content: "# 成都\n简介。\n## 美食\n火锅。\n```\n# not a heading\n```\n## 景点\n宽窄巷子。"}
+	chunks, err := ingest.Split(doc, ingest.ChunkOptions{Strategy: ingest.StrategyHeading, Size: 100, Overlap: 0})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	want := []string{"成都", "成都 > 美食", "成都 > 景点"}
+	if len(chunks) != len(want) {
+		t.Fatalf("expected %d chunks, got %d: %+v", len(want), len(chunks), chunks)
+	}
+	for i, heading := range want {
+		if chunks[i].Heading != heading {
+			t.Fatalf("chunk %d heading = %q, want %q", i, chunks[i].Heading, heading)
+		}
+		if !strings.HasPrefix(chunks[i].Content, heading+"\n") {
+			t.Fatalf("chunk %d content should start with heading: %q", i, chunks[i].Content)
+		}
+	}
+	if !strings.Contains(chunks[1].Content, "# not a heading") {
+		t.Fatalf("heading inside code fence should stay in body: %q", chunks[1].Content)
+	}
+}
+
+func TestSplitCJK(t *testing.T) {
+	text := strings.Repeat("成都是四川省的省会，美食众多，节奏很慢，适合旅行。", 4)
+	chunks, err := ingest.Split(&ingest.Document{Content: text}, ingest.ChunkOptions{Strategy: ingest.StrategyCJK, Size: 30, Overlap: 0})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(chunks) < 4 {
+		t.Fatalf("expected at least 4 chunks, got %d", len(chunks))
+	}
+	for _, c := range chunks {
+		if strings.Contains(c.Content, " ") {
+			t.Fatalf("cjk chunks should be joined without spaces: %q", c.Content)
+		}
+	}
+}
+
+// 中文标点不计入 token，全角字母数字与半角一样按词计数
+func TestSplitCJKTokenCount(t *testing.T) {
+	text := "一二三四五，六七八九十。ＡＢＣＤＥＦＧＨＩＪＫＬ。"
+	chunks, err := ingest.Split(&ingest.Document{Content: text}, ingest.ChunkOptions{Strategy: ingest.StrategyCJK, Size: 11, Overlap: 0})
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	if len(chunks) != 1 {
+		t.Fatalf("expected 11 tokens to fit in one chunk, got %d chunks: %+v", len(chunks), chunks)
+	}
+}
+
+func TestSplitRejectsInvalidOverlap(t *testing.T) {
+	_, err := ingest.Split(&ingest.Document{Content: "hello."}, ingest.ChunkOptions{Size: 10, Overlap: 10})
+	if err == nil {
+		t.Fatal("expected error when overlap >= size")
+	}
+}
+
+func TestChunkOverlapDefaults(t *testing.T) {
+	// 小分块的默认重叠长度不会超过分块大小
+	for _, size := range []int{1, 40, 50, 500, 2000} {
+		opts := ingest.ChunkOptions{Size: size, Overlap: ingest.DefaultOverlapFor(size)}
+		if err := opts.Validate(); err != nil {
+			t.Fatalf("default overlap for size %d is invalid: %v", size, err)
+		}
+	}
+	if got := ingest.DefaultOverlapFor(500); got != ingest.DefaultChunkOverlap {
+		t.Fatalf("expected default overlap %d for size 500, got %d", ingest.DefaultChunkOverlap, got)
+	}
+	if err := (ingest.ChunkOptions{Size: 30, Overlap: 0}).Validate(); err != nil {
+		t.Fatalf("zero overlap should be allowed: %v", err)
+	}
+	if err := (ingest.ChunkOptions{Size: 30, Overlap: -1}).Validate(); err == nil {
+		t.Fatal("expected error for negative overlap")
+	}
+}
+
+func TestPipelineStoresMetadata(t *testing.T) {
+	db := &recordingRAG{}
+	pipeline := ingest.NewPipeline(db, ingest.ChunkOptions{Strategy: ingest.StrategyHeading, Size: 100})
+	result, err := pipeline.IngestBytes(context.Background(), "guide.md", []byte("# 指南\n## 交通\n坐地铁。"), "", "", []string{"travel"})
+	if err != nil {
+		t.Fatalf("IngestBytes failed: %v", err)
+	}
+	if result.Stored != 1 || len(db.metas) != 1 {
+		t.Fatalf("expected 1 stored chunk, got %+v", result)
+	}
+	meta := db.metas[0]
+	if meta.Source != "guide.md" || meta.Title != "指南" || meta.Heading != "指南 > 交通" || meta.ChunkIndex != 0 || len(meta.Tags) != 1 {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+}
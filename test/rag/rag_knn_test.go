@@ -38,7 +38,7 @@ func TestGetKNN(t *testing.T) {
 		t.Skipf("rag index not available (run common/rag/redis_init.sh): %v", err)
 	}
 	// Query nearest neighbors for the target text.
-	results, err := redisRag.GetKNN(ctx, "小宝宝有点发烧，什么情况", 5)
+	results, err := redisRag.GetKNN(ctx, "小宝宝有点发烧，什么情况", 5, rag.Scope{})
 	if err != nil {
 		t.Fatalf("GetKNN failed: %v", err)
 	}
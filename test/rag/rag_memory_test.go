@@ -0,0 +1,165 @@
+package rag_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"GopherAI/common/rag"
+)
+
+func TestMemoryRAGDocuments(t *testing.T) {
+	ctx := context.Background()
+	memoryRag := rag.NewMemoryRAG(&rag.FakeEmbedder{Dimension: 64})
+	const kb = "test-memory-kb"
+
+	doc := rag.DocumentInfo{KnowledgeBase: kb, Source: "guide.md", Title: "Guide"}
+	first, err := memoryRag.UpsertDocument(ctx, doc, []rag.Record{
+		{Content: "chengdu hotpot is spicy"}, {Content: "kuanzhai alley"}, {Content: "giant panda base"},
+	})
+	if err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+	if first.ID != rag.DocumentID("guide.md") || first.Chunks != 3 || first.KnowledgeBase != kb {
+		t.Fatalf("unexpected document %+v", first)
+	}
+
+	// 替换后多出的旧分块不再被检索到
+	if _, err := memoryRag.UpsertDocument(ctx, doc, []rag.Record{{Content: "chengdu hotpot is spicy"}}); err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+	results, err := memoryRag.Search(ctx, "giant panda", rag.Scope{KnowledgeBases: []string{kb}}, rag.SearchOptions{Mode: rag.SearchModeKeyword, K: 5})
+	if err != nil || len(results) != 0 {
+		t.Fatalf("expected replaced chunks to be gone, got %v %v", results, err)
+	}
+
+	docs, total, err := memoryRag.ListDocuments(ctx, kb, 0, 10)
+	if err != nil || total != 1 || len(docs) != 1 || docs[0].Chunks != 1 {
+		t.Fatalf("unexpected list: %v %d %v", docs, total, err)
+	}
+	deleted, err := memoryRag.DeleteDocumentsBySource(ctx, kb, "guide.md")
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteDocumentsBySource: deleted=%d err=%v", deleted, err)
+	}
+	if _, err := memoryRag.GetDocument(ctx, kb, first.ID); !errors.Is(err, rag.ErrDocumentNotFound) {
+		t.Fatalf("expected ErrDocumentNotFound, got %v", err)
+	}
+}
+
+func TestMemoryRAGSearch(t *testing.T) {
+	ctx := context.Background()
+	memoryRag := rag.NewMemoryRAG(&rag.FakeEmbedder{Dimension: 128})
+	for _, doc := range []struct{ source, content, tag string }{
+		{"food.md", "sichuan hotpot and spicy noodles", "food"},
+		{"panda.md", "giant panda research base in chengdu", "nature"},
+		{"tea.md", "teahouse culture in people's park", "culture"},
+	} {
+		_, err := memoryRag.UpsertDocument(ctx, rag.DocumentInfo{Source: doc.source}, []rag.Record{
+			{Content: doc.content, Metadata: rag.Metadata{Source: doc.source, Tags: []string{doc.tag}}},
+		})
+		if err != nil {
+			t.Fatalf("UpsertDocument failed: %v", err)
+		}
+	}
+
+	for _, mode := range []string{rag.SearchModeVector, rag.SearchModeKeyword, rag.SearchModeHybrid} {
+		results, err := memoryRag.Search(ctx, "giant panda", rag.Scope{}, rag.SearchOptions{Mode: mode, K: 2})
+		if err != nil {
+			t.Fatalf("%s search failed: %v", mode, err)
+		}
+		if len(results) == 0 || results[0].Metadata.Source != "panda.md" || results[0].Metadata.DocID != rag.DocumentID("panda.md") {
+			t.Fatalf("%s: expected panda.md first, got %+v", mode, results)
+		}
+	}
+
+	results, err := memoryRag.Search(ctx, "giant panda", rag.Scope{}, rag.SearchOptions{
+		Mode:   rag.SearchModeVector,
+		Filter: rag.Filter{Tags: []string{"food"}},
+	})
+	if err != nil || len(results) != 1 || results[0].Metadata.Source != "food.md" {
+		t.Fatalf("expected only food.md with tag filter, got %+v %v", results, err)
+	}
+
+	// 同一知识库中的向量维度必须一致
+	memoryRag.Embedder = &rag.FakeEmbedder{Dimension: 32}
+	if _, err := memoryRag.UpsertDocument(ctx, rag.DocumentInfo{Source: "new.md"}, []rag.Record{{Content: "new"}}); !errors.Is(err, rag.ErrDimensionMismatch) {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+	count, err := memoryRag.ReembedAll(ctx, rag.DefaultKnowledgeBase, rag.IndexConfig{})
+	if err != nil || count != 3 {
+		t.Fatalf("ReembedAll: count=%d err=%v", count, err)
+	}
+	if _, err := memoryRag.UpsertDocument(ctx, rag.DocumentInfo{Source: "new.md"}, []rag.Record{{Content: "new"}}); err != nil {
+		t.Fatalf("UpsertDocument after reembed failed: %v", err)
+	}
+}
+
+// 中文查询没有空格，连续的汉字需要切分后才能与文档中的词匹配
+func TestMemoryRAGChineseKeywordSearch(t *testing.T) {
+	ctx := context.Background()
+	memoryRag := rag.NewMemoryRAG(&rag.FakeEmbedder{Dimension: 64})
+	for _, doc := range []struct{ source, content string }{
+		{"panda.md", "成都大熊猫繁育研究基地位于北郊"},
+		{"food.md", "四川火锅以麻辣著称"},
+		{"tea.md", "人民公园的茶馆很热闹"},
+	} {
+		if _, err := memoryRag.UpsertDocument(ctx, rag.DocumentInfo{Source: doc.source}, []rag.Record{{Content: doc.content, Metadata: rag.Metadata{Source: doc.source}}}); err != nil {
+			t.Fatalf("UpsertDocument failed: %v", err)
+		}
+	}
+
+	for _, query := range []string{"大熊猫基地", "哪里可以看熊猫？", "panda 熊猫"} {
+		results, err := memoryRag.Search(ctx, query, rag.Scope{}, rag.SearchOptions{Mode: rag.SearchModeKeyword, K: 3})
+		if err != nil {
+			t.Fatalf("keyword search %q failed: %v", query, err)
+		}
+		if len(results) == 0 || results[0].Metadata.Source != "panda.md" {
+			t.Fatalf("%q: expected panda.md first, got %+v", query, results)
+		}
+	}
+
+	results, err := memoryRag.Search(ctx, "火锅", rag.Scope{}, rag.SearchOptions{Mode: rag.SearchModeKeyword, K: 3})
+	if err != nil || len(results) != 1 || results[0].Metadata.Source != "food.md" {
+		t.Fatalf("expected only food.md for 火锅, got %+v %v", results, err)
+	}
+}
+
+func TestFileRAGPersistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "rag", "rag.json")
+	embedder := &rag.FakeEmbedder{Dimension: 64}
+	fileRag, err := rag.OpenFileRAG(path, embedder)
+	if err != nil {
+		t.Fatalf("OpenFileRAG failed: %v", err)
+	}
+	doc, err := fileRag.UpsertDocument(ctx, rag.DocumentInfo{KnowledgeBase: "kb1", Source: "guide.md"}, []rag.Record{
+		{Content: "dujiangyan irrigation system"},
+	})
+	if err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+
+	reopened, err := rag.OpenFileRAG(path, embedder)
+	if err != nil {
+		t.Fatalf("OpenFileRAG failed: %v", err)
+	}
+	if _, err := reopened.GetDocument(ctx, "kb1", doc.ID); err != nil {
+		t.Fatalf("expected document to be persisted: %v", err)
+	}
+	results, err := reopened.Search(ctx, "dujiangyan", rag.ParseScope("kb1"), rag.SearchOptions{})
+	if err != nil || len(results) != 1 || results[0].Metadata.KnowledgeBase != "kb1" {
+		t.Fatalf("unexpected results after reopen: %+v %v", results, err)
+	}
+	if kbs := reopened.KnowledgeBases(); len(kbs) != 1 || kbs[0] != "kb1" {
+		t.Fatalf("unexpected knowledge bases %v", kbs)
+	}
+
+	// 另一个实例的写入在下次读取时可见
+	if err := reopened.DropIndex(ctx, "kb1", true); err != nil {
+		t.Fatalf("DropIndex failed: %v", err)
+	}
+	if _, err := fileRag.GetDocument(ctx, "kb1", doc.ID); !errors.Is(err, rag.ErrDocumentNotFound) {
+		t.Fatalf("expected reload to see the drop, got %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package rag_test
+
+import (
+	"testing"
+
+	"GopherAI/common/rag"
+)
+
+func TestParseScope(t *testing.T) {
+	scope := rag.ParseScope(" kb-1, ,kb-2,kb-1 ")
+	if len(scope.KnowledgeBases) != 3 {
+		t.Fatalf("expected 3 raw ids, got %v", scope.KnowledgeBases)
+	}
+	if got := scope.String(); got != "kb-1,kb-2" {
+		t.Fatalf("expected deduplicated scope, got %q", got)
+	}
+}
+
+func TestScopeDefaultsToDefaultKnowledgeBase(t *testing.T) {
+	if got := rag.ParseScope("").String(); got != rag.DefaultKnowledgeBase {
+		t.Fatalf("expected default knowledge base, got %q", got)
+	}
+	if got := (rag.Scope{KnowledgeBases: []string{"", "default", "kb-1"}}).String(); got != "default,kb-1" {
+		t.Fatalf("expected empty id to map to default, got %q", got)
+	}
+}
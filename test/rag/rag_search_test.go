@@ -0,0 +1,139 @@
+package rag_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"GopherAI/common/rag"
+)
+
+func TestFuseRRF(t *testing.T) {
+	vector := []*rag.RagReturnedData{
+		{ID: "a", Content: "a", VectorDistance: 0.1},
+		{ID: "b", Content: "b", VectorDistance: 0.2},
+	}
+	keyword := []*rag.RagReturnedData{
+		{ID: "c", Content: "c", KeywordScore: 9},
+		{ID: "b", Content: "b", KeywordScore: 5},
+	}
+
+	fused := rag.FuseRRF(60, vector, keyword)
+	if len(fused) != 3 {
+		t.Fatalf("expected 3 fused results, got %d", len(fused))
+	}
+	// b 在两路中都被召回，应排在最前
+	if fused[0].ID != "b" {
+		t.Fatalf("expected b first, got %s", fused[0].ID)
+	}
+	if fused[0].VectorDistance != 0.2 || fused[0].KeywordScore != 5 {
+		t.Fatalf("expected both raw scores kept, got %+v", fused[0])
+	}
+	want := 1.0/62 + 1.0/62
+	if diff := fused[0].Score - want; diff > 1e-9 || diff < -1e-9 {
+		t.Fatalf("expected score %f, got %f", want, fused[0].Score)
+	}
+	if vector[1].Score != 0 {
+		t.Fatalf("FuseRRF should not modify the input")
+	}
+}
+
+func TestFilterByScore(t *testing.T) {
+	results := []*rag.RagReturnedData{
+		{ID: "a", Score: 0.9},
+		{ID: "b", Score: 0.6},
+		{ID: "c", Score: 0.3},
+	}
+	filtered := rag.FilterByScore(results, 0.5, 5)
+	if len(filtered) != 2 || filtered[1].ID != "b" {
+		t.Fatalf("unexpected filtered results: %+v", filtered)
+	}
+	if got := rag.FilterByScore(results, 0, 1); len(got) != 1 || got[0].ID != "a" {
+		t.Fatalf("expected only top 1, got %+v", got)
+	}
+}
+
+func TestSearchScoresNormalized(t *testing.T) {
+	ctx := context.Background()
+	memoryRag := rag.NewMemoryRAG(&rag.FakeEmbedder{Dimension: 64})
+	_, err := memoryRag.UpsertDocument(ctx, rag.DocumentInfo{Source: "panda.md"}, []rag.Record{
+		{Content: "giant panda research base"}, {Content: "panda cubs and bamboo"}, {Content: "sichuan hotpot"},
+	})
+	if err != nil {
+		t.Fatalf("UpsertDocument failed: %v", err)
+	}
+
+	// 各模式的分数都在 [0, 1]，同一个 MinScore 在不同模式下含义一致
+	for _, mode := range []string{rag.SearchModeVector, rag.SearchModeKeyword, rag.SearchModeHybrid} {
+		results, err := memoryRag.Search(ctx, "giant panda", rag.Scope{}, rag.SearchOptions{Mode: mode, K: 5})
+		if err != nil || len(results) == 0 {
+			t.Fatalf("%s search failed: %v %v", mode, results, err)
+		}
+		for _, r := range results {
+			if r.Score < 0 || r.Score > 1 {
+				t.Fatalf("%s: score %f out of [0, 1]", mode, r.Score)
+			}
+		}
+		filtered, err := memoryRag.Search(ctx, "giant panda", rag.Scope{}, rag.SearchOptions{Mode: mode, K: 5, MinScore: 0.3})
+		if err != nil || len(filtered) == 0 {
+			t.Fatalf("%s: MinScore 0.3 should keep the best match, got %v %v", mode, filtered, err)
+		}
+	}
+
+	// hybrid 中两路都排第一的结果分数为 1
+	results, err := memoryRag.Search(ctx, "giant panda research base", rag.Scope{}, rag.SearchOptions{Mode: rag.SearchModeHybrid, K: 1})
+	if err != nil || len(results) != 1 || results[0].Score < 1-1e-9 {
+		t.Fatalf("expected top hybrid score 1, got %+v %v", results, err)
+	}
+
+	if _, err := memoryRag.Search(ctx, "giant panda", rag.Scope{}, rag.SearchOptions{MinScore: 2}); err == nil {
+		t.Fatalf("expected error for MinScore out of [0, 1]")
+	}
+}
+
+func TestCrossEncoderReranker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string   `json:"query"`
+			Documents []string `json:"documents"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Documents) != 2 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// 结果按相关性排序返回，需要按 index 还原
+		_, _ = w.Write([]byte(`{"results":[{"index":1,"relevance_score":0.9},{"index":0,"relevance_score":0.1}]}`))
+	}))
+	defer server.Close()
+
+	reranker := &rag.CrossEncoderReranker{URL: server.URL, APIKey: "secret"}
+	scores, err := reranker.Rerank(context.Background(), "CA1234", []string{"无关内容", "CA1234 航班"})
+	if err != nil {
+		t.Fatalf("Rerank failed: %v", err)
+	}
+	if len(scores) != 2 || scores[0] != 0.1 || scores[1] != 0.9 {
+		t.Fatalf("unexpected scores: %v", scores)
+	}
+}
+
+func TestCrossEncoderRerankerArrayResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"index":0,"score":0.3},{"index":1,"score":0.7}]`))
+	}))
+	defer server.Close()
+
+	reranker := &rag.CrossEncoderReranker{URL: server.URL}
+	scores, err := reranker.Rerank(context.Background(), "q", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Rerank failed: %v", err)
+	}
+	if scores[0] != 0.3 || scores[1] != 0.7 {
+		t.Fatalf("unexpected scores: %v", scores)
+	}
+}
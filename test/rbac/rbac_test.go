@@ -0,0 +1,92 @@
+package rbac_test
+
+import (
+	"GopherAI/model"
+	"GopherAI/utils/myrbac"
+	"slices"
+	"testing"
+)
+
+func TestBuiltinRoles(t *testing.T) {
+	policy := myrbac.NewPolicy(nil)
+	for _, perm := range myrbac.AllPermissions {
+		if !policy.Has(model.RoleAdmin, perm) {
+			t.Fatalf("admin should have %s", perm)
+		}
+		if policy.Has(model.RoleUser, perm) {
+			t.Fatalf("user should not have %s", perm)
+		}
+	}
+	if policy.Has("unknown", myrbac.PermViewUsage) || policy.IsRole("unknown") {
+		t.Fatalf("unknown role should have no permissions")
+	}
+}
+
+func TestPolicyOverrides(t *testing.T) {
+	policy := myrbac.NewPolicy(map[string][]string{
+		"support":       {myrbac.PermViewSessions, myrbac.PermViewUsage},
+		model.RoleAdmin: {myrbac.PermManageUsers},
+	})
+	if !policy.IsRole("support") || !policy.Has("support", myrbac.PermViewUsage) {
+		t.Fatalf("support should be able to view usage")
+	}
+	if policy.Has("support", myrbac.PermManageUsers) {
+		t.Fatalf("support should not manage users")
+	}
+	if policy.Has(model.RoleAdmin, myrbac.PermManageModels) {
+		t.Fatalf("overridden admin should only have the configured permissions")
+	}
+	if got := policy.Roles(); !slices.Equal(got, []string{model.RoleAdmin, "support", model.RoleUser}) {
+		t.Fatalf("unexpected roles: %v", got)
+	}
+}
+
+func TestPermissionsExpandWildcard(t *testing.T) {
+	policy := myrbac.NewPolicy(map[string][]string{"ops": {"*"}})
+	if got := policy.Permissions("ops"); !slices.Equal(got, myrbac.AllPermissions) {
+		t.Fatalf("wildcard should expand to all permissions, got %v", got)
+	}
+	if got := policy.Permissions(model.RoleUser); len(got) != 0 {
+		t.Fatalf("user should have no permissions, got %v", got)
+	}
+}
+
+func TestCoversLimitsRoleAssignment(t *testing.T) {
+	policy := myrbac.NewPolicy(map[string][]string{
+		"user_manager": {myrbac.PermManageUsers},
+		"support":      {myrbac.PermViewSessions, myrbac.PermViewUsage},
+		"moderator":    {myrbac.PermManageUsers, myrbac.PermViewSessions, myrbac.PermViewUsage},
+	})
+	// 只有 admin:users 的角色不能把其他账号提升为 admin 或权限更多的角色
+	if policy.Covers("user_manager", model.RoleAdmin) {
+		t.Fatalf("user_manager must not grant admin")
+	}
+	if policy.Covers("user_manager", "support") {
+		t.Fatalf("user_manager must not grant permissions it lacks")
+	}
+	if !policy.Covers("user_manager", model.RoleUser) || !policy.Covers("user_manager", "user_manager") {
+		t.Fatalf("user_manager should grant roles within its own permissions")
+	}
+	if !policy.Covers("moderator", "support") {
+		t.Fatalf("moderator has every support permission")
+	}
+	if !policy.Covers(model.RoleAdmin, "moderator") {
+		t.Fatalf("admin should grant any role")
+	}
+}
+
+func TestCoversLimitsManagedUsers(t *testing.T) {
+	policy := myrbac.NewPolicy(map[string][]string{
+		"user_manager": {myrbac.PermManageUsers},
+	})
+	// 禁用、删除目标用户时按目标用户的角色检查
+	if policy.Covers("user_manager", model.RoleAdmin) {
+		t.Fatalf("user_manager must not manage admins")
+	}
+	if !policy.Covers("user_manager", model.RoleUser) {
+		t.Fatalf("user_manager should manage plain users")
+	}
+	if policy.Covers("unknown", "user_manager") {
+		t.Fatalf("unknown role must not manage roles that have permissions")
+	}
+}
@@ -2,26 +2,57 @@ package myjwt
 
 import (
 	"GopherAI/config"
+	"GopherAI/utils"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+const (
+	defaultAccessExpire  = 15 * time.Minute
+	defaultRefreshExpire = 30 * 24 * time.Hour
+)
+
 type Claims struct {
 	ID       int64  `json:"id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
+	// 签发该令牌的登录会话，退出登录后会话进入撤销列表
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(id int64, username string) (string, error) {
+// AccessExpire 访问令牌有效期
+func AccessExpire() time.Duration {
+	if minutes := config.GetConfig().AccessExpireMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultAccessExpire
+}
+
+// RefreshExpire 刷新令牌有效期
+func RefreshExpire() time.Duration {
+	if hours := config.GetConfig().RefreshExpireHours; hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return defaultRefreshExpire
+}
+
+// GenerateToken 为登录会话签发短期的访问令牌
+// 角色随令牌下发，修改角色或禁用用户时会撤销该用户的全部会话
+func GenerateToken(id int64, username, role, sessionID string) (string, error) {
+	now := time.Now()
 	claims := Claims{
-		ID:       id,
-		Username: username,
+		ID:        id,
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(config.GetConfig().ExpireDuration) * time.Hour)),
+			ID:        utils.GenerateUUID(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessExpire())),
 			Issuer:    config.GetConfig().Issuer,
 			Subject:   config.GetConfig().Subject,
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
@@ -30,14 +61,14 @@ func GenerateToken(id int64, username string) (string, error) {
 	return token.SignedString([]byte(config.GetConfig().JwtConfig.Key))
 }
 
-// ParseToken 解析Token
-func ParseToken(token string) (string, bool) {
+// ParseToken 解析Token，只接受 HS256 签名且带有会话ID的令牌
+func ParseToken(token string) (*Claims, bool) {
 	claims := new(Claims)
 	t, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
 		return []byte(config.GetConfig().JwtConfig.Key), nil
-	})
-	if !t.Valid || err != nil || claims == nil {
-		return "", false
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || t == nil || !t.Valid || claims.SessionID == "" {
+		return nil, false
 	}
-	return claims.Username, true
+	return claims, true
 }
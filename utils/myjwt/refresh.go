@@ -0,0 +1,36 @@
+package myjwt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+const refreshSecretLen = 32
+
+// GenerateRefreshToken 生成 "<会话ID>.<随机串>" 形式的刷新令牌
+// 服务端只保存随机串的哈希，返回值 hash 用于存储
+func GenerateRefreshToken(sessionID string) (token string, hash string, err error) {
+	secret := make([]byte, refreshSecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	return sessionID + "." + encoded, hashRefreshSecret(encoded), nil
+}
+
+// ParseRefreshToken 拆出刷新令牌中的会话ID与随机串的哈希
+func ParseRefreshToken(token string) (sessionID string, hash string, ok bool) {
+	sessionID, secret, found := strings.Cut(strings.TrimSpace(token), ".")
+	if !found || sessionID == "" || secret == "" {
+		return "", "", false
+	}
+	return sessionID, hashRefreshSecret(secret), true
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,99 @@
+package myrbac
+
+import (
+	"GopherAI/config"
+	"GopherAI/model"
+	"slices"
+	"sort"
+)
+
+// /admin 接口的权限
+const (
+	PermManageUsers          = "admin:users"           // 查看、禁用、删除用户以及修改角色
+	PermViewSessions         = "admin:sessions"        // 查看任意用户的会话
+	PermViewUsage            = "admin:usage"           // 查看用量统计
+	PermManageKnowledgeBases = "admin:knowledge_bases" // 管理所有知识库
+	PermManageModels         = "admin:models"          // 管理模型配置
+	PermViewAuditLogs        = "admin:audit_logs"      // 查看审计日志
+
+	// 配置中使用 "*" 表示全部权限
+	wildcard = "*"
+)
+
+// AllPermissions 全部权限
+var AllPermissions = []string{
+	PermManageUsers,
+	PermViewSessions,
+	PermViewUsage,
+	PermManageKnowledgeBases,
+	PermManageModels,
+	PermViewAuditLogs,
+}
+
+// Policy 角色到权限的映射
+type Policy struct {
+	roles map[string][]string
+}
+
+// NewPolicy 内置 admin 拥有全部权限、user 没有权限，overrides 中的角色会覆盖或新增
+func NewPolicy(overrides map[string][]string) *Policy {
+	roles := map[string][]string{
+		model.RoleUser:  {},
+		model.RoleAdmin: {wildcard},
+	}
+	for role, perms := range overrides {
+		roles[role] = perms
+	}
+	return &Policy{roles: roles}
+}
+
+// Default 使用 [roleConfig] 中的权限配置
+func Default() *Policy {
+	return NewPolicy(config.GetConfig().RoleConfig.Permissions)
+}
+
+// Has 角色是否拥有该权限，未知角色没有任何权限
+func (p *Policy) Has(role, perm string) bool {
+	perms, ok := p.roles[role]
+	if !ok {
+		return false
+	}
+	return slices.Contains(perms, wildcard) || slices.Contains(perms, perm)
+}
+
+// IsRole 是否为内置或配置过的角色
+func (p *Policy) IsRole(role string) bool {
+	_, ok := p.roles[role]
+	return ok
+}
+
+// Roles 所有角色，按名称排序
+func (p *Policy) Roles() []string {
+	roles := make([]string, 0, len(p.roles))
+	for role := range p.roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// Permissions 角色拥有的权限，"*" 会展开为全部权限
+func (p *Policy) Permissions(role string) []string {
+	perms := p.roles[role]
+	if slices.Contains(perms, wildcard) {
+		return append([]string(nil), AllPermissions...)
+	}
+	return append([]string{}, perms...)
+}
+
+// Covers actorRole 是否拥有 role 的全部权限
+// 管理用户时，只能授予或管理不超过自己权限的角色，避免借助其他账号提权
+func (p *Policy) Covers(actorRole, role string) bool {
+	actorPerms := p.Permissions(actorRole)
+	for _, perm := range p.Permissions(role) {
+		if !slices.Contains(actorPerms, perm) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,202 @@
+package password
+
+import (
+	"GopherAI/config"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+
+	defaultMinLength     = 8
+	defaultArgon2Time    = 3
+	defaultArgon2Memory  = 64 * 1024 // KiB
+	defaultArgon2Threads = 2
+	argon2SaltLen        = 16
+	argon2KeyLen         = 32
+	// bcrypt 只使用前 72 字节，更长的密码直接拒绝
+	maxLength = 72
+)
+
+var (
+	// ErrWeakPassword 密码不满足强度要求
+	ErrWeakPassword = errors.New("password is too weak")
+	// ErrMalformedHash 存储的哈希无法解析
+	ErrMalformedHash = errors.New("malformed password hash")
+)
+
+// Hasher 密码哈希参数，零值字段使用默认值
+type Hasher struct {
+	Algorithm     string // bcrypt（默认）或 argon2id
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32 // KiB
+	Argon2Threads uint8
+	MinLength     int
+}
+
+// Default 使用 [passwordConfig] 中的参数
+func Default() *Hasher {
+	conf := config.GetConfig().PasswordConfig
+	return &Hasher{
+		Algorithm:     conf.Algorithm,
+		BcryptCost:    conf.BcryptCost,
+		Argon2Time:    conf.Argon2Time,
+		Argon2Memory:  conf.Argon2Memory,
+		Argon2Threads: conf.Argon2Threads,
+		MinLength:     conf.MinLength,
+	}
+}
+
+func (h *Hasher) withDefaults() Hasher {
+	o := *h
+	o.Algorithm = strings.ToLower(strings.TrimSpace(o.Algorithm))
+	if o.Algorithm == "" {
+		o.Algorithm = AlgorithmBcrypt
+	}
+	if o.BcryptCost < bcrypt.MinCost || o.BcryptCost > bcrypt.MaxCost {
+		o.BcryptCost = bcrypt.DefaultCost
+	}
+	if o.Argon2Time == 0 {
+		o.Argon2Time = defaultArgon2Time
+	}
+	if o.Argon2Memory == 0 {
+		o.Argon2Memory = defaultArgon2Memory
+	}
+	if o.Argon2Threads == 0 {
+		o.Argon2Threads = defaultArgon2Threads
+	}
+	if o.MinLength <= 0 {
+		o.MinLength = defaultMinLength
+	}
+	return o
+}
+
+// Hash 按配置的算法生成带盐的哈希
+func (h *Hasher) Hash(plain string) (string, error) {
+	o := h.withDefaults()
+	switch o.Algorithm {
+	case AlgorithmBcrypt:
+		hashed, err := bcrypt.GenerateFromPassword([]byte(plain), o.BcryptCost)
+		return string(hashed), err
+	case AlgorithmArgon2id:
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(plain), salt, o.Argon2Time, o.Argon2Memory, o.Argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, o.Argon2Memory, o.Argon2Time, o.Argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	default:
+		return "", fmt.Errorf("unknown password algorithm %q", h.Algorithm)
+	}
+}
+
+// Verify 校验密码，支持 bcrypt、argon2id 与旧的无盐 MD5
+// needsRehash 为 true 时表示哈希是旧格式或参数与当前配置不同，应在登录成功后重新哈希
+func (h *Hasher) Verify(plain, hashed string) (ok bool, needsRehash bool) {
+	o := h.withDefaults()
+	switch {
+	case strings.HasPrefix(hashed, "$2"):
+		if bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain)) != nil {
+			return false, false
+		}
+		cost, err := bcrypt.Cost([]byte(hashed))
+		return true, o.Algorithm != AlgorithmBcrypt || err != nil || cost != o.BcryptCost
+	case strings.HasPrefix(hashed, "$argon2id$"):
+		params, salt, key, err := parseArgon2(hashed)
+		if err != nil {
+			return false, false
+		}
+		actual := argon2.IDKey([]byte(plain), salt, params.time, params.memory, params.threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return false, false
+		}
+		return true, o.Algorithm != AlgorithmArgon2id || params.time != o.Argon2Time ||
+			params.memory != o.Argon2Memory || params.threads != o.Argon2Threads
+	case isLegacyMD5(hashed):
+		sum := md5.Sum([]byte(plain))
+		expected := hex.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(hashed))) == 1, true
+	default:
+		return false, false
+	}
+}
+
+// CheckStrength 注册与修改密码时的强度要求：长度不少于 MinLength，同时包含字母和数字
+func (h *Hasher) CheckStrength(plain string) error {
+	o := h.withDefaults()
+	if len([]rune(plain)) < o.MinLength {
+		return fmt.Errorf("%w: at least %d characters", ErrWeakPassword, o.MinLength)
+	}
+	if len(plain) > maxLength {
+		return fmt.Errorf("%w: at most %d bytes", ErrWeakPassword, maxLength)
+	}
+	var letter, digit bool
+	for _, r := range plain {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			return fmt.Errorf("%w: whitespace is not allowed", ErrWeakPassword)
+		}
+	}
+	if !letter || !digit {
+		return fmt.Errorf("%w: must contain letters and digits", ErrWeakPassword)
+	}
+	return nil
+}
+
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+// parseArgon2 解析 $argon2id$v=19$m=65536,t=3,p=2$salt$key
+func parseArgon2(hashed string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrMalformedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrMalformedHash
+	}
+	return params, salt, key, nil
+}
+
+func isLegacyMD5(hashed string) bool {
+	if len(hashed) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(hashed)
+	return err == nil
+}
@@ -1,11 +1,16 @@
 package utils
 
 import (
+	"GopherAI/common/imagestore"
 	"GopherAI/model"
 	"crypto/md5"
+	"encoding/base64"
 	"encoding/hex"
+	"fmt"
+	"log"
 	"math/rand"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cloudwego/eino/schema"
@@ -38,29 +43,151 @@ func GenerateUUID() string {
 
 // 将 schema 消息转换为数据库可存储的格式
 func ConvertToModelMessage(sessionID string, userName string, msg *schema.Message) *model.Message {
+	role := string(msg.Role)
+	if role == "" {
+		role = model.MessageRoleAssistant
+	}
+	var toolCalls []model.MessageToolCall
+	for _, tc := range msg.ToolCalls {
+		toolCalls = append(toolCalls, model.MessageToolCall{
+			ID:        tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: tc.Function.Arguments,
+		})
+	}
 	return &model.Message{
-		SessionID: sessionID,
-		UserName:  userName,
-		Content:   msg.Content,
+		SessionID:  sessionID,
+		UserName:   userName,
+		Role:       role,
+		Content:    msg.Content,
+		IsUser:     role == model.MessageRoleUser,
+		ToolCalls:  toolCalls,
+		ToolCallID: msg.ToolCallID,
+		ToolName:   msg.ToolName,
 	}
 }
 
+// MaxHistoryImages 之前轮次的用户消息中最多发送给模型的图片数，从最近的消息开始计算
+// 最后一条用户消息的图片总是发送，更早的图片替换为文字说明
+const MaxHistoryImages = 4
+
 // 将数据库消息转换为 schema 消息（供 AI 使用）
-func ConvertToSchemaMessages(msgs []*model.Message) []*schema.Message {
-	schemaMsgs := make([]*schema.Message, 0, len(msgs))
+// 没有对应工具结果的工具调用会被去掉（例如用户没有处理待确认的工具调用），避免模型侧报错
+// withImages 为 false 时（模型不支持图片输入）用户消息中的图片替换为文字说明
+func ConvertToSchemaMessages(msgs []*model.Message, withImages bool) []*schema.Message {
+	answered := make(map[string]bool)
 	for _, m := range msgs {
-		role := schema.Assistant
-		if m.IsUser == true {
-			role = schema.User
+		if m.GetRole() == model.MessageRoleTool && m.ToolCallID != "" {
+			answered[m.ToolCallID] = true
 		}
-		schemaMsgs = append(schemaMsgs, &schema.Message{
-			Role:    role,
+	}
+	sendImages := imagesToSend(msgs)
+
+	schemaMsgs := make([]*schema.Message, 0, len(msgs))
+	for _, m := range msgs {
+		msg := &schema.Message{
+			Role:    schema.RoleType(m.GetRole()),
 			Content: m.Content,
-		})
+		}
+		switch msg.Role {
+		case schema.User:
+			if len(m.Images) == 0 {
+				break
+			}
+			if withImages && sendImages[m] {
+				msg.UserInputMultiContent = ConvertToSchemaImageParts(m.Content, m.Images)
+				msg.Content = ""
+			} else if withImages {
+				msg.Content = strings.TrimSpace(fmt.Sprintf("%s\n[用户之前上传了 %d 张图片，已省略]", m.Content, len(m.Images)))
+			} else {
+				msg.Content = strings.TrimSpace(fmt.Sprintf("%s\n[用户上传了 %d 张图片，当前模型无法查看图片内容]", m.Content, len(m.Images)))
+			}
+		case schema.Assistant:
+			for _, tc := range m.ToolCalls {
+				if !answered[tc.ID] {
+					continue
+				}
+				msg.ToolCalls = append(msg.ToolCalls, schema.ToolCall{
+					ID:   tc.ID,
+					Type: "function",
+					Function: schema.FunctionCall{
+						Name:      tc.Name,
+						Arguments: tc.Arguments,
+					},
+				})
+			}
+			if len(m.ToolCalls) > 0 && len(msg.ToolCalls) == 0 && msg.Content == "" {
+				continue
+			}
+		case schema.Tool:
+			msg.ToolCallID = m.ToolCallID
+			msg.ToolName = m.ToolName
+		}
+		schemaMsgs = append(schemaMsgs, msg)
 	}
 	return schemaMsgs
 }
 
+// imagesToSend 返回图片需要发送给模型的用户消息：最后一条用户消息，以及更早的消息中最近的 MaxHistoryImages 张
+func imagesToSend(msgs []*model.Message) map[*model.Message]bool {
+	send := make(map[*model.Message]bool)
+	latest := true
+	budget := MaxHistoryImages
+	for i := len(msgs) - 1; i >= 0; i-- {
+		m := msgs[i]
+		if m.GetRole() != model.MessageRoleUser {
+			continue
+		}
+		if latest {
+			latest = false
+			send[m] = true
+			continue
+		}
+		if len(m.Images) == 0 {
+			continue
+		}
+		if len(m.Images) > budget {
+			break
+		}
+		budget -= len(m.Images)
+		send[m] = true
+	}
+	return send
+}
+
+// ConvertToSchemaImageParts 把文字与图片转换为多模态输入，文字在前
+// 只有引用的图片从 imagestore 读取，读取失败的图片会被跳过
+func ConvertToSchemaImageParts(text string, images []model.MessageImage) []schema.MessageInputPart {
+	parts := make([]schema.MessageInputPart, 0, len(images)+1)
+	if text != "" {
+		parts = append(parts, schema.MessageInputPart{
+			Type: schema.ChatMessagePartTypeText,
+			Text: text,
+		})
+	}
+	for _, img := range images {
+		if img.Data == "" && img.Key != "" {
+			data, err := imagestore.Get(img.Key)
+			if err != nil {
+				log.Printf("ConvertToSchemaImageParts load image %s error: %v", img.Key, err)
+				continue
+			}
+			img.Data = base64.StdEncoding.EncodeToString(data)
+		}
+		parts = append(parts, schema.MessageInputPart{
+			Type: schema.ChatMessagePartTypeImageURL,
+			Image: &schema.MessageInputImage{
+				MessagePartCommon: schema.MessagePartCommon{
+					Base64Data: of(img.Data),
+					MIMEType:   img.MIMEType,
+				},
+				Detail: schema.ImageURLDetailAuto,
+			},
+		})
+	}
+	return parts
+}
+
 func of[T any](a T) *T {
 	return &a
 }
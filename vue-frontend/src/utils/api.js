@@ -5,9 +5,55 @@ const api = axios.create({
   timeout: 0  //不启用超时机制
 })
 
+// 保存登录、注册或刷新返回的令牌，expiresIn 为访问令牌的有效秒数
+export function saveTokens (data) {
+  localStorage.setItem('token', data.token)
+  if (data.refreshToken) {
+    localStorage.setItem('refresh_token', data.refreshToken)
+  }
+  if (data.expiresIn) {
+    localStorage.setItem('token_expires_at', String(Date.now() + data.expiresIn * 1000))
+  }
+}
+
+export function clearTokens () {
+  localStorage.removeItem('token')
+  localStorage.removeItem('refresh_token')
+  localStorage.removeItem('token_expires_at')
+}
+
+// 刷新令牌只能使用一次，同一时间只发起一个刷新请求
+let refreshing = null
+
+// 访问令牌即将过期时先用刷新令牌换取新的令牌
+export function ensureFreshToken () {
+  const refreshToken = localStorage.getItem('refresh_token')
+  const expiresAt = Number(localStorage.getItem('token_expires_at') || 0)
+  if (!refreshToken || !expiresAt || Date.now() < expiresAt - 30 * 1000) {
+    return Promise.resolve()
+  }
+  if (!refreshing) {
+    refreshing = axios.post('/api/user/token/refresh', { refreshToken })
+      .then(response => {
+        if (response.data.status_code === 1000) {
+          saveTokens(response.data)
+        } else {
+          clearTokens()
+        }
+      })
+      .finally(() => {
+        refreshing = null
+      })
+  }
+  return refreshing
+}
+
 // 请求拦截器
 api.interceptors.request.use(
-  config => {
+  async config => {
+    if (config.url !== '/user/token/refresh') {
+      await ensureFreshToken().catch(() => {})
+    }
     const token = localStorage.getItem('token')
     if (token) {
       config.headers.Authorization = `Bearer ${token}`
@@ -26,11 +72,11 @@ api.interceptors.response.use(
   },
   error => {
     if (error.response && error.response.status === 401) {
-      localStorage.removeItem('token')
+      clearTokens()
       window.location.href = '/login'
     }
     return Promise.reject(error)
   }
 )
 
-export default api
\ No newline at end of file
+export default api